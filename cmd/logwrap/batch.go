@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sgaunet/logwrap/pkg/apperrors"
+	"github.com/sgaunet/logwrap/pkg/config"
+	"github.com/sgaunet/logwrap/pkg/executor"
+)
+
+// readBatchFile reads one shell-quoted command per line from path. Blank
+// lines and lines starting with "#" are skipped.
+func readBatchFile(path string) ([][]string, error) {
+	file, err := os.Open(path) //nolint:gosec // path is provided by the user on purpose
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var commands [][]string
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := executor.SplitCommandLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		commands = append(commands, args)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrEmptyBatch, path)
+	}
+	return commands, nil
+}
+
+// runBatch runs commands in order, each wrapped like a single command and
+// preceded by a banner line written through the command's processor, so
+// that it reaches the same output and sinks as the command's lines. It stops at the first failing command unless
+// keepGoing is set; a signal always stops the batch. The exit code is the
+// one of the first failing command, or 0 when all succeeded. Each command
+// writes its own line to summary, when set (see run).
+func runBatch(cfg *config.Config, commands [][]string, keepGoing bool, summary io.Writer) int {
+	exitCode := 0
+	for i, command := range commands {
+		banner := fmt.Sprintf("==> [%d/%d] %s", i+1, len(commands), strings.Join(command, " "))
+		code := run(cfg, [][]string{command}, banner, summary)
+		if code == 0 {
+			continue
+		}
+		if exitCode == 0 {
+			exitCode = code
+		}
+		if !keepGoing || code == exitCodeSIGINT || code == exitCodeSIGTERM {
+			break
+		}
+	}
+	return exitCode
+}
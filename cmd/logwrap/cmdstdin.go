@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sgaunet/logwrap/pkg/apperrors"
+	"github.com/sgaunet/logwrap/pkg/executor"
+)
+
+// readCommandStdin reads the command for -command-stdin: one shell-quoted
+// command line, read from r up to EOF and split like a batch file line
+// (see [executor.SplitCommandLine]). No shell is involved, so the input can
+// only choose the command and its arguments. command is the command given
+// on the command line, which must be empty.
+func readCommandStdin(r io.Reader, command []string) ([]string, error) {
+	if len(command) > 0 {
+		return nil, apperrors.ErrCommandStdinWithCommand
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command from stdin: %w", err)
+	}
+	args, err := executor.SplitCommandLine(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid command on stdin: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, apperrors.ErrEmptyCommandStdin
+	}
+	return args, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// exitExplanation records how logwrap's exit code was derived from the
+// command's, for execution.explain_exit. A nil *exitExplanation records
+// nothing, so callers need not check whether explaining is enabled.
+type exitExplanation struct {
+	steps []string
+}
+
+func (e *exitExplanation) add(format string, args ...any) {
+	if e != nil {
+		e.steps = append(e.steps, fmt.Sprintf(format, args...))
+	}
+}
+
+// addClassification records how classifyExitCode turned code into result.
+func (e *exitExplanation) addClassification(code, result int, successCodes []int) {
+	listed := formatExitCodes(successCodes)
+	switch {
+	case result == 0 && code != 0:
+		e.add("code %d is listed in success_exit_codes [%s]: remapped to 0", code, listed)
+	case result == 0:
+		e.add("code 0 is a success code [%s]: kept as 0", listed)
+	case code == 0:
+		e.add("code 0 is not listed in success_exit_codes [%s]: remapped to 1 so the failure is not masked", listed)
+	case len(successCodes) > 0:
+		e.add("code %d is not listed in success_exit_codes [%s]: kept as %d", code, listed, code)
+	default:
+		e.add("code %d is a failure: kept as %d", code, code)
+	}
+}
+
+// finish prints the explanation to stderr, if explaining is enabled, and
+// returns exitCode.
+func (e *exitExplanation) finish(exitCode int) int {
+	if e != nil {
+		e.print(os.Stderr, exitCode)
+	}
+	return exitCode
+}
+
+// print writes the recorded steps and the final exit code to w.
+func (e *exitExplanation) print(w io.Writer, exitCode int) {
+	_, _ = fmt.Fprintln(w, "Exit code explanation:")
+	for _, step := range e.steps {
+		_, _ = fmt.Fprintf(w, "  %s\n", step)
+	}
+	_, _ = fmt.Fprintf(w, "  final exit code: %d\n", exitCode)
+}
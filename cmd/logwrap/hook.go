@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/sgaunet/logwrap/pkg/executor"
+	"github.com/sgaunet/logwrap/pkg/processor"
+)
+
+// hookWaitDelay is how long the on_exit command's output is still read
+// once it has exited or been killed. A process it started in the background
+// may keep its pipes open; it is not waited for any longer.
+const hookWaitDelay = time.Second
+
+// Environment variables passed to the execution.on_exit command.
+const (
+	exitCodeEnv   = "LOGWRAP_EXIT_CODE"
+	durationMSEnv = "LOGWRAP_DURATION_MS"
+)
+
+// exitHook is the command configured by execution.on_exit. An empty args
+// disables it.
+type exitHook struct {
+	args    []string
+	timeout time.Duration // kills the command after this long; 0 means no limit
+}
+
+// parseExitHook splits the execution.on_exit command line, so that a
+// malformed one fails the run before anything executes.
+func parseExitHook(line string, timeout time.Duration) (*exitHook, error) {
+	args, err := executor.SplitCommandLine(line)
+	if err != nil {
+		return nil, fmt.Errorf("invalid on_exit command: %w", err)
+	}
+	return &exitHook{args: args, timeout: timeout}, nil
+}
+
+// run executes the hook once the wrapped command has exited, with
+// logwrap's exit code and the command's duration in its environment. When
+// form is not nil, the hook's output is formatted with it and written to
+// output; otherwise it goes to logwrap's stdout and stderr unchanged. A
+// hook still running after its timeout is killed. A failing hook is
+// reported but does not change logwrap's exit code.
+func (h *exitHook) run(exitCode int, duration time.Duration, form processor.Formatter, output io.Writer) {
+	if len(h.args) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	//nolint:gosec // the hook comes from the user's own configuration
+	cmd := exec.CommandContext(ctx, h.args[0], h.args[1:]...)
+	cmd.WaitDelay = hookWaitDelay
+	cmd.Env = append(os.Environ(),
+		exitCodeEnv+"="+strconv.Itoa(exitCode),
+		durationMSEnv+"="+strconv.FormatInt(duration.Milliseconds(), 10))
+
+	var err error
+	if form == nil {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+	} else {
+		err = runFormatted(cmd, processor.New(form, output))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: on_exit command %q failed: %v\n", h.args[0], err)
+	}
+}
+
+// runFormatted runs cmd with its output processed by proc. The output is
+// copied by cmd itself rather than read from StdoutPipe, so that
+// cmd.WaitDelay bounds how long it is read after cmd has exited: Wait then
+// returns and closes the pipes proc reads from.
+func runFormatted(cmd *exec.Cmd, proc *processor.Processor) error {
+	stdout, stdoutW := io.Pipe()
+	stderr, stderrW := io.Pipe()
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start: %w", err)
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		_ = stdoutW.Close()
+		_ = stderrW.Close()
+		waitErr <- err
+	}()
+	procErr := proc.ProcessStreams(context.Background(), stdout, stderr)
+	// Unblock cmd's copies should proc have stopped reading early.
+	_ = stdout.Close()
+	_ = stderr.Close()
+	if err := <-waitErr; err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+	if procErr != nil {
+		return fmt.Errorf("failed to process output: %w", procErr)
+	}
+	return nil
+}
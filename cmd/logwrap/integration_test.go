@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -471,6 +476,28 @@ echo "regular message"`,
 	assert.Equal(t, "INFO", levelMap["regular message"]) // default for stdout
 }
 
+func TestIntegration_DropKeyword(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("shell echo test not supported on Windows")
+	}
+
+	cfg := defaultTestConfig(t)
+	cfg.Prefix.Template = "[{{.Level}}] "
+	cfg.LogLevel.Detection.Keywords[config.DropLevel] = []string{"/healthz"}
+
+	output, exitCode := runPipeline(t, cfg, []string{
+		"sh", "-c",
+		`echo "GET /healthz 200"
+echo "handled request"
+echo "GET /healthz 503 ERROR" >&2`,
+	})
+
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "[INFO] handled request\n", output)
+}
+
 func TestIntegration_JSONOutput(t *testing.T) {
 	t.Parallel()
 
@@ -539,3 +566,1316 @@ func TestIntegration_EmptyOutput(t *testing.T) {
 	assert.Empty(t, strings.TrimSpace(output))
 }
 
+
+func TestIntegration_SuccessExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell exit codes test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+execution:
+  success_exit_codes: [0, 1]
+`)
+
+	tests := []struct {
+		name         string
+		command      []string
+		expectedCode int
+	}{
+		{"exit 1 classified as success", []string{"sh", "-c", "exit 1"}, 0},
+		{"exit 2 still fails", []string{"sh", "-c", "exit 2"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			args := append([]string{"-config", configFile, "--"}, tt.command...)
+			cmd := exec.Command(testBinaryPath, args...)
+			err := cmd.Run()
+
+			if tt.expectedCode == 0 {
+				assert.NoError(t, err)
+			} else {
+				var exitErr *exec.ExitError
+				require.ErrorAs(t, err, &exitErr)
+				assert.Equal(t, tt.expectedCode, exitErr.ExitCode())
+			}
+		})
+	}
+}
+
+func TestIntegration_Pipeline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("pipeline test uses POSIX commands")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+execution:
+  pipeline_policy: any
+`)
+
+	t.Run("formats final stage output", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := exec.Command(testBinaryPath, "-config", configFile, "-pipeline", "--",
+			"printf", "hello\nworld\n", "--", "tr", "a-z", "A-Z")
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		assert.Equal(t, "[INFO] HELLO\n[INFO] WORLD\n", string(output))
+	})
+
+	t.Run("any policy reports failing stage", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := exec.Command(testBinaryPath, "-config", configFile, "-pipeline", "--",
+			"sh", "-c", "echo hi; exit 3", "--", "cat")
+		output, err := cmd.Output()
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, 3, exitErr.ExitCode())
+		assert.Equal(t, "[INFO] hi\n", string(output))
+	})
+}
+
+func TestIntegration_BrokenOutputPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("broken pipe test uses POSIX commands")
+	}
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		config       string
+		expectedCode int
+	}{
+		{"default exits cleanly", "", 0},
+		{"configured shell exit code", "output:\n  broken_pipe_exit_code: 141\n", 141},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			configFile := testutils.CreateTempConfigFile(t, tt.config)
+			cmd := exec.Command(testBinaryPath, "-config", configFile, "--",
+				"sh", "-c", "while true; do echo y; done")
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+
+			stdout, err := cmd.StdoutPipe()
+			require.NoError(t, err)
+			require.NoError(t, cmd.Start())
+
+			// Read one line, then close our end like "head -1" would.
+			line, err := bufio.NewReader(stdout).ReadString('\n')
+			require.NoError(t, err)
+			assert.Contains(t, line, "y")
+			require.NoError(t, stdout.Close())
+
+			err = cmd.Wait()
+			if tt.expectedCode == 0 {
+				assert.NoError(t, err)
+			} else {
+				var exitErr *exec.ExitError
+				require.ErrorAs(t, err, &exitErr)
+				assert.Equal(t, tt.expectedCode, exitErr.ExitCode())
+			}
+			assert.NotContains(t, stderr.String(), "error")
+		})
+	}
+}
+
+func TestIntegration_OnFormatError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	// Fails at execution time for ERROR lines only.
+	const template = `{{if eq .Level "ERROR"}}{{.Missing}}{{end}}[{{.Level}}] `
+
+	tests := []struct {
+		name           string
+		policy         string
+		expectedStdout string
+		expectedCode   int
+	}{
+		{"raw", "raw", "[INFO] ok\nboom\n", 0},
+		{"drop", "drop", "[INFO] ok\n", 0},
+		{"error", "error", "[INFO] ok\nboom\n", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			configFile := testutils.CreateTempConfigFile(t, fmt.Sprintf(`
+prefix:
+  template: '%s'
+output:
+  on_format_error: %s
+`, template, tt.policy))
+
+			// The sleep orders the lines, which are read from two pipes.
+			cmd := exec.Command(testBinaryPath, "-config", configFile, "--",
+				"sh", "-c", "echo ok; sleep 0.1; echo boom >&2")
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			output, err := cmd.Output()
+
+			assert.Equal(t, tt.expectedStdout, string(output))
+			if tt.expectedCode == 0 {
+				assert.NoError(t, err)
+				assert.Empty(t, stderr.String())
+			} else {
+				var exitErr *exec.ExitError
+				require.ErrorAs(t, err, &exitErr)
+				assert.Equal(t, tt.expectedCode, exitErr.ExitCode())
+				assert.Contains(t, stderr.String(), "Stream processing error")
+			}
+		})
+	}
+}
+
+func TestIntegration_CLIKeyword(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	run := func(extra ...string) string {
+		args := append([]string{"-template", "[{{.Level}}] "}, extra...)
+		// The trailing sleep keeps the pipes open until the line is read.
+		args = append(args, "--", "sh", "-c", "echo 'call is deprecated'; sleep 0.1")
+		output, err := exec.Command(testBinaryPath, args...).Output()
+		require.NoError(t, err)
+		return string(output)
+	}
+
+	assert.Equal(t, "[INFO] call is deprecated\n", run())
+	assert.Equal(t, "[WARN] call is deprecated\n", run("-keyword", "warn=deprecated"))
+
+	cmd := exec.Command(testBinaryPath, "-keyword", "loud=deprecated", "--", "echo", "hi")
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+	assert.Contains(t, string(output), "invalid log level 'loud'")
+}
+
+func TestIntegration_ListFormatsAndColors(t *testing.T) {
+	t.Parallel()
+
+	output, err := exec.Command(testBinaryPath, "-list-formats").Output()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(config.OutputFormats(), "\n")+"\n", string(output))
+
+	output, err = exec.Command(testBinaryPath, "-list-colors").Output()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(config.ColorNames(), "\n")+"\n", string(output))
+}
+
+func TestIntegration_NoDetect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	// The trailing sleep keeps the pipes open until the line is read.
+	cmd := exec.Command(testBinaryPath, "-no-detect", "-template", "[{{.Level}}] ", "--",
+		"sh", "-c", "echo 'ERROR: not really'; sleep 0.1")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] ERROR: not really\n", string(output))
+}
+
+func TestIntegration_HealthLineEvery(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep not available on Windows")
+	}
+	t.Parallel()
+
+	cmd := exec.Command(testBinaryPath, "-health-line-every", "100ms", "-format", "json", "--", "sleep", "0.45")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	assert.GreaterOrEqual(t, len(lines), 2, "a silent command gets repeated heartbeats")
+	for _, line := range lines {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), "heartbeats respect the output format")
+		assert.Contains(t, entry["message"], "heartbeat")
+	}
+
+	// Continuous output suppresses heartbeats.
+	cmd = exec.Command(testBinaryPath, "-health-line-every", "200ms", "-template", "[{{.Level}}] ", "--",
+		"sh", "-c", "for i in 1 2 3 4 5 6; do echo tick; sleep 0.05; done; sleep 0.1")
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), "heartbeat")
+}
+
+func TestIntegration_DedupeWindow(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	cmd := exec.Command(testBinaryPath, "-dedupe-window", "1h", "-template", "{{.Line}}", "--",
+		"sh", "-c", "for i in 1 2 3 4; do echo retrying; done; echo done; echo retrying >&2; sleep 0.1")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "retrying\ndone\nlogwrap: suppressed 4 duplicate lines in the last 1h0m0s\n", string(output))
+}
+
+func TestIntegration_LevelSummary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	cmd := exec.Command(testBinaryPath, "-level-summary", "-template", "{{.Line}}", "--", "sh", "-c",
+		"echo 'ERROR: disk full'; echo started; echo 'WARN: slow'; echo 'ERROR: retry failed'; echo oops >&2; sleep 0.1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "WARN: slow\n")
+	assert.Equal(t, "Level summary: 3 ERROR, 1 WARN, 1 INFO\n", stderr.String(),
+		"stderr lines without keywords count at default_stderr")
+}
+
+func TestIntegration_SummaryFD(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	// ExtraFiles[0] becomes file descriptor 3 in the child.
+	cmd := exec.Command(testBinaryPath, "-summary-fd", "3", "--", "sh", "-c",
+		"echo 'ERROR: disk full'; echo started; echo oops >&2; sleep 0.1; exit 2")
+	cmd.ExtraFiles = []*os.File{w}
+	err = cmd.Run()
+	_ = w.Close()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 2, exitErr.ExitCode())
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	var summary struct {
+		Command     string         `json:"command"`
+		ExitCode    int            `json:"exit_code"`
+		DurationMS  int64          `json:"duration_ms"`
+		Lines       int64          `json:"lines"`
+		Bytes       int64          `json:"bytes"`
+		Levels      map[string]int `json:"levels"`
+		LineLengths struct {
+			Min int     `json:"min"`
+			Max int     `json:"max"`
+			Avg float64 `json:"avg"`
+			P50 int     `json:"p50"`
+		} `json:"line_lengths"`
+	}
+	require.NoError(t, json.Unmarshal(data, &summary), "summary: %q", data)
+	assert.True(t, strings.HasPrefix(summary.Command, "sh -c "), summary.Command)
+	assert.Equal(t, 2, summary.ExitCode)
+	assert.GreaterOrEqual(t, summary.DurationMS, int64(100))
+	assert.Equal(t, int64(3), summary.Lines)
+	assert.Equal(t, int64(len("ERROR: disk full\nstarted\noops\n")), summary.Bytes)
+	assert.Equal(t, map[string]int{"ERROR": 2, "INFO": 1}, summary.Levels)
+	assert.Equal(t, 4, summary.LineLengths.Min)
+	assert.Equal(t, 16, summary.LineLengths.Max)
+	assert.InDelta(t, 9.0, summary.LineLengths.Avg, 0.001)
+	assert.Equal(t, 7, summary.LineLengths.P50)
+}
+
+func TestIntegration_SummaryFD_NotOpen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	cmd := exec.Command(testBinaryPath, "-summary-fd", "9", "--", "echo", "hello")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	require.Error(t, err)
+	assert.Empty(t, output, "the command must not run")
+	assert.Contains(t, stderr.String(), "summary file descriptor 9 is not open")
+}
+
+func TestIntegration_ControlChars(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("printf not available on Windows")
+	}
+	t.Parallel()
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{"keep", "a\x00b\x07c"},
+		{"escape", `a\x00b\x07c`},
+		{"strip", "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Parallel()
+
+			configFile := testutils.CreateTempConfigFile(t, "output:\n  control_chars: "+tt.mode+"\n")
+			cmd := exec.Command(testBinaryPath, "-config", configFile, "-format", "json", "--",
+				"sh", "-c", `printf 'a\000b\007c\n'; sleep 0.1`)
+			output, err := cmd.Output()
+			require.NoError(t, err)
+
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(output, &entry), "output is valid JSON: %q", output)
+			assert.Equal(t, tt.expected, entry["message"])
+		})
+	}
+}
+
+func TestIntegration_Batch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	// The trailing sleeps keep the pipes open until the line is read.
+	batchFile := filepath.Join(t.TempDir(), "commands.txt")
+	content := "sh -c 'echo first; sleep 0.1'\n" +
+		"sh -c 'exit 3'\n" +
+		"sh -c \"echo 'third command'; sleep 0.1\"\n"
+	require.NoError(t, os.WriteFile(batchFile, []byte(content), 0o600))
+
+	// Stops at the first failure.
+	cmd := exec.Command(testBinaryPath, "-template", "[{{.Level}}] ", "-batch", batchFile)
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.Contains(t, string(output), "[INFO] ==> [1/3] sh -c echo first; sleep 0.1\n")
+	assert.Contains(t, string(output), "[INFO] first\n")
+	assert.Contains(t, string(output), "==> [2/3]")
+	assert.NotContains(t, string(output), "third command")
+
+	// -keep-going runs everything and still reports the failure.
+	cmd = exec.Command(testBinaryPath, "-template", "[{{.Level}}] ", "-batch", batchFile, "-keep-going")
+	output, err = cmd.Output()
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.Contains(t, string(output), "==> [3/3]")
+	assert.Contains(t, string(output), "[INFO] third command\n")
+
+	// Banners reach the sinks like the commands' lines.
+	logFile := filepath.Join(t.TempDir(), "batch.log")
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  sinks:
+    - type: file
+      path: `+logFile+`
+`)
+	cmd = exec.Command(testBinaryPath, "-config", configFile, "-batch", batchFile, "-keep-going")
+	require.ErrorAs(t, cmd.Run(), &exitErr)
+	logged, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(logged), "==> [1/3] sh -c echo first; sleep 0.1")
+	assert.Contains(t, string(logged), "==> [3/3]")
+
+	cmd = exec.Command(testBinaryPath, "-batch", batchFile, "--", "echo", "hi")
+	assert.Error(t, cmd.Run(), "-batch cannot be combined with a command")
+}
+
+func TestIntegration_PIDSourceChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.PID}}] "
+  user:
+    enabled: false
+  pid:
+    enabled: true
+    format: decimal
+    source: child
+`)
+
+	// The shell prints its own PID; the trailing sleep keeps the pipes open
+	// until the line is read.
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo $$; sleep 0.1")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var prefixPID, childPID int
+	_, err = fmt.Sscanf(string(output), "[%d] %d", &prefixPID, &childPID)
+	require.NoError(t, err, "output: %q", output)
+	assert.Equal(t, childPID, prefixPID)
+	assert.NotEqual(t, cmd.Process.Pid, prefixPID, "the child PID is not logwrap's")
+}
+
+func TestIntegration_CommandField(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Command}}] "
+  command:
+    enabled: true
+`)
+
+	// The trailing sleep keeps the pipes open until the line is read.
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "/bin/sh", "-c", "echo hi; sleep 0.1")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[sh] hi\n", string(output))
+}
+
+func TestIntegration_FileSink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	logFile := filepath.Join(t.TempDir(), "out.json")
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+  colors:
+    enabled: true
+output:
+  format: text
+  sinks:
+    - type: file
+      path: `+logFile+`
+      format: json
+`)
+
+	// The trailing sleep keeps the pipes open until the line is read.
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo hello; sleep 0.1")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "\033[", "the terminal keeps colored text")
+	assert.Contains(t, string(output), "hello")
+
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(content, &entry), "the file sink gets JSON: %q", content)
+	assert.Equal(t, "hello", entry["message"])
+	assert.Equal(t, "INFO", entry["level"])
+}
+
+func TestIntegration_ForceColorFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		format  string
+		args    []string
+		colored bool
+	}{
+		{"default", "text", nil, false},
+		{"forced", "text", []string{"-force-color-file"}, true},
+		{"forced with a JSON sink", "json", []string{"-force-color-file"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			logFile := filepath.Join(t.TempDir(), "out.log")
+			configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+output:
+  sinks:
+    - type: file
+      path: `+logFile+`
+      format: `+tt.format+`
+`)
+			args := append([]string{"-config", configFile}, tt.args...)
+			args = append(args, "--", "sh", "-c", "echo 'ERROR: disk full'; sleep 0.1")
+			output, err := exec.Command(testBinaryPath, args...).Output()
+			require.NoError(t, err)
+			assert.NotContains(t, string(output), "\033[", "the terminal output is not colored")
+
+			content, err := os.ReadFile(logFile)
+			require.NoError(t, err)
+			assert.Equal(t, tt.colored, strings.Contains(string(content), "\033["), "file content: %q", content)
+			assert.Contains(t, string(content), "disk full")
+		})
+	}
+}
+
+func TestIntegration_CommandStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	cmd := exec.Command(testBinaryPath, "-template", "{{.Line}}", "-command-stdin")
+	cmd.Stdin = strings.NewReader(`sh -c 'printf "%s|" "$@"; echo; sleep 0.1' sh "hello world" 'a;b'` + "\n")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world|a;b|\n", string(output), "arguments keep their quoting and are not run by a shell")
+
+	cmd = exec.Command(testBinaryPath, "-command-stdin")
+	cmd.Stdin = strings.NewReader("\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	_, err = cmd.Output()
+	require.Error(t, err)
+	assert.Contains(t, stderr.String(), "stdin contains no command")
+}
+
+func TestIntegration_RawCapture(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	stdoutFile := filepath.Join(dir, "raw.stdout")
+	stderrFile := filepath.Join(dir, "raw.stderr")
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+execution:
+  raw_stdout_file: `+stdoutFile+`
+  raw_stderr_file: `+stderrFile+`
+`)
+
+	// Carriage returns, tabs and a missing final newline must survive as-is.
+	script := `printf 'one\r\n\ttwo\nthree'; printf 'oops\n' >&2; sleep 0.1`
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", script)
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "[INFO] \ttwo\n", "formatted output is still produced")
+	assert.Contains(t, string(output), "[INFO] three\n")
+	assert.Contains(t, string(output), "[ERROR] oops\n")
+
+	rawStdout, err := os.ReadFile(stdoutFile)
+	require.NoError(t, err)
+	assert.Equal(t, "one\r\n\ttwo\nthree", string(rawStdout))
+
+	rawStderr, err := os.ReadFile(stderrFile)
+	require.NoError(t, err)
+	assert.Equal(t, "oops\n", string(rawStderr))
+}
+
+func TestIntegration_StartRetries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "late.sh")
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+execution:
+  start_retries: 40
+  start_retry_delay: 50ms
+`)
+
+	// The command only appears after logwrap's first attempts have failed,
+	// like a binary on a volume that is still being mounted. The trailing
+	// sleep keeps the pipes open until the line is read.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		tmp := script + ".tmp"
+		if err := os.WriteFile(tmp, []byte("#!/bin/sh\necho mounted\nsleep 0.1\n"), 0o700); err == nil {
+			_ = os.Rename(tmp, script)
+		}
+	}()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", script)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	require.NoError(t, err, "stderr: %s", stderr.String())
+	assert.Equal(t, "[INFO] mounted\n", string(output))
+	assert.Contains(t, stderr.String(), "retrying in 50ms")
+}
+
+func TestIntegration_OptionalConfigMissing(t *testing.T) {
+	t.Parallel()
+
+	missing := filepath.Join(t.TempDir(), "overlay.yaml")
+
+	cmd := exec.Command(testBinaryPath, "-config", missing, "-config-optional", "--", "echo", "hello")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "hello")
+
+	cmd = exec.Command(testBinaryPath, "-config", missing, "--", "echo", "hello")
+	require.Error(t, cmd.Run(), "a missing config must fail without -config-optional")
+}
+
+func TestIntegration_RunMarkers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  run_markers: true
+prefix:
+  template: "[{{.Level}}] "
+`)
+
+	// The trailing sleep keeps the pipes open until the line is read.
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo working; sleep 0.1; exit 3")
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^\[INFO\] --- START sh -c echo working; sleep 0\.1; exit 3 \d{4}-\d{2}-\d{2}T\S+ ---$`, lines[0])
+	assert.Equal(t, "[INFO] working", lines[1])
+	assert.Equal(t, "[ERROR] --- END sh -c echo working; sleep 0.1; exit 3 code=3 ---", lines[2],
+		"a failed run ends at ERROR")
+}
+
+func TestIntegration_RunMarkers_ExitLevelMap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  run_markers: true
+prefix:
+  template: "[{{.Level}}] "
+execution:
+  exit_level_map:
+    0: DEBUG
+    3: WARN
+`)
+
+	endLine := func(script string) string {
+		// The trailing sleep keeps the pipes open until the line is read.
+		output, _ := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", script).Output()
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		require.Len(t, lines, 3)
+		return lines[2]
+	}
+
+	assert.True(t, strings.HasPrefix(endLine("echo ok; sleep 0.1"), "[DEBUG] --- END "))
+	assert.True(t, strings.HasPrefix(endLine("echo slow; sleep 0.1; exit 3"), "[WARN] --- END "))
+	assert.True(t, strings.HasPrefix(endLine("echo broken; sleep 0.1; exit 4"), "[ERROR] --- END "),
+		"codes without an entry keep the default nonzero level")
+}
+
+func TestIntegration_ExitCodeTemplateField(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  run_markers: true
+prefix:
+  template: "[{{.Level}}] [code={{.ExitCode}}] "
+`)
+
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo working; sleep 0.1; exit 3")
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "[INFO] [code=] --- START "), "exit code is unknown at start: %q", lines[0])
+	assert.Equal(t, "[INFO] [code=] working", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "[ERROR] [code=3] --- END "), "END marker carries the exit code: %q", lines[2])
+}
+
+func TestIntegration_DurationTemplateField(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  run_markers: true
+prefix:
+  template: "[took={{.Duration}}] "
+`)
+
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo working; sleep 0.2")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "[took=] working", lines[1])
+	match := regexp.MustCompile(`^\[took=([0-9.]+m?s)\] --- END `).FindStringSubmatch(lines[2])
+	require.NotNil(t, match, "END marker carries the duration: %q", lines[2])
+	d, err := time.ParseDuration(match[1])
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, d, 200*time.Millisecond)
+}
+
+func TestIntegration_NoteEmptyRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  note_empty_runs: true
+prefix:
+  template: "[{{.Level}}] "
+`)
+
+	output, err := exec.Command(testBinaryPath, "-config", configFile, "--", "true").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] (no output)\n", string(output))
+
+	// The trailing sleep keeps the pipes open until the line is read.
+	output, err = exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo hi; sleep 0.1").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] hi\n", string(output))
+}
+
+func TestIntegration_ContextBefore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+filter:
+  enabled: true
+  only_levels: [error]
+output:
+  context_before: 2
+`)
+
+	script := `for i in 1 2 3; do echo "step $i"; done; echo "ERROR: step 3 failed"; echo done; sleep 0.1`
+	output, err := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", script).Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] context: step 2\n[INFO] context: step 3\n[ERROR] ERROR: step 3 failed\n", string(output))
+}
+
+func TestIntegration_SinkFallback(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full not available")
+	}
+	t.Parallel()
+
+	// Every write to /dev/full fails with ENOSPC, like a full disk.
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+output:
+  sinks:
+    - type: file
+      path: /dev/full
+      name: archive
+      format: json
+      fallback_to_stderr: true
+`)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo one; echo two; sleep 0.1")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] one\n[INFO] two\n", string(output))
+
+	assert.Equal(t, 1, strings.Count(stderr.String(), "sink write failed"), "stderr: %s", stderr.String())
+	assert.Contains(t, stderr.String(), `sink "archive"`)
+	assert.Contains(t, stderr.String(), `"message":"one"`, "the sink's lines go to stderr instead")
+	assert.Contains(t, stderr.String(), `"message":"two"`)
+}
+
+func TestIntegration_PIDFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	pidPath := filepath.Join(t.TempDir(), "cmd.pid")
+	cmd := exec.Command(testBinaryPath, "-template", "{{.Line}}", "-pid-file", pidPath, "--",
+		"sh", "-c", "echo $$; sleep 0.5")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	// The shell prints its own PID, which is the child PID logwrap sees.
+	childPID, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	content, err := os.ReadFile(pidPath)
+	require.NoError(t, err, "the PID file exists while the command runs")
+	assert.Equal(t, childPID, string(content))
+
+	_, _ = io.Copy(io.Discard, stdout)
+	require.NoError(t, cmd.Wait())
+	assert.NoFileExists(t, pidPath, "the PID file is removed once the command exits")
+}
+
+func TestIntegration_PIDFile_BadPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	cmd := exec.Command(testBinaryPath, "-pid-file", filepath.Join(dir, "missing", "cmd.pid"), "--",
+		"touch", marker)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+	assert.Contains(t, stderr.String(), "failed to create PID file")
+	assert.NoFileExists(t, marker, "the command is not started")
+}
+
+func TestIntegration_RunMarkers_Signal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  run_markers: true
+prefix:
+  template: "[{{.Level}}] "
+`)
+
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo ready; sleep 5")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	scanner := bufio.NewScanner(stdout)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if strings.HasSuffix(scanner.Text(), "ready") {
+			require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+		}
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, exitCodeSIGTERM, exitErr.ExitCode())
+
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "--- START sh -c echo ready; sleep 5 ")
+	assert.Equal(t, "[INFO] ready", lines[1])
+	assert.Equal(t, fmt.Sprintf("[WARN] --- END sh -c echo ready; sleep 5 code=%d ---", exitCodeSIGTERM), lines[2],
+		"an interrupted run ends at WARN")
+}
+
+func TestIntegration_OnExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	hookOut := filepath.Join(t.TempDir(), "hook.out")
+	hook := fmt.Sprintf(`sh -c 'echo "$LOGWRAP_EXIT_CODE $LOGWRAP_DURATION_MS" > %s'`, hookOut)
+	cmd := exec.Command(testBinaryPath, "-on-exit", hook, "--", "sh", "-c", "echo working; sleep 0.1; exit 3")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+
+	content, err := os.ReadFile(hookOut)
+	require.NoError(t, err, "the hook ran")
+	fields := strings.Fields(string(content))
+	require.Len(t, fields, 2)
+	assert.Equal(t, "3", fields[0])
+	ms, err := strconv.Atoi(fields[1])
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, ms, 100)
+}
+
+func TestIntegration_OnExit_Formatted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+execution:
+  on_exit: sh -c 'echo "exited with $LOGWRAP_EXIT_CODE"'
+  on_exit_format: true
+`)
+	output, err := exec.Command(testBinaryPath, "-config", configFile, "--", "echo", "done").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] done\n[INFO] exited with 0\n", string(output))
+}
+
+func TestIntegration_OnExit_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	// A hanging hook is killed once on_exit_timeout has passed.
+	configFile := testutils.CreateTempConfigFile(t, `
+execution:
+  on_exit: sleep 10
+  on_exit_timeout: 200ms
+`)
+	start := time.Now()
+	var stderr bytes.Buffer
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "echo", "done")
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run(), "a failing hook does not change the exit code")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, stderr.String(), "on_exit command \"sleep\" failed")
+
+	// A formatted hook is not held up by a background process keeping its
+	// output open.
+	configFile = testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "[{{.Level}}] "
+execution:
+  on_exit: sh -c 'echo notified; sleep 10 &'
+  on_exit_format: true
+`)
+	start = time.Now()
+	output, err := exec.Command(testBinaryPath, "-config", configFile, "--", "echo", "done").Output()
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, "[INFO] done\n[INFO] notified\n", string(output))
+}
+
+func TestIntegration_OnExit_Signal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals not supported on Windows")
+	}
+	t.Parallel()
+
+	hookOut := filepath.Join(t.TempDir(), "hook.out")
+	hook := fmt.Sprintf(`sh -c 'echo "$LOGWRAP_EXIT_CODE" > %s'`, hookOut)
+	cmd := exec.Command(testBinaryPath, "-template", "{{.Line}}", "-on-exit", hook, "--",
+		"sh", "-c", "echo ready; sleep 5")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if scanner.Text() == "ready" {
+			require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+		}
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, exitCodeSIGTERM, exitErr.ExitCode())
+
+	content, err := os.ReadFile(hookOut)
+	require.NoError(t, err, "the hook runs after a signal too")
+	assert.Equal(t, fmt.Sprintf("%d\n", exitCodeSIGTERM), string(content))
+}
+
+func TestIntegration_AppendSummaryRecord(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, "output:\n  append_summary_record: true\n")
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "-format", "json", "--",
+		"sh", "-c", "echo 'ERROR: disk full'; echo started; echo oops >&2; sleep 0.1; exit 3")
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	require.Len(t, lines, 4)
+	var record struct {
+		Event    string         `json:"event"`
+		Levels   map[string]int `json:"levels"`
+		ExitCode int            `json:"exit_code"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &record), "summary record: %q", lines[3])
+	assert.Equal(t, "summary", record.Event)
+	assert.Equal(t, map[string]int{"ERROR": 2, "INFO": 1}, record.Levels)
+	assert.Equal(t, 3, record.ExitCode)
+}
+
+func TestIntegration_AppendSummaryRecord_Signal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, "output:\n  append_summary_record: true\n")
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "-format", "json", "--",
+		"sh", "-c", "echo ready; sleep 5")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	var last string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		last = scanner.Text()
+		if strings.Contains(last, `"message":"ready"`) {
+			require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+		}
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, exitCodeSIGTERM, exitErr.ExitCode())
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(last), &record), "summary record: %q", last)
+	assert.Equal(t, "summary", record["event"], "the summary record is written after a signal too")
+	assert.Equal(t, map[string]any{"INFO": float64(1)}, record["levels"])
+	assert.InDelta(t, float64(exitCodeSIGTERM), record["exit_code"], 0)
+}
+
+func TestIntegration_MaxRestarts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  template: "{{.Line}}"
+execution:
+  restart_backoff: 10ms
+`)
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "-max-restarts", "2", "-restart-window", "1m", "--",
+		"sh", "-c", "echo crashed; sleep 0.1; exit 4")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 4, exitErr.ExitCode(), "the last run's code is reported")
+
+	assert.Equal(t, "crashed\ncrashed\ncrashed\n", string(output), "the first run and 2 restarts")
+	assert.Equal(t, "Warning: command exited with code 4, restarting in 10ms (restart 1 of 2 within 1m0s)\n"+
+		"Warning: command exited with code 4, restarting in 20ms (restart 2 of 2 within 1m0s)\n"+
+		"Error: command exited with code 4 after 2 restarts within 1m0s, giving up\n", stderr.String())
+}
+
+func TestIntegration_MaxRestarts_OnExitOnce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	hookOut := filepath.Join(t.TempDir(), "hook.out")
+	configFile := testutils.CreateTempConfigFile(t, fmt.Sprintf(`
+execution:
+  restart_backoff: 10ms
+  on_exit: sh -c 'echo "$LOGWRAP_EXIT_CODE" >> %s'
+`, hookOut))
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "-max-restarts", "2", "--", "sh", "-c", "exit 4")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 4, exitErr.ExitCode())
+
+	content, err := os.ReadFile(hookOut)
+	require.NoError(t, err)
+	assert.Equal(t, "4\n", string(content), "the hook runs once, after the last restart")
+}
+
+func TestIntegration_LineEndingCRLF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  line_ending: crlf
+prefix:
+  template: "[{{.Level}}] "
+`)
+
+	// The trailing sleep keeps the pipes open until the lines are read.
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "--", "sh", "-c", "echo one; echo two; sleep 0.1")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] one\r\n[INFO] two\r\n", string(output))
+}
+
+func TestIntegration_OnlyLevel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	// The trailing sleep keeps the pipes open until the lines are read.
+	cmd := exec.Command(testBinaryPath, "-only-level", "ERROR", "-template", "[{{.Level}}] ", "--",
+		"sh", "-c", "echo 'INFO: starting'; echo 'ERROR: failed'; echo plain; echo 'WARN: slow'; sleep 0.1")
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "[ERROR] ERROR: failed\n", string(output))
+}
+
+func TestIntegration_StderrOnLevel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	run := func(script string) (string, string) {
+		// The trailing sleep keeps the pipes open until the lines are read.
+		cmd := exec.Command(testBinaryPath, "-stderr-on-level", "ERROR", "-template", "[{{.Level}}] ", "--",
+			"sh", "-c", script+"; sleep 0.1")
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		require.NoError(t, cmd.Run())
+		return stdout.String(), stderr.String()
+	}
+
+	stdout, stderr := run("echo 'INFO: backup started'; echo 'INFO: backup done'")
+	assert.Empty(t, stdout)
+	assert.Empty(t, stderr, "a run without errors prints nothing")
+
+	stdout, stderr = run("echo 'INFO: backup started'; echo 'ERROR: disk full'; echo 'INFO: cleanup'")
+	assert.Empty(t, stdout)
+	assert.Equal(t, "[INFO] INFO: backup started\n[ERROR] ERROR: disk full\n[INFO] INFO: cleanup\n", stderr,
+		"an error flushes the held output to stderr")
+}
+
+func TestIntegration_MaxLineRatePerLevel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	script := "for i in 1 2 3 4 5 6 7 8 9 10; do echo \"INFO step $i\"; echo \"ERROR failed $i\"; done; sleep 0.1"
+	output, err := exec.Command(testBinaryPath, "-template", "{{.Line}}", "-max-line-rate-per-level", "info=1",
+		"--", "sh", "-c", script).Output()
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, strings.Count(string(output), "ERROR failed"), "ERROR is not throttled")
+	assert.Less(t, strings.Count(string(output), "INFO step"), 10, "INFO is throttled during the burst")
+}
+
+func TestIntegration_Interactive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  run_markers: true
+prefix:
+  template: "[{{.Level}}] "
+`)
+
+	// The sleep before exiting keeps the pipes open until the output is read.
+	script := `printf '> '; read name; echo "hello $name"; echo oops >&2; sleep 0.1; exit 3`
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "-interactive", "--", "sh", "-c", script)
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Start())
+
+	// The prompt has no line ending: it must arrive before any input is sent.
+	prompt := make([]byte, 2)
+	_, err = io.ReadFull(stdout, prompt)
+	require.NoError(t, err)
+	assert.Equal(t, "> ", string(prompt))
+
+	// SIGINT is left to the command, which gets Ctrl-C from the terminal.
+	require.NoError(t, cmd.Process.Signal(syscall.SIGINT))
+
+	_, err = io.WriteString(stdin, "world\n")
+	require.NoError(t, err)
+	require.NoError(t, stdin.Close())
+
+	rest, err := io.ReadAll(stdout)
+	require.NoError(t, err)
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode(), "the exit code is propagated")
+	assert.Equal(t, "hello world\n", string(rest), "output is neither prefixed nor framed by run markers")
+	assert.Equal(t, "oops\n", stderr.String())
+}
+
+func TestIntegration_ExplainExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell exit codes test not supported on Windows")
+	}
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+execution:
+  success_exit_codes: [0, 3]
+`)
+
+	cmd := exec.Command(testBinaryPath, "-config", configFile, "-explain-exit", "-template", "{{.Line}}",
+		"--", "sh", "-c", "exit 3")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run(), "code 3 is remapped to success")
+
+	assert.Equal(t, "Exit code explanation:\n"+
+		"  command exited with code 3\n"+
+		"  code 3 is listed in success_exit_codes [0, 3]: remapped to 0\n"+
+		"  final exit code: 0\n", stderr.String())
+
+	cmd = exec.Command(testBinaryPath, "-explain-exit", "-pipeline", "-template", "{{.Line}}",
+		"--", "sh", "-c", "exit 2", "--", "sh", "-c", "exit 0")
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run())
+	assert.Contains(t, stderr.String(), "pipeline stages exited with codes 2, 0: the pipeline policy selects 0\n")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// defaultJournalSocket is where systemd-journald receives native protocol
+// entries.
+const defaultJournalSocket = "/run/systemd/journal/socket"
+
+// journalWriter sends every line written to it, as formatted in the
+// journal format, as one datagram to the journal socket.
+type journalWriter struct {
+	conn *net.UnixConn
+}
+
+// openJournal connects to the journal socket at path, or at the default
+// socket when path is empty. It fails on hosts without systemd, where the
+// socket does not exist, and on platforms without Unix datagram sockets.
+func openJournal(path string) (*journalWriter, error) {
+	if path == "" {
+		path = defaultJournalSocket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journald is not available at %s: %w", path, err)
+	}
+	return &journalWriter{conn: conn}, nil
+}
+
+// Write sends p as one journal entry. The line ending the processor appends
+// is replaced with the single newline terminating the entry's last field.
+func (w *journalWriter) Write(p []byte) (int, error) {
+	entry := bytes.TrimSuffix(bytes.TrimSuffix(p, []byte("\n")), []byte("\r"))
+	entry = append(entry[:len(entry):len(entry)], '\n')
+	if _, err := w.conn.Write(entry); err != nil {
+		return 0, fmt.Errorf("failed to write to journald: %w", err)
+	}
+	return len(p), nil
+}
+
+func (w *journalWriter) Close() error {
+	return w.conn.Close() //nolint:wrapcheck // closing errors are ignored by the caller
+}
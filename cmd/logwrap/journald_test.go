@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/config"
+	"github.com/sgaunet/logwrap/pkg/formatter"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listenJournal starts a mock journal socket and returns its path.
+func listenJournal(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("unix datagram sockets not supported on Windows")
+	}
+
+	// Socket paths are limited to about 100 bytes, which t.TempDir() may exceed.
+	dir, err := os.MkdirTemp("", "journal")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return path, conn
+}
+
+func TestOpenSinks_Journald(t *testing.T) {
+	t.Parallel()
+
+	path, journal := listenJournal(t)
+
+	cfg, err := config.LoadConfig("", []string{"-template", "[{{.Level}}] "})
+	require.NoError(t, err)
+	cfg.Output.CustomFields = map[string]string{"env": "prod"}
+	cfg.Output.Sinks = []config.SinkConfig{{Type: "journald", Path: path}}
+
+	sinks, _, closeSinks, err := openSinks(cfg, formatter.WithCommand("make"))
+	require.NoError(t, err)
+	defer closeSinks()
+	require.Len(t, sinks, 1)
+
+	form, err := formatter.New(cfg)
+	require.NoError(t, err)
+	proc := processor.New(form, &testutils.MockWriter{}, processor.WithSinks(sinks...))
+	require.NoError(t, proc.ProcessStreams(context.Background(),
+		strings.NewReader("build started\n"), strings.NewReader("ERROR: build failed\n")))
+
+	entries := make(map[string]bool)
+	buf := make([]byte, 4096)
+	for range 2 {
+		require.NoError(t, journal.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := journal.Read(buf)
+		require.NoError(t, err)
+		entries[string(buf[:n])] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"PRIORITY=6\nSYSLOG_IDENTIFIER=make\nLOGWRAP_LEVEL=INFO\nENV=prod\nMESSAGE=build started\n":        true,
+		"PRIORITY=3\nSYSLOG_IDENTIFIER=make\nLOGWRAP_LEVEL=ERROR\nENV=prod\nMESSAGE=ERROR: build failed\n": true,
+	}, entries, "one datagram per line, in the native protocol encoding")
+}
+
+func TestOpenSinks_JournaldUnavailable(t *testing.T) {
+	t.Parallel()
+
+	missing := filepath.Join(t.TempDir(), "no-journal")
+
+	cfg, err := config.LoadConfig("", []string{"-template", "{{.Line}}"})
+	require.NoError(t, err)
+	cfg.Output.Sinks = []config.SinkConfig{
+		{Type: "journald", Path: missing},
+		{Type: "journald", Path: missing, FallbackToStderr: true},
+	}
+
+	sinks, formatters, closeSinks, err := openSinks(cfg)
+	require.NoError(t, err, "a missing journal is not fatal")
+	defer closeSinks()
+
+	require.Len(t, sinks, 1, "the sink without fallback is left out")
+	assert.Equal(t, os.Stderr, sinks[0].Output)
+	assert.Equal(t, "hello", formatters[0].FormatLine("hello", processor.StreamStdout),
+		"the fallback uses the regular format, not the journal's")
+}
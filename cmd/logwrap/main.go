@@ -3,9 +3,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -26,12 +30,13 @@ var (
 )
 
 const (
-	signalExitCodeBase     = 128 // UNIX convention: 128 + signal number
-	exitCodeSIGINT         = signalExitCodeBase + 2  // SIGINT
-	exitCodeSIGTERM        = signalExitCodeBase + 15 // SIGTERM
 	gracefulShutdownTimeout = 5 * time.Second
 	processorWaitTimeout    = 3 * time.Second
 	killTimeout             = 2 * time.Second
+	heartbeatMessage        = "logwrap: heartbeat, command still running"
+	dedupeMessage           = "logwrap: suppressed %d duplicate lines in the last %s"
+	emptyRunMessage         = "(no output)"
+	contextLevel            = "ERROR" // lines at or above it get output.context_before
 	usage                   = `LogWrap - Command execution wrapper with configurable log prefixes
 
 Usage:
@@ -40,19 +45,97 @@ Usage:
 
 Options:
   -config string      Configuration file path
+  -config-optional    Use built-in defaults if the -config file does not exist
+  -config-precedence SOURCES
+                      Comma-separated sources from lowest to highest precedence
+                      (default "file,flags": flags override the config file)
   -template string    Log prefix template (default "[{{.Timestamp}}] [{{.Level}}] [{{.User}}:{{.PID}}] ")
+  -prefix-cache       Precompile templates of plain fields ({{.Level}}, ...) and
+                      build prefixes by concatenation, for high line rates
   -utc                Use UTC timestamps (default false)
+  -strict-timestamp   Warn when the timestamp format cannot represent a full
+                      date and time (e.g. "%H:%M:%S")
   -colors             Enable colored output (default false)
-  -format string      Output format: text, json, structured (default "text")
+  -force-color-file   Keep colors in text file sinks, e.g. for viewing with less -R
+  -rotate-time P      Rotate file sinks every hour or day (hourly, daily),
+                      renaming the finished file with the period as suffix
+  -format string      Output format: text, json, structured, otel (default "text")
+  -keyword LEVEL=WORD Add a detection keyword for LEVEL (repeatable)
+  -only-level LEVEL   Only output lines of LEVEL, detected or stream default (repeatable)
+  -max-line-rate-per-level LEVEL=RATE
+                      Write at most RATE lines per second of LEVEL, dropping
+                      the rest (repeatable)
+  -no-detect          Disable log level detection (use per-stream defaults)
+  -flatten            Merge JSON lines into JSON output, flattening nested keys (a.b.c)
+  -on-json-parse-failure policy
+                      Merge JSON lines into JSON output; lines that are not
+                      JSON objects are wrapped as "message" (wrap), written
+                      unchanged (passthrough) or dropped (drop)
+  -prefix-width N     Pad prefixes to N characters so messages line up
+                      (0 pads to the widest prefix seen)
+  -stderr-on-level LEVEL
+                      Hold all output and write it to stderr only if a line at
+                      LEVEL or above appears (e.g. ERROR for cron jobs)
+  -health-line-every D
+                      Emit a heartbeat line after D of silence (e.g. 30s)
+  -dedupe-window D    Drop lines repeated within D of being written and report
+                      how many were dropped every D (e.g. 5s)
+  -batch file         Run each line of file as a shell-quoted command, in order
+  -keep-going         With -batch, run the remaining commands after a failure
+  -command-stdin      Read the command as one shell-quoted line from stdin
+                      instead of the arguments (split without a shell, like
+                      -batch lines); the command then gets an empty stdin
+  -allow-root         Run the command as root even if execution.disallow_root is set
+  -pid-file path      Write the command's PID to path while it runs
+  -max-restarts N     Restart the command when it fails, at most N times within
+                      the restart window, waiting twice as long before each
+                      restart (execution.restart_backoff, 1s by default)
+  -restart-window D   How long a restart counts against -max-restarts (default
+                      1m; 0 counts every restart)
+  -on-exit command    After the run, even one stopped by a signal, run command
+                      with LOGWRAP_EXIT_CODE and LOGWRAP_DURATION_MS set
+                      (split like a shell line; use sh -c '...' to expand them)
+  -explain-exit       After the run, print to stderr how the exit code was
+                      derived (command code, core dump, success code
+                      remapping, signals)
+  -level-summary      After the run, print to stderr the number of lines of
+                      each level (e.g. "Level summary: 12 ERROR, 3 WARN")
+  -summary-fd N       After the run, write a JSON summary (exit code, duration,
+                      lines, bytes, lines per level, line lengths, and
+                      core_dumped when the command dumped core) to file
+                      descriptor N, e.g. -summary-fd 3 3>summary.json
+  -interactive        Pass the command's output through unmodified and
+                      unbuffered, for REPLs and prompts (stdin stays
+                      connected; Ctrl-C is left to the command)
+  -single-pipe        Capture stdout and stderr through one pipe so lines keep
+                      the order the command wrote them in (all lines are
+                      then treated as stdout)
+  -pipeline           Treat standalone "--" arguments after the command as pipe
+                      separators: logwrap -pipeline -- cmd1 args -- cmd2 args
   -validate           Validate configuration and exit (no command needed)
+  -list-formats       Print the supported output formats and exit
+  -list-colors        Print the valid color names and exit
   -help               Show this help message
   -version            Show version information
 
+  When an option is repeated, the last value wins (-keyword, -only-level and
+  -max-line-rate-per-level accumulate).
+  Contradicting options, such as -flatten with -format text, are rejected.
+
 Template Variables:
   {{.Timestamp}}      Current timestamp (formatted using strftime format in config)
   {{.Level}}          Log level (INFO, ERROR, etc.)
+  {{.Severity}}       Syslog severity of the level, 0-7 (output.severity_map)
   {{.User}}           Username (controlled via config file)
   {{.PID}}            Process ID (controlled via config file)
+  {{.PPID}}           Parent process ID of logwrap (controlled via config file)
+  {{.Command}}        Base name of the wrapped command (controlled via config file)
+  {{.Host}}           Host name of the machine running logwrap
+  {{.Raw}}            The line exactly as read, before any input cleanup
+  {{.LineNo}}         Line number within the stream (stdout and stderr count separately)
+  {{.Fields.name}}    Value extracted from the line (detection.extract_fields) or
+                      set in output.custom_fields
+  {{.ExitCode}}       Exit code of the command, empty until it has exited
 
 Timestamp Format (strftime):
   Uses Linux date command format (not Go time format)
@@ -72,8 +155,13 @@ Examples:
   logwrap echo "Hello World"
   logwrap -config myconfig.yaml make build
   logwrap -utc -colors make test
+  logwrap -keyword warn=deprecated -keyword error=panicked make test
+  logwrap -only-level ERROR -only-level FATAL make test
+  logwrap -stderr-on-level ERROR ./nightly-backup.sh
   logwrap -template "[{{.Timestamp}}] " ls -la
   logwrap -template "[{{.Level}}] [{{.User}}:{{.PID}}] " -- sh -c "echo stdout; echo stderr >&2"
+  logwrap -pipeline -- printf "b\na\n" -- sort
+  logwrap -batch commands.txt -keep-going
   logwrap -validate
   logwrap -validate -config myconfig.yaml
 
@@ -112,40 +200,193 @@ func main() {
 		os.Exit(0)
 	}
 
+	if hasFlag(args, "-list-formats") {
+		_, _ = fmt.Fprintf(os.Stdout, "%s\n", strings.Join(config.OutputFormats(), "\n"))
+		os.Exit(0)
+	}
+
+	if hasFlag(args, "-list-colors") {
+		_, _ = fmt.Fprintf(os.Stdout, "%s\n", strings.Join(config.ColorNames(), "\n"))
+		os.Exit(0)
+	}
+
 	if hasFlag(args, "-validate") {
 		os.Exit(validateConfig(args))
 	}
 
+	if hasFlag(args, "-color-test") {
+		os.Exit(colorTest(args))
+	}
+
+	batchFile, isBatch := flagValue(args, "-batch")
+	if isBatch && hasFlag(args, "-pipeline") {
+		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v: -batch cannot be used with -pipeline\n",
+			apperrors.ErrConflictingFlags)
+		os.Exit(1)
+	}
+	if isBatch && hasFlag(args, "-command-stdin") {
+		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v: -batch cannot be used with -command-stdin\n",
+			apperrors.ErrConflictingFlags)
+		os.Exit(1)
+	}
+
+	if isBatch {
+		os.Exit(batch(args, command, batchFile))
+	}
+
+	if hasFlag(args, "-command-stdin") {
+		args = removeFlag(args, "-command-stdin")
+		command, err = readCommandStdin(os.Stdin, command)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if len(command) == 0 {
 		fmt.Fprintf(os.Stderr, "Error: no command specified\n\n%s\n", usage)
 		os.Exit(1)
 	}
 
-	configFile := getConfigFile(args)
-	cfg, err := config.LoadConfig(configFile, args)
+	stages := [][]string{command}
+	if hasFlag(args, "-pipeline") {
+		args = removeFlag(args, "-pipeline")
+		stages, err = splitPipeline(command)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := loadConfig(args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
 		os.Exit(1)
 	}
+	printConfigWarnings(cfg)
 
-	os.Exit(run(cfg, command))
+	if err := checkRoot(cfg, os.Geteuid); err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		os.Exit(1)
+	}
+
+	summary, err := openSummaryFD(cfg.Output.SummaryFD)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		os.Exit(1)
+	}
+	exitCode := run(cfg, stages, "", summary)
+	closeSummary(summary)
+	os.Exit(exitCode)
+}
+
+// checkRoot refuses to run when execution.disallow_root is set and geteuid
+// reports root. On Windows [os.Geteuid] returns -1, so the check never fires.
+func checkRoot(cfg *config.Config, geteuid func() int) error {
+	if cfg.Execution.DisallowRoot && geteuid() == 0 {
+		return fmt.Errorf("%w: execution.disallow_root is set, pass -allow-root to override", apperrors.ErrRunAsRoot)
+	}
+	return nil
+}
+
+// splitPipeline splits command on standalone "--" arguments into pipeline
+// stages. Every stage must contain at least a command name.
+func splitPipeline(command []string) ([][]string, error) {
+	var stages [][]string
+	start := 0
+	for i := 0; i <= len(command); i++ {
+		if i < len(command) && command[i] != "--" {
+			continue
+		}
+		if i == start {
+			return nil, fmt.Errorf("%w: stage %d", apperrors.ErrEmptyPipelineStage, len(stages)+1)
+		}
+		stages = append(stages, command[start:i])
+		start = i + 1
+	}
+	return stages, nil
+}
+
+// batch runs the commands listed in batchFile (see runBatch). The -batch
+// and -keep-going flags are logwrap-only and stripped before loading the
+// configuration.
+func batch(args, command []string, batchFile string) int {
+	if len(command) > 0 {
+		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", apperrors.ErrBatchWithCommand)
+		return 1
+	}
+
+	keepGoing := hasFlag(args, "-keep-going")
+	args = removeFlag(removeFlagWithValue(args, "-batch"), "-keep-going")
+
+	commands, err := readBatchFile(batchFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return 1
+	}
+	printConfigWarnings(cfg)
+
+	if err := checkRoot(cfg, os.Geteuid); err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		return 1
+	}
+
+	summary, err := openSummaryFD(cfg.Output.SummaryFD)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		return 1
+	}
+	defer closeSummary(summary)
+
+	return runBatch(cfg, commands, keepGoing, summary)
+}
+
+// lineEnding maps the output.line_ending setting to the terminator written
+// after every line. Unknown values are rejected by config validation.
+func lineEnding(cfg *config.Config) string {
+	if cfg.Output.LineEnding == "crlf" {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// controlChars maps the output.control_chars setting to the processor's
+// mode. Unknown values are rejected by config validation.
+func controlChars(mode string) processor.ControlChars {
+	switch mode {
+	case "escape":
+		return processor.ControlCharsEscape
+	case "strip":
+		return processor.ControlCharsStrip
+	default:
+		return processor.ControlCharsKeep
+	}
+}
+
+// pipelinePolicy maps the execution.pipeline_policy setting to the
+// executor's policy. Unknown values are rejected by config validation.
+func pipelinePolicy(policy string) executor.PipelinePolicy {
+	if policy == "any" {
+		return executor.PipelineAnyFailure
+	}
+	return executor.PipelineLast
 }
 
 func validateConfig(args []string) int {
 	// Filter out -validate before passing to LoadConfig, since it's
 	// not a config flag and would be rejected by the flag parser.
-	var filteredArgs []string
-	for _, arg := range args {
-		if arg != "-validate" && arg != "-validate=true" {
-			filteredArgs = append(filteredArgs, arg)
-		}
-	}
-	args = filteredArgs
+	args = removeFlag(args, "-validate")
 
 	configFile := getConfigFile(args)
 
 	source := configFile
-	if source == "" {
+	if source == "" || (hasFlag(args, "-config-optional") && config.IsMissingConfigFile(configFile)) {
 		source = "(built-in defaults)"
 	}
 
@@ -158,12 +399,106 @@ func validateConfig(args []string) int {
 		return 1
 	}
 
+	printConfigWarnings(cfg)
 	_, _ = fmt.Fprintf(os.Stdout, "Configuration is valid\n\n")
 	_, _ = fmt.Fprintf(os.Stdout, "Loaded from: %s\n\n", source)
 	printConfigSettings(cfg)
 	return 0
 }
 
+// printConfigWarnings reports the configuration warnings, see
+// [config.Config.Warnings].
+func printConfigWarnings(cfg *config.Config) {
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
+
+// colorTest prints a sample line for each log level using the effective
+// color configuration, so users can preview a theme without running a command.
+// Colors are forced on for the preview even if disabled in the config.
+func colorTest(args []string) int {
+	args = removeFlag(args, "-color-test")
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return 1
+	}
+
+	if err := printColorTest(os.Stdout, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printColorTest(w io.Writer, cfg *config.Config) error {
+	preview := *cfg
+	preview.Prefix.Colors.Enabled = true
+
+	form, err := formatter.New(&preview)
+	if err != nil {
+		return fmt.Errorf("failed to create formatter: %w", err)
+	}
+
+	theme := preview.Prefix.Colors.Theme
+	if theme == "" {
+		theme = "(none)"
+	}
+	_, _ = fmt.Fprintf(w, "Color theme: %s\n\n", theme)
+	for _, level := range []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"} {
+		_, _ = fmt.Fprintf(w, "  %s\n", form.ColorizeLevel(level+" sample log line", level))
+	}
+	return nil
+}
+
+// flagValue returns the value of a flag that takes one, given either as
+// "-flag value" or "-flag=value". When the flag is repeated the last value
+// wins, as with the standard flag package.
+func flagValue(args []string, flag string) (string, bool) {
+	value, found := "", false
+	for i := 0; i < len(args); i++ {
+		if args[i] == flag && i+1 < len(args) {
+			i++
+			value, found = args[i], true
+			continue
+		}
+		if val, ok := strings.CutPrefix(args[i], flag+"="); ok {
+			value, found = val, true
+		}
+	}
+	return value, found
+}
+
+// removeFlagWithValue returns args without the given flag and its value.
+func removeFlagWithValue(args []string, flag string) []string {
+	var filtered []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag:
+			i++ // skip the value
+		case strings.HasPrefix(args[i], flag+"="):
+		default:
+			filtered = append(filtered, args[i])
+		}
+	}
+	return filtered
+}
+
+// removeFlag returns args without the given boolean flag, in both its bare
+// and "=true" forms. Used to strip logwrap-only flags that the config flag
+// parser does not know about.
+func removeFlag(args []string, flag string) []string {
+	var filtered []string
+	for _, arg := range args {
+		if arg != flag && arg != flag+"=true" {
+			filtered = append(filtered, arg)
+		}
+	}
+	return filtered
+}
+
 func printConfigSettings(cfg *config.Config) {
 	_, _ = fmt.Fprintf(os.Stdout, "Settings:\n")
 	_, _ = fmt.Fprintf(os.Stdout, "  Output format:    %s\n", cfg.Output.Format)
@@ -179,11 +514,24 @@ func printConfigSettings(cfg *config.Config) {
 	_, _ = fmt.Fprintf(os.Stdout, "  Default stdout:   %s\n", cfg.LogLevel.DefaultStdout)
 	_, _ = fmt.Fprintf(os.Stdout, "  Default stderr:   %s\n", cfg.LogLevel.DefaultStderr)
 	_, _ = fmt.Fprintf(os.Stdout, "  Detection:        %t\n", cfg.LogLevel.Detection.Enabled)
+	_, _ = fmt.Fprintf(os.Stdout, "  Success codes:    %s\n", formatExitCodes(cfg.Execution.SuccessExitCodes))
+	_, _ = fmt.Fprintf(os.Stdout, "  Pipeline policy:  %s\n", cfg.Execution.PipelinePolicy)
 	if cfg.Filter.Enabled {
 		printFilterSettings(cfg)
 	}
 }
 
+func formatExitCodes(codes []int) string {
+	if len(codes) == 0 {
+		return "0"
+	}
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = strconv.Itoa(code)
+	}
+	return strings.Join(parts, ", ")
+}
+
 func printColorSettings(cfg *config.Config) {
 	if cfg.Prefix.Colors.Theme != "" {
 		_, _ = fmt.Fprintf(os.Stdout, "    Theme:          %s\n", cfg.Prefix.Colors.Theme)
@@ -198,6 +546,9 @@ func printFilterSettings(cfg *config.Config) {
 	if len(cfg.Filter.IncludeLevels) > 0 {
 		_, _ = fmt.Fprintf(os.Stdout, "    Include levels: %s\n", strings.Join(cfg.Filter.IncludeLevels, ", "))
 	}
+	if len(cfg.Filter.OnlyLevels) > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "    Only levels:    %s\n", strings.Join(cfg.Filter.OnlyLevels, ", "))
+	}
 	if len(cfg.Filter.ExcludeLevels) > 0 {
 		_, _ = fmt.Fprintf(os.Stdout, "    Exclude levels: %s\n", strings.Join(cfg.Filter.ExcludeLevels, ", "))
 	}
@@ -225,7 +576,12 @@ func parseArgs(args []string) ([]string, []string, error) {
 		if len(arg) > 0 && arg[0] == '-' {
 			configArgs = append(configArgs, arg)
 
-			if arg == "-config" || arg == "-template" || arg == "-format" {
+			if arg == "-config" || arg == "-template" || arg == "-format" || arg == "-keyword" ||
+				arg == "-only-level" || arg == "-stderr-on-level" || arg == "-health-line-every" || arg == "-batch" ||
+				arg == "-dedupe-window" || arg == "-config-precedence" || arg == "-summary-fd" ||
+				arg == "-on-json-parse-failure" || arg == "-prefix-width" || arg == "-on-exit" ||
+				arg == "-max-restarts" || arg == "-restart-window" || arg == "-pid-file" || arg == "-max-line-rate-per-level" ||
+				arg == "-rotate-time" {
 				if i+1 >= len(args) {
 					return nil, nil, fmt.Errorf("%w: %s", apperrors.ErrOptionRequiresValue, arg)
 				}
@@ -252,33 +608,155 @@ func hasFlag(args []string, flag string) bool {
 	return false
 }
 
+// getConfigFile returns the -config value (the last one if repeated) or
+// the discovered configuration file.
 func getConfigFile(args []string) string {
-	for i, arg := range args {
-		if arg == "-config" && i+1 < len(args) {
-			return args[i+1]
-		}
-		if val, ok := strings.CutPrefix(arg, "-config="); ok {
-			return val
-		}
+	if configFile, ok := flagValue(args, "-config"); ok {
+		return configFile
 	}
 	return config.FindConfigFile()
 }
 
-func run(cfg *config.Config, command []string) int {
-	exec, err := executor.New(command)
+// loadConfig loads the configuration for a run. A file given with -config
+// must be valid, but one found by discovery is only a convenience: if it
+// fails to load or validate, a warning is printed and the defaults are
+// used instead, so a stray logwrap.yaml cannot stop a command from running.
+// Errors caused by the command-line flags themselves are still reported.
+func loadConfig(args []string) (*config.Config, error) {
+	if configFile, ok := flagValue(args, "-config"); ok {
+		return config.LoadConfig(configFile, args) //nolint:wrapcheck // already descriptive
+	}
+
+	configFile := config.FindConfigFile()
+	cfg, err := config.LoadConfig(configFile, args)
+	if err == nil || configFile == "" {
+		return cfg, err //nolint:wrapcheck // already descriptive
+	}
+
+	fallback, fallbackErr := config.LoadConfig("", args)
+	if fallbackErr != nil {
+		return nil, err //nolint:wrapcheck // already descriptive
+	}
+	fmt.Fprintf(os.Stderr, "Warning: ignoring discovered config file %s, using defaults: %v\n", configFile, err)
+	return fallback, nil
+}
+
+// commandExecutor is the part of [executor.Executor] that run drives. Tests
+// substitute their own implementation through runWith to exercise exit and
+// signal handling without starting processes.
+type commandExecutor interface {
+	Start() error
+	Wait() error
+	GetStreams() (io.Reader, io.Reader)
+	PID() int
+	Duration() time.Duration
+	Stop() error
+	Kill() error
+	GetExitCode() int
+	StageExitCodes() []int
+	CoreDumped() bool
+	IsFinished() bool
+	Cleanup()
+}
+
+// newExecutorFunc creates the executor of a run. It is called again for
+// every start retry, since an executor runs its command at most once.
+type newExecutorFunc func(stages [][]string, policy executor.PipelinePolicy) (commandExecutor, error)
+
+// pipelineExecutor returns the newExecutorFunc of real runs.
+func pipelineExecutor(cfg *config.Config) newExecutorFunc {
+	var opts []executor.Option
+	if cfg.Execution.SinglePipe {
+		opts = append(opts, executor.WithSinglePipe())
+	}
+	return func(stages [][]string, policy executor.PipelinePolicy) (commandExecutor, error) {
+		exec, err := executor.NewPipeline(stages, policy, opts...)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // already names the command
+		}
+		return exec, nil
+	}
+}
+
+// run wraps the command built from stages, restarting it on failure when
+// execution.max_restarts is set, and returns logwrap's exit code. When
+// summary is not nil, a JSON summary of every run is written to it once the
+// command has exited. A non-empty banner is written through the first run's
+// processor before its command starts. The execution.on_exit command runs
+// once, after the last run.
+func run(cfg *config.Config, stages [][]string, banner string, summary io.Writer) int {
+	hook, err := parseExitHook(cfg.Execution.OnExit, cfg.Execution.OnExitTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		return 1
+	}
+
+	var last finishedRun
+	code := newRestarter(cfg).run(func() (int, bool) {
+		last = finishedRun{}
+		code := runWith(cfg, stages, banner, summary, pipelineExecutor(cfg), &last)
+		banner = ""
+		return code, last.interrupted || last.outputClosed
+	})
+	hook.run(code, last.duration, last.form, last.output)
+	return code
+}
+
+// finishedRun is what the restarts and the on_exit command need from the
+// last run.
+type finishedRun struct {
+	duration     time.Duration
+	form         processor.Formatter // formats the hook's output; nil passes it through
+	output       io.Writer
+	interrupted  bool // logwrap received SIGINT or SIGTERM
+	outputClosed bool // logwrap's stdout was closed, e.g. by "| head"
+}
+
+// runWith is run with the executors created by newExecutor, without
+// restarts. When last is not nil, it is set once the command has exited.
+func runWith(
+	cfg *config.Config, stages [][]string, banner string, summary io.Writer, newExecutor newExecutorFunc, last *finishedRun,
+) int {
+	policy := pipelinePolicy(cfg.Execution.PipelinePolicy)
+	exec, err := newExecutor(stages, policy)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Execution error: failed to create executor: %v\n", err)
 		return 1
 	}
-	defer exec.Cleanup()
+	// exec is replaced when a start attempt is retried.
+	defer func() { exec.Cleanup() }()
 
-	form, err := formatter.New(cfg)
+	form, err := formatter.New(cfg, formatter.WithCommand(stages[0][0]))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Execution error: failed to create formatter: %v\n", err)
 		return 1
 	}
 
+	sinks, sinkFormatters, closeSinks, err := openSinks(cfg, formatter.WithCommand(stages[0][0]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		return 1
+	}
+	defer closeSinks()
+
+	capture, err := openRawCapture(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		return 1
+	}
+	defer capture.close()
+
+	pids, err := createPIDFile(cfg.Execution.PIDFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
+		return 1
+	}
+	defer pids.remove()
+
 	var procOpts []processor.Option
+	if len(sinks) > 0 {
+		procOpts = append(procOpts, processor.WithSinks(sinks...))
+	}
 	if cfg.Filter.Enabled {
 		f, fErr := filter.New(filter.Config{
 			Enabled:         cfg.Filter.Enabled,
@@ -286,6 +764,7 @@ func run(cfg *config.Config, command []string) int {
 			IncludePatterns: cfg.Filter.IncludePatterns,
 			ExcludeLevels:   cfg.Filter.ExcludeLevels,
 			IncludeLevels:   cfg.Filter.IncludeLevels,
+			CaseSensitive:   cfg.LogLevel.Detection.CaseSensitive,
 		}, cfg.LogLevel.Detection.Keywords)
 		if fErr != nil {
 			fmt.Fprintf(os.Stderr, "Execution error: failed to create filter: %v\n", fErr)
@@ -298,20 +777,129 @@ func run(cfg *config.Config, command []string) int {
 	// a race where a signal arrives after Start() but before Notify(),
 	// which would use Go's default handler (os.Exit) and orphan the child.
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	if cfg.Execution.Interactive {
+		// Ctrl-C reaches the command straight from the terminal and a REPL
+		// handles it itself, so SIGINT must neither stop the command nor
+		// kill logwrap. It is caught rather than ignored, since an ignored
+		// signal would stay ignored in the command too.
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, syscall.SIGINT)
+		defer signal.Stop(interrupts)
+		signal.Notify(sigChan, syscall.SIGTERM)
+	} else {
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	}
+
+	// Catch SIGPIPE so a closed stdout (e.g. "logwrap cmd | head") surfaces
+	// as EPIPE write errors instead of killing logwrap outright. The
+	// processor reports it via OutputClosed and the command is stopped.
+	pipeChan := make(chan os.Signal, 1)
+	signal.Notify(pipeChan, syscall.SIGPIPE)
+	defer signal.Stop(pipeChan)
 
 	ctx, ctxCancel := context.WithCancel(context.Background())
 	defer ctxCancel()
 
-	procOpts = append(procOpts, processor.WithContext(ctx))
-	proc := processor.New(form, os.Stdout, procOpts...)
+	procOpts = append(procOpts, processor.WithContext(ctx), processor.WithLineEnding(lineEnding(cfg)),
+		processor.WithControlChars(controlChars(cfg.Output.ControlChars)))
+	if cfg.Output.Progress == "final" {
+		procOpts = append(procOpts, processor.WithProgressFinal())
+	}
+	if cfg.Output.HeartbeatInterval > 0 {
+		procOpts = append(procOpts, processor.WithHeartbeat(cfg.Output.HeartbeatInterval, heartbeatMessage))
+	}
+	output := io.Writer(os.Stdout)
+	if cfg.Output.SquashBlankLines {
+		procOpts = append(procOpts, processor.WithSquashBlankLines(cfg.Output.SquashBlankLinesTo))
+	}
+	if len(cfg.Output.Routes) > 0 {
+		procOpts = append(procOpts, processor.WithRoutes(func(rec processor.Record) string {
+			return recordLevel(form, rec)
+		}, cfg.Output.Routes))
+	}
+	if cfg.Output.ReorderWindow > 0 {
+		procOpts = append(procOpts, processor.WithReorderWindow(cfg.Output.ReorderWindow))
+	}
+	if window := cfg.Output.DedupeWindow; window > 0 {
+		procOpts = append(procOpts, processor.WithDedupeWindow(window, func(suppressed int) string {
+			return fmt.Sprintf(dedupeMessage, suppressed, window)
+		}))
+	}
+	if cfg.Output.ReadAhead > 0 {
+		procOpts = append(procOpts, processor.WithReadAhead(cfg.Output.ReadAhead))
+	}
+	if cfg.Output.Workers > 1 {
+		procOpts = append(procOpts, processor.WithWorkers(cfg.Output.Workers))
+	}
+	if len(cfg.Output.RatePerLevel) > 0 {
+		procOpts = append(procOpts, processor.WithLevelRates(func(rec processor.Record) string {
+			return recordLevel(form, rec)
+		}, cfg.Output.RatePerLevel))
+	}
+	if cfg.Output.ContextBefore > 0 {
+		procOpts = append(procOpts, processor.WithContextBefore(cfg.Output.ContextBefore, func(rec processor.Record) bool {
+			return levelAtLeast(recordLevel(form, rec), contextLevel)
+		}))
+	}
+	if threshold := cfg.Output.StderrOnLevel; threshold != "" {
+		output = os.Stderr
+		procOpts = append(procOpts, processor.WithBufferUntil(func(rec processor.Record) bool {
+			return levelAtLeast(recordLevel(form, rec), threshold)
+		}, cfg.Output.StderrOnLevelMaxLines))
+	}
+	if cfg.Output.LevelSummary || cfg.Output.AppendSummaryRecord || summary != nil {
+		procOpts = append(procOpts, processor.WithLevelCounts(func(rec processor.Record) string {
+			return recordLevel(form, rec)
+		}))
+	}
+	if summary != nil {
+		procOpts = append(procOpts, processor.WithLineLengths())
+	}
+	if cfg.Execution.Interactive {
+		// Copy the streams as they come; no line-based option applies.
+		output = os.Stdout
+		procOpts = []processor.Option{processor.WithContext(ctx), processor.WithPassthrough(os.Stderr)}
+	}
+	proc := processor.New(form, output, procOpts...)
+	if banner != "" {
+		if err := proc.WriteMessage(banner); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write banner: %v\n", err)
+		}
+	}
 
-	if err := exec.Start(); err != nil {
+	err = retryStart(cfg.Execution.StartRetries, cfg.Execution.StartRetryDelay, time.Sleep, func(attempt int) error {
+		if attempt > 0 {
+			// An executor whose command failed to start cannot be reused.
+			// If no new one can be created, exec keeps the failed one so
+			// that the deferred Cleanup still has one to call.
+			exec.Cleanup()
+			next, err := newExecutor(stages, policy)
+			if err != nil {
+				return err //nolint:wrapcheck // reported as a start failure below
+			}
+			exec = next
+		}
+		return exec.Start() //nolint:wrapcheck // reported as a start failure below
+	})
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Execution error: failed to start command: %v\n", err)
 		return 1
 	}
 
-	stdout, stderr := exec.GetStreams()
+	form.SetChildPID(exec.PID())
+	for _, f := range sinkFormatters {
+		f.SetChildPID(exec.PID())
+	}
+	if err := pids.write(exec.PID()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	label := runLabel(stages)
+	if cfg.Output.RunMarkers && !cfg.Execution.Interactive {
+		writeRunMarker(proc, fmt.Sprintf("--- START %s %s ---", label, time.Now().Format(time.RFC3339)), "")
+	}
+
+	stdout, stderr := capture.wrap(exec.GetStreams())
 
 	// Start stream processing in background
 	processingDone := make(chan error, 1)
@@ -328,11 +916,154 @@ func run(cfg *config.Config, command []string) int {
 	// Clean up signal handler before exit
 	signal.Stop(sigChan)
 
-	return determineExitCode(exec, receivedSignal, cmdErr)
+	if counts := proc.LevelCounts(); counts != nil && cfg.Output.LevelSummary {
+		printLevelSummary(os.Stderr, counts)
+	}
+
+	var explain *exitExplanation
+	if cfg.Execution.ExplainExit {
+		explain = &exitExplanation{}
+	}
+	var hookForm processor.Formatter
+	if cfg.Execution.OnExitFormat && !cfg.Execution.Interactive {
+		hookForm = form
+	}
+	finish := func(exitCode int) int {
+		if cfg.Output.AppendSummaryRecord && !cfg.Execution.Interactive {
+			writeSummaryRecord(proc, exitCode)
+		}
+		if summary != nil {
+			report := newRunSummary(label, exitCode, exec.Duration(), proc)
+			report.CoreDumped = exec.CoreDumped()
+			writeRunSummary(summary, report)
+		}
+		if last != nil {
+			*last = finishedRun{
+				duration:     exec.Duration(),
+				form:         hookForm,
+				output:       output,
+				interrupted:  receivedSignal != nil,
+				outputClosed: isClosed(proc.OutputClosed()),
+			}
+		}
+		return explain.finish(exitCode)
+	}
+
+	if receivedSignal == nil && isClosed(proc.OutputClosed()) {
+		explain.add("stdout was closed early (broken pipe): output.broken_pipe_exit_code applies")
+		return finish(cfg.Output.BrokenPipeExitCode)
+	}
+
+	if cfg.Output.NoteEmptyRuns && !cfg.Execution.Interactive && proc.LinesRead() == 0 {
+		if err := proc.WriteMessage(emptyRunMessage); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write empty run note: %v\n", err)
+		}
+	}
+
+	exitCode := determineExitCode(exec, receivedSignal, cmdErr, cfg.Execution.SuccessExitCodes, explain)
+	if exitCode == 0 && cfg.Output.OnFormatError == "error" && hasFormatErrors(proc.GetErrors()) {
+		explain.add("lines failed to format and output.on_format_error is \"error\": 0 becomes 1")
+		exitCode = 1
+	}
+	form.SetExitCode(exitCode)
+	form.SetDuration(exec.Duration())
+	for _, f := range sinkFormatters {
+		f.SetExitCode(exitCode)
+		f.SetDuration(exec.Duration())
+	}
+	if cfg.Output.RunMarkers && !cfg.Execution.Interactive {
+		level := exitLevel(exitCode, receivedSignal != nil, cfg.Execution.ExitLevelMap)
+		writeRunMarker(proc, fmt.Sprintf("--- END %s code=%d ---", label, exitCode), level)
+	}
+	return finish(exitCode)
+}
+
+// recordLevel returns the level of rec: its explicit Level, such as the
+// exit_level_map level of the END run marker, or else the level form
+// detects in its text.
+func recordLevel(form *formatter.DefaultFormatter, rec processor.Record) string {
+	if rec.Level != "" {
+		return rec.Level
+	}
+	return form.Level(rec.Line, rec.Stream)
+}
+
+// levelSeverity orders log levels from least to most severe.
+var levelSeverity = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// levelAtLeast reports whether level is at least as severe as threshold.
+// Levels outside levelSeverity, such as the drop pseudo-level, never are.
+func levelAtLeast(level, threshold string) bool {
+	i := slices.Index(levelSeverity, strings.ToUpper(level))
+	return i >= 0 && i >= slices.Index(levelSeverity, strings.ToUpper(threshold))
+}
+
+// retryStart calls start until it succeeds, at most retries + 1 times,
+// sleeping delay between attempts, and returns the last error. start receives
+// the attempt number, starting at 0. This only covers commands that fail to
+// start; a command that started is never restarted.
+func retryStart(retries int, delay time.Duration, sleep func(time.Duration), start func(attempt int) error) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: failed to start command: %v, retrying in %v (%d/%d)\n",
+				err, delay, attempt, retries)
+			sleep(delay)
+		}
+		if err = start(attempt); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// exitLevel returns the level of the END run marker for the exit code
+// logwrap reports, looked up in levels (execution.exit_level_map): the
+// signal entry when the run was interrupted by a signal, then the exit
+// code's own entry, then the nonzero entry for failures. It returns "" when
+// no entry applies, leaving the level to detection.
+func exitLevel(exitCode int, signaled bool, levels map[string]string) string {
+	if level, ok := levels[config.ExitLevelSignal]; ok && signaled {
+		return level
+	}
+	if level, ok := levels[strconv.Itoa(exitCode)]; ok {
+		return level
+	}
+	if level, ok := levels[config.ExitLevelNonZero]; ok && exitCode != 0 {
+		return level
+	}
+	return ""
+}
+
+// runLabel identifies a run in its start and end markers: the command line,
+// with pipeline stages joined by " | ".
+func runLabel(stages [][]string) string {
+	parts := make([]string, len(stages))
+	for i, stage := range stages {
+		parts[i] = strings.Join(stage, " ")
+	}
+	return strings.Join(parts, " | ")
+}
+
+// writeRunMarker writes a run marker line through the processor so that it
+// is formatted like the command's output and reaches every sink. An empty
+// level detects the marker's level like any other line.
+func writeRunMarker(proc *processor.Processor, marker, level string) {
+	if err := proc.WriteMessageWithLevel(marker, level); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write run marker: %v\n", err)
+	}
+}
+
+// hasFormatErrors reports whether any processing error is a formatting
+// failure surfaced by the "error" on_format_error policy.
+func hasFormatErrors(errs []*processor.ProcessingError) bool {
+	return slices.ContainsFunc(errs, func(err *processor.ProcessingError) bool {
+		return errors.Is(err, apperrors.ErrFormatFailed)
+	})
 }
 
 func waitForCommandOrSignal(
-	exec *executor.Executor,
+	exec commandExecutor,
 	proc *processor.Processor,
 	sigChan chan os.Signal,
 ) (os.Signal, error) {
@@ -348,6 +1079,9 @@ func waitForCommandOrSignal(
 	case sig := <-sigChan:
 		receivedSignal = sig
 		cmdErr = handleSignalShutdown(exec, proc, sig, cmdDone)
+	case <-proc.OutputClosed():
+		// Nobody is reading our output any more; stop the command quietly.
+		cmdErr = stopCommand(exec, proc, cmdDone)
 	case cmdErr = <-cmdDone:
 		// Command finished normally
 	}
@@ -355,9 +1089,14 @@ func waitForCommandOrSignal(
 	return receivedSignal, cmdErr
 }
 
-func handleSignalShutdown(exec *executor.Executor, proc *processor.Processor, sig os.Signal, cmdDone chan error) error {
+func handleSignalShutdown(exec commandExecutor, proc *processor.Processor, sig os.Signal, cmdDone chan error) error {
 	fmt.Fprintf(os.Stderr, "\nReceived signal %v, initiating graceful shutdown...\n", sig)
+	return stopCommand(exec, proc, cmdDone)
+}
 
+// stopCommand sends SIGTERM to the command and waits for it to exit,
+// escalating to SIGKILL after gracefulShutdownTimeout.
+func stopCommand(exec commandExecutor, proc *processor.Processor, cmdDone chan error) error {
 	// Signal the child process first so it can produce cleanup output.
 	// The processor keeps running to capture any final output from the child.
 	if err := exec.Stop(); err != nil {
@@ -407,28 +1146,63 @@ func waitForProcessing(proc *processor.Processor, processingDone chan error) {
 	}
 }
 
-func determineExitCode(exec *executor.Executor, receivedSignal os.Signal, cmdErr error) int {
+// isClosed reports whether ch has been closed, without blocking.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func determineExitCode(
+	exec commandExecutor, receivedSignal os.Signal, cmdErr error, successCodes []int, explain *exitExplanation,
+) int {
 	// If we received a signal, use signal-based exit code
 	if receivedSignal != nil {
-		switch receivedSignal {
-		case syscall.SIGINT:
-			return exitCodeSIGINT
-		case syscall.SIGTERM:
-			return exitCodeSIGTERM
-		default:
-			if sig, ok := receivedSignal.(syscall.Signal); ok {
-				return signalExitCodeBase + int(sig)
-			}
+		code := signalExitCode(receivedSignal)
+		explain.add("logwrap received %v and stopped the command: 128 + signal number = %d", receivedSignal, code)
+		return code
+	}
 
-			return 1
-		}
+	code := exec.GetExitCode()
+	if stages := exec.StageExitCodes(); len(stages) > 1 {
+		explain.add("pipeline stages exited with codes %s: the pipeline policy selects %d", formatExitCodes(stages), code)
+	} else {
+		explain.add("command exited with code %d", code)
+	}
+	if exec.CoreDumped() {
+		explain.add("the command was killed by a signal and dumped core")
 	}
 
 	// If the command failed with a non-exit error (e.g., I/O error, context error),
 	// the executor's exit code stays at 0. Use 1 to avoid masking the failure.
-	if cmdErr != nil && exec.GetExitCode() == 0 {
+	if cmdErr != nil && code == 0 {
+		explain.add("waiting for the command failed (%v): reported as 1", cmdErr)
+		return 1
+	}
+
+	result := classifyExitCode(code, successCodes)
+	explain.addClassification(code, result, successCodes)
+	return result
+}
+
+// classifyExitCode maps the command's exit code through the configured
+// success codes. A listed code is reported as 0; an unlisted 0 becomes 1 so
+// the failure is not masked; any other code is passed through unchanged.
+// An empty list is treated as [0].
+func classifyExitCode(code int, successCodes []int) int {
+	if len(successCodes) == 0 {
+		successCodes = []int{0}
+	}
+
+	if slices.Contains(successCodes, code) {
+		return 0
+	}
+	if code == 0 {
 		return 1
 	}
 
-	return exec.GetExitCode()
+	return code
 }
@@ -1,9 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/sgaunet/logwrap/pkg/apperrors"
+	"github.com/sgaunet/logwrap/pkg/config"
+	"github.com/sgaunet/logwrap/pkg/executor"
+	"github.com/sgaunet/logwrap/pkg/formatter"
+	"github.com/sgaunet/logwrap/pkg/processor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -53,6 +67,12 @@ func TestParseArgs_Success(t *testing.T) {
 			expectedConfig:  []string{"-format", "json"},
 			expectedCommand: []string{"echo", "test"},
 		},
+		{
+			name:            "repeated keyword flags",
+			args:            []string{"-keyword", "warn=deprecated", "-keyword", "error=panicked", "make"},
+			expectedConfig:  []string{"-keyword", "warn=deprecated", "-keyword", "error=panicked"},
+			expectedCommand: []string{"make"},
+		},
 		{
 			name:            "multiple flags",
 			args:            []string{"-colors", "-template", "[test] ", "-utc", "echo", "hello"},
@@ -553,4 +573,559 @@ func TestParseArgs_Stability(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, argsCopy, originalArgs, "Original args should not be modified")
-}
\ No newline at end of file
+}
+func TestClassifyExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		code         int
+		successCodes []int
+		expected     int
+	}{
+		{"zero with default codes", 0, []int{0}, 0},
+		{"failure with default codes", 2, []int{0}, 2},
+		{"empty list behaves as default", 0, nil, 0},
+		{"grep no-match classified as success", 1, []int{0, 1}, 0},
+		{"unlisted failure passes through", 2, []int{0, 1}, 2},
+		{"unlisted zero becomes failure", 0, []int{1}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, classifyExitCode(tt.code, tt.successCodes))
+		})
+	}
+}
+
+func TestExitExplanation_Classification(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		code         int
+		successCodes []int
+		expected     string
+	}{
+		{"success", 0, nil, "code 0 is a success code [0]: kept as 0"},
+		{"failure", 2, nil, "code 2 is a failure: kept as 2"},
+		{"remapped to success", 1, []int{0, 1}, "code 1 is listed in success_exit_codes [0, 1]: remapped to 0"},
+		{"unlisted failure", 2, []int{0, 1}, "code 2 is not listed in success_exit_codes [0, 1]: kept as 2"},
+		{
+			"unlisted zero", 0, []int{1},
+			"code 0 is not listed in success_exit_codes [1]: remapped to 1 so the failure is not masked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			explain := &exitExplanation{}
+			explain.addClassification(tt.code, classifyExitCode(tt.code, tt.successCodes), tt.successCodes)
+			assert.Equal(t, []string{tt.expected}, explain.steps)
+		})
+	}
+}
+
+func TestExitExplanation_Print(t *testing.T) {
+	t.Parallel()
+
+	var explain *exitExplanation
+	explain.add("ignored when explaining is disabled")
+	assert.Equal(t, 3, explain.finish(3))
+
+	explain = &exitExplanation{}
+	explain.add("command exited with code %d", 1)
+	var buf bytes.Buffer
+	explain.print(&buf, 0)
+	assert.Equal(t, "Exit code explanation:\n  command exited with code 1\n  final exit code: 0\n", buf.String())
+}
+
+func TestPrintLevelSummary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		counts   map[string]int
+		expected string
+	}{
+		{
+			"most severe first", map[string]int{"INFO": 40, "ERROR": 12, "WARN": 3, "DEBUG": 0},
+			"Level summary: 12 ERROR, 3 WARN, 40 INFO\n",
+		},
+		{
+			"unknown levels last", map[string]int{"DROP": 2, "TRACE": 1, "FATAL": 1},
+			"Level summary: 1 FATAL, 1 TRACE, 2 DROP\n",
+		},
+		{"no lines", map[string]int{}, "Level summary: no lines\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			printLevelSummary(&buf, tt.counts)
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestExitLevel(t *testing.T) {
+	t.Parallel()
+
+	defaults := map[string]string{"0": "INFO", config.ExitLevelNonZero: "ERROR", config.ExitLevelSignal: "WARN"}
+	custom := map[string]string{"0": "DEBUG", "1": "WARN", config.ExitLevelNonZero: "FATAL"}
+
+	tests := []struct {
+		name     string
+		code     int
+		signaled bool
+		levels   map[string]string
+		expected string
+	}{
+		{"success", 0, false, defaults, "INFO"},
+		{"failure", 2, false, defaults, "ERROR"},
+		{"signal", exitCodeSIGTERM, true, defaults, "WARN"},
+		{"custom success", 0, false, custom, "DEBUG"},
+		{"custom code", 1, false, custom, "WARN"},
+		{"custom nonzero", 2, false, custom, "FATAL"},
+		{"signal without entry uses the code", exitCodeSIGINT, true, custom, "FATAL"},
+		{"no entry leaves detection", 0, false, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, exitLevel(tt.code, tt.signaled, tt.levels))
+		})
+	}
+}
+
+func TestSplitPipeline(t *testing.T) {
+	t.Parallel()
+
+	stages, err := splitPipeline([]string{"printf", "b\na", "--", "sort", "-r", "--", "cat"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"printf", "b\na"}, {"sort", "-r"}, {"cat"}}, stages)
+
+	stages, err = splitPipeline([]string{"echo", "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"echo", "hi"}}, stages)
+
+	for _, command := range [][]string{
+		{"echo", "--"},
+		{"--", "cat"},
+		{"echo", "--", "--", "cat"},
+	} {
+		_, err := splitPipeline(command)
+		assert.ErrorIs(t, err, apperrors.ErrEmptyPipelineStage, "command %q", command)
+	}
+}
+
+func TestPrintColorTest(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.LoadConfig("", []string{})
+	require.NoError(t, err)
+	cfg.Prefix.Colors.Info = "cyan"
+	cfg.Prefix.Colors.Error = "magenta"
+
+	var buf bytes.Buffer
+	require.NoError(t, printColorTest(&buf, cfg))
+	output := buf.String()
+
+	const (
+		cyan    = "\033[36m"
+		magenta = "\033[35m"
+		reset   = "\033[0m"
+	)
+	for _, level := range []string{"TRACE", "DEBUG", "INFO", "WARN"} {
+		assert.Contains(t, output, cyan+level+" sample log line"+reset)
+	}
+	for _, level := range []string{"ERROR", "FATAL"} {
+		assert.Contains(t, output, magenta+level+" sample log line"+reset)
+	}
+	assert.False(t, cfg.Prefix.Colors.Enabled, "preview must not mutate the caller's config")
+}
+
+func TestRemoveFlag(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"-color-test", "-config", "a.yaml", "-color-test=true", "-utc"}
+	assert.Equal(t, []string{"-config", "a.yaml", "-utc"}, removeFlag(args, "-color-test"))
+	assert.Nil(t, removeFlag(nil, "-validate"))
+}
+
+func TestReadBatchFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "commands.txt")
+	content := "# build steps\n\necho \"hello world\"\n  sh -c 'exit 3'  \n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	commands, err := readBatchFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"echo", "hello world"}, {"sh", "-c", "exit 3"}}, commands)
+
+	require.NoError(t, os.WriteFile(path, []byte("echo ok\necho 'oops\n"), 0o600))
+	_, err = readBatchFile(path)
+	require.ErrorIs(t, err, apperrors.ErrUnterminatedQuote)
+	assert.Contains(t, err.Error(), ":2:", "the error names the offending line")
+
+	require.NoError(t, os.WriteFile(path, []byte("# nothing\n"), 0o600))
+	_, err = readBatchFile(path)
+	assert.ErrorIs(t, err, apperrors.ErrEmptyBatch)
+}
+
+func TestFlagValue(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"-colors", "-batch", "cmds.txt", "-keep-going"}
+	value, ok := flagValue(args, "-batch")
+	assert.True(t, ok)
+	assert.Equal(t, "cmds.txt", value)
+	assert.Equal(t, []string{"-colors", "-keep-going"}, removeFlagWithValue(args, "-batch"))
+
+	value, ok = flagValue([]string{"-batch=other.txt"}, "-batch")
+	assert.True(t, ok)
+	assert.Equal(t, "other.txt", value)
+	assert.Empty(t, removeFlagWithValue([]string{"-batch=other.txt"}, "-batch"))
+
+	_, ok = flagValue([]string{"-colors"}, "-batch")
+	assert.False(t, ok)
+}
+
+func TestGetConfigFile_LastWins(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "b.yaml", getConfigFile([]string{"-config", "a.yaml", "-colors", "-config", "b.yaml"}))
+	assert.Equal(t, "c.yaml", getConfigFile([]string{"-config", "a.yaml", "-config=c.yaml"}))
+
+	value, ok := flagValue([]string{"-batch", "one.txt", "-batch=two.txt"}, "-batch")
+	assert.True(t, ok)
+	assert.Equal(t, "two.txt", value)
+}
+
+func TestLoadConfig_DiscoveredFallback(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile("logwrap.yaml", []byte("output:\n  format: bogus\n"), 0o600))
+
+	cfg, err := loadConfig([]string{"-colors"})
+	require.NoError(t, err, "an invalid discovered file falls back to the defaults")
+	assert.Equal(t, "text", cfg.Output.Format)
+	assert.True(t, cfg.Prefix.Colors.Enabled, "flags still apply on top of the defaults")
+
+	_, err = loadConfig([]string{"-config", "logwrap.yaml"})
+	require.Error(t, err, "an explicit -config file must be valid")
+
+	_, err = loadConfig([]string{"-format", "bogus"})
+	require.Error(t, err, "invalid flags are not hidden by the fallback")
+}
+
+func TestLevelAtLeast(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, levelAtLeast("ERROR", "ERROR"))
+	assert.True(t, levelAtLeast("FATAL", "error"))
+	assert.True(t, levelAtLeast("warn", "INFO"))
+	assert.False(t, levelAtLeast("WARN", "ERROR"))
+	assert.False(t, levelAtLeast("DROP", "TRACE"), "the drop pseudo-level never qualifies")
+}
+
+func TestRecordLevel(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.LoadConfig("", nil)
+	require.NoError(t, err)
+	form, err := formatter.New(cfg)
+	require.NoError(t, err)
+
+	line := "--- END deploy --no-warnings code=3 ---"
+	assert.Equal(t, "WARN", recordLevel(form, processor.Record{Line: line, Stream: processor.StreamStdout}),
+		"without an explicit level, the level is detected")
+	assert.Equal(t, "ERROR", recordLevel(form, processor.Record{Line: line, Stream: processor.StreamStdout, Level: "ERROR"}),
+		"an explicit level wins over the keywords in the text")
+}
+
+func TestPIDFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cmd.pid")
+	require.NoError(t, os.WriteFile(path, []byte("999\n"), 0o600))
+
+	pids, err := createPIDFile(path)
+	require.NoError(t, err)
+	assert.NoFileExists(t, path, "a stale PID file is removed, and no empty one takes its place")
+
+	require.NoError(t, pids.write(1234))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1234\n", string(content))
+
+	pids.remove()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the PID file is removed and no temporary file is left")
+
+	pids, err = createPIDFile(path)
+	require.NoError(t, err)
+	pids.remove()
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a command that never started leaves nothing behind")
+
+	_, err = createPIDFile(dir)
+	require.Error(t, err, "a directory cannot be a PID file")
+}
+
+func TestCheckRoot(t *testing.T) {
+	t.Parallel()
+
+	root := func() int { return 0 }
+	user := func() int { return 1000 }
+
+	path := filepath.Join(t.TempDir(), "logwrap.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("execution:\n  disallow_root: true\n"), 0o600))
+
+	cfg, err := config.LoadConfig(path, []string{"-config", path})
+	require.NoError(t, err)
+	require.ErrorIs(t, checkRoot(cfg, root), apperrors.ErrRunAsRoot)
+	assert.NoError(t, checkRoot(cfg, user), "non-root users are not affected")
+
+	cfg, err = config.LoadConfig(path, []string{"-config", path, "-allow-root"})
+	require.NoError(t, err)
+	assert.NoError(t, checkRoot(cfg, root), "-allow-root overrides disallow_root")
+
+	cfg, err = config.LoadConfig("", []string{})
+	require.NoError(t, err)
+	assert.NoError(t, checkRoot(cfg, root), "root is allowed by default")
+}
+
+func TestRetryStart(t *testing.T) {
+	t.Parallel()
+
+	errNotMounted := errors.New("binary not found")
+	var slept []time.Duration
+	sleep := func(d time.Duration) { slept = append(slept, d) }
+
+	// A fake command that fails to start twice, then starts.
+	var attempts []int
+	start := func(attempt int) error {
+		attempts = append(attempts, attempt)
+		if len(attempts) <= 2 {
+			return errNotMounted
+		}
+		return nil
+	}
+
+	require.NoError(t, retryStart(3, time.Second, sleep, start))
+	assert.Equal(t, []int{0, 1, 2}, attempts)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, slept)
+
+	attempts, slept = nil, nil
+	require.ErrorIs(t, retryStart(1, time.Second, sleep, start), errNotMounted,
+		"the last error is returned once retries are exhausted")
+	assert.Equal(t, []int{0, 1}, attempts)
+	assert.Len(t, slept, 1)
+
+	attempts, slept = nil, nil
+	require.ErrorIs(t, retryStart(0, time.Second, sleep, start), errNotMounted)
+	assert.Equal(t, []int{0}, attempts, "no retries by default")
+	assert.Empty(t, slept)
+}
+
+// fakeExecutor is a commandExecutor that runs nothing: its streams are
+// fixed strings and Wait returns at once, or once Stop or Kill is called
+// when blockUntilStopped is set.
+type fakeExecutor struct {
+	stdout, stderr    string
+	exitCode          int
+	coreDumped        bool
+	startErr          error
+	blockUntilStopped bool
+
+	stopOnce  sync.Once
+	stopped   chan struct{}
+	stopCalls atomic.Int32
+	cleanedUp atomic.Bool
+}
+
+func newFakeExecutor(exitCode int) *fakeExecutor {
+	return &fakeExecutor{exitCode: exitCode, stopped: make(chan struct{})}
+}
+
+func (f *fakeExecutor) Start() error { return f.startErr }
+
+func (f *fakeExecutor) Wait() error {
+	if f.blockUntilStopped {
+		<-f.stopped
+	}
+	return nil
+}
+
+func (f *fakeExecutor) GetStreams() (io.Reader, io.Reader) {
+	return strings.NewReader(f.stdout), strings.NewReader(f.stderr)
+}
+
+func (f *fakeExecutor) PID() int                { return 4242 }
+func (f *fakeExecutor) Duration() time.Duration { return time.Second }
+func (f *fakeExecutor) GetExitCode() int        { return f.exitCode }
+func (f *fakeExecutor) StageExitCodes() []int   { return []int{f.exitCode} }
+func (f *fakeExecutor) CoreDumped() bool        { return f.coreDumped }
+func (f *fakeExecutor) IsFinished() bool        { return true }
+func (f *fakeExecutor) Cleanup()                { f.cleanedUp.Store(true) }
+
+func (f *fakeExecutor) Stop() error {
+	f.stopCalls.Add(1)
+	f.stopOnce.Do(func() { close(f.stopped) })
+	return nil
+}
+
+func (f *fakeExecutor) Kill() error { return f.Stop() }
+
+func TestRunWith_FakeExecutor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		exitCode     int
+		successCodes []int
+		expected     int
+	}{
+		{"success", 0, nil, 0},
+		{"failure is passed through", 3, nil, 3},
+		{"success exit codes apply", 3, []int{0, 3}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := config.LoadConfig("", nil)
+			require.NoError(t, err)
+			cfg.Execution.SuccessExitCodes = tt.successCodes
+
+			fake := newFakeExecutor(tt.exitCode)
+			newExecutor := func([][]string, executor.PipelinePolicy) (commandExecutor, error) {
+				return fake, nil
+			}
+			assert.Equal(t, tt.expected, runWith(cfg, [][]string{{"fake"}}, "", nil, newExecutor, nil))
+			assert.True(t, fake.cleanedUp.Load(), "the executor is cleaned up")
+		})
+	}
+}
+
+func TestRunWith_StartRetryCreatesNewExecutor(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.LoadConfig("", nil)
+	require.NoError(t, err)
+	cfg.Execution.StartRetries = 1
+	cfg.Execution.StartRetryDelay = time.Millisecond
+
+	failing := newFakeExecutor(0)
+	failing.startErr = apperrors.ErrCommandNotFound
+	started := newFakeExecutor(0)
+	executors := []*fakeExecutor{failing, started}
+	newExecutor := func([][]string, executor.PipelinePolicy) (commandExecutor, error) {
+		next := executors[0]
+		executors = executors[1:]
+		return next, nil
+	}
+
+	assert.Equal(t, 0, runWith(cfg, [][]string{{"fake"}}, "", nil, newExecutor, nil))
+	assert.Empty(t, executors, "a new executor is created for the retry")
+	assert.True(t, failing.cleanedUp.Load())
+	assert.True(t, started.cleanedUp.Load())
+}
+
+func TestWaitForCommandOrSignal_SignalStopsCommand(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.LoadConfig("", nil)
+	require.NoError(t, err)
+	form, err := formatter.New(cfg)
+	require.NoError(t, err)
+	proc := processor.New(form, io.Discard)
+
+	fake := newFakeExecutor(0)
+	fake.blockUntilStopped = true
+	sigChan := make(chan os.Signal, 1)
+	sigChan <- syscall.SIGTERM
+
+	sig, cmdErr := waitForCommandOrSignal(fake, proc, sigChan)
+	require.NoError(t, cmdErr)
+	assert.Equal(t, syscall.SIGTERM, sig)
+	assert.Equal(t, int32(1), fake.stopCalls.Load(), "the command is stopped gracefully, without a kill")
+
+	explain := &exitExplanation{}
+	assert.Equal(t, exitCodeSIGTERM, determineExitCode(fake, sig, cmdErr, []int{0}, explain),
+		"the signal decides the exit code, not the command's own")
+}
+
+func TestDetermineExitCode_CoreDumped(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeExecutor(134)
+	fake.coreDumped = true
+	explain := &exitExplanation{}
+	assert.Equal(t, 134, determineExitCode(fake, nil, nil, []int{0}, explain))
+	assert.Equal(t, []string{
+		"command exited with code 134",
+		"the command was killed by a signal and dumped core",
+		"code 134 is not listed in success_exit_codes [0]: kept as 134",
+	}, explain.steps)
+}
+
+func TestParseExitHook(t *testing.T) {
+	t.Parallel()
+
+	hook, err := parseExitHook(`notify --title "build done"`, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"notify", "--title", "build done"}, hook.args)
+
+	hook, err = parseExitHook("", 0)
+	require.NoError(t, err)
+	assert.Empty(t, hook.args, "an empty command disables the hook")
+
+	_, err = parseExitHook(`notify "unterminated`, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid on_exit command")
+}
+
+func TestReadCommandStdin(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		command  []string
+		expected []string
+		err      error
+	}{
+		{"quoted argument", `echo "hello world"` + "\n", nil, []string{"echo", "hello world"}, nil},
+		{"no shell", `echo a; rm -rf "$HOME" | cat`, nil, []string{"echo", "a;", "rm", "-rf", "$HOME", "|", "cat"}, nil},
+		{"single quotes and escapes", `printf '%s\n' it\'s`, nil, []string{"printf", `%s\n`, "it's"}, nil},
+		{"empty", "", nil, nil, apperrors.ErrEmptyCommandStdin},
+		{"blank", " \n\t\n", nil, nil, apperrors.ErrEmptyCommandStdin},
+		{"unterminated quote", `echo "oops`, nil, nil, apperrors.ErrUnterminatedQuote},
+		{"command on the command line", "echo hi", []string{"ls"}, nil, apperrors.ErrCommandStdinWithCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			args, err := readCommandStdin(strings.NewReader(tt.input), tt.command)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, args)
+		})
+	}
+}
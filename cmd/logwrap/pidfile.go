@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// pidFilePerm is the permission of the file written by execution.pid_file.
+// Other users may read it, as init scripts commonly do.
+const pidFilePerm = 0o644
+
+// pidFile is the file configured by execution.pid_file. An empty path
+// disables it.
+type pidFile struct {
+	path string
+	tmp  *os.File // written and renamed to path once the command has started
+}
+
+// createPIDFile prepares the PID file before the command is started, so
+// that a bad path fails the run before anything executes. The PID is
+// written to a temporary file next to path, which only replaces path once
+// the command has started: scripts polling for the file never read it
+// empty, even while start attempts are retried. A PID file left by an
+// earlier run is removed, so that its PID is not mistaken for the new one.
+func createPIDFile(path string) (*pidFile, error) {
+	if path == "" {
+		return &pidFile{}, nil
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return nil, fmt.Errorf("failed to create PID file %s: is a directory", path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PID file %s: %w", path, err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to remove stale PID file %s: %w", path, err)
+	}
+	return &pidFile{path: path, tmp: tmp}, nil
+}
+
+// write records pid, followed by a newline, and moves the file into place.
+func (p *pidFile) write(pid int) error {
+	if p.tmp == nil {
+		return nil
+	}
+	tmp := p.tmp
+	p.tmp = nil
+	_, err := tmp.WriteString(strconv.Itoa(pid) + "\n")
+	if err == nil {
+		err = tmp.Chmod(pidFilePerm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write PID file %s: %w", p.path, err)
+	}
+	return nil
+}
+
+// remove deletes the PID file once the command has exited, or the unused
+// temporary file if the command never started. Removal is best effort: a
+// file already removed by someone else is not an error.
+func (p *pidFile) remove() {
+	if p.path == "" {
+		return
+	}
+	if p.tmp != nil {
+		_ = p.tmp.Close()
+		_ = os.Remove(p.tmp.Name())
+		return
+	}
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove PID file: %v\n", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+)
+
+// rawCapture holds the files configured by execution.raw_stdout_file and
+// execution.raw_stderr_file. A nil file disables capture of that stream.
+type rawCapture struct {
+	stdout *os.File
+	stderr *os.File
+}
+
+// openRawCapture opens the raw capture files, which are appended to like
+// file sinks. Open it before starting the command so that a bad path fails
+// the run before anything executes.
+func openRawCapture(cfg *config.Config) (*rawCapture, error) {
+	capture := &rawCapture{}
+	var err error
+	if path := cfg.Execution.RawStdoutFile; path != "" {
+		if capture.stdout, err = openRawFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if path := cfg.Execution.RawStderrFile; path != "" {
+		if capture.stderr, err = openRawFile(path); err != nil {
+			capture.close()
+			return nil, err
+		}
+	}
+	return capture, nil
+}
+
+func openRawFile(path string) (*os.File, error) {
+	//nolint:gosec // the path comes from the user's own configuration
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, sinkFilePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open raw capture file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// wrap returns the command's streams with every byte read from them also
+// copied, unmodified, to the capture files. The copy is made as the
+// processor reads, so the raw file and the formatted output see the same
+// bytes.
+func (c *rawCapture) wrap(stdout, stderr io.Reader) (io.Reader, io.Reader) {
+	return teeStream(stdout, c.stdout), teeStream(stderr, c.stderr)
+}
+
+func (c *rawCapture) close() {
+	for _, f := range []*os.File{c.stdout, c.stderr} {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+}
+
+// teeStream returns r copying to file, or r itself when file is nil.
+func teeStream(r io.Reader, file *os.File) io.Reader {
+	if file == nil {
+		return r
+	}
+	return teeReadCloser{Reader: io.TeeReader(r, file), source: r}
+}
+
+// teeReadCloser keeps the source reader closable through the tee, so that
+// stopping the processor still unblocks a pending read.
+type teeReadCloser struct {
+	io.Reader
+	source io.Reader
+}
+
+func (t teeReadCloser) Close() error {
+	if c, ok := t.source.(io.Closer); ok {
+		return c.Close() //nolint:wrapcheck // passed through unchanged for the processor
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+)
+
+// restarter runs a command again when it fails, as configured by
+// execution.max_restarts: each restart waits twice as long as the previous
+// one within the restart window, and it gives up once the command has been
+// restarted max times within the window.
+type restarter struct {
+	max        int
+	window     time.Duration // 0 counts every restart
+	backoff    time.Duration
+	maxBackoff time.Duration
+	now        func() time.Time
+	// wait sleeps for the backoff delay and returns the signal that
+	// interrupted it, if any.
+	wait func(time.Duration) os.Signal
+}
+
+// newRestarter returns the restarter configured by cfg.
+func newRestarter(cfg *config.Config) *restarter {
+	return &restarter{
+		max:        cfg.Execution.MaxRestarts,
+		window:     cfg.Execution.RestartWindow,
+		backoff:    cfg.Execution.RestartBackoff,
+		maxBackoff: cfg.Execution.RestartBackoffMax,
+		now:        time.Now,
+		wait:       waitOrSignal,
+	}
+}
+
+// run calls runOnce until it returns 0 or a final run, logwrap is
+// interrupted, or the restart limit is reached, and returns the last exit
+// code. runOnce reports a run as final when restarting it is pointless:
+// logwrap itself received SIGINT or SIGTERM, or its output was closed.
+// A command that exits with 130 or 143 on its own is restarted like any
+// other failure.
+func (r *restarter) run(runOnce func() (code int, final bool)) int {
+	var restarts []time.Time // restarts within the window, oldest first
+	for {
+		code, final := runOnce()
+		if code == 0 || final || r.max == 0 {
+			return code
+		}
+
+		now := r.now()
+		for len(restarts) > 0 && r.window > 0 && now.Sub(restarts[0]) >= r.window {
+			restarts = restarts[1:]
+		}
+		if len(restarts) >= r.max {
+			fmt.Fprintf(os.Stderr, "Error: command exited with code %d after %d restarts%s, giving up\n",
+				code, len(restarts), r.withinWindow())
+			return code
+		}
+
+		delay := r.delay(len(restarts))
+		fmt.Fprintf(os.Stderr, "Warning: command exited with code %d, restarting in %v (restart %d of %d%s)\n",
+			code, delay, len(restarts)+1, r.max, r.withinWindow())
+		if sig := r.wait(delay); sig != nil {
+			return signalExitCode(sig)
+		}
+		restarts = append(restarts, r.now())
+	}
+}
+
+// delay returns the backoff before the restart that follows n restarts
+// within the window: the base backoff doubled n times, at most maxBackoff.
+func (r *restarter) delay(n int) time.Duration {
+	delay := r.backoff
+	for range n {
+		if r.maxBackoff > 0 && delay >= r.maxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	if r.maxBackoff > 0 && delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
+
+// withinWindow describes the restart window for diagnostics.
+func (r *restarter) withinWindow() string {
+	if r.window == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" within %v", r.window)
+}
+
+// waitOrSignal sleeps for d, or until logwrap receives SIGINT or SIGTERM,
+// which it returns.
+func waitOrSignal(d time.Duration) os.Signal {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case sig := <-sigChan:
+		return sig
+	case <-timer.C:
+		return nil
+	}
+}
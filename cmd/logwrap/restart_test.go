@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a restarter clock advanced by the restarter's waits.
+type fakeClock struct {
+	now    time.Time
+	waited []time.Duration
+}
+
+func (c *fakeClock) wait(d time.Duration) os.Signal {
+	c.waited = append(c.waited, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func newTestRestarter(maxRestarts int, window time.Duration) (*restarter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return &restarter{
+		max:        maxRestarts,
+		window:     window,
+		backoff:    time.Second,
+		maxBackoff: 5 * time.Second,
+		now:        func() time.Time { return clock.now },
+		wait:       clock.wait,
+	}, clock
+}
+
+func TestRestarter_BacksOffThenGivesUp(t *testing.T) {
+	t.Parallel()
+
+	r, clock := newTestRestarter(4, time.Minute)
+	runs := 0
+	code := r.run(func() (int, bool) {
+		runs++
+		return 3, false
+	})
+
+	assert.Equal(t, 3, code, "the last run's code is reported")
+	assert.Equal(t, 5, runs, "the first run and 4 restarts")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, clock.waited,
+		"the backoff doubles up to its maximum")
+}
+
+func TestRestarter_StopsOnSuccess(t *testing.T) {
+	t.Parallel()
+
+	r, clock := newTestRestarter(4, time.Minute)
+	codes := []int{1, 1, 0}
+	code := r.run(func() (int, bool) {
+		next := codes[0]
+		codes = codes[1:]
+		return next, false
+	})
+
+	assert.Equal(t, 0, code)
+	assert.Empty(t, codes)
+	assert.Len(t, clock.waited, 2)
+}
+
+func TestRestarter_WindowForgetsOldRestarts(t *testing.T) {
+	t.Parallel()
+
+	// Each run lasts 10s, so with a 15s window at most one earlier restart
+	// is ever within the window: the backoff stays low and the limit of 2
+	// is never reached.
+	r, clock := newTestRestarter(2, 15*time.Second)
+	runs := 0
+	code := r.run(func() (int, bool) {
+		runs++
+		clock.now = clock.now.Add(10 * time.Second)
+		if runs == 6 {
+			return 0, false
+		}
+		return 1, false
+	})
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, 6, runs)
+	for _, d := range clock.waited {
+		assert.LessOrEqual(t, d, 2*time.Second)
+	}
+}
+
+func TestRestarter_NoRestarts(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		max      int
+		exitCode int
+		final    bool
+	}{
+		{"disabled", 0, 1, false},
+		{"success", 3, 0, false},
+		{"interrupted", 3, exitCodeSIGINT, true},
+		{"terminated", 3, exitCodeSIGTERM, true},
+		{"output closed", 3, 141, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, clock := newTestRestarter(tc.max, time.Minute)
+			runs := 0
+			code := r.run(func() (int, bool) {
+				runs++
+				return tc.exitCode, tc.final
+			})
+			assert.Equal(t, tc.exitCode, code)
+			assert.Equal(t, 1, runs)
+			assert.Empty(t, clock.waited)
+		})
+	}
+}
+
+func TestRestarter_RestartsSignalCodesOfTheCommand(t *testing.T) {
+	t.Parallel()
+
+	// A command that exits with 130 on its own was not stopped by a
+	// signal sent to logwrap, and is restarted.
+	r, clock := newTestRestarter(2, time.Minute)
+	runs := 0
+	code := r.run(func() (int, bool) {
+		runs++
+		return exitCodeSIGINT, false
+	})
+
+	assert.Equal(t, exitCodeSIGINT, code)
+	assert.Equal(t, 3, runs, "the first run and 2 restarts")
+	assert.Len(t, clock.waited, 2)
+}
+
+func TestRestarter_SignalDuringBackoff(t *testing.T) {
+	t.Parallel()
+
+	r, _ := newTestRestarter(3, time.Minute)
+	r.wait = func(time.Duration) os.Signal { return syscall.SIGTERM }
+	runs := 0
+	code := r.run(func() (int, bool) {
+		runs++
+		return 1, false
+	})
+
+	assert.Equal(t, exitCodeSIGTERM, code)
+	assert.Equal(t, 1, runs, "no restart after the signal")
+}
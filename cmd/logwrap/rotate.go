@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rotatingFile is a file sink output for output.rotate_time. Before each
+// write it checks whether the period of the open file has ended; if so the
+// file is closed, renamed with the period as a suffix and replaced by a
+// new file at the same path, so tail -F keeps following it.
+type rotatingFile struct {
+	path   string
+	hourly bool // hourly periods; daily otherwise
+	loc    *time.Location
+	now    func() time.Time
+
+	rename   func(oldpath, newpath string) error
+	warnings io.Writer // receives rotation failures
+
+	mu    sync.Mutex
+	file  *os.File  // nil after a failed rotation, until it is opened again
+	start time.Time // start of the period the open file covers
+	first time.Time // start of the period of the file's first lines, which names it
+}
+
+// openRotatingFile opens path for appending. An existing file counts as
+// covering the period of its last modification, so a file left by an
+// earlier run in a past period is rotated on the first write.
+func openRotatingFile(path, period string, loc *time.Location, now func() time.Time) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:     path,
+		hourly:   period == "hourly",
+		loc:      loc,
+		now:      now,
+		rename:   os.Rename,
+		warnings: os.Stderr,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.start = r.periodStart(r.now())
+	if info, err := r.file.Stat(); err == nil && info.Size() > 0 {
+		r.start = r.periodStart(info.ModTime())
+	}
+	r.first = r.start
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	//nolint:gosec // the path comes from the user's own configuration
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, sinkFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", r.path, err)
+	}
+	r.file = file
+	return nil
+}
+
+// periodStart returns the start of the hour or day t falls in, in r.loc.
+func (r *rotatingFile) periodStart(t time.Time) time.Time {
+	t = t.In(r.loc)
+	hour := 0
+	if r.hourly {
+		hour = t.Hour()
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, r.loc)
+}
+
+// suffix names the period starting at start, e.g. 2024-01-15T10.
+func (r *rotatingFile) suffix(start time.Time) string {
+	if r.hourly {
+		return start.Format("2006-01-02T15")
+	}
+	return start.Format("2006-01-02")
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if start := r.periodStart(r.now()); r.file == nil || !start.Equal(r.start) {
+		if err := r.rotate(start); err != nil {
+			return 0, err
+		}
+	}
+	return r.file.Write(p) //nolint:wrapcheck // reported by the processor as a sink failure
+}
+
+// rotate closes the open file, renames it after the period of its first
+// lines and opens a new one covering the period starting at start. A name
+// already taken, e.g. after the clock was set back, gets a numbered suffix
+// instead of being overwritten. If the file cannot be closed or renamed, a
+// warning is printed and the file at path is opened again: lines keep
+// going to it, and rotation is tried again at the end of the new period,
+// still under the name of the period it was started in. Only a failure to
+// open the file is returned; the open is then retried on the next write.
+func (r *rotatingFile) rotate(start time.Time) error {
+	if r.file != nil {
+		err := r.file.Close()
+		r.file = nil
+		if err != nil {
+			r.warn(fmt.Errorf("failed to close %s for rotation: %w", r.path, err))
+		} else {
+			r.moveAside()
+		}
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.start = start
+	if info, err := r.file.Stat(); err != nil || info.Size() == 0 {
+		r.first = start
+	}
+	return nil
+}
+
+// moveAside renames the closed file at path after r.first.
+func (r *rotatingFile) moveAside() {
+	target := r.path + "." + r.suffix(r.first)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(target); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		target = r.path + "." + r.suffix(r.first) + "." + strconv.Itoa(i)
+	}
+	if err := r.rename(r.path, target); err != nil {
+		r.warn(fmt.Errorf("failed to rotate %s: %w", r.path, err))
+	}
+}
+
+func (r *rotatingFile) warn(err error) {
+	_, _ = fmt.Fprintf(r.warnings, "Warning: %v\n", err)
+}
+
+// Close closes the open file.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close() //nolint:wrapcheck // closing errors are ignored by the caller
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockClock is a settable clock for rotatingFile.
+type mockClock struct{ t time.Time }
+
+func (c *mockClock) now() time.Time { return c.t }
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotatingFile_Hourly(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	clock := &mockClock{t: time.Date(2024, 1, 15, 10, 59, 58, 0, time.UTC)}
+	r, err := openRotatingFile(path, "hourly", time.UTC, clock.now)
+	require.NoError(t, err)
+
+	_, err = r.Write([]byte("before\n"))
+	require.NoError(t, err)
+	clock.t = clock.t.Add(time.Second)
+	_, err = r.Write([]byte("still before\n"))
+	require.NoError(t, err)
+	assert.NoFileExists(t, path+".2024-01-15T10", "no rotation within the hour")
+
+	clock.t = clock.t.Add(2 * time.Second)
+	_, err = r.Write([]byte("after\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	assert.Equal(t, "before\nstill before\n", readFile(t, path+".2024-01-15T10"))
+	assert.Equal(t, "after\n", readFile(t, path), "a new file is started at the same path")
+}
+
+func TestRotatingFile_DailyUsesLocation(t *testing.T) {
+	t.Parallel()
+
+	// 21:30 and 22:30 UTC are the same day in UTC, but straddle midnight
+	// two hours east of it.
+	east := time.FixedZone("UTC+2", 2*60*60)
+	for _, tt := range []struct {
+		loc     *time.Location
+		rotated string
+	}{
+		{time.UTC, ""},
+		{east, "2024-01-15"},
+	} {
+		path := filepath.Join(t.TempDir(), "app.log")
+		clock := &mockClock{t: time.Date(2024, 1, 15, 21, 30, 0, 0, time.UTC)}
+		r, err := openRotatingFile(path, "daily", tt.loc, clock.now)
+		require.NoError(t, err)
+
+		_, err = r.Write([]byte("one\n"))
+		require.NoError(t, err)
+		clock.t = clock.t.Add(time.Hour)
+		_, err = r.Write([]byte("two\n"))
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+
+		if tt.rotated == "" {
+			assert.Equal(t, "one\ntwo\n", readFile(t, path), tt.loc.String())
+			continue
+		}
+		assert.Equal(t, "one\n", readFile(t, path+"."+tt.rotated), tt.loc.String())
+		assert.Equal(t, "two\n", readFile(t, path), tt.loc.String())
+	}
+}
+
+func TestRotatingFile_ExistingFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("old run\n"), 0o600))
+	old := time.Date(2024, 1, 15, 8, 15, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, old, old))
+	require.NoError(t, os.WriteFile(path+".2024-01-15T08", []byte("taken\n"), 0o600))
+
+	clock := &mockClock{t: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)}
+	r, err := openRotatingFile(path, "hourly", time.UTC, clock.now)
+	require.NoError(t, err)
+	_, err = r.Write([]byte("new run\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	assert.Equal(t, "old run\n", readFile(t, path+".2024-01-15T08.1"),
+		"a file from a past period is rotated without overwriting an existing one")
+	assert.Equal(t, "taken\n", readFile(t, path+".2024-01-15T08"))
+	assert.Equal(t, "new run\n", readFile(t, path))
+}
+
+func TestRotatingFile_RenameFailureKeepsWriting(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	clock := &mockClock{t: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)}
+	r, err := openRotatingFile(path, "hourly", time.UTC, clock.now)
+	require.NoError(t, err)
+	var warnings strings.Builder
+	r.warnings = &warnings
+	r.rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EACCES}
+	}
+
+	_, err = r.Write([]byte("one\n"))
+	require.NoError(t, err)
+	clock.t = clock.t.Add(time.Hour)
+	for _, line := range []string{"two\n", "three\n"} {
+		_, err = r.Write([]byte(line))
+		require.NoError(t, err, "a failed rotation does not fail the write")
+	}
+	assert.Equal(t, "one\ntwo\nthree\n", readFile(t, path), "no line is lost")
+	assert.Equal(t, 1, strings.Count(warnings.String(), "Warning: failed to rotate "),
+		"the failure is reported once, not on every write: %s", warnings.String())
+	assert.NoFileExists(t, path+".2024-01-15T10")
+
+	// The next period rotates the file as usual, named after the period
+	// its first line was written in.
+	r.rename = os.Rename
+	clock.t = clock.t.Add(time.Hour)
+	_, err = r.Write([]byte("four\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "one\ntwo\nthree\n", readFile(t, path+".2024-01-15T10"))
+	assert.NoFileExists(t, path+".2024-01-15T11")
+	assert.Equal(t, "four\n", readFile(t, path))
+}
+
+func TestRotatingFile_OpenFailureRetries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	clock := &mockClock{t: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)}
+	r, err := openRotatingFile(path, "hourly", time.UTC, clock.now)
+	require.NoError(t, err)
+	r.rename = func(oldpath, newpath string) error {
+		if err := os.Rename(oldpath, newpath); err != nil {
+			return err
+		}
+		return os.Mkdir(oldpath, 0o755) // a directory cannot be opened for writing
+	}
+
+	_, err = r.Write([]byte("one\n"))
+	require.NoError(t, err)
+	clock.t = clock.t.Add(time.Hour)
+	_, err = r.Write([]byte("two\n"))
+	require.Error(t, err, "the new file cannot be opened")
+
+	require.NoError(t, os.Remove(path))
+	_, err = r.Write([]byte("three\n"))
+	require.NoError(t, err, "the open is retried on the next write")
+	require.NoError(t, r.Close())
+	assert.Equal(t, "one\n", readFile(t, path+".2024-01-15T10"))
+	assert.Equal(t, "three\n", readFile(t, path))
+}
+
+func TestRotatingFile_CloseFailureReopens(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	clock := &mockClock{t: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)}
+	r, err := openRotatingFile(path, "hourly", time.UTC, clock.now)
+	require.NoError(t, err)
+	var warnings strings.Builder
+	r.warnings = &warnings
+
+	_, err = r.Write([]byte("one\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.file.Close(), "closing it again at rotation fails")
+	clock.t = clock.t.Add(time.Hour)
+	_, err = r.Write([]byte("two\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	assert.Contains(t, warnings.String(), "Warning: failed to close ")
+	assert.Equal(t, "one\ntwo\n", readFile(t, path), "the file is opened again")
+}
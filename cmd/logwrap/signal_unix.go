@@ -0,0 +1,31 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	signalExitCodeBase = 128                     // UNIX convention: 128 + signal number
+	exitCodeSIGINT     = signalExitCodeBase + 2  // SIGINT
+	exitCodeSIGTERM    = signalExitCodeBase + 15 // SIGTERM
+)
+
+// signalExitCode returns the exit code reported when logwrap is stopped by
+// sig, following the shell convention of 128 + signal number.
+func signalExitCode(sig os.Signal) int {
+	switch sig {
+	case syscall.SIGINT:
+		return exitCodeSIGINT
+	case syscall.SIGTERM:
+		return exitCodeSIGTERM
+	default:
+		if s, ok := sig.(syscall.Signal); ok {
+			return signalExitCodeBase + int(s)
+		}
+
+		return 1
+	}
+}
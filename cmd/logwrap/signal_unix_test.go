@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignalExitCode(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 130, signalExitCode(syscall.SIGINT))
+	assert.Equal(t, 143, signalExitCode(syscall.SIGTERM))
+	assert.Equal(t, 129, signalExitCode(syscall.SIGHUP))
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// statusControlCExit is STATUS_CONTROL_C_EXIT, the exit code Windows reports
+// for a console program ended by Ctrl+C or Ctrl+Break.
+const statusControlCExit uint32 = 0xC000013A
+
+var (
+	exitCodeSIGINT  = statusExitCode(statusControlCExit) // Ctrl+C / Ctrl+Break
+	exitCodeSIGTERM = statusExitCode(statusControlCExit) // console closed, logoff or shutdown
+)
+
+// statusExitCode converts an exit status the way [os.ProcessState.ExitCode]
+// does. As a constant, 0xC000013A overflows int on 32-bit Windows, where
+// ExitCode reports it as a negative number.
+func statusExitCode(status uint32) int {
+	return int(status) //nolint:gosec // wraps on 32-bit Windows, like ExitCode
+}
+
+// signalExitCode returns the exit code reported when logwrap is stopped by
+// sig. Windows has no 128 + signal number convention: the console control
+// events Go delivers as SIGINT and SIGTERM both map to STATUS_CONTROL_C_EXIT.
+func signalExitCode(sig os.Signal) int {
+	switch sig {
+	case syscall.SIGINT, syscall.SIGTERM:
+		return exitCodeSIGINT
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignalExitCode(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, statusExitCode(statusControlCExit), signalExitCode(os.Interrupt))
+	assert.Equal(t, statusExitCode(statusControlCExit), signalExitCode(syscall.SIGTERM))
+	assert.Equal(t, 1, signalExitCode(syscall.SIGHUP))
+}
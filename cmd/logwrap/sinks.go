@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+	"github.com/sgaunet/logwrap/pkg/formatter"
+	"github.com/sgaunet/logwrap/pkg/processor"
+)
+
+// sinkFilePerm is the permission of log files created by file sinks.
+const sinkFilePerm = 0o600
+
+// openSinks creates a formatter and writer for each configured sink. The
+// formatters are returned as well so the caller can set the child PID.
+// The returned close function closes the files and journal connections
+// opened for file and journald sinks. File sinks are rotated with
+// output.rotate_time. A journald sink whose journal cannot be reached,
+// e.g. on a host without systemd, is reported with a warning and writes to
+// stderr if it has fallback_to_stderr; otherwise it is left out.
+func openSinks(
+	cfg *config.Config, opts ...formatter.Option,
+) ([]processor.Sink, []*formatter.DefaultFormatter, func(), error) {
+	var sinks []processor.Sink
+	var formatters []*formatter.DefaultFormatter
+	var files []io.Closer
+	closeFiles := func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}
+
+	for i, sinkCfg := range cfg.Output.Sinks {
+		var output io.Writer
+		switch sinkCfg.Type {
+		case "stdout":
+			output = os.Stdout
+		case "stderr":
+			output = os.Stderr
+		case "file":
+			if period := cfg.Output.RotateTime; period != "" {
+				file, err := openRotatingFile(sinkCfg.Path, period, timestampLocation(cfg), time.Now)
+				if err != nil {
+					closeFiles()
+					return nil, nil, nil, fmt.Errorf("sink %d: %w", i+1, err)
+				}
+				files = append(files, file)
+				output = file
+				break
+			}
+			//nolint:gosec // the path comes from the user's own configuration
+			file, err := os.OpenFile(sinkCfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, sinkFilePerm)
+			if err != nil {
+				closeFiles()
+				return nil, nil, nil, fmt.Errorf("sink %d: failed to open %s: %w", i+1, sinkCfg.Path, err)
+			}
+			files = append(files, file)
+			output = file
+		case "journald":
+			journal, err := openJournal(sinkCfg.Path)
+			if err != nil {
+				if !sinkCfg.FallbackToStderr {
+					fmt.Fprintf(os.Stderr, "Warning: sink %d: %v, dropping its lines\n", i+1, err)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "Warning: sink %d: %v, writing its lines to stderr\n", i+1, err)
+				sinkCfg.Type = "stderr"
+				output = os.Stderr
+				break
+			}
+			files = append(files, journal)
+			output = journal
+		}
+
+		form, err := formatter.New(sinkConfig(cfg, sinkCfg), opts...)
+		if err != nil {
+			closeFiles()
+			return nil, nil, nil, fmt.Errorf("sink %d: %w", i+1, err)
+		}
+		formatters = append(formatters, form)
+		sink := processor.Sink{Name: sinkCfg.Name, Formatter: form, Output: output}
+		if sinkCfg.FallbackToStderr {
+			sink.Fallback = os.Stderr
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, formatters, closeFiles, nil
+}
+
+// sinkConfig returns a copy of cfg with the sink's format and color
+// overrides applied. File sinks default to no colors, or to colors with
+// output.force_color_file; journald sinks always use the journal format.
+func sinkConfig(cfg *config.Config, sinkCfg config.SinkConfig) *config.Config {
+	c := *cfg
+	if sinkCfg.Format != "" {
+		c.Output.Format = sinkCfg.Format
+	}
+	if sinkCfg.Type == "journald" {
+		c.Output.Format = formatter.JournalFormat
+	}
+	switch {
+	case sinkCfg.Colors != nil:
+		c.Prefix.Colors.Enabled = *sinkCfg.Colors
+	case sinkCfg.Type == "file":
+		c.Prefix.Colors.Enabled = cfg.Output.ForceColorFile
+	}
+	return &c
+}
+
+// timestampLocation returns the time zone of the prefix timestamps: UTC
+// with prefix.timestamp.utc, local time otherwise.
+func timestampLocation(cfg *config.Config) *time.Location {
+	if cfg.Prefix.Timestamp.UTC {
+		return time.UTC
+	}
+	return time.Local
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+)
+
+// printLevelSummary writes the line counts of output.level_summary to w on
+// one line, most severe level first. Levels outside levelSeverity, such as
+// the drop pseudo-level, follow in alphabetical order.
+func printLevelSummary(w io.Writer, counts map[string]int) {
+	levels := slices.Clone(levelSeverity)
+	slices.Reverse(levels)
+	var others []string
+	for _, level := range slices.Sorted(maps.Keys(counts)) {
+		if !slices.Contains(levels, level) {
+			others = append(others, level)
+		}
+	}
+
+	var parts []string
+	for _, level := range append(levels, others...) {
+		if n := counts[level]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, level))
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{"no lines"}
+	}
+	_, _ = fmt.Fprintf(w, "Level summary: %s\n", strings.Join(parts, ", "))
+}
+
+// runSummary is the JSON document written to output.summary_fd after each
+// run.
+type runSummary struct {
+	Command    string         `json:"command"`
+	ExitCode   int            `json:"exit_code"`
+	DurationMS int64          `json:"duration_ms"`
+	Lines      int64          `json:"lines"`
+	Bytes      int64          `json:"bytes"`
+	Levels     map[string]int `json:"levels,omitempty"`
+	// CoreDumped is set when the command was killed by a signal and
+	// dumped core; it is omitted otherwise.
+	CoreDumped bool `json:"core_dumped,omitempty"`
+	// LineLengths is omitted when no line was read.
+	LineLengths *lineLengthSummary `json:"line_lengths,omitempty"`
+}
+
+// lineLengthSummary is the line length histogram of a run summary, in
+// bytes. Percentiles are estimates, at most 1/16 above the exact length.
+type lineLengthSummary struct {
+	Min int     `json:"min"`
+	Max int     `json:"max"`
+	Avg float64 `json:"avg"`
+	P50 int     `json:"p50"`
+	P90 int     `json:"p90"`
+	P99 int     `json:"p99"`
+}
+
+// newRunSummary collects the summary of a run from its processor.
+func newRunSummary(command string, exitCode int, duration time.Duration, proc *processor.Processor) runSummary {
+	summary := runSummary{
+		Command:    command,
+		ExitCode:   exitCode,
+		DurationMS: duration.Milliseconds(),
+		Lines:      proc.LinesRead(),
+		Bytes:      proc.BytesRead(),
+		Levels:     proc.LevelCounts(),
+	}
+	if stats := proc.LineLengths(); stats != nil && stats.Count > 0 {
+		summary.LineLengths = &lineLengthSummary{
+			Min: stats.Min,
+			Max: stats.Max,
+			Avg: math.Round(stats.Mean*10) / 10,
+			P50: stats.P50,
+			P90: stats.P90,
+			P99: stats.P99,
+		}
+	}
+	return summary
+}
+
+// writeRunSummary writes summary to w as one line of JSON.
+func writeRunSummary(w io.Writer, summary runSummary) {
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write run summary: %v\n", err)
+	}
+}
+
+// summaryRecord is the record output.append_summary_record adds at the end
+// of JSON output.
+type summaryRecord struct {
+	Event    string         `json:"event"`
+	Levels   map[string]int `json:"levels"`
+	ExitCode int            `json:"exit_code"`
+}
+
+// writeSummaryRecord writes the summary record of a run that exited with
+// exitCode to the output of proc.
+func writeSummaryRecord(proc *processor.Processor, exitCode int) {
+	levels := proc.LevelCounts()
+	if levels == nil {
+		levels = map[string]int{}
+	}
+	data, err := json.Marshal(summaryRecord{Event: "summary", Levels: levels, ExitCode: exitCode})
+	if err == nil {
+		err = proc.WriteRaw(string(data))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write summary record: %v\n", err)
+	}
+}
+
+// openSummaryFD returns the file for output.summary_fd, or nil when fd is 0.
+// The descriptor must already be open, e.g. through 3>summary.json.
+func openSummaryFD(fd int) (io.Writer, error) {
+	switch fd {
+	case 0:
+		return nil, nil
+	case 1:
+		return os.Stdout, nil
+	case 2:
+		return os.Stderr, nil
+	}
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if _, err := file.Stat(); err != nil {
+		return nil, fmt.Errorf("summary file descriptor %d is not open: %w", fd, err)
+	}
+	return file, nil
+}
+
+// closeSummary closes a file returned by openSummaryFD. Stdout and stderr
+// are left open.
+func closeSummary(w io.Writer) {
+	if file, ok := w.(*os.File); ok && file != os.Stdout && file != os.Stderr {
+		_ = file.Close()
+	}
+}
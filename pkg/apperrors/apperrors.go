@@ -12,29 +12,88 @@ import "errors"
 var (
 	ErrTemplateEmpty               = errors.New("template cannot be empty")
 	ErrInvalidTemplate             = errors.New("invalid template")
+	ErrTemplateFieldUnavailable    = errors.New("template references data that is never set")
 	ErrTimestampFormatEmpty        = errors.New("timestamp format cannot be empty")
 	ErrInvalidTimestampFormat      = errors.New("invalid timestamp format")
 	ErrInvalidTimezone             = errors.New("invalid timezone")
+	ErrInvalidCacheInterval        = errors.New("invalid timestamp cache interval")
+	ErrInvalidHeartbeatInterval    = errors.New("invalid heartbeat interval")
 	ErrInvalidColor                = errors.New("invalid color")
 	ErrInvalidColorTheme           = errors.New("unknown color theme")
 	ErrInvalidUserFormat           = errors.New("invalid user format")
 	ErrInvalidPIDFormat            = errors.New("invalid PID format")
+	ErrInvalidPIDSource            = errors.New("invalid PID source")
 	ErrInvalidOutputFormat         = errors.New("invalid output format")
+	ErrInvalidSinkType             = errors.New("invalid sink type")
+	ErrInvalidPrefixWidth          = errors.New("invalid prefix width")
+	ErrInvalidLineEnding           = errors.New("invalid line ending")
+	ErrInvalidControlChars         = errors.New("invalid control characters mode")
+	ErrInvalidProgressMode         = errors.New("invalid progress mode")
+	ErrInvalidStripPattern         = errors.New("invalid strip input prefix pattern")
+	ErrInvalidStripStage           = errors.New("invalid strip input prefix stage")
+	ErrInvalidSkipPattern          = errors.New("invalid skip prefix pattern")
+	ErrInvalidBufferLines          = errors.New("invalid buffer size")
+	ErrInvalidBlankLines           = errors.New("invalid number of blank lines kept")
+	ErrInvalidContextLines         = errors.New("invalid number of context lines")
+	ErrInvalidReorderWindow        = errors.New("invalid reorder window")
+	ErrInvalidWorkers              = errors.New("invalid number of workers")
+	ErrInvalidReadAhead            = errors.New("invalid number of read-ahead lines")
+	ErrInvalidDedupeWindow         = errors.New("invalid dedupe window")
+	ErrInvalidSummaryFD            = errors.New("invalid summary file descriptor")
+	ErrSinkPathRequired            = errors.New("file sink requires a path")
+	ErrDuplicateSinkName           = errors.New("duplicate sink name")
+	ErrJournaldSinkFormat          = errors.New("journald sinks do not take a format")
+	ErrInvalidRoute                = errors.New("invalid output route")
+	ErrInvalidSeverity             = errors.New("invalid severity mapping")
+	ErrInvalidLevelRate            = errors.New("invalid line rate")
 	ErrInvalidStdoutLogLevel       = errors.New("invalid default stdout log level")
 	ErrInvalidStderrLogLevel       = errors.New("invalid default stderr log level")
 	ErrInvalidLogLevel             = errors.New("invalid log level")
 	ErrNoDetectionKeywords         = errors.New("log level has no detection keywords")
 	ErrEmptyKeyword                = errors.New("empty keyword in detection keywords")
+	ErrConflictingKeyword          = errors.New("detection keyword listed under several levels")
 	ErrDetectionDisabledWithKeywords = errors.New("detection disabled but keywords are configured")
 	ErrEmptyFilterPattern            = errors.New("empty string in filter patterns is not allowed")
 	ErrFilterLevelsWithoutDetection  = errors.New("filter include_levels/exclude_levels require detection to be enabled")
 	ErrInvalidFilterPattern          = errors.New("invalid regex in filter pattern")
 	ErrInvalidFilterLevel            = errors.New("invalid log level in filter")
+	ErrInvalidExitCode               = errors.New("invalid exit code")
+	ErrEmptyFieldName                = errors.New("extracted field name cannot be empty")
+	ErrReservedFieldName             = errors.New("extracted field name is reserved")
+	ErrInvalidExtractPattern         = errors.New("invalid extract field pattern")
+	ErrInvalidFieldType              = errors.New("invalid extract field type")
+	ErrInvalidCustomField            = errors.New("invalid custom field")
+	ErrCustomFieldRecursion          = errors.New("custom field template references a templated custom field")
+	ErrInvalidCacheSize              = errors.New("invalid level cache size")
+	ErrInvalidMaxScanBytes           = errors.New("invalid detection max scan bytes")
+	ErrInvalidLevelKey               = errors.New("invalid detection level key")
+	ErrInvalidPipelinePolicy         = errors.New("invalid pipeline exit policy")
+	ErrInvalidStartRetries           = errors.New("invalid start retry setting")
+	ErrInvalidRestartSetting         = errors.New("invalid restart setting")
+	ErrInvalidExitLevel              = errors.New("invalid exit level mapping")
+	ErrInvalidFormatErrorPolicy      = errors.New("invalid format error policy")
+	ErrFlattenWithoutPassthrough     = errors.New("flatten requires json_passthrough to be enabled")
+	ErrInvalidJSONParseFailurePolicy = errors.New("invalid JSON parse failure policy")
+	ErrParseFailureWithoutPassthrough = errors.New("on_json_parse_failure requires json_passthrough to be enabled")
+	ErrSummaryRecordWithoutJSON       = errors.New("append_summary_record requires the json output format")
+	ErrInvalidRotateTime              = errors.New("invalid rotate time")
+	ErrInvalidOnExitTimeout           = errors.New("invalid on_exit timeout")
 )
 
 // Command line errors.
 var (
 	ErrOptionRequiresValue = errors.New("option requires a value")
+	ErrEmptyPipelineStage  = errors.New("pipeline stage cannot be empty")
+	ErrInvalidKeywordFlag  = errors.New("invalid -keyword value")
+	ErrInvalidRateFlag     = errors.New("invalid -max-line-rate-per-level value")
+	ErrInvalidPrecedence   = errors.New("invalid -config-precedence value")
+	ErrUnterminatedQuote   = errors.New("unterminated quote in command line")
+	ErrUnterminatedEscape  = errors.New("command line ends with an unescaped backslash")
+	ErrBatchWithCommand    = errors.New("-batch cannot be combined with a command")
+	ErrConflictingFlags    = errors.New("conflicting flags")
+	ErrEmptyBatch          = errors.New("batch file contains no commands")
+	ErrCommandStdinWithCommand = errors.New("-command-stdin cannot be combined with a command")
+	ErrEmptyCommandStdin       = errors.New("stdin contains no command")
 )
 
 // Executor errors.
@@ -42,6 +101,10 @@ var (
 	ErrCommandEmpty      = errors.New("command cannot be empty")
 	ErrExecutorStarted   = errors.New("executor already started")
 	ErrExecutorNotStarted = errors.New("executor not started")
+	ErrExecutorCleanedUp  = errors.New("executor already cleaned up")
+	ErrCommandNotFound    = errors.New("command not found")
+	ErrCommandPermission  = errors.New("permission denied")
+	ErrCommandNotExecutable = errors.New("not executable")
 )
 
 // Processor errors.
@@ -49,6 +112,10 @@ var (
 	ErrReadersNil        = errors.New("stdout and stderr readers cannot be nil")
 	ErrProcessingErrors  = errors.New("processing errors occurred")
 	ErrProcessorTimeout  = errors.New("processor wait timeout")
+	ErrFormatFailed      = errors.New("failed to format line")
+	ErrLineDropped       = errors.New("line dropped")
+	ErrNotJSONObject     = errors.New("line is not a JSON object")
+	ErrSinkFailed        = errors.New("sink write failed")
 )
 
 // Security errors.
@@ -56,4 +123,5 @@ var (
 	ErrPathTraversal        = errors.New("path traversal not allowed")
 	ErrInvalidFileType      = errors.New("only .yaml and .yml files are allowed")
 	ErrCommandPathTraversal = errors.New("path traversal not allowed in command")
+	ErrRunAsRoot            = errors.New("refusing to run as root")
 )
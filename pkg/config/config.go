@@ -14,6 +14,9 @@
 //  5. ~/.logwrap.yaml
 //  6. Built-in defaults (lowest priority)
 //
+// The -config-precedence flag swaps CLI flags and the config file, e.g.
+// "flags,file" lets a shared config file override flags baked into scripts.
+//
 // Use [LoadConfig] to load and merge all sources, or [FindConfigFile]
 // to locate a configuration file in standard locations.
 //
@@ -21,15 +24,16 @@
 //
 // The [Config] struct is organized into sections:
 //   - Prefix: Template, timestamp format, colors, user/PID display
-//   - Output: Format (text, json, structured)
+//   - Output: Format (text, json, structured, otel) and broken pipe handling
 //   - LogLevel: Default levels and keyword-based detection rules
+//   - Execution: How the wrapped command's exit status is classified
 //
 // # Validation
 //
 // All configuration is validated before use via [Config.Validate]:
 //   - Strftime format: round-trip format/parse testing
 //   - Log levels: must be TRACE, DEBUG, INFO, WARN, ERROR, or FATAL
-//   - Output format: must be text, json, or structured
+//   - Output format: must be text, json, structured, or otel
 //   - Colors: validated against known color names when enabled
 //   - File paths: path traversal protection and extension validation
 //
@@ -60,23 +64,165 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sgaunet/logwrap/pkg/apperrors"
 	"gopkg.in/yaml.v3"
 )
 
+// defaultLevelCacheSize is the default number of cached level detections.
+const defaultLevelCacheSize = 1000
+
+// defaultStderrOnLevelMaxLines is the default number of lines held for
+// output.stderr_on_level.
+const defaultStderrOnLevelMaxLines = 10000
+
+// defaultSquashBlankLinesTo is the default number of consecutive blank
+// lines kept by output.squash_blank_lines.
+const defaultSquashBlankLinesTo = 1
+
+// defaultStartRetryDelay is the default wait between attempts to start the
+// command, see execution.start_retries.
+const defaultStartRetryDelay = time.Second
+
+// defaultOnExitTimeout is the default time limit of the execution.on_exit
+// command.
+const defaultOnExitTimeout = time.Minute
+
+// Defaults of the restart settings, see execution.max_restarts.
+const (
+	defaultRestartWindow     = time.Minute
+	defaultRestartBackoff    = time.Second
+	defaultRestartBackoffMax = time.Minute
+)
+
 // Config represents the complete configuration for logwrap.
 type Config struct {
-	Prefix   PrefixConfig   `yaml:"prefix"`
-	Output   OutputConfig   `yaml:"output"`
-	LogLevel LogLevelConfig `yaml:"log_level"`
-	Filter   FilterConfig   `yaml:"filter"`
+	Prefix    PrefixConfig    `yaml:"prefix"`
+	Output    OutputConfig    `yaml:"output"`
+	LogLevel  LogLevelConfig  `yaml:"log_level"`
+	Filter    FilterConfig    `yaml:"filter"`
+	Execution ExecutionConfig `yaml:"execution"`
 }
 
+// ExecutionConfig contains configuration for running the wrapped command.
+type ExecutionConfig struct {
+	// SuccessExitCodes lists the exit codes treated as success. A listed
+	// code makes logwrap exit 0; any other code is reported as a failure.
+	// An empty list is equivalent to [0].
+	SuccessExitCodes []int `yaml:"success_exit_codes"`
+
+	// PipelinePolicy selects how a pipeline's exit code is derived when
+	// logwrap runs with -pipeline: "last" uses the final stage's code (like
+	// a POSIX shell), "any" uses the rightmost failing stage's code (like
+	// bash's pipefail). An empty value is equivalent to "last".
+	PipelinePolicy string `yaml:"pipeline_policy"`
+
+	// DisallowRoot refuses to start the wrapped command when logwrap runs
+	// with an effective UID of 0, unless -allow-root is passed. It guards
+	// shared CI runners against accidental privileged execution and has
+	// no effect on Windows.
+	DisallowRoot bool `yaml:"disallow_root"`
+
+	// RawStdoutFile and RawStderrFile receive the command's stdout and
+	// stderr exactly as read, before any formatting, e.g. to keep the
+	// pristine program output as a CI artifact. Files are appended to.
+	// Empty disables the capture.
+	RawStdoutFile string `yaml:"raw_stdout_file"`
+	RawStderrFile string `yaml:"raw_stderr_file"`
+
+	// StartRetries is how many more times logwrap tries to start the
+	// command when starting it fails, e.g. because its binary lives on a
+	// volume that is not mounted yet. Only start failures are retried: a
+	// command that started and then exited is only run again through
+	// MaxRestarts. 0 disables retries.
+	StartRetries int `yaml:"start_retries"`
+
+	// StartRetryDelay is the wait between start attempts.
+	StartRetryDelay time.Duration `yaml:"start_retry_delay"`
+
+	// MaxRestarts runs the command again when logwrap would exit with a
+	// failure, at most this many times within RestartWindow, so that a
+	// crashing service is not restarted in a tight loop. Runs stopped by
+	// SIGINT or SIGTERM are not restarted. Once the limit is reached
+	// logwrap gives up and exits with the last run's code. 0 disables
+	// restarts.
+	MaxRestarts int `yaml:"max_restarts"`
+
+	// RestartWindow is how long a restart counts against MaxRestarts. 0
+	// counts every restart of the process.
+	RestartWindow time.Duration `yaml:"restart_window"`
+
+	// RestartBackoff is the wait before the first restart within the
+	// window. It doubles with every further restart in the window, up to
+	// RestartBackoffMax (0 for no limit).
+	RestartBackoff    time.Duration `yaml:"restart_backoff"`
+	RestartBackoffMax time.Duration `yaml:"restart_backoff_max"`
+
+	// PIDFile receives the PID of the command (the last stage of a
+	// pipeline) once it has started, and is removed when it exits. Empty
+	// disables it.
+	PIDFile string `yaml:"pid_file"`
+
+	// ExitLevelMap gives the level of the END run marker from the exit
+	// code logwrap reports: keys are exit codes ("0", "3"), ExitLevelNonZero
+	// for other non-zero codes and ExitLevelSignal for runs interrupted by
+	// SIGINT or SIGTERM. Entries are merged with the defaults
+	// 0: INFO, nonzero: ERROR, signal: WARN.
+	ExitLevelMap map[string]string `yaml:"exit_level_map"`
+
+	// Interactive runs REPLs and other interactive programs through
+	// logwrap: the command's stdout and stderr are copied to logwrap's
+	// unmodified and unbuffered, so that prompts without a line ending show
+	// up at once, while stdin stays connected to the command. Formatting,
+	// filtering, sinks, run markers and heartbeats do not apply. SIGINT is
+	// left to the command, which receives Ctrl-C from the terminal itself;
+	// SIGTERM still stops it, and its exit code is propagated as usual.
+	Interactive bool `yaml:"interactive"`
+
+	// SinglePipe captures the command's stdout and stderr through one pipe,
+	// like "2>&1" in a shell, so that lines keep the order in which the
+	// command wrote them. Every line is then read as stdout: stderr's
+	// default level, raw_stderr_file and other per-stream settings no
+	// longer see any output.
+	SinglePipe bool `yaml:"single_pipe"`
+
+	// ExplainExit prints to stderr, once the command has exited, how
+	// logwrap's exit code was derived: the command's code (each stage's for
+	// a pipeline), success_exit_codes remapping, signal and broken pipe
+	// handling, and on_format_error.
+	ExplainExit bool `yaml:"explain_exit"`
+
+	// OnExit is a command run once the wrapped command has exited,
+	// including when a signal stopped it, e.g. to send a notification. With
+	// MaxRestarts, it runs once, after the last run. It
+	// is split like a shell command line, without expansions, and gets
+	// logwrap's exit code and the run's duration in LOGWRAP_EXIT_CODE and
+	// LOGWRAP_DURATION_MS. Its failure is only reported. Empty disables it.
+	OnExit string `yaml:"on_exit"`
+
+	// OnExitFormat formats the output of the on_exit command like the
+	// wrapped command's instead of passing it through unchanged.
+	OnExitFormat bool `yaml:"on_exit_format"`
+
+	// OnExitTimeout is how long the on_exit command may run before it is
+	// killed, so that a hanging hook cannot keep logwrap from exiting.
+	// 0 disables the limit.
+	OnExitTimeout time.Duration `yaml:"on_exit_timeout"`
+}
+
+// Keys of ExecutionConfig.ExitLevelMap besides exit codes.
+const (
+	ExitLevelNonZero = "nonzero"
+	ExitLevelSignal  = "signal"
+)
+
 // FilterConfig contains configuration for output line filtering.
 type FilterConfig struct {
 	Enabled         bool     `yaml:"enabled"`
@@ -84,6 +230,10 @@ type FilterConfig struct {
 	IncludePatterns []string `yaml:"include_patterns"`
 	ExcludeLevels   []string `yaml:"exclude_levels"`
 	IncludeLevels   []string `yaml:"include_levels"`
+	// OnlyLevels keeps only lines whose level, detected or defaulted for
+	// the stream, is in the set; all other lines are dropped. Unlike
+	// IncludeLevels it also applies to lines without a level keyword.
+	OnlyLevels []string `yaml:"only_levels"`
 }
 
 // PrefixConfig contains configuration for log prefixes.
@@ -93,12 +243,46 @@ type PrefixConfig struct {
 	Colors    ColorsConfig    `yaml:"colors"`
 	User      UserConfig      `yaml:"user"`
 	PID       PIDConfig       `yaml:"pid"`
+	PPID      PPIDConfig      `yaml:"ppid"`
+	Command   CommandConfig   `yaml:"command"`
+
+	// TidyEmptySegments removes the artifacts disabled or empty fields leave
+	// in the rendered prefix, e.g. "[:] " when user and PID are disabled in
+	// the default template. Templates that include {{.Line}} are not tidied.
+	TidyEmptySegments bool `yaml:"tidy_empty_segments"`
+
+	// Cache precompiles templates made only of text and plain fields such
+	// as {{.Timestamp}} or {{.Level}}, building text prefixes by
+	// concatenation instead of executing the template for every line. The
+	// output is the same. Templates using functions, conditionals or
+	// {{.Fields}} are still executed.
+	Cache bool `yaml:"cache"`
+
+	// StrictTemplate turns the warnings about template references that
+	// always render empty (see [Config.TemplateWarnings]) into validation
+	// errors.
+	StrictTemplate bool `yaml:"strict_template"`
 }
 
 // TimestampConfig contains timestamp formatting configuration.
 type TimestampConfig struct {
 	Format string `yaml:"format"`
 	UTC    bool   `yaml:"utc"`
+
+	// CacheInterval reuses a formatted timestamp for up to this long, and
+	// never past the end of its second, instead of formatting one per line
+	// (e.g. "100ms"). It only applies when Format and Fallback have no
+	// sub-second directive (%f). 0 disables caching.
+	CacheInterval time.Duration `yaml:"cache_interval"`
+
+	// Strict warns when Format cannot represent a full instant, e.g. a
+	// time of day without a date, so that log timestamps are unambiguous.
+	Strict bool `yaml:"strict"`
+
+	// Fallback is the strftime format used, with a warning printed once,
+	// should Format yield an empty timestamp at runtime. Empty, or a
+	// fallback that is empty itself, uses RFC 3339.
+	Fallback string `yaml:"fallback"`
 }
 
 // ColorsConfig contains color configuration for output.
@@ -122,66 +306,452 @@ type UserConfig struct {
 type PIDConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Format  string `yaml:"format"`
+	// Source selects whose PID is shown: "self" (logwrap, the default) or
+	// "child" (the wrapped command; the last stage of a pipeline).
+	Source string `yaml:"source"`
+}
+
+// PPIDConfig contains parent process ID configuration.
+type PPIDConfig struct {
+	// Enabled exposes logwrap's parent PID as {{.PPID}} and adds it as
+	// ppid to JSON and structured output.
+	Enabled bool `yaml:"enabled"`
+}
+
+// CommandConfig contains wrapped command name configuration.
+type CommandConfig struct {
+	// Enabled exposes the base name of the wrapped command (the first
+	// stage of a pipeline) as {{.Command}} and adds it as command to JSON
+	// and structured output.
+	Enabled bool `yaml:"enabled"`
 }
 
 // OutputConfig contains output formatting configuration.
 type OutputConfig struct {
 	Format string `yaml:"format"`
+
+	// BrokenPipeExitCode is the exit code used when logwrap's own output is
+	// closed early (EPIPE, e.g. "logwrap cmd | head"). The command is then
+	// stopped gracefully. Use 141 (128 + SIGPIPE) to mimic shells.
+	BrokenPipeExitCode int `yaml:"broken_pipe_exit_code"`
+
+	// OnFormatError selects what happens when a line cannot be formatted
+	// (e.g. a template fails at execution time): "raw" emits the unformatted
+	// line, "drop" discards it, and "error" emits it raw and reports a
+	// processing error that makes logwrap exit non-zero. Empty means "raw".
+	OnFormatError string `yaml:"on_format_error"`
+
+	// IncludeLineNumber adds the per-stream source line number as line_no
+	// to JSON and structured output. {{.LineNo}} is always available to
+	// text templates.
+	IncludeLineNumber bool `yaml:"include_line_number"`
+
+	// IncludeDelta adds the time since the previous line, of either
+	// stream, as delta_ms to JSON and structured output and as {{.Delta}}
+	// to text templates, to spot stalls. The first line has 0.
+	IncludeDelta bool `yaml:"include_delta"`
+
+	// JSONPassthrough makes the json format merge lines that are JSON
+	// objects into the output object instead of quoting them as "message".
+	// Input keys that collide with logwrap's own keys are kept under an
+	// "input." prefix. Lines that are not JSON objects are unaffected.
+	JSONPassthrough bool `yaml:"json_passthrough"`
+
+	// OnJSONParseFailure selects what happens with JSONPassthrough to
+	// lines that are not JSON objects: "wrap" quotes them as "message"
+	// like any other line, "passthrough" writes them unchanged and "drop"
+	// discards them. Empty means "wrap". Requires JSONPassthrough unless
+	// "wrap".
+	OnJSONParseFailure string `yaml:"on_json_parse_failure"`
+
+	// Flatten flattens nested objects and arrays of passed-through JSON
+	// into dot-separated keys (a.b.c, items.0). Requires JSONPassthrough.
+	Flatten bool `yaml:"flatten"`
+
+	// HeartbeatInterval makes logwrap emit a heartbeat line whenever the
+	// command has produced no output for this long, so that liveness checks
+	// tailing the logs can tell a silent command from a hung one.
+	// 0 disables heartbeats.
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+
+	// AutoCIFields adds the custom fields of CIFieldSources, by default
+	// ci_commit_sha, ci_branch and ci_job_id detected from CI environment
+	// variables (GitHub Actions, GitLab CI, CircleCI, ...). Fields with no
+	// matching variable are omitted.
+	AutoCIFields bool `yaml:"auto_ci_fields"`
+
+	// CIFieldSources maps each field added by AutoCIFields to the
+	// environment variables it is read from, in lookup order: the first
+	// non-empty one wins. Entries are merged with
+	// [DefaultCIFieldSources]; an empty list drops a default field.
+	CIFieldSources map[string][]string `yaml:"ci_field_sources"`
+
+	// CustomFields maps a field name to a value attached to every line.
+	// Values may be templates evaluated per line against the same data as
+	// the prefix template, e.g. "{{.Host}}-prod"; they may not reference
+	// other templated custom fields. A custom field overrides a CI field of
+	// the same name.
+	CustomFields map[string]string `yaml:"custom_fields"`
+
+	// IncludeRaw adds the line exactly as read, before any input cleanup,
+	// as a raw field to JSON and structured output. Off by default as it
+	// roughly doubles the output size.
+	IncludeRaw bool `yaml:"include_raw"`
+
+	// AlignMessages pads text prefixes so messages start in the same
+	// column. Templates that include {{.Line}} are not aligned.
+	AlignMessages bool `yaml:"align_messages"`
+
+	// PrefixWidth is the fixed width prefixes are padded to with
+	// AlignMessages. 0 pads to the widest prefix seen so far instead.
+	PrefixWidth int `yaml:"prefix_width"`
+
+	// RunMarkers brackets the command's output with "--- START ---" and
+	// "--- END code=N ---" lines, formatted like any other line, so that
+	// runs appended to a shared log can be told apart. The END line's level
+	// comes from execution.exit_level_map.
+	RunMarkers bool `yaml:"run_markers"`
+
+	// NoteEmptyRuns writes a "(no output)" line, formatted like any other
+	// line, when the command completes without producing a single line,
+	// so that monitoring can tell a silent run from one that did not run.
+	NoteEmptyRuns bool `yaml:"note_empty_runs"`
+
+	// SquashBlankLines collapses runs of consecutive blank (empty or
+	// whitespace-only) lines of a stream to SquashBlankLinesTo lines.
+	// Blank lines kept are formatted like any other line.
+	SquashBlankLines bool `yaml:"squash_blank_lines"`
+
+	// SquashBlankLinesTo is the number of consecutive blank lines kept by
+	// SquashBlankLines; 0 drops blank lines altogether.
+	SquashBlankLinesTo int `yaml:"squash_blank_lines_to"`
+
+	// LineEnding is the terminator written after every line: "lf" or
+	// "crlf" for Windows consumers. Empty means "lf".
+	LineEnding string `yaml:"line_ending"`
+
+	// ControlChars selects what happens to control characters in the
+	// command's output, such as NUL bytes that garble terminals: "keep"
+	// them, "escape" them as \xNN (e.g. \x00) or "strip" them. Tab is
+	// never touched; ESC is, so ANSI colors from the command are affected
+	// too. Empty means "keep".
+	ControlChars string `yaml:"control_chars"`
+
+	// Progress selects what happens to progress displays that overwrite a
+	// line with carriage returns, as curl, wget or pip write them: "keep"
+	// them as part of the line, or write only the "final" state of the
+	// line, without the intermediate updates. Empty means "keep".
+	Progress string `yaml:"progress"`
+
+	// StripInputPrefixPattern is a regular expression removed from the
+	// start of every line before formatting, e.g. a timestamp the command
+	// already prints, so that messages are not prefixed twice. Matches that
+	// do not start at the beginning of the line are ignored.
+	StripInputPrefixPattern string `yaml:"strip_input_prefix_pattern"`
+
+	// StripInputPrefixStage selects when the prefix is stripped:
+	// "after_detection" (level keywords in the prefix still count) or
+	// "before_detection". Empty means "after_detection".
+	StripInputPrefixStage string `yaml:"strip_input_prefix_stage"`
+
+	// SkipPrefixIfMatches is a regular expression for lines that are
+	// already formatted, e.g. JSON objects or the output of a nested
+	// logwrap. Matching lines are written as they are, without a prefix.
+	SkipPrefixIfMatches string `yaml:"skip_prefix_if_matches"`
+
+	// StderrOnLevel makes logwrap hold all output in memory and write it
+	// to stderr only once a line at or above this level appears; runs
+	// without such a line print nothing (e.g. cron jobs that should only
+	// mail on failure). Empty disables buffering.
+	StderrOnLevel string `yaml:"stderr_on_level"`
+
+	// StderrOnLevelMaxLines bounds the lines held for StderrOnLevel; the
+	// oldest lines are dropped beyond it.
+	StderrOnLevelMaxLines int `yaml:"stderr_on_level_max_lines"`
+
+	// ContextBefore writes, ahead of each ERROR or FATAL line, those of the
+	// preceding ContextBefore lines that the filter or filter.only_levels
+	// hid, marked with "context: ", like grep -B. 0 disables context.
+	ContextBefore int `yaml:"context_before"`
+
+	// ReorderWindow holds every line this long after it was read and
+	// writes lines in the order they were read, so that stdout and stderr
+	// interleave more faithfully at the cost of this much latency. 0 writes
+	// lines as soon as they are processed.
+	ReorderWindow time.Duration `yaml:"reorder_window"`
+
+	// Workers formats lines on this many goroutines, for commands whose
+	// output is produced faster than one goroutine per stream can format
+	// it. Lines are still written in the order they were read from each
+	// stream. 0 or 1 formats each stream on the goroutine reading it. It is
+	// ignored when reorder_window is set.
+	Workers int `yaml:"workers"`
+
+	// ReadAhead lets each stream read up to this many lines ahead of the
+	// lines being formatted and written, so that a burst of output or a
+	// slow write does not stall reading and leave the command blocked on a
+	// full pipe. Reading waits once the lines are all taken. 0 formats
+	// and writes each line before the next is read.
+	ReadAhead int `yaml:"read_ahead"`
+
+	// DedupeWindow drops lines identical to one written less than this
+	// long ago, on either stream, and writes how many were dropped at the
+	// end of every window that had some. The 10000 most recently seen
+	// distinct lines are remembered. 0 disables it.
+	DedupeWindow time.Duration `yaml:"dedupe_window"`
+
+	// LevelSummary prints to stderr, once the command has exited, how many
+	// lines of each level it printed, e.g. "Level summary: 12 ERROR, 3 WARN,
+	// 40 INFO". Lines hidden by filters or rate limits are counted too. It
+	// is ignored in interactive mode.
+	LevelSummary bool `yaml:"level_summary"`
+
+	// AppendSummaryRecord ends JSON output with a summary record once the
+	// command has exited, also after a signal, so that the NDJSON stream
+	// describes itself: {"event":"summary","levels":{...},"exit_code":N}.
+	// Levels are counted like LevelSummary. It requires Format "json" and
+	// is ignored in interactive mode.
+	AppendSummaryRecord bool `yaml:"append_summary_record"`
+
+	// SummaryFD writes a JSON summary of the run (exit code, duration,
+	// lines and bytes read, lines per level, line length min, max, average
+	// and percentiles) to this already open file descriptor once the
+	// command has exited, e.g. 3 for a descriptor passed with
+	// 3>summary.json. 0 disables it.
+	SummaryFD int `yaml:"summary_fd"`
+
+	// Sinks are additional destinations that receive every line, each
+	// with its own format and color settings.
+	Sinks []SinkConfig `yaml:"sinks"`
+
+	// ForceColorFile colors file sinks, which are not colored by default,
+	// for color-aware viewers such as less -R. Like colors in general it
+	// only affects the text format; a sink's own colors setting wins.
+	ForceColorFile bool `yaml:"force_color_file"`
+
+	// RotateTime rotates file sinks at every hour ("hourly") or day
+	// ("daily") boundary, in UTC with prefix.timestamp.utc and in local
+	// time otherwise: the file is closed and renamed with the period it
+	// covers as a suffix (app.log.2024-01-15T10 or app.log.2024-01-15),
+	// and a new one is started at the sink's path. Empty never rotates.
+	RotateTime string `yaml:"rotate_time"`
+
+	// Routes sends lines of a level only to the listed destinations: sink
+	// names, or "primary" for logwrap's own output, e.g.
+	// error: [primary, alerts]. Once any route is set, lines of a level
+	// without a route go to the primary output only. Empty sends every
+	// line everywhere.
+	Routes map[string][]string `yaml:"routes"`
+
+	// SeverityMap overrides the syslog severity (0-7, RFC 5424) of log
+	// levels, exposed as {{.Severity}}, e.g. warn: 5 to report WARN as
+	// notice. Unlisted levels keep their default severity.
+	SeverityMap map[string]int `yaml:"severity_map"`
+
+	// RatePerLevel caps the lines per second written for a level, e.g.
+	// info: 10, allowing bursts of up to one second's worth of lines.
+	// Lines over the cap are dropped. Unlisted levels are never throttled.
+	RatePerLevel map[string]float64 `yaml:"rate_per_level"`
+}
+
+// SinkConfig describes an additional output destination.
+type SinkConfig struct {
+	// Name identifies the sink in output.routes. Names must be unique and
+	// may not be "primary".
+	Name string `yaml:"name"`
+	// Type is "stdout", "stderr", "file" or "journald". journald sinks
+	// send every line to the systemd journal with its level as PRIORITY
+	// and custom and extracted fields as journal fields.
+	Type string `yaml:"type"`
+	// Path is the file to append to; required for type "file". For type
+	// "journald" it is the journal socket, /run/systemd/journal/socket by
+	// default.
+	Path string `yaml:"path"`
+	// Format overrides output.format for this sink when set. journald
+	// sinks use the journal's own format and do not take one.
+	Format string `yaml:"format"`
+	// Colors overrides prefix.colors.enabled for this sink when set.
+	// File sinks default to no colors.
+	Colors *bool `yaml:"colors"`
+	// FallbackToStderr writes the sink's lines to stderr once writing to
+	// the sink fails, e.g. because the disk is full. Otherwise they are
+	// dropped. Either way the failure is reported once.
+	FallbackToStderr bool `yaml:"fallback_to_stderr"`
 }
 
 // LogLevelConfig contains log level detection configuration.
 type LogLevelConfig struct {
-	DefaultStdout string              `yaml:"default_stdout"`
-	DefaultStderr string              `yaml:"default_stderr"`
-	Detection     DetectionConfig     `yaml:"detection"`
+	DefaultStdout string          `yaml:"default_stdout"`
+	DefaultStderr string          `yaml:"default_stderr"`
+	Detection     DetectionConfig `yaml:"detection"`
+	// CacheSize is the maximum number of lines whose detected level is
+	// cached (least recently used entries are evicted). 0 disables caching.
+	CacheSize int `yaml:"cache_size"`
 }
 
+// DropLevel is the pseudo-level that may be used as a detection keywords
+// key: lines matching one of its keywords are dropped from the output.
+const DropLevel = "drop"
+
 // DetectionConfig contains configuration for automatic log level detection.
 type DetectionConfig struct {
-	Enabled  bool                `yaml:"enabled"`
+	Enabled bool `yaml:"enabled"`
+	// Keywords maps a lowercase level name, or DropLevel, to the keywords
+	// that mark a line with that level.
 	Keywords map[string][]string `yaml:"keywords"`
+	// CaseSensitive matches keywords with their exact case, so that e.g.
+	// "ERROR" does not match "error". By default case is ignored.
+	CaseSensitive bool `yaml:"case_sensitive"`
+	// StrictKeywords turns the warnings about keywords listed under several
+	// levels (see [Config.KeywordWarnings]) into configuration errors.
+	StrictKeywords bool `yaml:"strict_keywords"`
+	// OverrideOnly keeps the stream's default level unless a keyword
+	// marks the line as more severe: keywords can raise a level but never
+	// lower it, so stderr lines stay at least DefaultStderr even if they
+	// contain "INFO". Drop keywords still drop lines.
+	OverrideOnly bool `yaml:"override_only"`
+	// LevelKey reads the level of partly structured lines from a field with
+	// this key before keywords are scanned, e.g. "level" matches
+	// level=warn and "level":"warn" anywhere in the line. The value is
+	// case-insensitive and accepts the level names plus warning, err,
+	// critical and panic. Lines without the field, or with a value that
+	// is no level, fall back to keywords. Level filters do not use it.
+	// Empty disables it.
+	LevelKey string `yaml:"level_key"`
+	// IncludeMatch records which keyword gave a line its level, exposed as
+	// {{.MatchedKeyword}} in templates and as a matched_keyword key in JSON
+	// and structured output, to help debug keyword lists. Lines whose level
+	// comes from the stream default or from LevelKey have none.
+	IncludeMatch bool `yaml:"include_match"`
+	// ExtractFields maps a field name to a regular expression with a capture
+	// group. The first group of the first match is exposed per line as
+	// {{.Fields.<name>}} and as a key in JSON and structured output.
+	// Extraction runs independently of keyword-based level detection.
+	ExtractFields map[string]string `yaml:"extract_fields"`
+	// ExtractFieldTypes gives extracted fields a JSON type: "int", "float"
+	// or "bool" values are written as JSON numbers or booleans instead of
+	// strings, e.g. count: int. Values that do not parse as the type, and
+	// fields without a type ("string"), stay strings. Other formats are
+	// not affected.
+	ExtractFieldTypes map[string]string `yaml:"extract_field_types"`
+	// MaxScanBytes skips keyword detection for lines longer than this many
+	// bytes, which then get the stream's default level. Huge lines are
+	// rarely classifiable and would dominate detection latency. 0 scans
+	// lines of any length.
+	MaxScanBytes int `yaml:"max_scan_bytes"`
 }
 
 // CLIFlags contains parsed command line flags.
 type CLIFlags struct {
-	ConfigFile    *string
-	Template      *string
-	TimestampUTC  *bool
-	ColorsEnabled *bool
-	OutputFormat  *string
-	Help          *bool
-	Version       *bool
-	setFlags      map[string]bool // tracks which flags were explicitly set on the command line
+	ConfigFile         *string
+	ConfigOptional     *bool
+	ConfigPrecedence   *string
+	Template           *string
+	TimestampUTC       *bool
+	StrictTimestamp    *bool
+	ColorsEnabled      *bool
+	OutputFormat       *string
+	Help               *bool
+	Version            *bool
+	NoDetect           *bool
+	Flatten            *bool
+	HealthEvery        *time.Duration
+	DedupeWindow       *time.Duration
+	PrefixWidth        *int
+	PrefixCache        *bool
+	StderrOnLevel      *string
+	AllowRoot          *bool
+	PIDFile            *string
+	OnExit             *string
+	MaxRestarts        *int
+	RestartWindow      *time.Duration
+	Interactive        *bool
+	ExplainExit        *bool
+	SinglePipe         *bool
+	LevelSummary       *bool
+	ForceColorFile     *bool
+	RotateTime         *string
+	OnJSONParseFailure *string
+	SummaryFD          *int
+	Keywords           []string        // repeatable -keyword LEVEL=WORD values, in order
+	OnlyLevels         []string        // repeatable -only-level LEVEL values
+	LevelRates         []string        // repeatable -max-line-rate-per-level LEVEL=RATE values
+	setFlags           map[string]bool // tracks which flags were explicitly set on the command line
+}
+
+// stringList is a flag.Value that collects every occurrence of a repeatable flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
 // LoadConfig loads configuration from file and applies CLI overrides.
+//
+// When the -config-optional flag is set, a configFile that does not exist is
+// skipped and the built-in defaults are used instead. A file that exists but
+// is invalid is still reported as an error.
+//
+// The config file and CLI flags are applied over the defaults in the order
+// given by -config-precedence, so that later sources override earlier ones.
 func LoadConfig(configFile string, args []string) (*Config, error) {
 	config := getDefaultConfig()
 
-	var explicit explicitColorFields
-
-	if configFile != "" {
-		if err := loadConfigFile(config, configFile); err != nil {
-			return nil, fmt.Errorf("failed to load config file: %w", err)
-		}
-		explicit = detectExplicitColorFields(configFile)
-	}
-
 	flags, err := parseCLIFlags(args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse CLI flags: %w", err)
 	}
+	if err := checkFlagConflicts(flags); err != nil {
+		return nil, err
+	}
+	precedence, err := parseConfigPrecedence(*flags.ConfigPrecedence)
+	if err != nil {
+		return nil, err
+	}
+
+	if *flags.ConfigOptional && IsMissingConfigFile(configFile) {
+		configFile = ""
+	}
 
-	applyCLIOverrides(config, flags)
+	var explicit explicitColorFields
 
-	// When detection is disabled, clear default keywords so the
-	// "disabled but keywords configured" validation does not reject
-	// configs that simply set detection.enabled: false. YAML
+	for _, source := range precedence {
+		switch source {
+		case "file":
+			if configFile == "" {
+				continue
+			}
+			if err := loadConfigFile(config, configFile); err != nil {
+				return nil, fmt.Errorf("failed to load config file: %w", err)
+			}
+			explicit = detectExplicitColorFields(configFile)
+		case "flags":
+			applyCLIOverrides(config, flags)
+		}
+	}
+
+	// When detection is disabled (detection.enabled: false or -no-detect),
+	// clear default keywords so the "disabled but keywords configured"
+	// validation does not reject configs that simply turn it off. YAML
 	// unmarshaling merges maps and cannot clear pre-populated defaults.
 	if !config.LogLevel.Detection.Enabled {
 		config.LogLevel.Detection.Keywords = nil
 	}
 
+	// CLI keywords are merged after the clearing above so that combining
+	// -keyword with disabled detection is still reported by Validate.
+	if err := applyCLIKeywords(config, flags.Keywords); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := applyCLILevelRates(config, flags.LevelRates); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Apply color theme if set. Theme provides base colors; explicit
 	// color fields from the config file or CLI override theme values.
 	if config.Prefix.Colors.Theme != "" {
@@ -197,6 +767,35 @@ func LoadConfig(configFile string, args []string) (*Config, error) {
 	return config, nil
 }
 
+// configSources are the sources -config-precedence orders. The built-in
+// defaults always have the lowest precedence.
+var configSources = []string{"file", "flags"}
+
+// defaultConfigPrecedence lists configSources from lowest to highest
+// precedence: CLI flags override the config file.
+const defaultConfigPrecedence = "file,flags"
+
+// parseConfigPrecedence parses a comma-separated -config-precedence value,
+// which must list every source in configSources exactly once.
+func parseConfigPrecedence(value string) ([]string, error) {
+	sources := strings.Split(value, ",")
+	for i, source := range sources {
+		sources[i] = strings.ToLower(strings.TrimSpace(source))
+	}
+
+	valid := len(sources) == len(configSources)
+	for _, source := range configSources {
+		if !slices.Contains(sources, source) {
+			valid = false
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w %q, must list each of %s once (default %q)",
+			apperrors.ErrInvalidPrecedence, value, strings.Join(configSources, ", "), defaultConfigPrecedence)
+	}
+	return sources, nil
+}
+
 // explicitColorFields tracks which color fields were explicitly set in the config file.
 type explicitColorFields struct {
 	info      bool
@@ -256,14 +855,21 @@ func getDefaultConfig() *Config {
 			PID: PIDConfig{
 				Enabled: true,
 				Format:  "decimal",
+				Source:  "self",
 			},
 		},
 		Output: OutputConfig{
-			Format: "text",
+			Format:                "text",
+			OnFormatError:         "raw",
+			OnJSONParseFailure:    "wrap",
+			StderrOnLevelMaxLines: defaultStderrOnLevelMaxLines,
+			SquashBlankLinesTo:    defaultSquashBlankLinesTo,
+			CIFieldSources:        DefaultCIFieldSources(),
 		},
 		LogLevel: LogLevelConfig{
 			DefaultStdout: "INFO",
 			DefaultStderr: "ERROR",
+			CacheSize:     defaultLevelCacheSize,
 			Detection: DetectionConfig{
 				Enabled: true,
 				Keywords: map[string][]string{
@@ -274,6 +880,20 @@ func getDefaultConfig() *Config {
 				},
 			},
 		},
+		Execution: ExecutionConfig{
+			SuccessExitCodes:  []int{0},
+			PipelinePolicy:    "last",
+			StartRetryDelay:   defaultStartRetryDelay,
+			RestartWindow:     defaultRestartWindow,
+			RestartBackoff:    defaultRestartBackoff,
+			RestartBackoffMax: defaultRestartBackoffMax,
+			OnExitTimeout:     defaultOnExitTimeout,
+			ExitLevelMap: map[string]string{
+				"0":              "INFO",
+				ExitLevelNonZero: "ERROR",
+				ExitLevelSignal:  "WARN",
+			},
+		},
 	}
 }
 
@@ -302,12 +922,41 @@ func parseCLIFlags(args []string) (*CLIFlags, error) {
 
 	fs := flag.NewFlagSet("logwrap", flag.ContinueOnError)
 	flags.ConfigFile = fs.String("config", "", "Configuration file path")
+	flags.ConfigOptional = fs.Bool("config-optional", false, "Use defaults if the config file does not exist")
+	flags.ConfigPrecedence = fs.String("config-precedence", defaultConfigPrecedence,
+		"Configuration sources from lowest to highest precedence")
 	flags.Template = fs.String("template", "", "Log prefix template")
 	flags.TimestampUTC = fs.Bool("utc", false, "Use UTC timestamps")
+	flags.StrictTimestamp = fs.Bool("strict-timestamp", false, "Warn when the timestamp format lacks date or time components")
 	flags.ColorsEnabled = fs.Bool("colors", false, "Enable colored output")
-	flags.OutputFormat = fs.String("format", "", "Output format (text, json, structured)")
+	flags.OutputFormat = fs.String("format", "", "Output format (text, json, structured, otel)")
 	flags.Help = fs.Bool("help", false, "Show help")
 	flags.Version = fs.Bool("version", false, "Show version")
+	flags.NoDetect = fs.Bool("no-detect", false, "Disable log level detection")
+	flags.OnJSONParseFailure = fs.String("on-json-parse-failure", "",
+		"With JSON passthrough, wrap, passthrough or drop lines that are not JSON objects")
+	flags.Flatten = fs.Bool("flatten", false, "Pass through JSON lines with nested keys flattened")
+	flags.HealthEvery = fs.Duration("health-line-every", 0, "Emit a heartbeat line after this much silence (0 disables)")
+	flags.DedupeWindow = fs.Duration("dedupe-window", 0, "Drop lines repeated within this long of being written (0 disables)")
+	flags.PrefixWidth = fs.Int("prefix-width", 0, "Align messages by padding prefixes to this width")
+	flags.PrefixCache = fs.Bool("prefix-cache", false, "Build prefixes of simple templates without executing the template")
+	flags.StderrOnLevel = fs.String("stderr-on-level", "", "Buffer output and write it to stderr only if a line at this level appears")
+	flags.AllowRoot = fs.Bool("allow-root", false, "Run the command as root even if execution.disallow_root is set")
+	flags.PIDFile = fs.String("pid-file", "", "Write the command's PID to this file while it runs")
+	flags.MaxRestarts = fs.Int("max-restarts", 0, "Restart a failing command at most this many times within the restart window")
+	flags.RestartWindow = fs.Duration("restart-window", 0, "How long a restart counts against -max-restarts")
+	flags.OnExit = fs.String("on-exit", "", "Run this command once the wrapped command has exited")
+	flags.ForceColorFile = fs.Bool("force-color-file", false, "Keep colors in file sinks")
+	flags.RotateTime = fs.String("rotate-time", "", "Rotate file sinks hourly or daily")
+	flags.LevelSummary = fs.Bool("level-summary", false, "Print the number of lines per level to stderr after the run")
+	flags.SummaryFD = fs.Int("summary-fd", 0, "Write a JSON summary of the run to this file descriptor (0 disables)")
+	flags.ExplainExit = fs.Bool("explain-exit", false, "Print how the exit code was derived after the run")
+	flags.Interactive = fs.Bool("interactive", false, "Pass the command's output through unmodified for interactive use")
+	flags.SinglePipe = fs.Bool("single-pipe", false, "Capture stdout and stderr through one pipe to keep their order")
+	fs.Var((*stringList)(&flags.Keywords), "keyword", "Extra detection keyword as LEVEL=WORD (repeatable)")
+	fs.Var((*stringList)(&flags.OnlyLevels), "only-level", "Only output lines of this level (repeatable)")
+	fs.Var((*stringList)(&flags.LevelRates), "max-line-rate-per-level",
+		"Cap the lines per second of a level as LEVEL=RATE (repeatable)")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, fmt.Errorf("failed to parse flags: %w", err)
@@ -321,19 +970,162 @@ func parseCLIFlags(args []string) (*CLIFlags, error) {
 	return flags, nil
 }
 
+// checkFlagConflicts rejects flag combinations that contradict each other.
+// Repeating a flag is not a conflict: the last value wins, except for
+// -keyword and -only-level, whose values accumulate.
+func checkFlagConflicts(flags *CLIFlags) error {
+	if flags.setFlags["flatten"] && *flags.Flatten &&
+		flags.setFlags["format"] && *flags.OutputFormat != "json" {
+		return fmt.Errorf("%w: -flatten requires -format json, got -format %s",
+			apperrors.ErrConflictingFlags, *flags.OutputFormat)
+	}
+	if flags.setFlags["no-detect"] && *flags.NoDetect && len(flags.Keywords) > 0 {
+		return fmt.Errorf("%w: -keyword cannot be used with -no-detect: %w",
+			apperrors.ErrConflictingFlags, apperrors.ErrDetectionDisabledWithKeywords)
+	}
+	return nil
+}
+
 func applyCLIOverrides(config *Config, flags *CLIFlags) {
+	if len(flags.OnlyLevels) > 0 {
+		config.Filter.Enabled = true
+		config.Filter.OnlyLevels = flags.OnlyLevels
+	}
 	if flags.setFlags["template"] {
 		config.Prefix.Template = *flags.Template
 	}
 	if flags.setFlags["utc"] {
 		config.Prefix.Timestamp.UTC = *flags.TimestampUTC
 	}
+	if flags.setFlags["strict-timestamp"] {
+		config.Prefix.Timestamp.Strict = *flags.StrictTimestamp
+	}
 	if flags.setFlags["colors"] {
 		config.Prefix.Colors.Enabled = *flags.ColorsEnabled
 	}
 	if flags.setFlags["format"] {
 		config.Output.Format = *flags.OutputFormat
 	}
+	// Only disabling is supported; keywords cleared by LoadConfig when
+	// detection is off could not be restored by a "-no-detect=false".
+	if flags.setFlags["no-detect"] && *flags.NoDetect {
+		config.LogLevel.Detection.Enabled = false
+	}
+	// -flatten only makes sense for passed-through JSON, so it enables both.
+	if flags.setFlags["flatten"] {
+		config.Output.Flatten = *flags.Flatten
+		if *flags.Flatten {
+			config.Output.JSONPassthrough = true
+		}
+	}
+	// Like -flatten, -on-json-parse-failure implies JSON passthrough unless
+	// it asks for the default behavior.
+	if flags.setFlags["on-json-parse-failure"] {
+		config.Output.OnJSONParseFailure = *flags.OnJSONParseFailure
+		if *flags.OnJSONParseFailure != "wrap" {
+			config.Output.JSONPassthrough = true
+		}
+	}
+	// -prefix-width implies alignment; 0 aligns to the widest prefix seen.
+	if flags.setFlags["prefix-width"] {
+		config.Output.PrefixWidth = *flags.PrefixWidth
+		config.Output.AlignMessages = true
+	}
+	if flags.setFlags["prefix-cache"] {
+		config.Prefix.Cache = *flags.PrefixCache
+	}
+	if flags.setFlags["stderr-on-level"] {
+		config.Output.StderrOnLevel = *flags.StderrOnLevel
+	}
+	if flags.setFlags["health-line-every"] {
+		config.Output.HeartbeatInterval = *flags.HealthEvery
+	}
+	if flags.setFlags["dedupe-window"] {
+		config.Output.DedupeWindow = *flags.DedupeWindow
+	}
+	if flags.setFlags["allow-root"] && *flags.AllowRoot {
+		config.Execution.DisallowRoot = false
+	}
+	if flags.setFlags["pid-file"] {
+		config.Execution.PIDFile = *flags.PIDFile
+	}
+	if flags.setFlags["max-restarts"] {
+		config.Execution.MaxRestarts = *flags.MaxRestarts
+	}
+	if flags.setFlags["restart-window"] {
+		config.Execution.RestartWindow = *flags.RestartWindow
+	}
+	if flags.setFlags["on-exit"] {
+		config.Execution.OnExit = *flags.OnExit
+	}
+	if flags.setFlags["interactive"] {
+		config.Execution.Interactive = *flags.Interactive
+	}
+	if flags.setFlags["force-color-file"] {
+		config.Output.ForceColorFile = *flags.ForceColorFile
+	}
+	if flags.setFlags["rotate-time"] {
+		config.Output.RotateTime = *flags.RotateTime
+	}
+	if flags.setFlags["level-summary"] {
+		config.Output.LevelSummary = *flags.LevelSummary
+	}
+	if flags.setFlags["summary-fd"] {
+		config.Output.SummaryFD = *flags.SummaryFD
+	}
+	if flags.setFlags["explain-exit"] {
+		config.Execution.ExplainExit = *flags.ExplainExit
+	}
+	if flags.setFlags["single-pipe"] {
+		config.Execution.SinglePipe = *flags.SinglePipe
+	}
+}
+
+// applyCLIKeywords merges -keyword LEVEL=WORD values into the detection
+// keywords. The level is case-insensitive and must be a known log level.
+func applyCLIKeywords(config *Config, keywords []string) error {
+	validLevels := []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+	for _, kw := range keywords {
+		level, word, ok := strings.Cut(kw, "=")
+		if !ok || level == "" || word == "" {
+			return fmt.Errorf("%w %q, expected LEVEL=WORD", apperrors.ErrInvalidKeywordFlag, kw)
+		}
+		if !strings.EqualFold(level, DropLevel) && !slices.Contains(validLevels, strings.ToUpper(level)) {
+			return fmt.Errorf("%w '%s' in -keyword, valid levels: %s, %s",
+				apperrors.ErrInvalidLogLevel, level, strings.Join(validLevels, ", "), DropLevel)
+		}
+
+		if config.LogLevel.Detection.Keywords == nil {
+			config.LogLevel.Detection.Keywords = make(map[string][]string)
+		}
+		key := strings.ToLower(level)
+		config.LogLevel.Detection.Keywords[key] = append(config.LogLevel.Detection.Keywords[key], word)
+	}
+
+	return nil
+}
+
+// applyCLILevelRates merges -max-line-rate-per-level LEVEL=RATE values into
+// output.rate_per_level. Levels and rates are checked by Validate.
+func applyCLILevelRates(config *Config, rates []string) error {
+	for _, r := range rates {
+		level, value, ok := strings.Cut(r, "=")
+		if !ok || level == "" {
+			return fmt.Errorf("%w %q, expected LEVEL=RATE", apperrors.ErrInvalidRateFlag, r)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%w %q, expected LEVEL=RATE", apperrors.ErrInvalidRateFlag, r)
+		}
+
+		if config.Output.RatePerLevel == nil {
+			config.Output.RatePerLevel = make(map[string]float64)
+		}
+		config.Output.RatePerLevel[strings.ToLower(level)] = rate
+	}
+
+	return nil
 }
 
 // FindConfigFile searches for configuration files in standard locations.
@@ -364,6 +1156,17 @@ func FindConfigFile() string {
 	return ""
 }
 
+// IsMissingConfigFile reports whether configFile is set but does not exist.
+// Other stat errors (e.g. permission denied) are not treated as missing so
+// that they surface when the file is loaded.
+func IsMissingConfigFile(configFile string) bool {
+	if configFile == "" {
+		return false
+	}
+	_, err := os.Stat(configFile)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
 // validateConfigPath validates that a configuration file path is safe to read.
 //
 // Security checks:
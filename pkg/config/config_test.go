@@ -4,8 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/apperrors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -564,4 +566,527 @@ log_level:
 	require.NoError(t, err, "detection.enabled: false should not fail validation")
 	assert.False(t, cfg.LogLevel.Detection.Enabled)
 	assert.Empty(t, cfg.LogLevel.Detection.Keywords, "keywords should be cleared when detection is disabled")
-}
\ No newline at end of file
+}
+func TestLoadConfig_SuccessExitCodes(t *testing.T) {
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+execution:
+  success_exit_codes: [0, 1]
+`)
+
+	cfg, err := LoadConfig(configFile, []string{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, cfg.Execution.SuccessExitCodes)
+
+	defaults, err := LoadConfig("", []string{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, defaults.Execution.SuccessExitCodes)
+}
+
+func TestLoadConfig_CLIKeywords(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-keyword", "WARN=deprecated", "-keyword=error=panicked", "-keyword", "trace=enter", "-keyword", "drop=/healthz"})
+	require.NoError(t, err)
+
+	keywords := cfg.LogLevel.Detection.Keywords
+	assert.Contains(t, keywords["warn"], "deprecated")
+	assert.Contains(t, keywords["warn"], "WARNING", "CLI keywords merge with existing ones")
+	assert.Contains(t, keywords["error"], "panicked")
+	assert.Equal(t, []string{"enter"}, keywords["trace"])
+	assert.Equal(t, []string{"/healthz"}, keywords[DropLevel])
+
+	tests := []struct {
+		name        string
+		keyword     string
+		expectedErr error
+	}{
+		{"unknown level", "verbose=chatty", apperrors.ErrInvalidLogLevel},
+		{"missing separator", "warn", apperrors.ErrInvalidKeywordFlag},
+		{"empty word", "warn=", apperrors.ErrInvalidKeywordFlag},
+		{"empty level", "=word", apperrors.ErrInvalidKeywordFlag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadConfig("", []string{"-keyword", tt.keyword})
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestLoadConfig_StrictTimestamp(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Prefix.Timestamp.Strict)
+
+	cfg, err = LoadConfig("", []string{"-strict-timestamp"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Prefix.Timestamp.Strict)
+}
+
+func TestLoadConfig_Interactive(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Execution.Interactive)
+
+	cfg, err = LoadConfig("", []string{"-interactive"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Execution.Interactive)
+}
+
+func TestLoadConfig_ExplainExit(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Execution.ExplainExit)
+
+	cfg, err = LoadConfig("", []string{"-explain-exit"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Execution.ExplainExit)
+}
+
+func TestLoadConfig_SinglePipe(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Execution.SinglePipe)
+
+	cfg, err = LoadConfig("", []string{"-single-pipe"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Execution.SinglePipe)
+}
+
+func TestLoadConfig_LevelSummary(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Output.LevelSummary)
+
+	cfg, err = LoadConfig("", []string{"-level-summary"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Output.LevelSummary)
+}
+
+func TestLoadConfig_PrefixCache(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Prefix.Cache)
+
+	cfg, err = LoadConfig("", []string{"-prefix-cache"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Prefix.Cache)
+}
+
+func TestLoadConfig_CLILevelRates(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-max-line-rate-per-level", "INFO=10", "-max-line-rate-per-level=debug=0.5"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"info": 10, "debug": 0.5}, cfg.Output.RatePerLevel)
+
+	tests := []struct {
+		name        string
+		rate        string
+		expectedErr error
+	}{
+		{"unknown level", "verbose=5", apperrors.ErrInvalidLevelRate},
+		{"missing separator", "info", apperrors.ErrInvalidRateFlag},
+		{"empty level", "=5", apperrors.ErrInvalidRateFlag},
+		{"not a number", "info=fast", apperrors.ErrInvalidRateFlag},
+		{"zero rate", "info=0", apperrors.ErrInvalidLevelRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadConfig("", []string{"-max-line-rate-per-level", tt.rate})
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestLoadConfig_OnlyLevel(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-only-level", "ERROR", "-only-level=fatal"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Filter.Enabled, "-only-level enables filtering")
+	assert.Equal(t, []string{"ERROR", "fatal"}, cfg.Filter.OnlyLevels)
+
+	_, err = LoadConfig("", []string{"-only-level", "verbose"})
+	require.ErrorIs(t, err, apperrors.ErrInvalidFilterLevel)
+
+	cfg, err = LoadConfig("", []string{"-no-detect", "-only-level", "INFO"})
+	require.NoError(t, err, "-only-level works with stream default levels")
+	assert.Equal(t, []string{"INFO"}, cfg.Filter.OnlyLevels)
+}
+
+func TestLoadConfig_CLIKeywordsWithDetectionDisabled(t *testing.T) {
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+log_level:
+  detection:
+    enabled: false
+`)
+
+	_, err := LoadConfig(configFile, []string{"-keyword", "warn=deprecated"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrDetectionDisabledWithKeywords)
+}
+
+func TestLoadConfig_NoDetect(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-no-detect"})
+	require.NoError(t, err, "-no-detect must not trip the disabled-with-keywords check")
+	assert.False(t, cfg.LogLevel.Detection.Enabled)
+	assert.Empty(t, cfg.LogLevel.Detection.Keywords)
+
+	// Also overrides a config file that enables detection with keywords.
+	configFile := testutils.CreateTempConfigFile(t, `
+log_level:
+  detection:
+    enabled: true
+    keywords:
+      warn: ["careful"]
+`)
+	cfg, err = LoadConfig(configFile, []string{"-no-detect"})
+	require.NoError(t, err)
+	assert.False(t, cfg.LogLevel.Detection.Enabled)
+	assert.Empty(t, cfg.LogLevel.Detection.Keywords)
+
+	_, err = LoadConfig("", []string{"-no-detect", "-keyword", "warn=careful"})
+	assert.ErrorIs(t, err, apperrors.ErrDetectionDisabledWithKeywords)
+}
+
+func TestLoadConfig_TimestampCacheInterval(t *testing.T) {
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+prefix:
+  timestamp:
+    cache_interval: 100ms
+`)
+	cfg, err := LoadConfig(configFile, []string{})
+	require.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, cfg.Prefix.Timestamp.CacheInterval)
+
+	configFile = testutils.CreateTempConfigFile(t, `
+prefix:
+  timestamp:
+    cache_interval: -1s
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCacheInterval)
+}
+
+func TestLoadConfig_FlattenFlag(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-format", "json", "-flatten"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Output.Flatten)
+	assert.True(t, cfg.Output.JSONPassthrough, "-flatten enables passthrough")
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  format: json
+  flatten: true
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrFlattenWithoutPassthrough)
+}
+
+func TestLoadConfig_OnJSONParseFailure(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "wrap", cfg.Output.OnJSONParseFailure)
+	assert.False(t, cfg.Output.JSONPassthrough)
+
+	cfg, err = LoadConfig("", []string{"-format", "json", "-on-json-parse-failure", "drop"})
+	require.NoError(t, err)
+	assert.Equal(t, "drop", cfg.Output.OnJSONParseFailure)
+	assert.True(t, cfg.Output.JSONPassthrough, "-on-json-parse-failure enables passthrough")
+
+	_, err = LoadConfig("", []string{"-on-json-parse-failure", "ignore"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidJSONParseFailurePolicy)
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  format: json
+  on_json_parse_failure: passthrough
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrParseFailureWithoutPassthrough)
+}
+
+func TestLoadConfig_ForceColorFile(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Output.ForceColorFile)
+
+	cfg, err = LoadConfig("", []string{"-force-color-file"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Output.ForceColorFile)
+	assert.False(t, cfg.Prefix.Colors.Enabled, "the terminal stays uncolored")
+}
+
+func TestLoadConfig_RotateTime(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Output.RotateTime, "rotation is off by default")
+
+	for _, period := range []string{"hourly", "daily"} {
+		cfg, err = LoadConfig("", []string{"-rotate-time", period})
+		require.NoError(t, err)
+		assert.Equal(t, period, cfg.Output.RotateTime)
+	}
+
+	_, err = LoadConfig("", []string{"-rotate-time", "weekly"})
+	require.ErrorIs(t, err, apperrors.ErrInvalidRotateTime)
+	assert.Contains(t, err.Error(), "valid periods: hourly, daily")
+}
+
+func TestLoadConfig_AppendSummaryRecord(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Output.AppendSummaryRecord)
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  append_summary_record: true
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrSummaryRecordWithoutJSON)
+
+	cfg, err = LoadConfig(configFile, []string{"-format", "json"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Output.AppendSummaryRecord)
+}
+
+func TestLoadConfig_HealthLineEvery(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-health-line-every", "30s"})
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Output.HeartbeatInterval)
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  heartbeat_interval: -1s
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidHeartbeatInterval)
+}
+
+func TestLoadConfig_ConfigPrecedence(t *testing.T) {
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  format: json
+prefix:
+  template: "[file] "
+`)
+
+	cfg, err := LoadConfig(configFile, []string{"-format", "structured"})
+	require.NoError(t, err)
+	assert.Equal(t, "structured", cfg.Output.Format, "flags override the file by default")
+
+	cfg, err = LoadConfig(configFile, []string{"-config-precedence", "flags,file", "-format", "structured", "-utc"})
+	require.NoError(t, err)
+	assert.Equal(t, "json", cfg.Output.Format, "the file overrides flags it also sets")
+	assert.True(t, cfg.Prefix.Timestamp.UTC, "flags the file does not set still apply")
+	assert.Equal(t, "[file] ", cfg.Prefix.Template)
+
+	cfg, err = LoadConfig("", []string{"-config-precedence", " Flags , FILE ", "-format", "json"})
+	require.NoError(t, err)
+	assert.Equal(t, "json", cfg.Output.Format, "sources are trimmed and case-insensitive")
+
+	for _, value := range []string{"", "flags", "file,file", "file,flags,file", "env,flags", "file,flags,env"} {
+		_, err = LoadConfig(configFile, []string{"-config-precedence", value})
+		require.ErrorIs(t, err, apperrors.ErrInvalidPrecedence, "value %q", value)
+	}
+}
+
+func TestLoadConfig_DedupeWindow(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-dedupe-window", "5s"})
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Output.DedupeWindow)
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  dedupe_window: -1s
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidDedupeWindow)
+}
+
+func TestLoadConfig_Restarts(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Execution.MaxRestarts, "restarts are disabled by default")
+	assert.Equal(t, time.Minute, cfg.Execution.RestartWindow)
+	assert.Equal(t, time.Second, cfg.Execution.RestartBackoff)
+	assert.Equal(t, time.Minute, cfg.Execution.RestartBackoffMax)
+
+	cfg, err = LoadConfig("", []string{"-max-restarts", "5", "-restart-window", "10m"})
+	require.NoError(t, err)
+	assert.Equal(t, 5, cfg.Execution.MaxRestarts)
+	assert.Equal(t, 10*time.Minute, cfg.Execution.RestartWindow)
+
+	_, err = LoadConfig("", []string{"-max-restarts", "-1"})
+	require.ErrorIs(t, err, apperrors.ErrInvalidRestartSetting)
+
+	configFile := testutils.CreateTempConfigFile(t, `
+execution:
+  restart_backoff: -1s
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.ErrorIs(t, err, apperrors.ErrInvalidRestartSetting)
+}
+
+func TestLoadConfig_SummaryFD(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-summary-fd", "3"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.Output.SummaryFD)
+
+	_, err = LoadConfig("", []string{"-summary-fd", "-1"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidSummaryFD)
+}
+
+func TestLoadConfig_RepeatedFlags(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-format", "json", "-format", "structured", "-template", "a ", "-template", "b "})
+	require.NoError(t, err)
+	assert.Equal(t, "structured", cfg.Output.Format, "the last value wins")
+	assert.Equal(t, "b ", cfg.Prefix.Template)
+}
+
+func TestLoadConfig_ConflictingFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"flatten with text format", []string{"-flatten", "-format", "text"}},
+		{"keyword with no-detect", []string{"-no-detect", "-keyword", "warn=slow"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := LoadConfig("", tt.args)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, apperrors.ErrConflictingFlags)
+		})
+	}
+
+	_, err := LoadConfig("", []string{"-flatten", "-format", "json"})
+	assert.NoError(t, err)
+}
+
+func TestLoadConfig_PrefixWidthFlag(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", []string{"-prefix-width", "24"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Output.AlignMessages, "-prefix-width enables alignment")
+	assert.Equal(t, 24, cfg.Output.PrefixWidth)
+
+	_, err = LoadConfig("", []string{"-prefix-width", "-1"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidPrefixWidth)
+}
+
+func TestLoadConfig_CIFieldSources(t *testing.T) {
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, `
+output:
+  auto_ci_fields: true
+  ci_field_sources:
+    ci_commit_sha: [DRONE_COMMIT_SHA]
+    ci_pipeline: [CI_PIPELINE_ID]
+    ci_job_id: []
+`)
+
+	cfg, err := LoadConfig(configFile, []string{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DRONE_COMMIT_SHA"}, cfg.Output.CIFieldSources["ci_commit_sha"])
+	assert.Equal(t, []string{"CI_PIPELINE_ID"}, cfg.Output.CIFieldSources["ci_pipeline"])
+	assert.Empty(t, cfg.Output.CIFieldSources["ci_job_id"])
+	assert.Equal(t, DefaultCIFieldSources()["ci_branch"], cfg.Output.CIFieldSources["ci_branch"],
+		"entries are merged with the defaults")
+
+	_, err = LoadConfig(testutils.CreateTempConfigFile(t, `
+output:
+  ci_field_sources:
+    level: [CI_LEVEL]
+`), []string{})
+	require.ErrorIs(t, err, apperrors.ErrInvalidCustomField, "reserved names are rejected")
+
+	_, err = LoadConfig(testutils.CreateTempConfigFile(t, `
+output:
+  ci_field_sources:
+    ci_pipeline: [""]
+`), []string{})
+	require.ErrorIs(t, err, apperrors.ErrInvalidCustomField, "empty variable names are rejected")
+}
+
+func TestLoadConfig_OnExitTimeout(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadConfig("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, cfg.Execution.OnExitTimeout)
+
+	configFile := testutils.CreateTempConfigFile(t, `
+execution:
+  on_exit_timeout: -1s
+`)
+	_, err = LoadConfig(configFile, []string{})
+	require.ErrorIs(t, err, apperrors.ErrInvalidOnExitTimeout)
+}
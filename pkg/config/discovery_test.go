@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/sgaunet/logwrap/internal/testutils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -186,3 +187,37 @@ log_level:
       info: ["INFO"]
 `
 }
+
+func TestLoadConfig_OptionalConfigMissing(t *testing.T) {
+	t.Parallel()
+
+	missing := filepath.Join(t.TempDir(), "overlay.yaml")
+
+	cfg, err := LoadConfig(missing, []string{"-config", missing, "-config-optional"})
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultConfig().Prefix.Template, cfg.Prefix.Template)
+
+	_, err = LoadConfig(missing, []string{"-config", missing})
+	require.Error(t, err, "a missing config must still fail without -config-optional")
+}
+
+func TestLoadConfig_OptionalConfigPresentButInvalid(t *testing.T) {
+	t.Parallel()
+
+	configFile := testutils.CreateTempConfigFile(t, testutils.InvalidYAMLConfig())
+
+	cfg, err := LoadConfig(configFile, []string{"-config", configFile, "-config-optional"})
+	require.Error(t, err)
+	assert.Nil(t, cfg)
+	assert.Contains(t, err.Error(), "failed to parse YAML config")
+}
+
+func TestIsMissingConfigFile(t *testing.T) {
+	t.Parallel()
+
+	existing := testutils.CreateTempConfigFile(t, testutils.MinimalYAMLConfig())
+
+	assert.False(t, IsMissingConfigFile(""))
+	assert.False(t, IsMissingConfigFile(existing))
+	assert.True(t, IsMissingConfigFile(filepath.Join(t.TempDir(), "missing.yaml")))
+}
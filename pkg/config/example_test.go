@@ -74,5 +74,5 @@ func ExampleConfig_Validate_invalidOutputFormat() {
 
 	err := cfg.Validate()
 	fmt.Println(err)
-	// Output: output configuration error: invalid output format 'xml', valid formats: text, json, structured
+	// Output: output configuration error: invalid output format 'xml', valid formats: text, json, structured, otel
 }
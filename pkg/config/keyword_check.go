@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// KeywordWarnings describes each detection keyword listed under more than
+// one level (the drop pseudo-level included), e.g. "ERROR" under both error
+// and debug. Such a keyword gives a line the most severe of its levels, so
+// the other entries have no effect. Keywords are compared without case
+// unless detection.case_sensitive is set, like lines are. The conflicts are
+// reported as warnings, or as errors with detection.strict_keywords.
+func (c *Config) KeywordWarnings() []string {
+	detection := c.LogLevel.Detection
+	levels := make(map[string][]string) // keyword -> levels listing it
+	spelling := make(map[string]string) // keyword -> first spelling seen
+	for _, level := range slices.Sorted(maps.Keys(detection.Keywords)) {
+		for _, keyword := range detection.Keywords[level] {
+			key := keyword
+			if !detection.CaseSensitive {
+				key = strings.ToUpper(keyword)
+			}
+			if _, ok := spelling[key]; !ok {
+				spelling[key] = keyword
+			}
+			if !slices.Contains(levels[key], level) {
+				levels[key] = append(levels[key], level)
+			}
+		}
+	}
+
+	var warnings []string
+	for _, key := range slices.Sorted(maps.Keys(levels)) {
+		if len(levels[key]) > 1 {
+			warnings = append(warnings, fmt.Sprintf("keyword %q is listed under several levels: %s",
+				spelling[key], strings.Join(levels[key], ", ")))
+		}
+	}
+	return warnings
+}
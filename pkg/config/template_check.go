@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// DefaultCIFieldSources returns the default output.ci_field_sources: per
+// field, the environment variables of common CI systems (GitHub Actions,
+// GitLab CI, CircleCI, Buildkite, Jenkins, Travis, Bitbucket Pipelines) in
+// lookup order.
+func DefaultCIFieldSources() map[string][]string {
+	return map[string][]string{
+		"ci_commit_sha": {
+			"GITHUB_SHA", "CI_COMMIT_SHA", "CIRCLE_SHA1", "BUILDKITE_COMMIT",
+			"GIT_COMMIT", "TRAVIS_COMMIT", "BITBUCKET_COMMIT",
+		},
+		"ci_branch": {
+			"GITHUB_HEAD_REF", "GITHUB_REF_NAME", "GITHUB_REF", "CI_COMMIT_REF_NAME", "CIRCLE_BRANCH",
+			"BUILDKITE_BRANCH", "GIT_BRANCH", "TRAVIS_BRANCH", "BITBUCKET_BRANCH",
+		},
+		"ci_job_id": {
+			"GITHUB_RUN_ID", "CI_JOB_ID", "CIRCLE_BUILD_NUM", "BUILDKITE_JOB_ID",
+			"BUILD_ID", "TRAVIS_JOB_ID", "BITBUCKET_BUILD_NUMBER",
+		},
+	}
+}
+
+// TemplateWarnings cross-checks the data the prefix template references
+// against the enabled features and describes each reference that always
+// renders empty, such as {{.User}} with user disabled or {{.Fields.x}}
+// without a field named x. Templates that fail to parse yield no warnings;
+// [Config.Validate] reports those.
+func (c *Config) TemplateWarnings() []string {
+	tmpl, err := template.New("prefix").Funcs(TemplateFuncs()).Parse(c.Prefix.Template)
+	if err != nil || tmpl.Tree == nil {
+		return nil
+	}
+
+	var warnings []string
+	seen := make(map[string]bool)
+	walkFieldRefs(tmpl.Tree.Root, func(ident []string, joined bool) {
+		ref := "{{." + strings.Join(ident, ".") + "}}"
+		if seen[ref] {
+			return
+		}
+		seen[ref] = true
+		if msg := c.checkTemplateField(ident, joined); msg != "" {
+			warnings = append(warnings, ref+" "+msg)
+		}
+	})
+	return warnings
+}
+
+// checkTemplateField returns why a referenced field always renders empty,
+// or "" when it can carry a value. Disabled user and PID are not reported
+// with tidy_empty_segments, which removes what they leave behind, nor when
+// joined is set: join already drops them.
+func (c *Config) checkTemplateField(ident []string, joined bool) string {
+	tidied := joined || c.Prefix.TidyEmptySegments
+	switch ident[0] {
+	case "User":
+		if !c.Prefix.User.Enabled && !tidied {
+			return "renders empty: prefix.user.enabled is false"
+		}
+	case "PID":
+		if !c.Prefix.PID.Enabled && !tidied {
+			return "renders empty: prefix.pid.enabled is false"
+		}
+	case "PPID":
+		if !c.Prefix.PPID.Enabled {
+			return "renders empty: prefix.ppid.enabled is false"
+		}
+	case "Command":
+		if !c.Prefix.Command.Enabled {
+			return "renders empty: prefix.command.enabled is false"
+		}
+	case "Delta":
+		if !c.Output.IncludeDelta {
+			return "is always 0s: output.include_delta is false"
+		}
+	case "MatchedKeyword":
+		if !c.LogLevel.Detection.IncludeMatch {
+			return "renders empty: log_level.detection.include_match is false"
+		}
+	case "Fields":
+		if len(ident) < 2 {
+			return ""
+		}
+		name := ident[1]
+		if _, ok := c.LogLevel.Detection.ExtractFields[name]; ok {
+			return ""
+		}
+		if _, ok := c.Output.CustomFields[name]; ok {
+			return ""
+		}
+		if envs := c.Output.CIFieldSources[name]; len(envs) > 0 {
+			if c.Output.AutoCIFields {
+				return ""
+			}
+			return "renders empty: output.auto_ci_fields is false"
+		}
+		return fmt.Sprintf("renders empty: no field %q is defined in log_level.detection.extract_fields", name)
+	}
+	return ""
+}
+
+// walkTemplateFields calls fn with the identifier chain of every field
+// reference ({{.A.B}} yields ["A", "B"]) in the template tree.
+func walkTemplateFields(node parse.Node, fn func(ident []string)) {
+	walkFieldRefs(node, func(ident []string, _ bool) { fn(ident) })
+}
+
+// walkFieldRefs is walkTemplateFields, also telling fn whether the field is
+// passed directly to join, which drops it when it is empty.
+func walkFieldRefs(node parse.Node, fn func(ident []string, joined bool)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkFieldRefs(child, fn)
+		}
+	case *parse.ActionNode:
+		walkFieldRefs(n.Pipe, fn)
+	case *parse.IfNode:
+		walkTemplateBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkTemplateBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkTemplateBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		walkFieldRefs(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkFieldRefs(cmd, fn)
+		}
+	case *parse.CommandNode:
+		joined := len(n.Args) > 0 && isIdentifier(n.Args[0], "join")
+		for _, arg := range n.Args {
+			if field, ok := arg.(*parse.FieldNode); ok {
+				fn(field.Ident, joined)
+				continue
+			}
+			walkFieldRefs(arg, fn)
+		}
+	case *parse.FieldNode:
+		fn(n.Ident, false)
+	}
+}
+
+func walkTemplateBranch(n *parse.BranchNode, fn func(ident []string, joined bool)) {
+	walkFieldRefs(n.Pipe, fn)
+	walkFieldRefs(n.List, fn)
+	walkFieldRefs(n.ElseList, fn)
+}
+
+func isIdentifier(node parse.Node, name string) bool {
+	ident, ok := node.(*parse.IdentifierNode)
+	return ok && ident.Ident == name
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs returns the functions available to the prefix template and
+// templated custom fields:
+//
+//   - join SEP VALUE...: the non-empty values joined with SEP, so
+//     "[{{join "] [" .Timestamp .Level (join ":" .User .PID)}}] " renders
+//     "[ts] [INFO] " rather than "[ts] [INFO] [:] " when user and PID are
+//     disabled.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"join": joinNonEmpty,
+	}
+}
+
+// joinNonEmpty joins the values that do not print as the empty string.
+func joinNonEmpty(sep string, values ...any) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		if s := fmt.Sprint(value); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinNonEmpty(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a:b", joinNonEmpty(":", "a", "b"))
+	assert.Equal(t, "a:c", joinNonEmpty(":", "a", "", "c"))
+	assert.Equal(t, "b", joinNonEmpty(":", "", "b", ""))
+	assert.Empty(t, joinNonEmpty(":", "", ""))
+	assert.Empty(t, joinNonEmpty(":"))
+	assert.Equal(t, "INFO 6 7", joinNonEmpty(" ", "INFO", 6, 7), "non-string values are printed")
+}
+
+func TestValidateTemplate_Join(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateTemplate(`[{{join "] [" .Timestamp .Level (join ":" .User .PID)}}] `))
+	assert.Error(t, validateTemplate(`[{{join ":" .Nope}}] `), "unknown fields are still rejected")
+	assert.Error(t, validateTemplate(`[{{join}}] `), "the separator is required")
+}
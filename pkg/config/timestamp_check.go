@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// instantComponents are the parts of an instant, in the order they are
+// reported by [Config.TimestampWarnings].
+var instantComponents = []string{"year", "month", "day", "hour", "minute", "second"}
+
+// timestampComponents maps strftime directives to the parts of an instant
+// they carry. Directives not listed (weekday, week number, time zone,
+// literals) carry none of them.
+var timestampComponents = map[byte][]string{
+	'Y': {"year"}, 'y': {"year"}, 'G': {"year"}, 'g': {"year"},
+	'm': {"month"}, 'b': {"month"}, 'B': {"month"}, 'h': {"month"},
+	'd': {"day"}, 'e': {"day"}, 'j': {"month", "day"},
+	'D': {"year", "month", "day"}, 'F': {"year", "month", "day"}, 'x': {"year", "month", "day"},
+	'H': {"hour"}, 'I': {"hour"}, 'k': {"hour"}, 'l': {"hour"},
+	'M': {"minute"}, 'S': {"second"},
+	'R': {"hour", "minute"}, 'T': {"hour", "minute", "second"},
+	'r': {"hour", "minute", "second"}, 'X': {"hour", "minute", "second"},
+	'c': instantComponents, 's': instantComponents,
+}
+
+// TimestampWarnings describes the parts of an instant that the timestamp
+// format cannot represent, e.g. the date for "%H:%M:%S", which makes lines
+// from different days indistinguishable. Such formats are valid; the
+// warnings are reported with prefix.timestamp.strict (-strict-timestamp).
+// Formats with unknown directives yield no warnings; [Config.Validate]
+// reports those.
+func (c *Config) TimestampWarnings() []string {
+	format := c.Prefix.Timestamp.Format
+	if validateStrftimeDirectives(format) != nil {
+		return nil
+	}
+
+	covered := make(map[string]bool, len(instantComponents))
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++ // skip '%'
+		if format[i] == '-' || format[i] == '_' || format[i] == '0' {
+			i++
+		}
+		for _, component := range timestampComponents[format[i]] {
+			covered[component] = true
+		}
+	}
+
+	var missing []string
+	for _, component := range instantComponents {
+		if !covered[component] {
+			missing = append(missing, component)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("format %q cannot represent a full instant: no %s",
+		format, strings.Join(missing, ", "))}
+}
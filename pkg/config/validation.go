@@ -3,8 +3,10 @@ package config
 import (
 	"fmt"
 	"io"
+	"math"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -19,7 +21,7 @@ import (
 // than collecting all errors. This keeps error messages actionable — users fix
 // one issue at a time.
 //
-// Validation order: prefix → output → log level. Within prefix validation,
+// Validation order: prefix → output → log level → filter → execution. Within prefix validation,
 // sub-fields are checked in order: template → timestamp → colors → user → PID.
 func (c *Config) Validate() error {
 	if err := c.validatePrefix(); err != nil {
@@ -38,12 +40,17 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("filter configuration error: %w", err)
 	}
 
+	if err := c.validateExecution(); err != nil {
+		return fmt.Errorf("execution configuration error: %w", err)
+	}
+
 	return nil
 }
 
 // validatePrefix validates all prefix-related configuration.
 //
-// It requires a non-empty template, then validates sub-fields in order:
+// It requires a non-empty template (which, with strict_template, must not
+// reference data that is never set), then validates sub-fields in order:
 // timestamp format, colors, user format, and PID format. Returns the first
 // error encountered.
 func (c *Config) validatePrefix() error {
@@ -55,6 +62,12 @@ func (c *Config) validatePrefix() error {
 		return fmt.Errorf("template error: %w", err)
 	}
 
+	if c.Prefix.StrictTemplate {
+		if warnings := c.TemplateWarnings(); len(warnings) > 0 {
+			return fmt.Errorf("%w: %s", apperrors.ErrTemplateFieldUnavailable, strings.Join(warnings, "; "))
+		}
+	}
+
 	if err := c.validateTimestamp(); err != nil {
 		return fmt.Errorf("timestamp config error: %w", err)
 	}
@@ -81,14 +94,18 @@ func (c *Config) validatePrefix() error {
 // The test struct fields must match formatter.TemplateData. We define them
 // locally to avoid a circular import (config ← formatter).
 func validateTemplate(tmplStr string) error {
-	tmpl, err := template.New("prefix").Parse(tmplStr)
+	tmpl, err := template.New("prefix").Funcs(TemplateFuncs()).Parse(tmplStr)
 	if err != nil {
 		return fmt.Errorf("%w: %w", apperrors.ErrInvalidTemplate, err)
 	}
 
 	testData := struct {
-		Timestamp, Level, User, PID, Line string
-	}{"t", "t", "t", "t", "t"}
+		Timestamp, Level, User, PID, PPID, Command, Host, Line, Raw string
+		LineNo, Severity                                            int
+		Fields                                                      map[string]string
+		ExitCode, Duration, MatchedKeyword                          string
+		Delta                                                       time.Duration
+	}{"t", "t", "t", "t", "t", "t", "t", "t", "t", 1, 6, nil, "0", "1s", "t", time.Millisecond}
 
 	if err := tmpl.Execute(io.Discard, testData); err != nil {
 		return fmt.Errorf("%w: %w", apperrors.ErrInvalidTemplate, err)
@@ -170,6 +187,15 @@ func (c *Config) validateTimestamp() error {
 		return err
 	}
 
+	if err := validateStrftimeDirectives(c.Prefix.Timestamp.Fallback); err != nil {
+		return fmt.Errorf("fallback: %w", err)
+	}
+
+	if c.Prefix.Timestamp.CacheInterval < 0 {
+		return fmt.Errorf("%w %s, must be 0 (disabled) or greater",
+			apperrors.ErrInvalidCacheInterval, c.Prefix.Timestamp.CacheInterval)
+	}
+
 	// Phase 2: round-trip test for format/parse compatibility
 	now := time.Now()
 	formatted := timefmt.Format(now, c.Prefix.Timestamp.Format)
@@ -218,19 +244,6 @@ func validateStrftimeDirectives(format string) error {
 // An empty string is also accepted (treated as no color override).
 // Matching is case-insensitive: "Red", "RED", and "red" are all valid.
 func (c *Config) validateColors() error {
-	validColors := map[string]bool{
-		"black":   true,
-		"red":     true,
-		"green":   true,
-		"yellow":  true,
-		"blue":    true,
-		"magenta": true,
-		"cyan":    true,
-		"white":   true,
-		"none":    true,
-		"":        true,
-	}
-
 	colors := []struct {
 		name  string
 		value string
@@ -241,7 +254,7 @@ func (c *Config) validateColors() error {
 	}
 
 	for _, color := range colors {
-		if !validColors[strings.ToLower(color.value)] {
+		if color.value != "" && !slices.Contains(colorNames, strings.ToLower(color.value)) {
 			return fmt.Errorf("%w '%s' for %s, valid colors: %s",
 				apperrors.ErrInvalidColor, color.value, color.name, getValidColorsString())
 		}
@@ -256,32 +269,290 @@ func (c *Config) validateColors() error {
 //   - "username": displays the login name (e.g., "alice")
 //   - "uid": displays the numeric user ID (e.g., "1000")
 //   - "full": displays both as username(uid) (e.g., "alice(1000)")
+//   - "user_host": displays username@host (e.g., "alice@build-01")
+//   - "user_group": displays username:group with the primary group
+//     (e.g., "alice:staff")
 func (c *Config) validateUser() error {
 	return validateOneOf(
-		c.Prefix.User.Format, []string{"username", "uid", "full"},
+		c.Prefix.User.Format, []string{"username", "uid", "full", "user_host", "user_group"},
 		"formats", apperrors.ErrInvalidUserFormat,
 	)
 }
 
-// validatePID validates the process ID display format.
+// validatePID validates the process ID display format and source.
 //
 // Valid formats:
 //   - "decimal": displays PID as a decimal number (e.g., "1234")
 //   - "hex": displays PID as a hexadecimal number (e.g., "0x4d2")
+//
+// Valid sources are "self" and "child" (empty is treated as "self").
 func (c *Config) validatePID() error {
+	if c.Prefix.PID.Source != "" {
+		if err := validateOneOf(
+			c.Prefix.PID.Source, []string{"self", "child"}, "sources", apperrors.ErrInvalidPIDSource,
+		); err != nil {
+			return err
+		}
+	}
 	return validateOneOf(c.Prefix.PID.Format, []string{"decimal", "hex"}, "formats", apperrors.ErrInvalidPIDFormat)
 }
 
-// validateOutput validates the output format setting.
+// validateOutput validates the output settings.
 //
-// Valid formats: "text", "json", "structured".
+// Valid formats: "text", "json", "structured", "otel". The broken pipe exit code
+// must be within 0-255. Flatten requires JSON passthrough, and so does a JSON
+// parse failure policy other than "wrap" (empty is treated as "wrap"). A summary
+// record requires the "json" format. The prefix width,
+// heartbeat interval and reorder window must not be negative. The format error policy must be "raw",
+// "drop" or "error" (empty is treated as "raw"), and the line ending "lf" or
+// "crlf" (empty is treated as "lf"). The strip input prefix pattern must
+// compile and its stage must be "after_detection" or "before_detection"
+// (empty is treated as "after_detection"). stderr_on_level, when set, must
+// be a log level with a positive line limit. Every sink must be valid, and
+// rate_per_level must map log levels to rates greater than 0.
 func (c *Config) validateOutput() error {
+	if code := c.Output.BrokenPipeExitCode; code < 0 || code > maxExitCode {
+		return fmt.Errorf("%w %d in broken_pipe_exit_code, valid range: 0-%d",
+			apperrors.ErrInvalidExitCode, code, maxExitCode)
+	}
+
+	if c.Output.Flatten && !c.Output.JSONPassthrough {
+		return apperrors.ErrFlattenWithoutPassthrough
+	}
+
+	if policy := c.Output.OnJSONParseFailure; policy != "" {
+		if err := validateOneOf(
+			policy, []string{"wrap", "passthrough", "drop"}, "policies", apperrors.ErrInvalidJSONParseFailurePolicy,
+		); err != nil {
+			return err
+		}
+		if policy != "wrap" && !c.Output.JSONPassthrough {
+			return apperrors.ErrParseFailureWithoutPassthrough
+		}
+	}
+
+	if c.Output.RotateTime != "" {
+		if err := validateOneOf(
+			c.Output.RotateTime, []string{"hourly", "daily"}, "periods", apperrors.ErrInvalidRotateTime,
+		); err != nil {
+			return err
+		}
+	}
+
+	if c.Output.AppendSummaryRecord && c.Output.Format != "json" {
+		return fmt.Errorf("%w, got %q", apperrors.ErrSummaryRecordWithoutJSON, c.Output.Format)
+	}
+
+	if c.Output.PrefixWidth < 0 {
+		return fmt.Errorf("%w %d, must be 0 (widest seen) or greater",
+			apperrors.ErrInvalidPrefixWidth, c.Output.PrefixWidth)
+	}
+
+	if c.Output.SquashBlankLinesTo < 0 {
+		return fmt.Errorf("%w %d in squash_blank_lines_to, must be 0 or greater",
+			apperrors.ErrInvalidBlankLines, c.Output.SquashBlankLinesTo)
+	}
+
+	if c.Output.ContextBefore < 0 {
+		return fmt.Errorf("%w %d in context_before, must be 0 or greater",
+			apperrors.ErrInvalidContextLines, c.Output.ContextBefore)
+	}
+
+	if c.Output.ReorderWindow < 0 {
+		return fmt.Errorf("%w %s, must be 0 (disabled) or greater",
+			apperrors.ErrInvalidReorderWindow, c.Output.ReorderWindow)
+	}
+
+	if c.Output.Workers < 0 {
+		return fmt.Errorf("%w %d, must be 0 (disabled) or greater", apperrors.ErrInvalidWorkers, c.Output.Workers)
+	}
+
+	if c.Output.ReadAhead < 0 {
+		return fmt.Errorf("%w %d, must be 0 (disabled) or greater", apperrors.ErrInvalidReadAhead, c.Output.ReadAhead)
+	}
+
+	if c.Output.DedupeWindow < 0 {
+		return fmt.Errorf("%w %s, must be 0 (disabled) or greater",
+			apperrors.ErrInvalidDedupeWindow, c.Output.DedupeWindow)
+	}
+
+	if c.Output.SummaryFD < 0 {
+		return fmt.Errorf("%w %d, must be 0 (disabled) or greater", apperrors.ErrInvalidSummaryFD, c.Output.SummaryFD)
+	}
+
+	if c.Output.HeartbeatInterval < 0 {
+		return fmt.Errorf("%w %s, must be 0 (disabled) or greater",
+			apperrors.ErrInvalidHeartbeatInterval, c.Output.HeartbeatInterval)
+	}
+
+	if c.Output.OnFormatError != "" {
+		if err := validateOneOf(
+			c.Output.OnFormatError, []string{"raw", "drop", "error"},
+			"policies", apperrors.ErrInvalidFormatErrorPolicy,
+		); err != nil {
+			return err
+		}
+	}
+
+	if c.Output.ControlChars != "" {
+		if err := validateOneOf(
+			c.Output.ControlChars, []string{"keep", "escape", "strip"}, "modes", apperrors.ErrInvalidControlChars,
+		); err != nil {
+			return err
+		}
+	}
+
+	if c.Output.Progress != "" {
+		if err := validateOneOf(
+			c.Output.Progress, []string{"keep", "final"}, "modes", apperrors.ErrInvalidProgressMode,
+		); err != nil {
+			return err
+		}
+	}
+
+	if c.Output.LineEnding != "" {
+		if err := validateOneOf(
+			c.Output.LineEnding, []string{"lf", "crlf"}, "line endings", apperrors.ErrInvalidLineEnding,
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := c.validateStripInputPrefix(); err != nil {
+		return err
+	}
+
+	if pattern := c.Output.SkipPrefixIfMatches; pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%w %q: %w", apperrors.ErrInvalidSkipPattern, pattern, err)
+		}
+	}
+
+	if err := c.validateStderrOnLevel(); err != nil {
+		return err
+	}
+
+	if err := c.validateCIFieldSources(); err != nil {
+		return err
+	}
+	if err := c.validateCustomFields(); err != nil {
+		return err
+	}
+
+	for i, sink := range c.Output.Sinks {
+		if err := validateSink(sink); err != nil {
+			return fmt.Errorf("sink %d: %w", i+1, err)
+		}
+	}
+
+	if err := c.validateRoutes(); err != nil {
+		return err
+	}
+
+	if err := c.validateSeverityMap(); err != nil {
+		return err
+	}
+
+	if err := c.validateRatePerLevel(); err != nil {
+		return err
+	}
+
 	return validateOneOf(
-		c.Output.Format, []string{"text", "json", "structured"},
-		"formats", apperrors.ErrInvalidOutputFormat,
+		c.Output.Format, outputFormats, "formats", apperrors.ErrInvalidOutputFormat,
 	)
 }
 
+// validateSink checks the sink type, that file sinks have a path, and the
+// format override if one is set; journald sinks take none.
+func validateSink(sink SinkConfig) error {
+	if err := validateOneOf(
+		sink.Type, []string{"stdout", "stderr", "file", "journald"}, "types", apperrors.ErrInvalidSinkType,
+	); err != nil {
+		return err
+	}
+	if sink.Type == "file" && sink.Path == "" {
+		return apperrors.ErrSinkPathRequired
+	}
+	if sink.Format == "" {
+		return nil
+	}
+	if sink.Type == "journald" {
+		return fmt.Errorf("%w, got %q", apperrors.ErrJournaldSinkFormat, sink.Format)
+	}
+	return validateOneOf(
+		sink.Format, outputFormats, "formats", apperrors.ErrInvalidOutputFormat,
+	)
+}
+
+// routePrimary is the route destination naming logwrap's own output.
+const routePrimary = "primary"
+
+// validateRoutes checks that sink names are unique and that every route is
+// keyed by a log level and lists known destinations.
+func (c *Config) validateRoutes() error {
+	names := map[string]bool{routePrimary: true}
+	for i, sink := range c.Output.Sinks {
+		if sink.Name == "" {
+			continue
+		}
+		if names[sink.Name] {
+			return fmt.Errorf("sink %d: %w %q", i+1, apperrors.ErrDuplicateSinkName, sink.Name)
+		}
+		names[sink.Name] = true
+	}
+
+	validLevels := []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+	for level, dests := range c.Output.Routes {
+		if !slices.Contains(validLevels, strings.ToUpper(level)) {
+			return fmt.Errorf("%w: unknown level '%s', valid levels: %s",
+				apperrors.ErrInvalidRoute, level, strings.Join(validLevels, ", "))
+		}
+		for _, dest := range dests {
+			if !names[dest] {
+				return fmt.Errorf("%w: level '%s' routes to unknown sink %q", apperrors.ErrInvalidRoute, level, dest)
+			}
+		}
+	}
+	return nil
+}
+
+// maxSeverity is the least severe syslog severity (debug).
+const maxSeverity = 7
+
+// validateSeverityMap checks that the severity map is keyed by log levels
+// and maps them to syslog severities 0-7.
+func (c *Config) validateSeverityMap() error {
+	validLevels := []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+	for level, severity := range c.Output.SeverityMap {
+		if !slices.Contains(validLevels, strings.ToUpper(level)) {
+			return fmt.Errorf("%w: unknown level '%s', valid levels: %s",
+				apperrors.ErrInvalidSeverity, level, strings.Join(validLevels, ", "))
+		}
+		if severity < 0 || severity > maxSeverity {
+			return fmt.Errorf("%w: severity %d for level '%s', valid range: 0-%d",
+				apperrors.ErrInvalidSeverity, severity, level, maxSeverity)
+		}
+	}
+	return nil
+}
+
+// validateRatePerLevel checks that line rates are keyed by log levels and
+// are greater than 0.
+func (c *Config) validateRatePerLevel() error {
+	validLevels := []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+	for level, rate := range c.Output.RatePerLevel {
+		if !slices.Contains(validLevels, strings.ToUpper(level)) {
+			return fmt.Errorf("%w: unknown level '%s', valid levels: %s",
+				apperrors.ErrInvalidLevelRate, level, strings.Join(validLevels, ", "))
+		}
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return fmt.Errorf("%w %v for level '%s', must be greater than 0 (omit the level to disable)",
+				apperrors.ErrInvalidLevelRate, rate, level)
+		}
+	}
+	return nil
+}
+
 // validateOneOf checks that value is one of validValues. If not, it returns
 // an error wrapping errType with the invalid value and list of valid options.
 func validateOneOf(value string, validValues []string, desc string, errType error) error {
@@ -303,6 +574,13 @@ func validateOneOf(value string, validValues []string, desc string, errType erro
 //   - Each keyword map key must be a valid log level
 //   - Empty keyword arrays are rejected — if a level is listed, it must have keywords
 //   - Empty strings within keyword arrays are rejected
+//   - With strict_keywords, a keyword listed under several levels is rejected
+//
+// The level cache size must not be negative, and the level key must be a
+// bare key without separators or quotes.
+//
+// Extracted fields must use a non-reserved name and a regular expression
+// with at least one capture group.
 func (c *Config) validateLogLevel() error {
 	validLevels := []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
 
@@ -316,13 +594,37 @@ func (c *Config) validateLogLevel() error {
 			apperrors.ErrInvalidStderrLogLevel, c.LogLevel.DefaultStderr, strings.Join(validLevels, ", "))
 	}
 
+	if c.LogLevel.CacheSize < 0 {
+		return fmt.Errorf("%w %d, must be 0 (disabled) or greater",
+			apperrors.ErrInvalidCacheSize, c.LogLevel.CacheSize)
+	}
+
+	if c.LogLevel.Detection.MaxScanBytes < 0 {
+		return fmt.Errorf("%w %d, must be 0 (unlimited) or greater",
+			apperrors.ErrInvalidMaxScanBytes, c.LogLevel.Detection.MaxScanBytes)
+	}
+
+	if strings.ContainsAny(c.LogLevel.Detection.LevelKey, " \t=:\"") {
+		return fmt.Errorf("%w %q, must not contain spaces, '=', ':' or quotes",
+			apperrors.ErrInvalidLevelKey, c.LogLevel.Detection.LevelKey)
+	}
+
 	// Check for conflicting configuration: detection disabled but keywords provided
 	if !c.LogLevel.Detection.Enabled && len(c.LogLevel.Detection.Keywords) > 0 {
 		return apperrors.ErrDetectionDisabledWithKeywords
 	}
 
+	if err := validateExtractFields(c.LogLevel.Detection.ExtractFields); err != nil {
+		return err
+	}
+	if err := validateExtractFieldTypes(c.LogLevel.Detection); err != nil {
+		return err
+	}
+
 	for level, keywords := range c.LogLevel.Detection.Keywords {
-		if !isValidLogLevel(strings.ToUpper(level), validLevels) {
+		// The drop pseudo-level is only meaningful here; default levels
+		// and filter levels still reject it.
+		if !strings.EqualFold(level, DropLevel) && !isValidLogLevel(strings.ToUpper(level), validLevels) {
 			return fmt.Errorf("%w '%s' in detection keywords", apperrors.ErrInvalidLogLevel, level)
 		}
 
@@ -339,6 +641,54 @@ func (c *Config) validateLogLevel() error {
 		}
 	}
 
+	if c.LogLevel.Detection.StrictKeywords {
+		if warnings := c.KeywordWarnings(); len(warnings) > 0 {
+			return fmt.Errorf("%w: %s", apperrors.ErrConflictingKeyword, strings.Join(warnings, "; "))
+		}
+	}
+
+	return nil
+}
+
+// reservedFieldNames are the keys logwrap itself writes in JSON and
+// structured output. Extracted fields may not shadow them.
+var reservedFieldNames = []string{"timestamp", "level", "message", "user", "pid", "ppid", "command", "line_no", "raw", "matched_keyword", "delta_ms"}
+
+// validateExtractFields checks that every extracted field has a usable name
+// and a regular expression with at least one capture group.
+func validateExtractFields(fields map[string]string) error {
+	for name, pattern := range fields {
+		if name == "" {
+			return apperrors.ErrEmptyFieldName
+		}
+		if slices.Contains(reservedFieldNames, name) {
+			return fmt.Errorf("%w %q, reserved names: %s",
+				apperrors.ErrReservedFieldName, name, strings.Join(reservedFieldNames, ", "))
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%w %q for field %q: %w", apperrors.ErrInvalidExtractPattern, pattern, name, err)
+		}
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("%w %q for field %q: no capture group", apperrors.ErrInvalidExtractPattern, pattern, name)
+		}
+	}
+	return nil
+}
+
+// validateExtractFieldTypes checks that every type hint names an extracted
+// field and a known type.
+func validateExtractFieldTypes(detection DetectionConfig) error {
+	for name, kind := range detection.ExtractFieldTypes {
+		if _, ok := detection.ExtractFields[name]; !ok {
+			return fmt.Errorf("%w: %q is not an extracted field", apperrors.ErrInvalidFieldType, name)
+		}
+		if err := validateOneOf(
+			kind, []string{"string", "int", "float", "bool"}, "field types", apperrors.ErrInvalidFieldType,
+		); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -363,6 +713,98 @@ func isValidLogLevel(level string, validLevels []string) bool {
 	return false
 }
 
+// validateCIFieldSources checks that every CI field has a usable name and
+// that its environment variable names are not empty.
+func (c *Config) validateCIFieldSources() error {
+	for name, envs := range c.Output.CIFieldSources {
+		if name == "" {
+			return fmt.Errorf("%w: CI field name cannot be empty", apperrors.ErrInvalidCustomField)
+		}
+		if slices.Contains(reservedFieldNames, name) {
+			return fmt.Errorf("%w %q: name is reserved, reserved names: %s",
+				apperrors.ErrInvalidCustomField, name, strings.Join(reservedFieldNames, ", "))
+		}
+		if slices.Contains(envs, "") {
+			return fmt.Errorf("%w %q: environment variable name cannot be empty", apperrors.ErrInvalidCustomField, name)
+		}
+	}
+	return nil
+}
+
+// validateCustomFields checks that every custom field has a usable name and
+// that templated values are valid templates. Templated values may not
+// reference templated custom fields (themselves included), which would make
+// rendering recursive or order-dependent.
+func (c *Config) validateCustomFields() error {
+	for name, value := range c.Output.CustomFields {
+		if name == "" {
+			return fmt.Errorf("%w: name cannot be empty", apperrors.ErrInvalidCustomField)
+		}
+		if slices.Contains(reservedFieldNames, name) {
+			return fmt.Errorf("%w %q: name is reserved, reserved names: %s",
+				apperrors.ErrInvalidCustomField, name, strings.Join(reservedFieldNames, ", "))
+		}
+		if !strings.Contains(value, "{{") {
+			continue
+		}
+		if err := validateTemplate(value); err != nil {
+			return fmt.Errorf("%w %q: %w", apperrors.ErrInvalidCustomField, name, err)
+		}
+
+		tmpl, err := template.New(name).Funcs(TemplateFuncs()).Parse(value)
+		if err != nil || tmpl.Tree == nil {
+			continue
+		}
+		var ref string
+		walkTemplateFields(tmpl.Tree.Root, func(ident []string) {
+			if len(ident) < 2 || ident[0] != "Fields" || ref != "" {
+				return
+			}
+			if other, ok := c.Output.CustomFields[ident[1]]; ok && strings.Contains(other, "{{") {
+				ref = ident[1]
+			}
+		})
+		if ref != "" {
+			return fmt.Errorf("%w: %q references %q", apperrors.ErrCustomFieldRecursion, name, ref)
+		}
+	}
+	return nil
+}
+
+// validateStripInputPrefix validates the strip input prefix pattern and stage.
+func (c *Config) validateStripInputPrefix() error {
+	if pattern := c.Output.StripInputPrefixPattern; pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%w %q: %w", apperrors.ErrInvalidStripPattern, pattern, err)
+		}
+	}
+	if c.Output.StripInputPrefixStage == "" {
+		return nil
+	}
+	return validateOneOf(
+		c.Output.StripInputPrefixStage, []string{"after_detection", "before_detection"},
+		"stages", apperrors.ErrInvalidStripStage,
+	)
+}
+
+// validateStderrOnLevel validates the stderr_on_level threshold and its
+// line limit. Both are ignored when the threshold is empty.
+func (c *Config) validateStderrOnLevel() error {
+	if c.Output.StderrOnLevel == "" {
+		return nil
+	}
+	validLevels := []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+	if !isValidLogLevel(strings.ToUpper(c.Output.StderrOnLevel), validLevels) {
+		return fmt.Errorf("%w '%s' in stderr_on_level, valid levels: %s",
+			apperrors.ErrInvalidLogLevel, c.Output.StderrOnLevel, strings.Join(validLevels, ", "))
+	}
+	if c.Output.StderrOnLevelMaxLines < 1 {
+		return fmt.Errorf("%w %d in stderr_on_level_max_lines, must be 1 or greater",
+			apperrors.ErrInvalidBufferLines, c.Output.StderrOnLevelMaxLines)
+	}
+	return nil
+}
+
 // validateFilter validates filter patterns and level-based filtering rules.
 //
 // Empty strings in exclude_patterns or include_patterns are rejected because
@@ -373,6 +815,8 @@ func isValidLogLevel(level string, validLevels []string) bool {
 // detection to be enabled, since level detection is the mechanism
 // that assigns levels to lines. Without detection, all lines have
 // an empty detected level and level filters silently drop everything.
+// only_levels does not: without detection, lines keep their stream's
+// default level.
 func (c *Config) validateFilter() error {
 	if !c.Filter.Enabled {
 		return nil
@@ -391,6 +835,9 @@ func (c *Config) validateFilter() error {
 	if err := validateFilterLevelNames(c.Filter.ExcludeLevels, "exclude_levels", validLevels); err != nil {
 		return err
 	}
+	if err := validateFilterLevelNames(c.Filter.OnlyLevels, "only_levels", validLevels); err != nil {
+		return err
+	}
 	if err := validateFilterPatterns(c.Filter.ExcludePatterns, "exclude_patterns"); err != nil {
 		return err
 	}
@@ -428,7 +875,115 @@ func validateRegexPatterns(patterns []string, field string) error {
 	return nil
 }
 
+// maxExitCode is the largest exit status a process can report on UNIX.
+const maxExitCode = 255
+
+// validateExecution validates the wrapped command's execution settings.
+//
+// Each success exit code must be within 0-255. An empty list is accepted
+// and treated as [0]. Start retries and their delay, and the on_exit
+// timeout, must not be negative.
+// The exit level map must map exit codes, "nonzero" or "signal" to log
+// levels. The pipeline policy must be "last" or "any" (empty is treated as
+// "last").
+func (c *Config) validateExecution() error {
+	for _, code := range c.Execution.SuccessExitCodes {
+		if code < 0 || code > maxExitCode {
+			return fmt.Errorf("%w %d in success_exit_codes, valid range: 0-%d",
+				apperrors.ErrInvalidExitCode, code, maxExitCode)
+		}
+	}
+
+	if c.Execution.StartRetries < 0 {
+		return fmt.Errorf("%w: start_retries must be >= 0, got %d",
+			apperrors.ErrInvalidStartRetries, c.Execution.StartRetries)
+	}
+	if c.Execution.StartRetryDelay < 0 {
+		return fmt.Errorf("%w: start_retry_delay must be >= 0, got %v",
+			apperrors.ErrInvalidStartRetries, c.Execution.StartRetryDelay)
+	}
+
+	if err := c.validateRestarts(); err != nil {
+		return err
+	}
+	if c.Execution.OnExitTimeout < 0 {
+		return fmt.Errorf("%w: on_exit_timeout must be >= 0, got %v",
+			apperrors.ErrInvalidOnExitTimeout, c.Execution.OnExitTimeout)
+	}
+
+	if err := c.validateExitLevelMap(); err != nil {
+		return err
+	}
+
+	if c.Execution.PipelinePolicy == "" {
+		return nil
+	}
+	return validateOneOf(
+		c.Execution.PipelinePolicy, []string{"last", "any"},
+		"policies", apperrors.ErrInvalidPipelinePolicy,
+	)
+}
+
+// validateRestarts checks that max_restarts and the restart durations are
+// not negative.
+func (c *Config) validateRestarts() error {
+	if c.Execution.MaxRestarts < 0 {
+		return fmt.Errorf("%w: max_restarts must be >= 0, got %d",
+			apperrors.ErrInvalidRestartSetting, c.Execution.MaxRestarts)
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"restart_window", c.Execution.RestartWindow},
+		{"restart_backoff", c.Execution.RestartBackoff},
+		{"restart_backoff_max", c.Execution.RestartBackoffMax},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return fmt.Errorf("%w: %s must be >= 0, got %v", apperrors.ErrInvalidRestartSetting, d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// validateExitLevelMap checks that exit_level_map is keyed by exit codes
+// (0-255), "nonzero" or "signal" and maps them to log levels.
+func (c *Config) validateExitLevelMap() error {
+	validLevels := []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+	for key, level := range c.Execution.ExitLevelMap {
+		if key != ExitLevelNonZero && key != ExitLevelSignal {
+			if code, err := strconv.Atoi(key); err != nil || code < 0 || code > maxExitCode {
+				return fmt.Errorf("%w: key '%s', expected an exit code 0-%d, %s or %s",
+					apperrors.ErrInvalidExitLevel, key, maxExitCode, ExitLevelNonZero, ExitLevelSignal)
+			}
+		}
+		if !slices.Contains(validLevels, strings.ToUpper(level)) {
+			return fmt.Errorf("%w: unknown level '%s' for '%s', valid levels: %s",
+				apperrors.ErrInvalidExitLevel, level, key, strings.Join(validLevels, ", "))
+		}
+	}
+	return nil
+}
+
+// outputFormats and colorNames are the values accepted for output.format
+// and the prefix.colors fields. Validation and the -list-formats and
+// -list-colors flags both read them, so the two cannot drift apart.
+var (
+	outputFormats = []string{"text", "json", "structured", "otel"}
+	colorNames    = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white", "none"}
+)
+
+// OutputFormats returns the supported output formats.
+func OutputFormats() []string {
+	return slices.Clone(outputFormats)
+}
+
+// ColorNames returns the valid color names, including "none".
+func ColorNames() []string {
+	return slices.Clone(colorNames)
+}
+
 func getValidColorsString() string {
-	colors := []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white", "none"}
-	return strings.Join(colors, ", ")
+	return strings.Join(colorNames, ", ")
 }
\ No newline at end of file
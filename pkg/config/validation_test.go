@@ -1,7 +1,9 @@
 package config
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/sgaunet/logwrap/pkg/apperrors"
 	"github.com/stretchr/testify/assert"
@@ -128,6 +130,20 @@ func TestConfig_ValidateTimestamp(t *testing.T) {
 	}
 }
 
+func TestConfig_ValidateTimestamp_Fallback(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Prefix.Timestamp.Fallback = "%H:%M:%S"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Prefix.Timestamp.Fallback = "%Q"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidTimestampFormat)
+	assert.Contains(t, err.Error(), "fallback")
+}
+
 func TestConfig_ValidateColors(t *testing.T) {
 	t.Parallel()
 
@@ -230,6 +246,14 @@ func TestConfig_ValidateUser(t *testing.T) {
 			name:   "valid full format",
 			format: "full",
 		},
+		{
+			name:   "valid user_host format",
+			format: "user_host",
+		},
+		{
+			name:   "valid user_group format",
+			format: "user_group",
+		},
 		{
 			name:        "invalid format",
 			format:      "invalid",
@@ -310,6 +334,22 @@ func TestConfig_ValidatePID(t *testing.T) {
 	}
 }
 
+func TestConfig_ValidatePID_Source(t *testing.T) {
+	t.Parallel()
+
+	for _, source := range []string{"", "self", "child"} {
+		cfg := getDefaultConfig()
+		cfg.Prefix.PID.Source = source
+		assert.NoError(t, cfg.Validate(), "source %q", source)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.Prefix.PID.Source = "parent"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidPIDSource)
+}
+
 func TestConfig_ValidateOutput(t *testing.T) {
 	t.Parallel()
 
@@ -331,6 +371,10 @@ func TestConfig_ValidateOutput(t *testing.T) {
 			name:   "valid structured format",
 			format: "structured",
 		},
+		{
+			name:   "valid otel format",
+			format: "otel",
+		},
 		{
 			name:        "invalid format",
 			format:      "invalid",
@@ -408,6 +452,20 @@ func TestConfig_ValidateLogLevel(t *testing.T) {
 			expectError:    true,
 			expectedErr:    apperrors.ErrInvalidLogLevel,
 		},
+		{
+			name:           "drop detection level",
+			stdoutLevel:    "INFO",
+			stderrLevel:    "ERROR",
+			detectionLevel: "drop",
+			keywords:       []string{"/healthz"},
+		},
+		{
+			name:        "drop is not a default level",
+			stdoutLevel: "DROP",
+			stderrLevel: "ERROR",
+			expectError: true,
+			expectedErr: apperrors.ErrInvalidStdoutLogLevel,
+		},
 		{
 			name:           "empty keywords",
 			stdoutLevel:    "INFO",
@@ -1008,6 +1066,7 @@ func TestConfig_ValidateOutputFormat_AllFormats(t *testing.T) {
 		{name: "valid text", format: "text", expectError: false},
 		{name: "valid json", format: "json", expectError: false},
 		{name: "valid structured", format: "structured", expectError: false},
+		{name: "valid otel", format: "otel", expectError: false},
 		// Invalid formats
 		{name: "invalid xml", format: "xml", expectError: true},
 		{name: "invalid yaml", format: "yaml", expectError: true},
@@ -1186,4 +1245,918 @@ func TestConfig_ValidateFilter_InvalidLevelNames(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestConfig_ValidateExecution_SuccessExitCodes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		codes       []int
+		expectError bool
+	}{
+		{"default", []int{0}, false},
+		{"empty treated as default", nil, false},
+		{"several codes", []int{0, 1, 2}, false},
+		{"upper bound", []int{255}, false},
+		{"negative code", []int{-1}, true},
+		{"code above 255", []int{0, 256}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Execution.SuccessExitCodes = tt.codes
+
+			err := cfg.Validate()
+			if tt.expectError {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, apperrors.ErrInvalidExitCode)
+				assert.Contains(t, err.Error(), "execution configuration error")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOutput_BrokenPipeExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		code        int
+		expectError bool
+	}{
+		{"default", 0, false},
+		{"shell convention", 141, false},
+		{"negative code", -1, true},
+		{"code above 255", 256, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Output.BrokenPipeExitCode = tt.code
+
+			err := cfg.Validate()
+			if tt.expectError {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, apperrors.ErrInvalidExitCode)
+				assert.Contains(t, err.Error(), "output configuration error")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOutput_OnFormatError(t *testing.T) {
+	t.Parallel()
+
+	for _, policy := range []string{"", "raw", "drop", "error"} {
+		cfg := getDefaultConfig()
+		cfg.Output.OnFormatError = policy
+		assert.NoError(t, cfg.Validate(), "policy %q", policy)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.Output.OnFormatError = "ignore"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidFormatErrorPolicy)
+}
+
+func TestConfig_ValidateOutput_LineEnding(t *testing.T) {
+	t.Parallel()
+
+	for _, ending := range []string{"", "lf", "crlf"} {
+		cfg := getDefaultConfig()
+		cfg.Output.LineEnding = ending
+		assert.NoError(t, cfg.Validate(), "line ending %q", ending)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.Output.LineEnding = "cr"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidLineEnding)
+}
+
+func TestConfig_ValidateOutput_ControlChars(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"", "keep", "escape", "strip"} {
+		cfg := getDefaultConfig()
+		cfg.Output.ControlChars = mode
+		assert.NoError(t, cfg.Validate(), "control chars mode %q", mode)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.Output.ControlChars = "replace"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidControlChars)
+}
+
+func TestConfig_ValidateOutput_Progress(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"", "keep", "final"} {
+		cfg := getDefaultConfig()
+		cfg.Output.Progress = mode
+		assert.NoError(t, cfg.Validate(), "progress mode %q", mode)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.Output.Progress = "periodic"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidProgressMode)
+}
+
+func TestConfig_ValidateOutput_StripInputPrefix(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Output.StripInputPrefixPattern = `^\d{2}:\d{2}:\d{2} `
+	for _, stage := range []string{"", "after_detection", "before_detection"} {
+		cfg.Output.StripInputPrefixStage = stage
+		assert.NoError(t, cfg.Validate(), "stage %q", stage)
+	}
+
+	cfg.Output.StripInputPrefixStage = "during_detection"
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidStripStage)
+
+	cfg = getDefaultConfig()
+	cfg.Output.StripInputPrefixPattern = `^(\d+`
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidStripPattern)
+}
+
+func TestConfig_ValidateOutput_SkipPrefixIfMatches(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Output.SkipPrefixIfMatches = `^\{`
+	require.NoError(t, cfg.Validate())
+
+	cfg.Output.SkipPrefixIfMatches = `^(\{`
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidSkipPattern)
+}
+
+func TestConfig_ValidateOutput_StderrOnLevel(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Output.StderrOnLevel = "error"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Output.StderrOnLevelMaxLines = 0
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidBufferLines)
+
+	cfg.Output.StderrOnLevel = ""
+	require.NoError(t, cfg.Validate(), "the line limit is ignored without a threshold")
+
+	cfg = getDefaultConfig()
+	cfg.Output.StderrOnLevel = "loud"
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidLogLevel)
+}
+
+func TestConfig_ValidateExecution_PipelinePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		policy      string
+		expectError bool
+	}{
+		{"last", "last", false},
+		{"any", "any", false},
+		{"empty treated as last", "", false},
+		{"unknown policy", "first", true},
+		{"wrong case", "LAST", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Execution.PipelinePolicy = tt.policy
+
+			err := cfg.Validate()
+			if tt.expectError {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, apperrors.ErrInvalidPipelinePolicy)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateExecution_StartRetries(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	assert.Equal(t, time.Second, cfg.Execution.StartRetryDelay)
+	cfg.Execution.StartRetries = 3
+	cfg.Execution.StartRetryDelay = 0
+	require.NoError(t, cfg.Validate())
+
+	cfg.Execution.StartRetries = -1
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidStartRetries)
+
+	cfg = getDefaultConfig()
+	cfg.Execution.StartRetryDelay = -time.Second
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidStartRetries)
+}
+
+func TestConfig_ValidateLogLevel_ExtractFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		fields      map[string]string
+		expectedErr error
+	}{
+		{"valid field", map[string]string{"req": `req=(\S+)`}, nil},
+		{"no fields", nil, nil},
+		{"empty name", map[string]string{"": `req=(\S+)`}, apperrors.ErrEmptyFieldName},
+		{"reserved name", map[string]string{"level": `lvl=(\w+)`}, apperrors.ErrReservedFieldName},
+		{"invalid regex", map[string]string{"req": `req=(`}, apperrors.ErrInvalidExtractPattern},
+		{"no capture group", map[string]string{"req": `req=\S+`}, apperrors.ErrInvalidExtractPattern},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.LogLevel.Detection.ExtractFields = tt.fields
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateLogLevel_ExtractFieldTypes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		types       map[string]string
+		expectedErr error
+	}{
+		{"valid type", map[string]string{"count": "int"}, nil},
+		{"all types", map[string]string{"count": "float", "req": "string"}, nil},
+		{"no types", nil, nil},
+		{"unknown field", map[string]string{"missing": "int"}, apperrors.ErrInvalidFieldType},
+		{"unknown type", map[string]string{"count": "number"}, apperrors.ErrInvalidFieldType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.LogLevel.Detection.ExtractFields = map[string]string{
+				"count": `count=(\d+)`,
+				"req":   `req=(\S+)`,
+			}
+			cfg.LogLevel.Detection.ExtractFieldTypes = tt.types
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOutput_ContextBefore(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Output.ContextBefore = 5
+	require.NoError(t, cfg.Validate())
+
+	cfg.Output.ContextBefore = -1
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidContextLines)
+}
+
+func TestConfig_ValidateOutput_ReorderWindow(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Output.ReorderWindow = 50 * time.Millisecond
+	require.NoError(t, cfg.Validate())
+
+	cfg.Output.ReorderWindow = -time.Millisecond
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidReorderWindow)
+}
+
+func TestConfig_ValidateOutput_Workers(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Output.Workers = 4
+	require.NoError(t, cfg.Validate())
+
+	cfg.Output.Workers = -1
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidWorkers)
+}
+
+func TestConfig_ValidateOutput_ReadAhead(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	assert.Zero(t, cfg.Output.ReadAhead, "read-ahead is off by default")
+	cfg.Output.ReadAhead = 1000
+	require.NoError(t, cfg.Validate())
+
+	cfg.Output.ReadAhead = -1
+	require.ErrorIs(t, cfg.Validate(), apperrors.ErrInvalidReadAhead)
+}
+
+func TestConfig_ValidateExecution_ExitLevelMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		levels      map[string]string
+		expectedErr error
+	}{
+		{"no mapping", nil, nil},
+		{"valid mapping", map[string]string{"0": "debug", "3": "WARN", "nonzero": "FATAL", "signal": "info"}, nil},
+		{"unknown key", map[string]string{"failure": "ERROR"}, apperrors.ErrInvalidExitLevel},
+		{"code out of range", map[string]string{"256": "ERROR"}, apperrors.ErrInvalidExitLevel},
+		{"unknown level", map[string]string{"0": "NOTICE"}, apperrors.ErrInvalidExitLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Execution.ExitLevelMap = tt.levels
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOutput_SquashBlankLinesTo(t *testing.T) {
+	t.Parallel()
+
+	for _, keep := range []int{0, 1, 5} {
+		cfg := getDefaultConfig()
+		cfg.Output.SquashBlankLines = true
+		cfg.Output.SquashBlankLinesTo = keep
+		assert.NoError(t, cfg.Validate(), "keeping %d blank lines should be valid", keep)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.Output.SquashBlankLinesTo = -1
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidBlankLines)
+}
+
+func TestConfig_ValidateOutput_SeverityMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		severities  map[string]int
+		expectedErr error
+	}{
+		{"no mapping", nil, nil},
+		{"valid mapping", map[string]int{"warn": 5, "FATAL": 0, "debug": 7}, nil},
+		{"unknown level", map[string]int{"notice": 5}, apperrors.ErrInvalidSeverity},
+		{"severity too high", map[string]int{"info": 8}, apperrors.ErrInvalidSeverity},
+		{"negative severity", map[string]int{"error": -1}, apperrors.ErrInvalidSeverity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Output.SeverityMap = tt.severities
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOutput_RatePerLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		rates       map[string]float64
+		expectedErr error
+	}{
+		{"no rates", nil, nil},
+		{"valid rates", map[string]float64{"info": 10, "DEBUG": 0.5}, nil},
+		{"unknown level", map[string]float64{"notice": 5}, apperrors.ErrInvalidLevelRate},
+		{"zero rate", map[string]float64{"info": 0}, apperrors.ErrInvalidLevelRate},
+		{"negative rate", map[string]float64{"info": -1}, apperrors.ErrInvalidLevelRate},
+		{"infinite rate", map[string]float64{"info": math.Inf(1)}, apperrors.ErrInvalidLevelRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Output.RatePerLevel = tt.rates
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOutput_Routes(t *testing.T) {
+	t.Parallel()
+
+	sinks := []SinkConfig{
+		{Name: "alerts", Type: "stderr"},
+		{Name: "archive", Type: "file", Path: "out.log"},
+	}
+
+	tests := []struct {
+		name        string
+		sinks       []SinkConfig
+		routes      map[string][]string
+		expectedErr error
+	}{
+		{"no routes", sinks, nil, nil},
+		{"named sinks and primary", sinks, map[string][]string{"error": {"primary", "alerts"}, "INFO": {"archive"}}, nil},
+		{"unknown level", sinks, map[string][]string{"loud": {"alerts"}}, apperrors.ErrInvalidRoute},
+		{"unknown sink", sinks, map[string][]string{"error": {"pager"}}, apperrors.ErrInvalidRoute},
+		{
+			"duplicate sink name",
+			[]SinkConfig{{Name: "alerts", Type: "stderr"}, {Name: "alerts", Type: "stdout"}},
+			nil,
+			apperrors.ErrDuplicateSinkName,
+		},
+		{"sink named primary", []SinkConfig{{Name: "primary", Type: "stderr"}}, nil, apperrors.ErrDuplicateSinkName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Output.Sinks = tt.sinks
+			cfg.Output.Routes = tt.routes
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateOutput_CustomFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		fields      map[string]string
+		expectedErr error
+	}{
+		{"fixed value", map[string]string{"env": "prod"}, nil},
+		{"templated value", map[string]string{"host_env": "{{.Host}}-prod"}, nil},
+		{"template references fixed field", map[string]string{"env": "prod", "tag": "{{.Fields.env}}"}, nil},
+		{"empty name", map[string]string{"": "prod"}, apperrors.ErrInvalidCustomField},
+		{"reserved name", map[string]string{"level": "prod"}, apperrors.ErrInvalidCustomField},
+		{"invalid template", map[string]string{"env": "{{.Host"}, apperrors.ErrInvalidCustomField},
+		{"unknown template field", map[string]string{"env": "{{.Nope}}"}, apperrors.ErrInvalidCustomField},
+		{"self reference", map[string]string{"env": "{{.Fields.env}}"}, apperrors.ErrCustomFieldRecursion},
+		{
+			"templated field reference",
+			map[string]string{"a": "{{.Fields.b}}", "b": "{{.Level}}"},
+			apperrors.ErrCustomFieldRecursion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Output.CustomFields = tt.fields
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateLogLevel_CacheSize(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{0, 1, 1000} {
+		cfg := getDefaultConfig()
+		cfg.LogLevel.CacheSize = size
+		assert.NoError(t, cfg.Validate(), "cache size %d should be valid", size)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.LogLevel.CacheSize = -1
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCacheSize)
+}
+
+func TestConfig_ValidateLogLevel_MaxScanBytes(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{0, 1, 4096} {
+		cfg := getDefaultConfig()
+		cfg.LogLevel.Detection.MaxScanBytes = size
+		assert.NoError(t, cfg.Validate(), "max scan bytes %d should be valid", size)
+	}
+
+	cfg := getDefaultConfig()
+	cfg.LogLevel.Detection.MaxScanBytes = -1
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidMaxScanBytes)
+}
+
+func TestConfig_ValidateLogLevel_LevelKey(t *testing.T) {
+	t.Parallel()
+
+	for _, key := range []string{"", "level", "severity", "log.level"} {
+		cfg := getDefaultConfig()
+		cfg.LogLevel.Detection.LevelKey = key
+		assert.NoError(t, cfg.Validate(), "level key %q should be valid", key)
+	}
+
+	for _, key := range []string{"level=", "log level", `"level"`, "level:"} {
+		cfg := getDefaultConfig()
+		cfg.LogLevel.Detection.LevelKey = key
+		err := cfg.Validate()
+		require.Error(t, err, "level key %q should be invalid", key)
+		assert.ErrorIs(t, err, apperrors.ErrInvalidLevelKey)
+	}
+}
+
+func TestConfig_TemplateWarnings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		template string
+		setup    func(cfg *Config)
+		expected []string
+	}{
+		{
+			name:     "default template and config",
+			template: "[{{.Timestamp}}] [{{.Level}}] [{{.User}}:{{.PID}}] ",
+		},
+		{
+			name:     "undefined custom field",
+			template: "[{{.Fields.req}}] ",
+			expected: []string{`{{.Fields.req}} renders empty: no field "req" is defined in log_level.detection.extract_fields`},
+		},
+		{
+			name:     "extracted field",
+			template: "[{{.Fields.req}}] ",
+			setup: func(cfg *Config) {
+				cfg.LogLevel.Detection.ExtractFields = map[string]string{"req": `req=(\S+)`}
+			},
+		},
+		{
+			name:     "CI field without auto_ci_fields",
+			template: "{{if .Fields.ci_branch}}[{{.Fields.ci_branch}}] {{end}}",
+			expected: []string{"{{.Fields.ci_branch}} renders empty: output.auto_ci_fields is false"},
+		},
+		{
+			name:     "CI field with auto_ci_fields",
+			template: "[{{.Fields.ci_branch}}] ",
+			setup:    func(cfg *Config) { cfg.Output.AutoCIFields = true },
+		},
+		{
+			name:     "configured CI field",
+			template: "[{{.Fields.ci_pipeline}}] ",
+			setup: func(cfg *Config) {
+				cfg.Output.AutoCIFields = true
+				cfg.Output.CIFieldSources["ci_pipeline"] = []string{"CI_PIPELINE_ID"}
+			},
+		},
+		{
+			name:     "disabled user and PID",
+			template: "[{{.User}}:{{.PID}}] ",
+			setup: func(cfg *Config) {
+				cfg.Prefix.User.Enabled = false
+				cfg.Prefix.PID.Enabled = false
+			},
+			expected: []string{
+				"{{.User}} renders empty: prefix.user.enabled is false",
+				"{{.PID}} renders empty: prefix.pid.enabled is false",
+			},
+		},
+		{
+			name:     "disabled PPID and command",
+			template: "[{{.PPID}}] [{{.Command}}] ",
+			expected: []string{
+				"{{.PPID}} renders empty: prefix.ppid.enabled is false",
+				"{{.Command}} renders empty: prefix.command.enabled is false",
+			},
+		},
+		{
+			name:     "delta without include_delta",
+			template: "[+{{.Delta}}] ",
+			expected: []string{"{{.Delta}} is always 0s: output.include_delta is false"},
+		},
+		{
+			name:     "delta with include_delta",
+			template: "[+{{.Delta}}] ",
+			setup:    func(cfg *Config) { cfg.Output.IncludeDelta = true },
+		},
+		{
+			name:     "matched keyword without include_match",
+			template: "[{{.MatchedKeyword}}] ",
+			expected: []string{"{{.MatchedKeyword}} renders empty: log_level.detection.include_match is false"},
+		},
+		{
+			name:     "matched keyword with include_match",
+			template: "[{{.MatchedKeyword}}] ",
+			setup:    func(cfg *Config) { cfg.LogLevel.Detection.IncludeMatch = true },
+		},
+		{
+			name:     "disabled user and PID are tidied",
+			template: "[{{.User}}:{{.PID}}] ",
+			setup: func(cfg *Config) {
+				cfg.Prefix.User.Enabled = false
+				cfg.Prefix.PID.Enabled = false
+				cfg.Prefix.TidyEmptySegments = true
+			},
+		},
+		{
+			name:     "disabled user and PID are joined",
+			template: `[{{join "] [" .Level (join ":" .User .PID)}}] `,
+			setup: func(cfg *Config) {
+				cfg.Prefix.User.Enabled = false
+				cfg.Prefix.PID.Enabled = false
+			},
+		},
+		{
+			name:     "joined command is still reported",
+			template: `[{{join ":" .Command .PID}}] `,
+			expected: []string{"{{.Command}} renders empty: prefix.command.enabled is false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Prefix.Template = tt.template
+			if tt.setup != nil {
+				tt.setup(cfg)
+			}
+			assert.Equal(t, tt.expected, cfg.TemplateWarnings())
+			assert.NoError(t, cfg.Validate(), "warnings do not fail validation by default")
+		})
+	}
+}
+
+func TestConfig_TimestampWarnings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{"%Y-%m-%d %H:%M:%S", nil},
+		{"%F %T", nil},
+		{"%Y-%j %-H:%M:%S.%f", nil},
+		{"%s", nil},
+		{"%c", nil},
+		{"%H:%M:%S", []string{`format "%H:%M:%S" cannot represent a full instant: no year, month, day`}},
+		{"%Y", []string{`format "%Y" cannot represent a full instant: no month, day, hour, minute, second`}},
+		{"%b %d %R", []string{`format "%b %d %R" cannot represent a full instant: no year, second`}},
+		{"%Q", nil}, // invalid, reported by Validate
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Prefix.Timestamp.Format = tt.format
+			assert.Equal(t, tt.expected, cfg.TimestampWarnings())
+		})
+	}
+}
+
+func TestConfig_ValidatePrefix_StrictTemplate(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.Prefix.Template = "[{{.Fields.req}}] "
+	cfg.Prefix.StrictTemplate = true
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	require.ErrorIs(t, err, apperrors.ErrTemplateFieldUnavailable)
+	assert.Contains(t, err.Error(), "{{.Fields.req}}")
+
+	cfg.LogLevel.Detection.ExtractFields = map[string]string{"req": `req=(\S+)`}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestConfig_Warnings(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, getDefaultConfig().Warnings(), "the default configuration has no warnings")
+
+	cfg := getDefaultConfig()
+	cfg.Prefix.Template = "[{{.Fields.req}}] "
+	cfg.LogLevel.Detection.Keywords = map[string][]string{"error": {"ERROR"}, "debug": {"ERROR"}}
+	cfg.Prefix.Timestamp.Format = "%H:%M:%S"
+	assert.Equal(t, []string{
+		"template " + cfg.TemplateWarnings()[0],
+		`detection keyword "ERROR" is listed under several levels: debug, error`,
+	}, cfg.Warnings(), "timestamp formats are only checked with prefix.timestamp.strict")
+
+	cfg.LogLevel.Detection.StrictKeywords = true
+	cfg.Prefix.Timestamp.Strict = true
+	assert.Equal(t, []string{
+		"template " + cfg.TemplateWarnings()[0],
+		`timestamp format "%H:%M:%S" cannot represent a full instant: no year, month, day`,
+	}, cfg.Warnings(), "strict keyword conflicts are errors, not warnings")
+}
+
+func TestConfig_KeywordWarnings(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, getDefaultConfig().KeywordWarnings(), "default keywords do not conflict")
+
+	tests := []struct {
+		name          string
+		keywords      map[string][]string
+		caseSensitive bool
+		expected      []string
+	}{
+		{
+			name:     "same keyword under two levels",
+			keywords: map[string][]string{"error": {"ERROR"}, "debug": {"DEBUG", "ERROR"}},
+			expected: []string{`keyword "ERROR" is listed under several levels: debug, error`},
+		},
+		{
+			name:     "case is ignored by default",
+			keywords: map[string][]string{"warn": {"Timeout"}, "error": {"TIMEOUT"}, "drop": {"timeout"}},
+			expected: []string{`keyword "timeout" is listed under several levels: drop, error, warn`},
+		},
+		{
+			name:          "case-sensitive keywords differ by case",
+			keywords:      map[string][]string{"warn": {"Timeout"}, "error": {"TIMEOUT"}},
+			caseSensitive: true,
+		},
+		{
+			name:     "repeated under one level",
+			keywords: map[string][]string{"error": {"ERROR", "error"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.LogLevel.Detection.Keywords = tt.keywords
+			cfg.LogLevel.Detection.CaseSensitive = tt.caseSensitive
+			assert.Equal(t, tt.expected, cfg.KeywordWarnings())
+		})
+	}
+}
+
+func TestConfig_ValidateLogLevel_StrictKeywords(t *testing.T) {
+	t.Parallel()
+
+	cfg := getDefaultConfig()
+	cfg.LogLevel.Detection.Keywords["debug"] = append(cfg.LogLevel.Detection.Keywords["debug"], "ERROR")
+	require.NoError(t, cfg.Validate(), "conflicts are only warnings by default")
+
+	cfg.LogLevel.Detection.StrictKeywords = true
+	err := cfg.Validate()
+	require.Error(t, err)
+	require.ErrorIs(t, err, apperrors.ErrConflictingKeyword)
+	assert.Contains(t, err.Error(), `keyword "ERROR" is listed under several levels: debug, error`)
+}
+
+func TestConfig_ValidateOutput_Sinks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		sink        SinkConfig
+		expectedErr error
+	}{
+		{"file sink", SinkConfig{Type: "file", Path: "out.log", Format: "json"}, nil},
+		{"stderr without format", SinkConfig{Type: "stderr"}, nil},
+		{"unknown type", SinkConfig{Type: "syslog"}, apperrors.ErrInvalidSinkType},
+		{"file without path", SinkConfig{Type: "file"}, apperrors.ErrSinkPathRequired},
+		{"invalid format", SinkConfig{Type: "stdout", Format: "xml"}, apperrors.ErrInvalidOutputFormat},
+		{"journald", SinkConfig{Type: "journald"}, nil},
+		{"journald with format", SinkConfig{Type: "journald", Format: "json"}, apperrors.ErrJournaldSinkFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := getDefaultConfig()
+			cfg.Output.Sinks = []SinkConfig{tt.sink}
+
+			err := cfg.Validate()
+			if tt.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Contains(t, err.Error(), "sink 1")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	t.Parallel()
+
+	formats := OutputFormats()
+	for _, format := range []string{"text", "json", "structured"} {
+		assert.Contains(t, formats, format)
+	}
+
+	for _, format := range formats {
+		cfg := getDefaultConfig()
+		cfg.Output.Format = format
+		assert.NoError(t, cfg.validateOutput(), "listed format %q must validate", format)
+	}
+
+	formats[0] = "changed"
+	assert.Equal(t, "text", OutputFormats()[0], "callers get a copy")
+}
+
+func TestColorNames(t *testing.T) {
+	t.Parallel()
+
+	colors := ColorNames()
+	for _, color := range []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"} {
+		assert.Contains(t, colors, color)
+	}
+
+	for _, color := range colors {
+		cfg := getDefaultConfig()
+		cfg.Prefix.Colors.Info = color
+		assert.NoError(t, cfg.validateColors(), "listed color %q must validate", color)
+	}
+}
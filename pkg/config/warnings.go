@@ -0,0 +1,25 @@
+package config
+
+// Warnings returns every warning about the configuration that logwrap
+// reports on stderr before a run and with -validate, each prefixed with the
+// check it comes from: "template" for [Config.TemplateWarnings], "detection"
+// for [Config.KeywordWarnings] unless detection.strict_keywords makes them
+// errors, and "timestamp" for [Config.TimestampWarnings] with
+// prefix.timestamp.strict.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	for _, warning := range c.TemplateWarnings() {
+		warnings = append(warnings, "template "+warning)
+	}
+	if !c.LogLevel.Detection.StrictKeywords {
+		for _, warning := range c.KeywordWarnings() {
+			warnings = append(warnings, "detection "+warning)
+		}
+	}
+	if c.Prefix.Timestamp.Strict {
+		for _, warning := range c.TimestampWarnings() {
+			warnings = append(warnings, "timestamp "+warning)
+		}
+	}
+	return warnings
+}
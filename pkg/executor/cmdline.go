@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/sgaunet/logwrap/pkg/apperrors"
+)
+
+// SplitCommandLine splits a shell-quoted command line into arguments
+// without invoking a shell, so no expansion, globbing or command
+// substitution takes place. It follows POSIX shell quoting:
+//   - Unquoted whitespace separates arguments
+//   - Single quotes preserve everything literally up to the closing quote
+//   - Double quotes preserve everything except \" \\ \$ \` and an escaped
+//     newline, which are unescaped
+//   - An unquoted backslash escapes the next character
+//
+// An empty or blank line yields no arguments.
+func SplitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case c == '\\':
+			if i+1 >= len(line) {
+				return nil, apperrors.ErrUnterminatedEscape
+			}
+			i++
+			if line[i] != '\n' {
+				current.WriteByte(line[i])
+				inArg = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, apperrors.ErrUnterminatedQuote
+			}
+			current.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			next, err := readDoubleQuoted(line, i+1, &current)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+			inArg = true
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// readDoubleQuoted appends the double-quoted text starting at line[start]
+// to sb and returns the index of the closing quote.
+func readDoubleQuoted(line string, start int, sb *strings.Builder) (int, error) {
+	for i := start; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 < len(line) && strings.IndexByte("\"\\$`\n", line[i+1]) >= 0 {
+				i++
+				if line[i] != '\n' {
+					sb.WriteByte(line[i])
+				}
+				continue
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return 0, apperrors.ErrUnterminatedQuote
+}
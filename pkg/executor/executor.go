@@ -26,14 +26,34 @@
 // the child process receives SIGTERM. If it doesn't exit within
 // [gracefulStopDelay], Go's stdlib escalates to SIGKILL.
 //
+// Windows has no SIGTERM: the child is started in its own process group and
+// receives CTRL_BREAK_EVENT instead, and killing it terminates the whole
+// process tree.
+//
 // # Exit Code Preservation
 //
 // The executor preserves the exact exit code from the wrapped command:
 //   - Success (0) → returns 0
 //   - Failure (N) → returns N
-//   - Signal termination → returns 128 + signal number
+//   - Signal termination → returns 128 + signal number (Unix only)
 //
 // Non-exit errors (e.g., command not found) are returned as Go errors.
+// Common start failures wrap [appErrors.ErrCommandNotFound],
+// [appErrors.ErrCommandPermission] or [appErrors.ErrCommandNotExecutable].
+//
+// # Pipelines
+//
+// [NewPipeline] runs several commands connected like a shell pipeline
+// (cmd1 | cmd2 | ...) without invoking a shell. Each stage's stdout feeds the
+// next stage's stdin. The final stage's stdout and the combined stderr of all
+// stages are exposed via [Executor.GetStreams]. The pipeline's exit code is
+// derived according to a [PipelinePolicy].
+//
+// # Output Order
+//
+// stdout and stderr are separate pipes, so the relative order of lines
+// written to both is lost between them. [WithSinglePipe] keeps it by
+// sharing one pipe, at the cost of telling the streams apart.
 package executor
 
 import (
@@ -62,20 +82,60 @@ const (
 	signalExitCodeBase = 128
 )
 
+// PipelinePolicy controls how a pipeline's exit code is derived from the
+// exit codes of its stages.
+type PipelinePolicy int
+
+const (
+	// PipelineLast uses the exit code of the last stage, like a POSIX shell.
+	PipelineLast PipelinePolicy = iota
+	// PipelineAnyFailure uses the exit code of the rightmost stage that
+	// failed, like bash's "set -o pipefail".
+	PipelineAnyFailure
+)
+
+// exitCode returns the pipeline exit code for the given per-stage codes.
+func (p PipelinePolicy) exitCode(codes []int) int {
+	if len(codes) == 0 {
+		return 0
+	}
+	if p == PipelineAnyFailure {
+		for i := len(codes) - 1; i >= 0; i-- {
+			if codes[i] != 0 {
+				return codes[i]
+			}
+		}
+		return 0
+	}
+	return codes[len(codes)-1]
+}
+
 // Executor manages command execution with stream capture and signal handling.
+// An Executor runs its command once: after Cleanup, Start and Wait return
+// [appErrors.ErrExecutorCleanedUp] and a new Executor is needed.
 type Executor struct {
-	cmd         *exec.Cmd
+	cmd         *exec.Cmd   // the last (or only) command
+	stages      []*exec.Cmd // every command in pipeline order, including cmd
+	pipeFiles   []*os.File  // parent copies of inter-stage pipe ends, closed once started
+	policy      PipelinePolicy
 	cancel      context.CancelFunc
-	stdoutPipe  io.ReadCloser
-	stderrPipe  io.ReadCloser
+	stdoutPipe  *streamReader
+	stderrPipe  *streamReader
 	commandName string // stored for error messages
 	exitCode    int
+	stageCodes  []int     // exit code of every stage, in pipeline order
+	coreDumped  bool      // a stage was killed by a signal and dumped core
+	startedAt   time.Time // when Start began, with a monotonic reading
+	finishedAt  time.Time // when Wait saw every stage exit
+	stdoutFD    int       // descriptor of the stdout read end, recorded by Start
+	stderrFD    int       // descriptor of the stderr read end, recorded by Start
 	isStarted   atomic.Bool
 	isFinished  atomic.Bool
+	isCleanedUp atomic.Bool
 }
 
 // New creates a new Executor instance for the given command.
-func New(command []string) (*Executor, error) {
+func New(command []string, opts ...Option) (*Executor, error) {
 	if len(command) == 0 {
 		return nil, appErrors.ErrCommandEmpty
 	}
@@ -85,37 +145,43 @@ func New(command []string) (*Executor, error) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	cmd := exec.CommandContext(ctx, command[0], command[1:]...) // #nosec G204 - command is validated above
-
-	// Send SIGTERM (not SIGKILL) when the context is cancelled.
-	// If the process doesn't exit within WaitDelay, Go escalates to SIGKILL.
-	cmd.Cancel = func() error {
-		if cmd.Process != nil {
-			return cmd.Process.Signal(syscall.SIGTERM)
-		}
-		return nil
-	}
-	cmd.WaitDelay = gracefulStopDelay
+	cmd := newCommand(ctx, command)
 	cmd.Stdin = os.Stdin
 
-	stdoutPipe, err := cmd.StdoutPipe()
+	// stdout and stderr are plain OS pipes rather than StdoutPipe and
+	// StderrPipe: Wait would otherwise close the read ends as soon as the
+	// command exits, racing with readers still draining its last output.
+	// The parent's copies of the write ends are closed by Start, the read
+	// ends by Cleanup or, once the command has exited, when they stay idle
+	// (see closeIdleStreams). WithSinglePipe gives stderr the stdout pipe.
+	stdoutReader, stdoutWriter, err := os.Pipe()
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to create stdout pipe for %q: %w", command[0], err)
 	}
-
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		_ = stdoutPipe.Close()
-		cancel()
-		return nil, fmt.Errorf("failed to create stderr pipe for %q: %w", command[0], err)
+	pipeFiles := []*os.File{stdoutWriter}
+	stderrReader, stderrWriter := emptyStream(), stdoutWriter
+	if !applyOptions(opts).singlePipe {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			_ = stdoutReader.Close()
+			closeFiles(pipeFiles)
+			cancel()
+			return nil, fmt.Errorf("failed to create stderr pipe for %q: %w", command[0], err)
+		}
+		pipeFiles = append(pipeFiles, writer)
+		stderrReader, stderrWriter = reader, writer
 	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
 
 	executor := &Executor{
 		cmd:         cmd,
+		stages:      []*exec.Cmd{cmd},
+		pipeFiles:   pipeFiles,
 		cancel:      cancel,
-		stdoutPipe:  stdoutPipe,
-		stderrPipe:  stderrPipe,
+		stdoutPipe:  newStreamReader(stdoutReader),
+		stderrPipe:  newStreamReader(stderrReader),
 		commandName: command[0],
 		exitCode:    0,
 	}
@@ -123,22 +189,212 @@ func New(command []string) (*Executor, error) {
 	return executor, nil
 }
 
-// Start begins execution of the command.
+// NewPipeline creates an Executor that runs stages as a pipeline, wiring each
+// stage's stdout to the next stage's stdin. The streams returned by
+// [Executor.GetStreams] are the last stage's stdout and the combined stderr
+// of every stage. A single stage behaves exactly like [New].
+func NewPipeline(stages [][]string, policy PipelinePolicy, opts ...Option) (*Executor, error) {
+	if len(stages) == 0 {
+		return nil, appErrors.ErrCommandEmpty
+	}
+	if len(stages) == 1 {
+		e, err := New(stages[0], opts...)
+		if err != nil {
+			return nil, err
+		}
+		e.policy = policy
+		return e, nil
+	}
+
+	names := make([]string, len(stages))
+	for i, stage := range stages {
+		if len(stage) == 0 {
+			return nil, fmt.Errorf("pipeline stage %d: %w", i+1, appErrors.ErrCommandEmpty)
+		}
+		if err := validateCommand(stage[0]); err != nil {
+			return nil, fmt.Errorf("invalid command %q: %w", stage[0], err)
+		}
+		names[i] = stage[0]
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmds := make([]*exec.Cmd, len(stages))
+	for i, stage := range stages {
+		cmds[i] = newCommand(ctx, stage)
+	}
+	cmds[0].Stdin = os.Stdin
+
+	var pipeFiles []*os.File
+	fail := func(err error) (*Executor, error) {
+		closeFiles(pipeFiles)
+		cancel()
+		return nil, err
+	}
+
+	for i := range len(cmds) - 1 {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return fail(fmt.Errorf("failed to create pipe between %q and %q: %w", names[i], names[i+1], err))
+		}
+		cmds[i].Stdout = w
+		cmds[i+1].Stdin = r
+		pipeFiles = append(pipeFiles, r, w)
+	}
+
+	// The final stdout and the shared stderr are plain OS pipes rather than
+	// StdoutPipe/StderrPipe: Wait would otherwise close them as soon as the
+	// last stage exits, racing with readers still draining earlier output.
+	// The read ends are closed by Cleanup or, once every stage has exited,
+	// when they stay idle (see closeIdleStreams).
+	last := cmds[len(cmds)-1]
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return fail(fmt.Errorf("failed to create stdout pipe for %q: %w", names[len(names)-1], err))
+	}
+	pipeFiles = append(pipeFiles, stdoutWriter)
+	last.Stdout = stdoutWriter
+
+	// All stages share one stderr pipe so their diagnostics are captured
+	// together, in the order the kernel receives them. WithSinglePipe
+	// shares the stdout pipe instead.
+	stderrReader, stderrWriter := emptyStream(), stdoutWriter
+	if !applyOptions(opts).singlePipe {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			_ = stdoutReader.Close()
+			return fail(fmt.Errorf("failed to create stderr pipe: %w", err))
+		}
+		pipeFiles = append(pipeFiles, writer)
+		stderrReader, stderrWriter = reader, writer
+	}
+	for _, cmd := range cmds {
+		cmd.Stderr = stderrWriter
+	}
+
+	return &Executor{
+		cmd:         last,
+		stages:      cmds,
+		pipeFiles:   pipeFiles,
+		policy:      policy,
+		cancel:      cancel,
+		stdoutPipe:  newStreamReader(stdoutReader),
+		stderrPipe:  newStreamReader(stderrReader),
+		commandName: strings.Join(names, " | "),
+	}, nil
+}
+
+// newCommand creates an exec.Cmd bound to ctx that receives SIGTERM (not
+// SIGKILL) when ctx is cancelled. If the process doesn't exit within
+// WaitDelay, Go escalates to SIGKILL.
+func newCommand(ctx context.Context, command []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...) // #nosec G204 - command is validated by callers
+	configureProcess(cmd)
+	cmd.Cancel = func() error {
+		if cmd.Process != nil {
+			return terminateProcess(cmd.Process)
+		}
+		return nil
+	}
+	cmd.WaitDelay = gracefulStopDelay
+	return cmd
+}
+
+// Start begins execution of the command, or of every pipeline stage in order.
+// If a stage fails to start, the stages already started are killed.
 func (e *Executor) Start() error {
+	if e.isCleanedUp.Load() {
+		return appErrors.ErrExecutorCleanedUp
+	}
 	if e.isStarted.Load() {
 		return appErrors.ErrExecutorStarted
 	}
 
-	if err := e.cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command %q: %w", e.commandName, err)
+	e.startedAt = time.Now()
+	for i, cmd := range e.stages {
+		if err := cmd.Start(); err != nil {
+			abortStages(e.stages[:i])
+			e.closePipeFiles()
+			if reason := startFailure(err); reason != nil {
+				return fmt.Errorf("failed to start command %q: %w: %w", cmd.Args[0], reason, err)
+			}
+			return fmt.Errorf("failed to start command %q: %w", cmd.Args[0], err)
+		}
 	}
 
+	// The children hold their own copies of the inter-stage pipes. Closing
+	// the parent's copies lets EOF and EPIPE propagate between stages.
+	e.closePipeFiles()
+
+	e.stdoutFD = fileDescriptor(e.stdoutPipe)
+	e.stderrFD = fileDescriptor(e.stderrPipe)
 	e.isStarted.Store(true)
 	return nil
 }
 
-// Wait waits for the command to complete and returns any error.
+// fileDescriptor returns the descriptor of r when it reads an [os.File], or -1.
+// It goes through SyscallConn rather than Fd, which would switch the pipe
+// to blocking mode.
+func fileDescriptor(r *streamReader) int {
+	file, ok := r.ReadCloser.(*os.File)
+	if !ok {
+		return -1
+	}
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return -1
+	}
+	fd := -1
+	if err := conn.Control(func(raw uintptr) { fd = int(raw) }); err != nil { //nolint:gosec // descriptors fit in an int
+		return -1
+	}
+	return fd
+}
+
+// startFailure classifies the error of a command that failed to start, as
+// returned by [exec.Cmd.Start] wrapping an [exec.Error] or [os.PathError],
+// or returns nil for failures without a common cause.
+func startFailure(err error) error {
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		return appErrors.ErrCommandNotFound
+	case errors.Is(err, os.ErrPermission):
+		return appErrors.ErrCommandPermission
+	case errors.Is(err, syscall.ENOEXEC):
+		return appErrors.ErrCommandNotExecutable
+	default:
+		return nil
+	}
+}
+
+// abortStages kills and reaps commands that were started before a later
+// pipeline stage failed to start.
+func abortStages(cmds []*exec.Cmd) {
+	for _, cmd := range cmds {
+		if cmd.Process != nil {
+			_ = killProcess(cmd.Process)
+		}
+		_ = cmd.Wait()
+	}
+}
+
+func (e *Executor) closePipeFiles() {
+	closeFiles(e.pipeFiles)
+	e.pipeFiles = nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// Wait waits for the command (or every pipeline stage) to complete and
+// returns any error. For pipelines, the exit code is derived from the stage
+// exit codes according to the [PipelinePolicy].
 func (e *Executor) Wait() error {
+	if e.isCleanedUp.Load() {
+		return appErrors.ErrExecutorCleanedUp
+	}
 	if !e.isStarted.Load() {
 		return appErrors.ErrExecutorNotStarted
 	}
@@ -147,37 +403,57 @@ func (e *Executor) Wait() error {
 		return nil
 	}
 
-	err := e.cmd.Wait()
-
-	if err != nil {
-		var exitError *exec.ExitError
-
-		switch {
-		// ErrWaitDelay means the process exited but its pipes weren't fully
-		// drained before WaitDelay expired (e.g., a grandchild holds them open).
-		// The process itself succeeded, so treat this as a normal exit.
-		case errors.Is(err, exec.ErrWaitDelay):
-			e.isFinished.Store(true)
-			return nil
-
-		case errors.As(err, &exitError):
-			e.exitCode = resolveExitCode(exitError)
-
-		// Context cancellation can race with the process exiting. If the
-		// process already exited, extract its real exit code instead of
-		// treating context.Canceled as a generic failure.
-		case errors.Is(err, context.Canceled) && e.cmd.ProcessState != nil:
-			e.exitCode = e.cmd.ProcessState.ExitCode()
-
-		default:
-			e.isFinished.Store(true)
-			return fmt.Errorf("command %q execution failed: %w", e.commandName, err)
+	codes := make([]int, len(e.stages))
+	var firstErr error
+	for i, cmd := range e.stages {
+		code, err := waitCommand(cmd)
+		codes[i] = code
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if cmd.ProcessState != nil && coreDumped(cmd.ProcessState) {
+			e.coreDumped = true
 		}
 	}
 
+	e.finishedAt = time.Now()
+	e.exitCode = e.policy.exitCode(codes)
+	e.stageCodes = codes
 	e.isFinished.Store(true)
+	go e.closeIdleStreams()
 
-	return nil
+	return firstErr
+}
+
+// waitCommand waits for a single command and returns its exit code.
+// A non-zero exit is not an error; only failures to wait are returned.
+func waitCommand(cmd *exec.Cmd) (int, error) {
+	err := cmd.Wait()
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitError *exec.ExitError
+
+	switch {
+	// ErrWaitDelay means the process exited but its pipes weren't fully
+	// drained before WaitDelay expired (e.g., a grandchild holds them open).
+	// The process itself succeeded, so treat this as a normal exit.
+	case errors.Is(err, exec.ErrWaitDelay):
+		return 0, nil
+
+	case errors.As(err, &exitError):
+		return resolveExitCode(exitError), nil
+
+	// Context cancellation can race with the process exiting. If the
+	// process already exited, extract its real exit code instead of
+	// treating context.Canceled as a generic failure.
+	case errors.Is(err, context.Canceled) && cmd.ProcessState != nil:
+		return cmd.ProcessState.ExitCode(), nil
+
+	default:
+		return 0, fmt.Errorf("command %q execution failed: %w", cmd.Args[0], err)
+	}
 }
 
 // resolveExitCode extracts the exit code from an ExitError.
@@ -188,8 +464,8 @@ func resolveExitCode(exitError *exec.ExitError) int {
 	if code != -1 {
 		return code
 	}
-	if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
-		return signalExitCodeBase + int(status.Signal())
+	if signalCode, ok := signalExitCode(exitError); ok {
+		return signalCode
 	}
 	return code
 }
@@ -199,17 +475,76 @@ func (e *Executor) GetStreams() (io.Reader, io.Reader) {
 	return e.stdoutPipe, e.stderrPipe
 }
 
+// PID returns the process ID of the command (the last stage of a
+// pipeline), or 0 if it has not been started.
+func (e *Executor) PID() int {
+	if !e.isStarted.Load() || e.cmd.Process == nil {
+		return 0
+	}
+	return e.cmd.Process.Pid
+}
+
+// CommandPath returns the path the command (the last stage of a pipeline)
+// was started from, as resolved through PATH, or "" if it has not been
+// started.
+func (e *Executor) CommandPath() string {
+	if !e.isStarted.Load() {
+		return ""
+	}
+	return e.cmd.Path
+}
+
+// StreamFDs returns the descriptors, in logwrap's process, of the pipes
+// read by [Executor.GetStreams], for tracing descriptor issues. They are
+// recorded by Start and are -1 before, or when a stream is not a pipe.
+// They stay the same after Cleanup closes the pipes, when the numbers may
+// be reused.
+func (e *Executor) StreamFDs() (int, int) {
+	if !e.isStarted.Load() {
+		return -1, -1
+	}
+	return e.stdoutFD, e.stderrFD
+}
+
 // GetExitCode returns the exit code of the finished command.
 func (e *Executor) GetExitCode() int {
 	return e.exitCode
 }
 
+// StageExitCodes returns the exit code of every stage of the finished
+// command, in pipeline order; a single command has one. GetExitCode is
+// derived from them by the pipeline policy.
+func (e *Executor) StageExitCodes() []int {
+	return slices.Clone(e.stageCodes)
+}
+
+// CoreDumped reports whether the finished command, or any stage of a
+// pipeline, was killed by a signal and dumped core, e.g. after an abort or
+// a segmentation fault. It is always false on Windows.
+func (e *Executor) CoreDumped() bool {
+	return e.coreDumped
+}
+
+// Duration returns how long the command ran, from Start until Wait saw it
+// (every pipeline stage) exit, measured on the monotonic clock. It is 0
+// before Start and the time elapsed so far while the command runs.
+func (e *Executor) Duration() time.Duration {
+	if !e.isStarted.Load() {
+		return 0
+	}
+	if e.isFinished.Load() {
+		return e.finishedAt.Sub(e.startedAt)
+	}
+	return time.Since(e.startedAt)
+}
+
 // IsFinished returns true if the command has finished execution.
 func (e *Executor) IsFinished() bool {
 	return e.isFinished.Load()
 }
 
-// Stop gracefully terminates the command using SIGTERM.
+// Stop gracefully terminates the command using SIGTERM (CTRL_BREAK_EVENT on
+// Windows).
 // Context cancellation triggers the custom Cancel function (SIGTERM).
 // If the process doesn't exit within WaitDelay, Go escalates to SIGKILL.
 func (e *Executor) Stop() error {
@@ -221,24 +556,32 @@ func (e *Executor) Stop() error {
 	return nil
 }
 
-// Kill forcefully terminates the command with SIGKILL.
+// Kill forcefully terminates the command (every pipeline stage) with SIGKILL.
+// On Windows, each stage's whole process tree is terminated.
 func (e *Executor) Kill() error {
 	if !e.isStarted.Load() || e.isFinished.Load() {
 		return nil
 	}
 
-	if e.cmd.Process != nil {
-		if err := e.cmd.Process.Kill(); err != nil {
-			return fmt.Errorf("failed to kill process %q: %w", e.commandName, err)
+	var firstErr error
+	for _, cmd := range e.stages {
+		if cmd.Process == nil {
+			continue
+		}
+		if err := killProcess(cmd.Process); err != nil && !errors.Is(err, os.ErrProcessDone) && firstErr == nil {
+			firstErr = fmt.Errorf("failed to kill process %q: %w", cmd.Args[0], err)
 		}
 	}
 
 	e.cancel()
-	return nil
+	return firstErr
 }
 
-// Cleanup closes pipes and cancels context to release resources.
+// Cleanup closes pipes and cancels context to release resources. The
+// executor cannot be started or waited for afterwards.
 func (e *Executor) Cleanup() {
+	e.isCleanedUp.Store(true)
+	e.closePipeFiles()
 	if e.stdoutPipe != nil {
 		_ = e.stdoutPipe.Close()
 	}
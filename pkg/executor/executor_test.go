@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"testing"
@@ -156,6 +158,102 @@ func TestExecutor_StartTwice(t *testing.T) {
 	_ = exec.Wait()
 }
 
+func TestExecutor_PID(t *testing.T) {
+	t.Parallel()
+
+	exec, err := executor.New([]string{"echo", "test"})
+	require.NoError(t, err)
+	t.Cleanup(exec.Cleanup)
+
+	assert.Zero(t, exec.PID(), "no PID before Start")
+
+	require.NoError(t, exec.Start())
+	assert.Positive(t, exec.PID())
+	assert.NotEqual(t, os.Getpid(), exec.PID())
+
+	_ = exec.Wait()
+}
+
+func TestExecutor_CommandPathAndStreamFDs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is not an executable on Windows")
+	}
+	t.Parallel()
+
+	exec, err := executor.New([]string{"echo", "test"})
+	require.NoError(t, err)
+	t.Cleanup(exec.Cleanup)
+
+	assert.Empty(t, exec.CommandPath(), "no path before Start")
+	stdoutFD, stderrFD := exec.StreamFDs()
+	assert.Equal(t, -1, stdoutFD)
+	assert.Equal(t, -1, stderrFD)
+
+	require.NoError(t, exec.Start())
+	assert.True(t, filepath.IsAbs(exec.CommandPath()), "path resolved through PATH: %q", exec.CommandPath())
+	assert.Equal(t, "echo", filepath.Base(exec.CommandPath()))
+
+	stdoutFD, stderrFD = exec.StreamFDs()
+	assert.Greater(t, stdoutFD, 2, "pipes are not the standard descriptors")
+	assert.Greater(t, stderrFD, 2)
+	assert.NotEqual(t, stdoutFD, stderrFD)
+
+	_ = exec.Wait()
+
+	pipeline, err := executor.NewPipeline([][]string{{"echo", "test"}, {"cat"}}, executor.PipelineLast)
+	require.NoError(t, err)
+	t.Cleanup(pipeline.Cleanup)
+	require.NoError(t, pipeline.Start())
+	assert.Equal(t, "cat", filepath.Base(pipeline.CommandPath()), "a pipeline reports its last stage")
+	stdoutFD, stderrFD = pipeline.StreamFDs()
+	assert.Greater(t, stdoutFD, 2)
+	assert.Greater(t, stderrFD, 2)
+	_ = pipeline.Wait()
+}
+
+func TestExecutor_Duration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep not available on Windows")
+	}
+	t.Parallel()
+
+	exec, err := executor.New([]string{"sleep", "0.2"})
+	require.NoError(t, err)
+	t.Cleanup(exec.Cleanup)
+
+	assert.Zero(t, exec.Duration(), "no duration before Start")
+
+	require.NoError(t, exec.Start())
+	assert.Less(t, exec.Duration(), 200*time.Millisecond, "elapsed time while running")
+	require.NoError(t, exec.Wait())
+
+	d := exec.Duration()
+	assert.GreaterOrEqual(t, d, 200*time.Millisecond)
+	assert.Less(t, d, 2*time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, d, exec.Duration(), "the duration is fixed once the command has exited")
+}
+
+func TestExecutor_OutputReadableAfterWait(t *testing.T) {
+	t.Parallel()
+
+	// The output of a command that exits at once can still be read after
+	// Wait has returned, so readers that fall behind Wait lose nothing.
+	exec, err := executor.New([]string{"echo", "hello"})
+	require.NoError(t, err)
+	t.Cleanup(exec.Cleanup)
+	require.NoError(t, exec.Start())
+	require.NoError(t, exec.Wait())
+
+	stdout, stderr := exec.GetStreams()
+	data, err := io.ReadAll(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+	data, err = io.ReadAll(stderr)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
 func TestExecutor_WaitWithoutStart(t *testing.T) {
 	t.Parallel()
 
@@ -173,6 +271,30 @@ func TestExecutor_WaitWithoutStart(t *testing.T) {
 	assert.ErrorIs(t, err, apperrors.ErrExecutorNotStarted)
 }
 
+func TestExecutor_ReuseAfterCleanup(t *testing.T) {
+	t.Parallel()
+
+	// Cleaned up before starting.
+	exec, err := executor.New([]string{"echo", "test"})
+	require.NoError(t, err)
+	exec.Cleanup()
+	require.ErrorIs(t, exec.Start(), apperrors.ErrExecutorCleanedUp)
+	require.ErrorIs(t, exec.Wait(), apperrors.ErrExecutorCleanedUp)
+
+	// Cleaned up after a complete run.
+	exec, err = executor.New([]string{"echo", "test"})
+	require.NoError(t, err)
+	require.NoError(t, exec.Start())
+	require.NoError(t, exec.Wait())
+	exec.Cleanup()
+
+	err = exec.Start()
+	require.ErrorIs(t, err, apperrors.ErrExecutorCleanedUp)
+	assert.NotErrorIs(t, err, apperrors.ErrExecutorStarted, "cleanup is reported rather than the earlier start")
+	require.ErrorIs(t, exec.Wait(), apperrors.ErrExecutorCleanedUp)
+	assert.Zero(t, exec.GetExitCode(), "the finished run's results stay available")
+}
+
 func TestExecutor_NonZeroExitCode(t *testing.T) {
 	t.Parallel()
 
@@ -217,6 +339,65 @@ func TestExecutor_InvalidCommand(t *testing.T) {
 	err = exec.Start()
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to start command")
+	assert.ErrorIs(t, err, apperrors.ErrCommandNotFound)
+}
+
+func TestExecutor_StartFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions and exec formats are Unix-specific")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	notExecutable := filepath.Join(dir, "script.sh")
+	require.NoError(t, os.WriteFile(notExecutable, []byte("#!/bin/sh\necho hi\n"), 0o600))
+	badFormat := filepath.Join(dir, "garbage")
+	require.NoError(t, os.WriteFile(badFormat, []byte{0x00, 0x01, 0x02, 0x03}, 0o700)) //nolint:gosec // must be executable
+
+	tests := []struct {
+		name     string
+		command  string
+		expected error
+		message  string
+	}{
+		{"not in PATH", "nonexistent-command-12345", apperrors.ErrCommandNotFound, "command not found"},
+		{"missing path", filepath.Join(dir, "missing"), apperrors.ErrCommandNotFound, "command not found"},
+		{"no execute permission", notExecutable, apperrors.ErrCommandPermission, "permission denied"},
+		{"unknown executable format", badFormat, apperrors.ErrCommandNotExecutable, "not executable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			exec, err := executor.New([]string{tt.command})
+			require.NoError(t, err)
+			t.Cleanup(exec.Cleanup)
+
+			err = exec.Start()
+			require.ErrorIs(t, err, tt.expected)
+			assert.Contains(t, err.Error(), fmt.Sprintf("failed to start command %q: %s: ", tt.command, tt.message))
+			for _, other := range []error{
+				apperrors.ErrCommandNotFound, apperrors.ErrCommandPermission, apperrors.ErrCommandNotExecutable,
+			} {
+				if other != tt.expected {
+					assert.NotErrorIs(t, err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutor_StartFailures_PipelineStage(t *testing.T) {
+	t.Parallel()
+
+	exec, err := executor.NewPipeline([][]string{{"echo", "test"}, {"nonexistent-command-12345"}}, executor.PipelineLast)
+	require.NoError(t, err)
+	t.Cleanup(exec.Cleanup)
+
+	err = exec.Start()
+	require.ErrorIs(t, err, apperrors.ErrCommandNotFound)
+	assert.Contains(t, err.Error(), `"nonexistent-command-12345"`, "the failing stage is named")
 }
 
 func TestExecutor_StderrOutput(t *testing.T) {
@@ -694,4 +875,131 @@ func TestExecutor_Stop_SendsSIGTERM(t *testing.T) {
 
 	// Verify the marker file was created (SIGTERM trap handler ran)
 	assert.FileExists(t, markerFile, "SIGTERM trap should have created marker file")
-}
\ No newline at end of file
+}
+func TestNewPipeline_EmptyStage(t *testing.T) {
+	t.Parallel()
+
+	_, err := executor.NewPipeline([][]string{{"echo", "hi"}, {}}, executor.PipelineLast)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, apperrors.ErrCommandEmpty)
+
+	_, err = executor.NewPipeline(nil, executor.PipelineLast)
+	assert.ErrorIs(t, err, apperrors.ErrCommandEmpty)
+}
+
+func TestExecutor_Pipeline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Pipeline test uses POSIX commands")
+	}
+
+	t.Parallel()
+
+	exec, err := executor.NewPipeline([][]string{
+		{"sh", "-c", "printf 'b\\na\\n'; echo first-stderr >&2"},
+		{"sh", "-c", "sort; echo second-stderr >&2"},
+	}, executor.PipelineLast)
+	require.NoError(t, err)
+	t.Cleanup(func() { exec.Cleanup() })
+
+	require.NoError(t, exec.Start())
+
+	stdout, stderr := exec.GetStreams()
+	var stderrData []byte
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		stderrData, _ = io.ReadAll(stderr)
+	}()
+
+	stdoutData, err := io.ReadAll(stdout)
+	require.NoError(t, err)
+	wg.Wait()
+
+	require.NoError(t, exec.Wait())
+	assert.Equal(t, "a\nb\n", string(stdoutData))
+	assert.Contains(t, string(stderrData), "first-stderr")
+	assert.Contains(t, string(stderrData), "second-stderr")
+	assert.Equal(t, 0, exec.GetExitCode())
+}
+
+func TestExecutor_PipelinePolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Pipeline test uses POSIX commands")
+	}
+
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		stages       [][]string
+		policy       executor.PipelinePolicy
+		expectedCode int
+	}{
+		{"last ignores earlier failure", [][]string{{"sh", "-c", "exit 3"}, {"cat"}}, executor.PipelineLast, 0},
+		{"any reports earlier failure", [][]string{{"sh", "-c", "exit 3"}, {"cat"}}, executor.PipelineAnyFailure, 3},
+		{"last reports last failure", [][]string{{"true"}, {"sh", "-c", "cat; exit 4"}}, executor.PipelineLast, 4},
+		{"any prefers rightmost failure", [][]string{
+			{"sh", "-c", "exit 3"}, {"sh", "-c", "cat; exit 5"}, {"cat"},
+		}, executor.PipelineAnyFailure, 5},
+		{"any with all success", [][]string{{"true"}, {"cat"}}, executor.PipelineAnyFailure, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			exec, err := executor.NewPipeline(tt.stages, tt.policy)
+			require.NoError(t, err)
+			t.Cleanup(func() { exec.Cleanup() })
+
+			require.NoError(t, exec.Start())
+
+			stdout, stderr := exec.GetStreams()
+			go func() { _, _ = io.Copy(io.Discard, stderr) }()
+			_, _ = io.Copy(io.Discard, stdout)
+
+			require.NoError(t, exec.Wait())
+			assert.Equal(t, tt.expectedCode, exec.GetExitCode())
+		})
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		line        string
+		expected    []string
+		expectedErr error
+	}{
+		{"simple", "echo hello world", []string{"echo", "hello", "world"}, nil},
+		{"extra whitespace", "  echo \t hello  ", []string{"echo", "hello"}, nil},
+		{"blank", "   ", nil, nil},
+		{"double quotes", `echo "hello world"`, []string{"echo", "hello world"}, nil},
+		{"single quotes are literal", `echo '$HOME \n "x"'`, []string{"echo", `$HOME \n "x"`}, nil},
+		{"escapes in double quotes", `echo "a \"b\" \\ \$c \d"`, []string{"echo", `a "b" \ $c \d`}, nil},
+		{"backslash outside quotes", `echo a\ b \'c`, []string{"echo", "a b", "'c"}, nil},
+		{"adjacent quoting joins", `echo pre"mid"'post'`, []string{"echo", "premidpost"}, nil},
+		{"empty quoted argument", `printf '' ""`, []string{"printf", "", ""}, nil},
+		{"no shell operators", `echo a; rm -rf / | cat`, []string{"echo", "a;", "rm", "-rf", "/", "|", "cat"}, nil},
+		{"unterminated single quote", `echo 'oops`, nil, apperrors.ErrUnterminatedQuote},
+		{"unterminated double quote", `echo "oops`, nil, apperrors.ErrUnterminatedQuote},
+		{"trailing backslash", `echo oops\`, nil, apperrors.ErrUnterminatedEscape},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			args, err := executor.SplitCommandLine(tt.line)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, args)
+		})
+	}
+}
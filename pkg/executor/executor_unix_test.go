@@ -0,0 +1,126 @@
+//go:build !windows
+
+package executor_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/pkg/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_CoreDumped(t *testing.T) {
+	t.Parallel()
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &limit); err != nil || limit.Max == 0 {
+		t.Skip("core dumps are disabled on this system")
+	}
+
+	run := func(script string) *executor.Executor {
+		t.Helper()
+		exec, err := executor.New([]string{"sh", "-c", script})
+		require.NoError(t, err)
+		t.Cleanup(exec.Cleanup)
+		require.NoError(t, exec.Start())
+		stdout, stderr := exec.GetStreams()
+		_, _ = io.Copy(io.Discard, stdout)
+		_, _ = io.Copy(io.Discard, stderr)
+		require.NoError(t, exec.Wait())
+		return exec
+	}
+
+	// The core file, if the system writes one next to the process, goes to
+	// a temporary directory.
+	exec := run("cd '" + t.TempDir() + "' && ulimit -c unlimited && kill -ABRT $$")
+	assert.Equal(t, 128+int(syscall.SIGABRT), exec.GetExitCode())
+	assert.True(t, exec.CoreDumped())
+
+	exec = run("exit 1")
+	assert.Equal(t, 1, exec.GetExitCode())
+	assert.False(t, exec.CoreDumped(), "an ordinary failure does not dump core")
+}
+
+func TestExecutor_SinglePipeKeepsOrder(t *testing.T) {
+	t.Parallel()
+
+	const script = `for i in 1 2 3 4 5 6 7 8 9 10; do echo "out $i"; echo "err $i" >&2; done`
+	var out, errOut, interleaved strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&out, "out %d\n", i)
+		fmt.Fprintf(&errOut, "err %d\n", i)
+		fmt.Fprintf(&interleaved, "out %d\nerr %d\n", i, i)
+	}
+
+	read := func(exec *executor.Executor) (string, string) {
+		t.Helper()
+		t.Cleanup(exec.Cleanup)
+		require.NoError(t, exec.Start())
+		stdout, stderr := exec.GetStreams()
+		stdoutData, err := io.ReadAll(stdout)
+		require.NoError(t, err)
+		stderrData, err := io.ReadAll(stderr)
+		require.NoError(t, err)
+		require.NoError(t, exec.Wait())
+		return string(stdoutData), string(stderrData)
+	}
+
+	// With two pipes, each stream keeps its own order but the order
+	// between them is lost.
+	exec, err := executor.New([]string{"sh", "-c", script})
+	require.NoError(t, err)
+	stdout, stderr := read(exec)
+	assert.Equal(t, out.String(), stdout)
+	assert.Equal(t, errOut.String(), stderr)
+
+	exec, err = executor.New([]string{"sh", "-c", script}, executor.WithSinglePipe())
+	require.NoError(t, err)
+	stdout, stderr = read(exec)
+	assert.Equal(t, interleaved.String(), stdout, "lines are read in the order they were written")
+	assert.Empty(t, stderr)
+	stdoutFD, stderrFD := exec.StreamFDs()
+	assert.Greater(t, stdoutFD, 2)
+	assert.Equal(t, -1, stderrFD, "there is no stderr pipe")
+
+	// The first stage's stderr is written before the last stage starts
+	// writing, once the first stage has closed its stdout.
+	pipeline, err := executor.NewPipeline([][]string{
+		{"sh", "-c", "echo first >&2"},
+		{"sh", "-c", "cat >/dev/null; " + script},
+	}, executor.PipelineLast, executor.WithSinglePipe())
+	require.NoError(t, err)
+	stdout, stderr = read(pipeline)
+	assert.Equal(t, "first\n"+interleaved.String(), stdout)
+	assert.Empty(t, stderr)
+}
+
+func TestExecutor_BackgroundProcessHoldingOutput(t *testing.T) {
+	t.Parallel()
+
+	// The background sleep inherits stdout and keeps it open after the
+	// shell has exited.
+	for _, stages := range [][][]string{
+		{{"sh", "-c", "echo hello; sleep 5 &"}},
+		{{"true"}, {"sh", "-c", "echo hello; sleep 5 &"}},
+	} {
+		exec, err := executor.NewPipeline(stages, executor.PipelineLast)
+		require.NoError(t, err)
+		t.Cleanup(exec.Cleanup)
+		require.NoError(t, exec.Start())
+		require.NoError(t, exec.Wait())
+
+		stdout, _ := exec.GetStreams()
+		start := time.Now()
+		data, err := io.ReadAll(stdout)
+		assert.ErrorIs(t, err, os.ErrClosed, "the idle pipe is closed")
+		assert.Equal(t, "hello\n", string(data), "the output written is read first")
+		assert.Less(t, time.Since(start), 3*time.Second, "reading does not wait for the background process")
+	}
+}
@@ -0,0 +1,39 @@
+package executor
+
+import (
+	"io"
+	"strings"
+)
+
+// Option configures an Executor created by [New] or [NewPipeline].
+type Option func(*options)
+
+type options struct {
+	singlePipe bool
+}
+
+// WithSinglePipe gives the command's stdout and stderr the write end of one
+// pipe, as "2>&1" does in a shell, so that the kernel keeps its output in
+// the order it was written: with two pipes, a line written to stderr right
+// after one written to stdout may be read first. For a pipeline, the last
+// stage's stdout and every stage's stderr share the pipe. The price is that
+// the origin of each line is lost: [Executor.GetStreams] returns all of the
+// output as stdout and an empty stderr.
+func WithSinglePipe() Option {
+	return func(o *options) {
+		o.singlePipe = true
+	}
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// emptyStream is the stderr of an executor created WithSinglePipe.
+func emptyStream() io.ReadCloser {
+	return io.NopCloser(strings.NewReader(""))
+}
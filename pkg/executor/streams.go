@@ -0,0 +1,76 @@
+package executor
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// streamIdleDelay is how long, once the command has exited, a read of its
+// output may wait for data before the pipe is closed. A background process
+// started by the command inherits the pipe and can hold it open long after
+// the command exited; readers would otherwise wait for it too.
+const streamIdleDelay = 500 * time.Millisecond
+
+// streamReader is the read end of an output pipe. It records when a pending
+// Read started waiting, so that a pipe nothing is written to any more can
+// be closed without cutting off a reader still draining it.
+type streamReader struct {
+	io.ReadCloser
+	waitingSince atomic.Int64 // UnixNano when the pending Read began, 0 if none
+	closed       atomic.Bool
+}
+
+func newStreamReader(r io.ReadCloser) *streamReader {
+	return &streamReader{ReadCloser: r}
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	r.waitingSince.Store(time.Now().UnixNano())
+	defer r.waitingSince.Store(0)
+	return r.ReadCloser.Read(p) //nolint:wrapcheck // readers check for io.EOF and os.ErrClosed
+}
+
+// Close closes the pipe. Later calls do nothing.
+func (r *streamReader) Close() error {
+	if r.closed.Swap(true) {
+		return nil
+	}
+	return r.ReadCloser.Close() //nolint:wrapcheck // closing errors are ignored by callers
+}
+
+// idle reports whether a Read has been waiting for data for at least d
+// since after.
+func (r *streamReader) idle(d time.Duration, after, now time.Time) bool {
+	since := r.waitingSince.Load()
+	if since == 0 {
+		return false
+	}
+	return now.Sub(time.Unix(0, max(since, after.UnixNano()))) >= d
+}
+
+// closeIdleStreams runs once the command has exited. It closes each output
+// pipe whose reader has waited streamIdleDelay for data: everything the
+// command wrote has been read by then, and only a process it left behind
+// keeps the pipe open. Readers then get [os.ErrClosed]. A reader that is
+// busy between reads is never cut off. It returns once both pipes are
+// closed, here or by Cleanup.
+func (e *Executor) closeIdleStreams() {
+	ticker := time.NewTicker(streamIdleDelay / 5)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		open := false
+		for _, r := range []*streamReader{e.stdoutPipe, e.stderrPipe} {
+			switch {
+			case r.closed.Load():
+			case r.idle(streamIdleDelay, e.finishedAt, now):
+				_ = r.Close()
+			default:
+				open = true
+			}
+		}
+		if !open {
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// configureProcess prepares cmd for [terminateProcess]. Nothing is needed on
+// Unix: the child already receives signals sent to its PID.
+func configureProcess(*exec.Cmd) {}
+
+// terminateProcess asks the process to exit with SIGTERM.
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM) //nolint:wrapcheck // wrapped by the callers
+}
+
+// killProcess kills the process with SIGKILL.
+func killProcess(p *os.Process) error {
+	return p.Kill() //nolint:wrapcheck // wrapped by the callers
+}
+
+// coreDumped reports whether the process was killed by a signal and dumped
+// core.
+func coreDumped(state *os.ProcessState) bool {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	return ok && status.CoreDump()
+}
+
+// signalExitCode returns 128 + signal number when the process was killed by
+// a signal.
+func signalExitCode(exitError *exec.ExitError) (int, bool) {
+	if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return signalExitCodeBase + int(status.Signal()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// ctrlBreakEvent is the CTRL_BREAK_EVENT console control event.
+const ctrlBreakEvent = 1
+
+var procGenerateConsoleCtrlEvent = syscall.NewLazyDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
+// configureProcess starts cmd in its own process group, so that
+// [terminateProcess] can send it a console control event without also
+// interrupting logwrap.
+func configureProcess(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// terminateProcess is the Windows counterpart of SIGTERM: it sends
+// CTRL_BREAK_EVENT to the process group, which console programs handle like
+// Ctrl+C. When the event cannot be delivered (e.g. logwrap has no console),
+// the process tree is killed instead.
+func terminateProcess(p *os.Process) error {
+	ok, _, _ := procGenerateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(p.Pid))
+	if ok != 0 {
+		return nil
+	}
+	return killProcess(p)
+}
+
+// killProcess kills the process and its descendants. os.Process.Kill only
+// terminates the process itself, leaving children such as those started by
+// "cmd /c" holding the output pipes open.
+func killProcess(p *os.Process) error {
+	//nolint:gosec // the PID is the command's own
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); err == nil {
+		return nil
+	}
+	return p.Kill() //nolint:wrapcheck // wrapped by the callers
+}
+
+// coreDumped always reports false: Windows processes do not dump core.
+func coreDumped(*os.ProcessState) bool {
+	return false
+}
+
+// signalExitCode always reports false: Windows processes are not terminated
+// by signals, and a killed process exits with an ordinary exit code.
+func signalExitCode(*exec.ExitError) (int, bool) {
+	return 0, false
+}
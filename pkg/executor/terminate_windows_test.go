@@ -0,0 +1,53 @@
+//go:build windows
+
+package executor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/pkg/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// longRunning keeps a child of cmd.exe alive, so killing only cmd.exe would
+// leave ping holding the output pipes open.
+var longRunning = []string{"cmd", "/c", "ping -n 30 127.0.0.1 >NUL"}
+
+func TestExecutor_Stop_Windows(t *testing.T) {
+	t.Parallel()
+
+	exec, err := executor.New(longRunning)
+	require.NoError(t, err)
+	t.Cleanup(exec.Cleanup)
+
+	require.NoError(t, exec.Start())
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, exec.Stop())
+
+	start := time.Now()
+	_ = exec.Wait()
+	assert.True(t, exec.IsFinished())
+	assert.Less(t, time.Since(start), 10*time.Second, "Command should have been terminated")
+}
+
+func TestExecutor_Kill_Windows(t *testing.T) {
+	t.Parallel()
+
+	exec, err := executor.New(longRunning)
+	require.NoError(t, err)
+	t.Cleanup(exec.Cleanup)
+
+	require.NoError(t, exec.Start())
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, exec.Kill())
+
+	start := time.Now()
+	_ = exec.Wait()
+	assert.True(t, exec.IsFinished())
+	assert.Less(t, time.Since(start), 2*time.Second, "Command should have been killed quickly")
+	assert.NotEqual(t, 0, exec.GetExitCode())
+}
@@ -28,6 +28,9 @@ type Config struct {
 	IncludePatterns []string `yaml:"include_patterns"`
 	ExcludeLevels   []string `yaml:"exclude_levels"`
 	IncludeLevels   []string `yaml:"include_levels"`
+	// CaseSensitive matches level keywords with their exact case, like
+	// the formatter's detection.case_sensitive.
+	CaseSensitive bool `yaml:"-"`
 }
 
 // Filter evaluates log lines against configured include/exclude rules.
@@ -39,6 +42,7 @@ type Filter struct {
 	// levelKeywords maps uppercase level names to their detection keywords.
 	// Used to check whether a line "is" at a given level.
 	levelKeywords map[string][]string
+	caseSensitive bool
 }
 
 // New creates a Filter from the given config and detection keywords.
@@ -49,6 +53,7 @@ func New(cfg Config, keywords map[string][]string) (*Filter, error) {
 		excludeLevels: make(map[string]bool),
 		includeLevels: make(map[string]bool),
 		levelKeywords: make(map[string][]string),
+		caseSensitive: cfg.CaseSensitive,
 	}
 
 	for _, p := range cfg.ExcludePatterns {
@@ -98,7 +103,7 @@ func (f *Filter) passesLevelFilter(line string) bool {
 		return true
 	}
 
-	detectedLevel := f.detectLevel(strings.ToUpper(line))
+	detectedLevel := f.detectLevel(line)
 
 	// Lines with no detected level always pass the level filter.
 	// Only lines with a recognized level keyword are subject to
@@ -132,13 +137,19 @@ func (f *Filter) matchesAny(line string, patterns []*regexp.Regexp) bool {
 
 // detectLevel returns the uppercase level name for a line, or empty string if none detected.
 // Uses the same keyword scanning approach as the formatter but with simplified priority.
-func (f *Filter) detectLevel(lineUpper string) string {
+func (f *Filter) detectLevel(line string) string {
+	if !f.caseSensitive {
+		line = strings.ToUpper(line)
+	}
 	// Check levels in deterministic priority order (most to least severe).
 	priorities := []string{"FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"}
 	for _, level := range priorities {
 		keywords := f.levelKeywords[level]
 		for _, kw := range keywords {
-			if strings.Contains(lineUpper, strings.ToUpper(kw)) {
+			if !f.caseSensitive {
+				kw = strings.ToUpper(kw)
+			}
+			if strings.Contains(line, kw) {
 				return level
 			}
 		}
@@ -213,6 +213,16 @@ func TestFilter_CaseInsensitiveLevels(t *testing.T) {
 	assert.True(t, f.ShouldInclude("ERROR: failed"))
 }
 
+func TestFilter_CaseSensitiveKeywords(t *testing.T) {
+	t.Parallel()
+
+	f, err := New(Config{ExcludeLevels: []string{"debug"}, CaseSensitive: true}, testKeywords)
+	require.NoError(t, err)
+
+	assert.False(t, f.ShouldInclude("DEBUG: variable dump"))
+	assert.True(t, f.ShouldInclude("debug: variable dump"), "lower-case debug is not a DEBUG keyword")
+}
+
 func TestFilter_FatalAndTraceLevels(t *testing.T) {
 	t.Parallel()
 
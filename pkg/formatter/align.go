@@ -0,0 +1,38 @@
+package formatter
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// alignPrefix pads prefix with spaces so that messages start in the same
+// column. With output.prefix_width set, prefixes are padded to that fixed
+// width; otherwise they are padded to the widest prefix seen so far, so
+// alignment settles after the first few lines. Longer prefixes are never
+// truncated. Width is counted in runes.
+func (f *DefaultFormatter) alignPrefix(prefix string) string {
+	width := utf8.RuneCountInString(prefix)
+
+	target := f.config.Output.PrefixWidth
+	if target == 0 {
+		target = f.trackPrefixWidth(width)
+	}
+
+	if width >= target {
+		return prefix
+	}
+	return prefix + strings.Repeat(" ", target-width)
+}
+
+// trackPrefixWidth records width and returns the widest prefix seen.
+func (f *DefaultFormatter) trackPrefixWidth(width int) int {
+	for {
+		widest := f.maxPrefixWidth.Load()
+		if int64(width) <= widest {
+			return int(widest)
+		}
+		if f.maxPrefixWidth.CompareAndSwap(widest, int64(width)) {
+			return width
+		}
+	}
+}
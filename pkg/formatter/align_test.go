@@ -0,0 +1,69 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLine_AlignMessages(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{"INFO: ready", "WARNING: slow", "ERROR: failed", "DEBUG: x"}
+
+	t.Run("fixed width", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("text")
+		cfg.Output.AlignMessages = true
+		cfg.Output.PrefixWidth = 10
+		f, err := New(cfg)
+		require.NoError(t, err)
+
+		for _, line := range lines {
+			out := f.FormatLine(line, processor.StreamStdout)
+			assert.Equal(t, 10, strings.Index(out, line), "message column in %q", out)
+		}
+	})
+
+	t.Run("widest seen", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("text")
+		cfg.Output.AlignMessages = true
+		f, err := New(cfg)
+		require.NoError(t, err)
+
+		// Prime with the widest level, then every message lines up with it.
+		first := f.FormatLine("ERROR: failed", processor.StreamStdout)
+		column := strings.Index(first, "ERROR: failed")
+		for _, line := range lines {
+			out := f.FormatLine(line, processor.StreamStdout)
+			assert.Equal(t, column, strings.Index(out, line), "message column in %q", out)
+		}
+	})
+
+	t.Run("longer prefixes are not truncated", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("text")
+		cfg.Output.AlignMessages = true
+		cfg.Output.PrefixWidth = 3
+		f, err := New(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "[INFO] hello", f.FormatLine("hello", processor.StreamStdout))
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("text")
+		cfg.Output.PrefixWidth = 10
+		f, err := New(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "[INFO] hello", f.FormatLine("hello", processor.StreamStdout))
+	})
+}
@@ -2,7 +2,9 @@ package formatter
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sgaunet/logwrap/pkg/config"
 	"github.com/sgaunet/logwrap/pkg/processor"
@@ -14,6 +16,7 @@ func BenchmarkGetLogLevel_WithCache(b *testing.B) {
 		LogLevel: config.LogLevelConfig{
 			DefaultStdout: "INFO",
 			DefaultStderr: "ERROR",
+			CacheSize:     1000,
 			Detection: config.DetectionConfig{
 				Enabled: true,
 				Keywords: map[string][]string{
@@ -207,12 +210,14 @@ func BenchmarkGetLogLevel_RealWorld(b *testing.B) {
 	})
 }
 
-// BenchmarkCacheGrowth measures memory impact of unbounded cache
+// BenchmarkCacheGrowth measures memory impact of the cache under many unique
+// lines. The cache is bounded by CacheSize, so memory stays flat.
 func BenchmarkCacheGrowth(b *testing.B) {
 	cfg := &config.Config{
 		LogLevel: config.LogLevelConfig{
 			DefaultStdout: "INFO",
 			DefaultStderr: "ERROR",
+			CacheSize:     1000,
 			Detection: config.DetectionConfig{
 				Enabled: true,
 				Keywords: map[string][]string{
@@ -281,3 +286,98 @@ func BenchmarkKeywordDetection_Complexity(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkGetLogLevel_MaxScanBytes measures detection on very long lines
+// with and without a max_scan_bytes limit.
+func BenchmarkGetLogLevel_MaxScanBytes(b *testing.B) {
+	line := strings.Repeat("a", 64*1024) + " ERROR"
+
+	for _, limit := range []int{0, 4096} {
+		b.Run(fmt.Sprintf("max_scan_bytes=%d", limit), func(b *testing.B) {
+			cfg := newTestConfig("text")
+			cfg.LogLevel.CacheSize = 0
+			cfg.LogLevel.Detection.MaxScanBytes = limit
+			f, err := New(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				_ = f.getLogLevel(line, processor.StreamStdout)
+			}
+		})
+	}
+}
+
+func BenchmarkGetTimestamp(b *testing.B) {
+	for _, interval := range []time.Duration{0, 100 * time.Millisecond} {
+		b.Run("interval="+interval.String(), func(b *testing.B) {
+			cfg := newTestConfig("text")
+			cfg.Prefix.Timestamp.Format = "%Y-%m-%d %H:%M:%S"
+			cfg.Prefix.Timestamp.CacheInterval = interval
+			f, err := New(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				_ = f.getTimestamp()
+			}
+		})
+	}
+}
+
+// BenchmarkFormatLine_PrefixCache compares executing the prefix template for
+// every line with the precompiled prefix of prefix.cache.
+func BenchmarkFormatLine_PrefixCache(b *testing.B) {
+	for _, cache := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache=%t", cache), func(b *testing.B) {
+			cfg := newTestConfig("text")
+			cfg.Prefix.Template = "[{{.Timestamp}}] [{{.Level}}] [{{.User}}:{{.PID}}] "
+			cfg.Prefix.Timestamp.CacheInterval = time.Second
+			cfg.Prefix.Cache = cache
+			f, err := New(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				_ = f.FormatLine("ERROR: connection failed", processor.StreamStdout)
+			}
+		})
+	}
+}
+
+// BenchmarkKeywordMatching compares the single-pass matcher with a
+// strings.Contains loop over every keyword, for growing keyword sets.
+func BenchmarkKeywordMatching(b *testing.B) {
+	line := "2024-01-15 10:30:45 request handled in 12ms for user 42 on /api/v1/orders"
+
+	for _, perLevel := range []int{2, 10, 50} {
+		keywords := make(map[string][]string)
+		for _, level := range levelPriority {
+			for i := range perLevel {
+				keywords[level] = append(keywords[level], fmt.Sprintf("%s_keyword_%d", level, i))
+			}
+		}
+		m := newKeywordMatcher(keywords, levelPriority, true)
+		name := fmt.Sprintf("keywords=%d", perLevel*len(levelPriority))
+
+		b.Run(name+"/naive", func(b *testing.B) {
+			for b.Loop() {
+				_, _ = naiveMatch(keywords, line, true)
+			}
+		})
+		b.Run(name+"/matcher", func(b *testing.B) {
+			for b.Loop() {
+				_, _ = m.match(line)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package formatter
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+)
+
+// maxCachedLineLen is the longest line whose detected level is cached.
+// Longer lines are rarely repeated verbatim, and skipping them keeps the
+// cache's memory bounded by roughly cacheSize × maxCachedLineLen.
+const maxCachedLineLen = 1024
+
+// levelCacheKey identifies a cached detection result. The stream is part of
+// the key because the fallback level differs between stdout and stderr.
+type levelCacheKey struct {
+	line   string
+	stream processor.StreamType
+}
+
+type levelCacheEntry struct {
+	key   levelCacheKey
+	match levelMatch
+}
+
+// levelCache is a fixed-size LRU cache of detected log levels. Repetitive
+// output (health checks, progress lines) hits the cache and skips keyword
+// scanning. It is safe for concurrent use.
+type levelCache struct {
+	mutex   sync.Mutex
+	maxSize int
+	order   *list.List // front = most recently used
+	entries map[levelCacheKey]*list.Element
+}
+
+// newLevelCache returns a cache holding at most maxSize entries, or nil
+// when maxSize is 0 (caching disabled).
+func newLevelCache(maxSize int) *levelCache {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &levelCache{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[levelCacheKey]*list.Element, maxSize),
+	}
+}
+
+func (c *levelCache) get(key levelCacheKey) (levelMatch, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return levelMatch{}, false
+	}
+	c.order.MoveToFront(elem)
+	entry, _ := elem.Value.(*levelCacheEntry)
+	return entry.match, true
+}
+
+func (c *levelCache) put(key levelCacheKey, match levelMatch) {
+	if len(key.line) > maxCachedLineLen {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if c.order.Len() >= c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		entry, _ := oldest.Value.(*levelCacheEntry)
+		delete(c.entries, entry.key)
+	}
+
+	c.entries[key] = c.order.PushFront(&levelCacheEntry{key: key, match: match})
+}
+
+func (c *levelCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
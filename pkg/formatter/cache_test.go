@@ -0,0 +1,80 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelCache_BoundedSize(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.CacheSize = 10
+	formatter, err := New(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, formatter.levelCache)
+
+	for i := range 1000 {
+		level := formatter.getLogLevel(fmt.Sprintf("ERROR: unique line %d", i), processor.StreamStdout)
+		assert.Equal(t, "ERROR", level)
+		assert.LessOrEqual(t, formatter.levelCache.len(), 10)
+	}
+	assert.Equal(t, 10, formatter.levelCache.len())
+}
+
+func TestLevelCache_Disabled(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.CacheSize = 0
+	formatter, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Nil(t, formatter.levelCache)
+	assert.Equal(t, "WARN", formatter.getLogLevel("WARN: disk almost full", processor.StreamStdout))
+}
+
+func TestLevelCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	cache := newLevelCache(2)
+	a := levelCacheKey{line: "a", stream: processor.StreamStdout}
+	b := levelCacheKey{line: "b", stream: processor.StreamStdout}
+	c := levelCacheKey{line: "c", stream: processor.StreamStdout}
+
+	cache.put(a, levelMatch{level: "INFO"})
+	cache.put(b, levelMatch{level: "WARN"})
+	_, _ = cache.get(a) // a is now most recently used
+	cache.put(c, levelMatch{level: "ERROR"})
+
+	_, ok := cache.get(b)
+	assert.False(t, ok, "least recently used entry should be evicted")
+	match, ok := cache.get(a)
+	assert.True(t, ok)
+	assert.Equal(t, "INFO", match.level)
+}
+
+func TestLevelCache_StreamIsPartOfKey(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.CacheSize = 10
+	formatter, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "INFO", formatter.getLogLevel("plain line", processor.StreamStdout))
+	assert.Equal(t, "ERROR", formatter.getLogLevel("plain line", processor.StreamStderr))
+}
+
+func TestLevelCache_SkipsLongLines(t *testing.T) {
+	t.Parallel()
+
+	cache := newLevelCache(10)
+	cache.put(levelCacheKey{line: strings.Repeat("x", maxCachedLineLen+1)}, levelMatch{level: "INFO"})
+	assert.Equal(t, 0, cache.len())
+}
@@ -0,0 +1,43 @@
+package formatter
+
+import (
+	"sync"
+	"time"
+)
+
+// lineDelta measures the time between formatted lines for
+// output.include_delta. It is shared by both streams.
+type lineDelta struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	last time.Time // zero until the first line
+}
+
+func newLineDelta(enabled bool) *lineDelta {
+	if !enabled {
+		return nil
+	}
+	return &lineDelta{now: time.Now}
+}
+
+// next returns the time since the previous call, rounded to the
+// millisecond, or 0 on the first call. Lines formatted out of order by
+// several workers never get a negative delta.
+func (d *lineDelta) next() time.Duration {
+	if d == nil {
+		return 0
+	}
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var delta time.Duration
+	if !d.last.IsZero() {
+		delta = max(now.Sub(d.last), 0)
+	}
+	if now.After(d.last) {
+		d.last = now
+	}
+	return delta.Round(time.Millisecond)
+}
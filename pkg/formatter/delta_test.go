@@ -0,0 +1,109 @@
+package formatter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockClock returns each time in turn, then the last one forever.
+func mockClock(times ...time.Time) func() time.Time {
+	return func() time.Time {
+		now := times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return now
+	}
+}
+
+func TestLineDelta(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	d := newLineDelta(true)
+	d.now = mockClock(
+		base,
+		base.Add(100*time.Millisecond),
+		base.Add(100*time.Millisecond),
+		base.Add(1500*time.Millisecond+400*time.Microsecond),
+		base.Add(time.Second), // formatted out of order by another worker
+		base.Add(2*time.Second),
+	)
+
+	assert.Equal(t, time.Duration(0), d.next(), "the first line has no delta")
+	assert.Equal(t, 100*time.Millisecond, d.next())
+	assert.Equal(t, time.Duration(0), d.next())
+	assert.Equal(t, 1400*time.Millisecond, d.next(), "deltas are rounded to the millisecond")
+	assert.Equal(t, time.Duration(0), d.next(), "deltas are never negative")
+	assert.Equal(t, 500*time.Millisecond, d.next(), "an earlier line does not move the clock back")
+
+	assert.Nil(t, newLineDelta(false))
+	assert.Equal(t, time.Duration(0), (*lineDelta)(nil).next())
+}
+
+func TestFormatLine_IncludeDelta(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	clock := []time.Time{base, base.Add(20 * time.Millisecond), base.Add(520 * time.Millisecond), base.Add(3 * time.Second)}
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("json")
+		cfg.Output.IncludeDelta = true
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+		formatter.delta.now = mockClock(clock...)
+
+		var deltas []any
+		for _, stream := range []processor.StreamType{
+			processor.StreamStdout, processor.StreamStderr, processor.StreamStdout, processor.StreamStderr,
+		} {
+			var data map[string]any
+			require.NoError(t, json.Unmarshal([]byte(formatter.FormatLine("tick", stream)), &data))
+			deltas = append(deltas, data["delta_ms"])
+		}
+		assert.Equal(t, []any{0.0, 20.0, 500.0, 2480.0}, deltas, "deltas span both streams")
+	})
+
+	t.Run("template", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("text")
+		cfg.Prefix.Template = "[+{{.Delta}}] "
+		cfg.Output.IncludeDelta = true
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+		formatter.delta.now = mockClock(clock...)
+
+		assert.Equal(t, "[+0s] a", formatter.FormatLine("a", processor.StreamStdout))
+		assert.Equal(t, "[+20ms] b", formatter.FormatLine("b", processor.StreamStdout))
+		assert.Equal(t, "[+500ms] c", formatter.FormatLine("c", processor.StreamStdout))
+	})
+
+	t.Run("dropped lines do not count", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("structured")
+		cfg.Output.IncludeDelta = true
+		cfg.LogLevel.Detection.Keywords["drop"] = []string{"/healthz"}
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+		formatter.delta.now = mockClock(clock...)
+
+		assert.Contains(t, formatter.FormatLine("start", processor.StreamStdout), " delta_ms=0 ")
+		_, err = formatter.FormatRecord(processor.Record{Line: "GET /healthz", Stream: processor.StreamStdout})
+		require.Error(t, err)
+		assert.Contains(t, formatter.FormatLine("next", processor.StreamStdout), " delta_ms=20 ")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		formatter, err := New(newTestConfig("json"))
+		require.NoError(t, err)
+		assert.NotContains(t, formatter.FormatLine("tick", processor.StreamStdout), "delta_ms")
+	})
+}
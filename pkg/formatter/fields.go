@@ -0,0 +1,142 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+)
+
+// customField is a field attached to every line, as opposed to a
+// [fieldExtractor] whose value comes from the line itself. Templated values
+// are compiled separately by [compileFieldTemplates].
+type customField struct {
+	name  string
+	value string
+}
+
+// ciFields returns the CI metadata fields found through getenv, sorted by
+// name. sources maps each field to its environment variables in lookup
+// order (see [config.DefaultCIFieldSources]); the first non-empty one wins.
+// Fields whose variables are all unset are omitted.
+func ciFields(sources map[string][]string, getenv func(string) string) []customField {
+	var fields []customField
+	for name, envs := range sources {
+		for _, env := range envs {
+			value := getenv(env)
+			if value == "" {
+				continue
+			}
+			if env == "GITHUB_REF" {
+				value = strings.TrimPrefix(strings.TrimPrefix(value, "refs/heads/"), "refs/tags/")
+			}
+			fields = append(fields, customField{name: name, value: value})
+			break
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// buildCustomFields collects the custom fields enabled in cfg, sorted by
+// name. output.custom_fields override CI fields of the same name, and names
+// also used by an extracted field are dropped: the per-line value is more
+// specific.
+func buildCustomFields(cfg *config.Config, getenv func(string) string) []customField {
+	var fields []customField
+	if cfg.Output.AutoCIFields {
+		for _, field := range ciFields(cfg.Output.CIFieldSources, getenv) {
+			if _, custom := cfg.Output.CustomFields[field.name]; !custom {
+				fields = append(fields, field)
+			}
+		}
+	}
+	for name, value := range cfg.Output.CustomFields {
+		fields = append(fields, customField{name: name, value: value})
+	}
+
+	kept := fields[:0]
+	for _, field := range fields {
+		if _, extracted := cfg.LogLevel.Detection.ExtractFields[field.name]; !extracted {
+			kept = append(kept, field)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].name < kept[j].name })
+	return kept
+}
+
+// compileFieldTemplates parses the custom field values that are templates,
+// keyed by field name, or returns nil when there are none. Missing keys
+// render empty so that {{.Fields.x}} for a field not set on a line does not
+// print "<no value>".
+func compileFieldTemplates(fields []customField) (map[string]*template.Template, error) {
+	var templates map[string]*template.Template
+	for _, field := range fields {
+		if !strings.Contains(field.value, "{{") {
+			continue
+		}
+		tmpl, err := template.New(field.name).Funcs(config.TemplateFuncs()).Option("missingkey=zero").Parse(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template of custom field %q: %w", field.name, err)
+		}
+		testData := TemplateData{Timestamp: "t", Level: "t", User: "t", PID: "t", Host: "t", Line: "t"}
+		if err := tmpl.Execute(io.Discard, testData); err != nil {
+			return nil, fmt.Errorf("invalid template of custom field %q: %w", field.name, err)
+		}
+		if templates == nil {
+			templates = make(map[string]*template.Template)
+		}
+		templates[field.name] = tmpl
+	}
+	return templates, nil
+}
+
+// renderFieldTemplates renders the templated custom fields into
+// data.Fields. Every template sees the fields as they were before any
+// templated value was set, so templated fields cannot reference each other
+// (or themselves) and rendering cannot recurse. A template that fails to
+// execute renders empty.
+func (f *DefaultFormatter) renderFieldTemplates(data *TemplateData) {
+	if f.fieldTemplates == nil {
+		return
+	}
+	rendered := make(map[string]string, len(f.fieldTemplates))
+	var sb strings.Builder
+	for name, tmpl := range f.fieldTemplates {
+		sb.Reset()
+		if err := tmpl.Execute(&sb, data); err == nil {
+			rendered[name] = sb.String()
+		}
+	}
+	for _, c := range f.customFields {
+		if _, templated := f.fieldTemplates[c.name]; templated {
+			data.Fields[c.name] = rendered[c.name]
+		}
+	}
+}
+
+// jsonValue converts an extracted value to the JSON type hinted by
+// extract_field_types. Values that do not parse as that type are kept as
+// strings, as are floats JSON cannot represent (NaN, Inf).
+func (e fieldExtractor) jsonValue(value string) any {
+	switch e.kind {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if n, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(n) && !math.IsInf(n, 0) {
+			return n
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
@@ -0,0 +1,171 @@
+package formatter
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		sources  map[string][]string // defaults when nil
+		env      map[string]string
+		expected []customField
+	}{
+		{
+			name:     "not in CI",
+			env:      map[string]string{},
+			expected: nil,
+		},
+		{
+			name: "github actions push",
+			env: map[string]string{
+				"GITHUB_SHA": "abc123", "GITHUB_REF": "refs/heads/main", "GITHUB_RUN_ID": "42",
+			},
+			expected: []customField{
+				{"ci_branch", "main"}, {"ci_commit_sha", "abc123"}, {"ci_job_id", "42"},
+			},
+		},
+		{
+			name: "github actions pull request prefers head ref",
+			env: map[string]string{
+				"GITHUB_HEAD_REF": "feature", "GITHUB_REF_NAME": "7/merge",
+			},
+			expected: []customField{{"ci_branch", "feature"}},
+		},
+		{
+			name: "gitlab",
+			env: map[string]string{
+				"CI_COMMIT_SHA": "def456", "CI_COMMIT_REF_NAME": "develop", "CI_JOB_ID": "7",
+			},
+			expected: []customField{
+				{"ci_branch", "develop"}, {"ci_commit_sha", "def456"}, {"ci_job_id", "7"},
+			},
+		},
+		{
+			name: "custom sources",
+			sources: map[string][]string{
+				"ci_commit_sha": {"DRONE_COMMIT_SHA", "GITHUB_SHA"},
+				"ci_pipeline":   {"CI_PIPELINE_ID"},
+				"ci_job_id":     {},
+			},
+			env: map[string]string{
+				"GITHUB_SHA": "abc123", "DRONE_COMMIT_SHA": "fed789", "CI_PIPELINE_ID": "9", "GITHUB_RUN_ID": "42",
+			},
+			expected: []customField{{"ci_commit_sha", "fed789"}, {"ci_pipeline", "9"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			sources := tt.sources
+			if sources == nil {
+				sources = config.DefaultCIFieldSources()
+			}
+			getenv := func(key string) string { return tt.env[key] }
+			assert.Equal(t, tt.expected, ciFields(sources, getenv))
+		})
+	}
+}
+
+func TestFormatLine_AutoCIFields(t *testing.T) {
+	t.Setenv("GITHUB_SHA", "abc123")
+	t.Setenv("GITHUB_HEAD_REF", "") // set for pull requests on GitHub Actions
+	t.Setenv("GITHUB_REF_NAME", "")
+	t.Setenv("GITHUB_REF", "refs/heads/main")
+	t.Setenv("GITHUB_RUN_ID", "42")
+
+	cfg := newTestConfig("json")
+	cfg.Output.AutoCIFields = true
+	cfg.Output.CIFieldSources = config.DefaultCIFieldSources()
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(f.FormatLine("hello", processor.StreamStdout)), &entry))
+	assert.Equal(t, "abc123", entry["ci_commit_sha"])
+	assert.Equal(t, "main", entry["ci_branch"])
+	assert.Equal(t, "42", entry["ci_job_id"])
+	assert.Equal(t, "hello", entry["message"])
+
+	cfg = newTestConfig("structured")
+	cfg.Output.AutoCIFields = true
+	cfg.Output.CIFieldSources = config.DefaultCIFieldSources()
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, f.FormatLine("hello", processor.StreamStdout),
+		" ci_branch=main ci_commit_sha=abc123 ci_job_id=42 ")
+
+	cfg = newTestConfig("json")
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, f.FormatLine("hello", processor.StreamStdout), "ci_commit_sha",
+		"CI fields are opt-in")
+}
+
+func TestFormatLine_CIFieldInTemplate(t *testing.T) {
+	t.Setenv("GITHUB_SHA", "") // takes precedence when running on GitHub Actions
+	t.Setenv("CI_COMMIT_SHA", "def456")
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Fields.ci_commit_sha}}] "
+	cfg.Output.AutoCIFields = true
+	cfg.Output.CIFieldSources = config.DefaultCIFieldSources()
+	f, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "[def456] hello", f.FormatLine("hello", processor.StreamStdout))
+}
+
+func TestFormatLine_TemplatedCustomFields(t *testing.T) {
+	t.Parallel()
+
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	cfg := newTestConfig("json")
+	cfg.Output.CustomFields = map[string]string{
+		"env":      "prod",
+		"host_env": "{{.Host}}-{{.Fields.env}}",
+		"severity": "{{.Level}}",
+		"self":     "[{{.Fields.self}}]",
+	}
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(f.FormatLine("WARN: disk almost full", processor.StreamStdout)), &entry))
+	assert.Equal(t, "prod", entry["env"])
+	assert.Equal(t, host+"-prod", entry["host_env"])
+	assert.Equal(t, "WARN", entry["severity"])
+	assert.Equal(t, "[]", entry["self"], "a templated field cannot see its own value")
+
+	require.NoError(t, json.Unmarshal([]byte(f.FormatLine("ERROR: failed", processor.StreamStdout)), &entry))
+	assert.Equal(t, "ERROR", entry["severity"], "templated fields are rendered per line")
+
+	cfg = newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Fields.severity}}] "
+	cfg.Output.CustomFields = map[string]string{"severity": "{{.Level}}"}
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "[ERROR] ERROR boom", f.FormatLine("ERROR boom", processor.StreamStdout))
+}
+
+func TestNew_InvalidCustomFieldTemplate(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []string{"{{.Host", "{{.Unknown}}"} {
+		cfg := newTestConfig("text")
+		cfg.Output.CustomFields = map[string]string{"bad": value}
+		_, err := New(cfg)
+		assert.Error(t, err, "custom field %q should be rejected", value)
+	}
+}
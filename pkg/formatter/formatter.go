@@ -9,14 +9,33 @@
 // Prefixes are generated using Go's [text/template] engine with these variables:
 //   - {{.Timestamp}} - Current time formatted using strftime (see below)
 //   - {{.Level}}     - Detected log level (ERROR, WARN, INFO, DEBUG)
+//   - {{.Severity}}  - Syslog severity (0-7) of the level, remapped by
+//     output.severity_map
 //   - {{.User}}      - Current username, UID, or both (controlled by config)
-//   - {{.PID}}       - Process ID in decimal or hex (controlled by config)
-//   - {{.Line}}      - The original log line content
+//   - {{.PID}}       - Process ID of logwrap or of the wrapped command, in
+//     decimal or hex (controlled by config)
+//   - {{.PPID}}      - Parent process ID of logwrap (prefix.ppid.enabled)
+//   - {{.Command}}   - Base name of the wrapped command (prefix.command.enabled)
+//   - {{.Host}}      - Host name of the machine running logwrap
+//   - {{.Line}}      - The log line content
+//   - {{.Raw}}       - The line exactly as read, before any input cleanup
+//   - {{.LineNo}}    - The 1-based line number within its stream
+//   - {{.Fields}}    - Values extracted from the line (see below)
+//   - {{.ExitCode}}  - Exit code of the wrapped command once it has exited
+//     (see [DefaultFormatter.SetExitCode]), empty while it runs
+//   - {{.Duration}}  - How long the wrapped command ran, e.g. "1.234s", once
+//     it has exited (see [DefaultFormatter.SetDuration]), empty while it runs
+//   - {{.Delta}}     - Time since the previous line was formatted, e.g.
+//     "12ms", with output.include_delta (0s for the first line)
 //
 // Example template:
 //
 //	[{{.Timestamp}}] {{.Level}} {{.User}}@{{.PID}}:
 //
+// The template only shapes text output. JSON, structured and otel output
+// never execute it: their message is the line itself, so a template that
+// repeats the timestamp or level does not duplicate them there.
+//
 // # Timestamp Formatting
 //
 // Timestamps use strftime format (not Go time format), powered by
@@ -24,13 +43,82 @@
 //   - Format: %Y-%m-%d %H:%M:%S (Linux date command style)
 //   - Timezone: UTC or local, controlled by config
 //
+// With prefix.timestamp.cache_interval set, a formatted timestamp is reused
+// for up to that interval instead of being formatted per line. Formats with
+// sub-second directives (%f) are never cached.
+//
 // # Log Level Detection
 //
 // Log levels are detected by scanning lines for configurable keywords
-// (case-insensitive). The first matching keyword determines the level.
+// (case-insensitive). All keywords are matched in a single pass over the
+// line (Aho-Corasick); when keywords of several levels match, the level
+// with the highest priority wins: FATAL, ERROR, WARN, INFO, DEBUG, TRACE.
+// Keywords listed under the "drop" pseudo-level take precedence over all
+// levels and make [DefaultFormatter.FormatRecord] drop the line.
 // When detection is disabled or no keyword matches, the default level
 // for the stream type (stdout→INFO, stderr→ERROR) is used.
 //
+// Lines longer than log_level.detection.max_scan_bytes skip detection and
+// get the stream's default level.
+//
+// Detection results are kept in a fixed-size LRU cache (log_level.cache_size
+// entries, 0 disables it) so repeated lines skip keyword scanning.
+//
+// # Field Extraction
+//
+// Fields configured in detection.extract_fields are extracted from each line
+// with a regular expression; the first capture group becomes the value.
+// Extracted values are available as {{.Fields.<name>}} in templates and are
+// added as keys to JSON and structured output when the pattern matched.
+//
+// # Custom Fields
+//
+// Custom fields carry a fixed value on every line. With
+// output.auto_ci_fields, ci_commit_sha, ci_branch and ci_job_id are read
+// from the environment variables of common CI systems (GITHUB_SHA,
+// CI_COMMIT_SHA, GITHUB_REF, ...). They are available as {{.Fields.<name>}}
+// and added to JSON and structured output; an extracted field of the same
+// name takes precedence.
+//
+// Fields from output.custom_fields are added the same way. Their values may
+// be templates, parsed once in [New] and rendered per line against the
+// line's [TemplateData] (e.g. "{{.Host}}-prod"). Templated values see the
+// fields as they are before any templated value is rendered, so they cannot
+// reference one another.
+//
+// # Empty Segments
+//
+// With prefix.tidy_empty_segments, separators and brackets left empty by
+// disabled fields are removed from the rendered prefix, so the default
+// template renders "[ts] [INFO] " rather than "[ts] [INFO] [:] " when user
+// and PID are disabled. Templates can instead call join (see
+// [config.TemplateFuncs]), which joins only non-empty values.
+//
+// # Message Alignment
+//
+// With output.align_messages, text prefixes are padded with spaces so that
+// messages start in the same column: to output.prefix_width when set, or
+// else to the widest prefix seen so far. Nothing is buffered.
+//
+// # JSON Passthrough
+//
+// With output.json_passthrough, lines that are JSON objects are merged into
+// the JSON output rather than quoted as "message". Input keys colliding with
+// logwrap's keys are renamed to "input.<key>". output.flatten (or -flatten)
+// additionally flattens nested values into dot-separated keys.
+// output.on_json_parse_failure decides whether other lines are wrapped as
+// "message" (the default), written unchanged, or dropped.
+//
+// # Line Numbers
+//
+// Each stream is numbered independently, starting at 1. The number is
+// available as {{.LineNo}} and, when output.include_line_number is set, is
+// added as line_no to JSON and structured output.
+//
+// With output.include_delta, the time since the previous line of either
+// stream is available as {{.Delta}} and added as delta_ms to JSON and
+// structured output, to spot stalls. Dropped lines do not count.
+//
 // # Color Support
 //
 // ANSI color codes can be applied to the prefix and log lines based on
@@ -41,7 +129,8 @@
 //
 // The formatter is safe for concurrent use by multiple goroutines.
 // The [DefaultFormatter] holds only read-only configuration after
-// initialization.
+// initialization, apart from the mutex-protected level cache and the
+// atomically updated timestamp cache.
 //
 // # Security Note
 //
@@ -53,16 +142,20 @@ package formatter
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"text/template"
 	"time"
 
-	"github.com/itchyny/timefmt-go"
 	"github.com/sgaunet/logwrap/pkg/apperrors"
 	"github.com/sgaunet/logwrap/pkg/config"
 	"github.com/sgaunet/logwrap/pkg/processor"
@@ -73,6 +166,11 @@ const (
 	estimatedPrefixLen = 64
 	// estimatedStructuredLen is the estimated overhead of structured format key=value pairs.
 	estimatedStructuredLen = 128
+	// dropLevel is the level detectLevel reports for a drop keyword match.
+	dropLevel = "DROP"
+	// contextMarker precedes the message of context lines (see
+	// output.context_before).
+	contextMarker = "context: "
 )
 
 // DefaultFormatter provides the default implementation of log line formatting.
@@ -81,23 +179,87 @@ type DefaultFormatter struct {
 	config           *config.Config
 	template         *template.Template
 	userInfo         *user.User
+	group            string // primary group name, only looked up for the user_group format
 	pid              int
+	childPID         atomic.Int64           // set by SetChildPID for pid.source "child"
+	exitCode         atomic.Pointer[string] // set by SetExitCode; nil while the command runs
+	duration         atomic.Pointer[string] // set by SetDuration; nil while the command runs
+	ppid             int
+	command          string // base name of the wrapped command, set by WithCommand
+	host             string
 	colors           map[string]string
 	templateUsesLine bool
+	prefixPlan       prefixPlan // nil unless prefix.cache is set and the template allows it
+	extractors       []fieldExtractor
+	customFields     []customField
+	fieldTemplates   map[string]*template.Template
+	levelCache       *levelCache // nil when caching is disabled
+	timestamp        *timestampFormat
+	delta            *lineDelta      // nil unless output.include_delta is set
+	timestampCache   *timestampCache // nil when caching is disabled or ineligible
+	maxPrefixWidth   atomic.Int64    // widest prefix seen, for output.align_messages
+	keywords         *keywordMatcher // nil when there are no detection keywords
+	onlyLevels       map[string]bool // uppercase filter.only_levels; nil keeps all levels
+	severities       map[string]int  // uppercase level -> syslog severity
+	stripPattern     *regexp.Regexp  // nil when no input prefix is stripped
+	skipPattern      *regexp.Regexp  // nil when every line is prefixed
+}
+
+// Option configures a [DefaultFormatter].
+type Option func(*DefaultFormatter)
+
+// WithCommand sets the wrapped command, whose base name is exposed as
+// {{.Command}} when prefix.command is enabled.
+func WithCommand(command string) Option {
+	return func(f *DefaultFormatter) {
+		f.command = filepath.Base(command)
+	}
+}
+
+// fieldExtractor extracts a named field from a line using the first capture
+// group of a regular expression.
+type fieldExtractor struct {
+	name    string
+	pattern *regexp.Regexp
+	kind    string // JSON type hint from extract_field_types; empty means string
 }
 
 // TemplateData contains the data available for template rendering.
 type TemplateData struct {
 	Timestamp string
 	Level     string
+	Severity  int // syslog severity (0-7) of Level
 	User      string
 	PID       string
+	PPID      string
+	Command   string
+	Host      string
 	Line      string
+	// Raw is the line exactly as read from the command. Input cleanup
+	// applies to Line only, so Raw is kept for debugging the formatter.
+	Raw    string
+	LineNo int
+	// ExitCode is the command's exit code, empty until it has exited.
+	// Lines streamed while the command runs never carry it; lines
+	// formatted afterwards, such as the END run marker, do.
+	ExitCode string
+	// Duration is how long the command ran, rounded to the millisecond,
+	// empty until it has exited like ExitCode.
+	Duration string
+	// Delta is the time since the previous formatted line, rounded to the
+	// millisecond; 0 for the first line or without output.include_delta.
+	Delta time.Duration
+	// MatchedKeyword is the detection keyword that gave the line its
+	// level, empty unless log_level.detection.include_match is set.
+	MatchedKeyword string
+	// Fields holds every configured extracted field (unmatched fields are
+	// empty) and every custom field such as CI metadata.
+	Fields map[string]string
 }
 
 // New creates a new DefaultFormatter with the given configuration.
-func New(cfg *config.Config) (*DefaultFormatter, error) {
-	tmpl, err := template.New("prefix").Parse(cfg.Prefix.Template)
+func New(cfg *config.Config, opts ...Option) (*DefaultFormatter, error) {
+	tmpl, err := template.New("prefix").Funcs(config.TemplateFuncs()).Parse(cfg.Prefix.Template)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -112,11 +274,15 @@ func New(cfg *config.Config) (*DefaultFormatter, error) {
 	}
 
 	var userInfo *user.User
+	var group string
 	if cfg.Prefix.User.Enabled {
 		userInfo, err = user.Current()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get user info: %w", err)
 		}
+		if cfg.Prefix.User.Format == "user_group" {
+			group = primaryGroup(userInfo)
+		}
 	}
 
 	colors := make(map[string]string)
@@ -141,53 +307,227 @@ func New(cfg *config.Config) (*DefaultFormatter, error) {
 		}
 	}
 
-	return &DefaultFormatter{
+	extractors, err := compileExtractors(cfg.LogLevel.Detection.ExtractFields, cfg.LogLevel.Detection.ExtractFieldTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	stripPattern, err := compileStripPattern(cfg.Output.StripInputPrefixPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	skipPattern, err := compileSkipPattern(cfg.Output.SkipPrefixIfMatches)
+	if err != nil {
+		return nil, err
+	}
+
+	customFields := buildCustomFields(cfg, os.Getenv)
+	fieldTemplates, err := compileFieldTemplates(customFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// An unknown host name renders empty rather than failing startup.
+	host, _ := os.Hostname()
+
+	var plan prefixPlan
+	if cfg.Prefix.Cache {
+		plan = compilePrefixPlan(tmpl)
+	}
+	timestamp := newTimestampFormat(cfg.Prefix.Timestamp)
+
+	f := &DefaultFormatter{
 		config:           cfg,
 		template:         tmpl,
 		userInfo:         userInfo,
+		group:            group,
 		pid:              os.Getpid(),
+		ppid:             os.Getppid(),
 		colors:           colors,
 		templateUsesLine: templateReferencesLine(cfg.Prefix.Template),
-	}, nil
+		prefixPlan:       plan,
+		extractors:       extractors,
+		host:             host,
+		customFields:     customFields,
+		fieldTemplates:   fieldTemplates,
+		levelCache:       newLevelCache(cfg.LogLevel.CacheSize),
+		keywords:         newKeywordMatcher(cfg.LogLevel.Detection.Keywords, detectionLevels, !cfg.LogLevel.Detection.CaseSensitive),
+		onlyLevels:       buildOnlyLevels(cfg),
+		severities:       buildSeverities(cfg),
+		stripPattern:     stripPattern,
+		skipPattern:      skipPattern,
+		timestamp:        timestamp,
+		delta:            newLineDelta(cfg.Output.IncludeDelta),
+		timestampCache:   newTimestampCache(timestamp, cfg.Prefix.Timestamp.CacheInterval),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f, nil
 }
 
-// templateReferencesLine reports whether the template string uses the .Line
-// field, accounting for Go template whitespace-trim syntax ({{- and {{).
+// buildOnlyLevels returns the set of levels kept by filter.only_levels, or
+// nil when the filter is disabled or the list is empty.
+func buildOnlyLevels(cfg *config.Config) map[string]bool {
+	if !cfg.Filter.Enabled || len(cfg.Filter.OnlyLevels) == 0 {
+		return nil
+	}
+	levels := make(map[string]bool, len(cfg.Filter.OnlyLevels))
+	for _, level := range cfg.Filter.OnlyLevels {
+		levels[strings.ToUpper(level)] = true
+	}
+	return levels
+}
+
+// compileExtractors compiles the extract_fields patterns, sorted by field
+// name so that structured output has a stable key order, with their
+// extract_field_types hints.
+func compileExtractors(fields, kinds map[string]string) ([]fieldExtractor, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	extractors := make([]fieldExtractor, 0, len(names))
+	for _, name := range names {
+		re, err := regexp.Compile(fields[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid extract pattern for field %q: %w", name, err)
+		}
+		extractors = append(extractors, fieldExtractor{name: name, pattern: re, kind: kinds[name]})
+	}
+	return extractors, nil
+}
+
+// lineFieldPattern matches an action referencing .Line, accounting for Go
+// template whitespace-trim syntax ({{- and {{). The word boundary keeps
+// .LineNo from counting as a reference to the line itself.
+var lineFieldPattern = regexp.MustCompile(`\{\{-? ?\.Line\b`)
+
+// templateReferencesLine reports whether the template string uses the .Line field.
 func templateReferencesLine(tmpl string) bool {
-	return strings.Contains(tmpl, "{{.Line") ||
-		strings.Contains(tmpl, "{{ .Line") ||
-		strings.Contains(tmpl, "{{- .Line") ||
-		strings.Contains(tmpl, "{{-.Line")
+	return lineFieldPattern.MatchString(tmpl)
 }
 
 // FormatLine formats a log line according to the configured output format.
+// If formatting fails, the raw line is returned regardless of
+// output.on_format_error; use [DefaultFormatter.FormatRecord] to apply it.
+// FormatLine cannot drop lines, so lines matching a drop detection keyword,
+// and non-JSON lines under the "drop" output.on_json_parse_failure policy,
+// are returned unformatted as well.
 func (f *DefaultFormatter) FormatLine(line string, streamType processor.StreamType) string {
-	data := f.buildTemplateData(line, streamType)
+	data := f.buildTemplateData(line, streamType, "")
+	if data.Level == dropLevel {
+		return data.Line
+	}
+	if f.alreadyFormatted(line) {
+		return line
+	}
+	data.Delta = f.delta.next()
+	formatted, err := f.format(data)
+	if err != nil {
+		return data.Line
+	}
+	return formatted
+}
 
+// FormatRecord formats a log line and applies the output.on_format_error
+// policy when formatting fails: "raw" returns the unformatted line, "drop"
+// returns an error wrapping [apperrors.ErrLineDropped], and "error" returns
+// the unformatted line with an error wrapping [apperrors.ErrFormatFailed].
+// Lines matching a drop detection keyword, lines whose level is not in
+// filter.only_levels, and non-JSON lines under the "drop"
+// output.on_json_parse_failure policy also return an error wrapping
+// [apperrors.ErrLineDropped]; under "passthrough" such lines are returned
+// unformatted.
+// Context records are kept whatever their level and their message is marked
+// with "context: ". It implements [processor.RecordFormatter].
+func (f *DefaultFormatter) FormatRecord(rec processor.Record) (string, error) {
+	data := f.buildTemplateData(rec.Line, rec.Stream, rec.Level)
+	if data.Level == dropLevel {
+		return "", fmt.Errorf("%w: matched a drop keyword", apperrors.ErrLineDropped)
+	}
+	if f.onlyLevels != nil && !rec.Context && !f.onlyLevels[strings.ToUpper(data.Level)] {
+		return "", fmt.Errorf("%w: level %s not selected", apperrors.ErrLineDropped, data.Level)
+	}
+	if f.alreadyFormatted(rec.Line) {
+		return rec.Line, nil
+	}
+	data.LineNo = rec.LineNo
+	data.Delta = f.delta.next()
+	if rec.Raw != "" {
+		data.Raw = rec.Raw
+	}
+	if rec.Context {
+		data.Line = contextMarker + data.Line
+	}
+	formatted, err := f.format(data)
+	if err == nil {
+		return formatted, nil
+	}
+	if errors.Is(err, apperrors.ErrNotJSONObject) {
+		if f.config.Output.OnJSONParseFailure == "drop" {
+			return "", fmt.Errorf("%w: %w", apperrors.ErrLineDropped, err)
+		}
+		return data.Line, nil
+	}
+
+	switch f.config.Output.OnFormatError {
+	case "drop":
+		return "", fmt.Errorf("%w: %w", apperrors.ErrLineDropped, err)
+	case "error":
+		return data.Line, fmt.Errorf("%w: %w", apperrors.ErrFormatFailed, err)
+	default: // "raw"
+		return data.Line, nil
+	}
+}
+
+func (f *DefaultFormatter) format(data TemplateData) (string, error) {
 	switch f.config.Output.Format {
 	case "json":
 		return f.formatJSON(data)
 	case "structured":
-		return f.formatStructured(data)
+		return f.formatStructured(data), nil
+	case "otel":
+		return f.formatOTel(data)
+	case JournalFormat:
+		return f.formatJournal(data), nil
 	default: // "text"
 		return f.formatText(data)
 	}
 }
 
-func (f *DefaultFormatter) formatText(data TemplateData) string {
+func (f *DefaultFormatter) formatText(data TemplateData) (string, error) {
 	var builder strings.Builder
 	builder.Grow(estimatedPrefixLen + len(data.Line))
-	if err := f.template.Execute(&builder, data); err != nil {
-		return data.Line
+	if f.prefixPlan != nil {
+		f.prefixPlan.render(&builder, &data)
+	} else if err := f.template.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("template execution failed: %w", err)
 	}
 
 	// When the template already includes {{.Line}}, it produces
 	// the complete output — don't append the line again.
 	if f.templateUsesLine {
 		if f.config.Prefix.Colors.Enabled {
-			return f.colorizePrefix(builder.String())
+			return f.colorizePrefix(builder.String()), nil
+		}
+		return builder.String(), nil
+	}
+
+	if f.config.Prefix.TidyEmptySegments || f.config.Output.AlignMessages {
+		prefix := builder.String()
+		if f.config.Prefix.TidyEmptySegments {
+			prefix = tidySegments(prefix)
+		}
+		if f.config.Output.AlignMessages {
+			prefix = f.alignPrefix(prefix)
 		}
-		return builder.String()
+		builder.Reset()
+		builder.Grow(len(prefix) + len(data.Line))
+		builder.WriteString(prefix)
 	}
 
 	if f.config.Prefix.Colors.Enabled {
@@ -198,33 +538,80 @@ func (f *DefaultFormatter) formatText(data TemplateData) string {
 		result.Grow(len(colorizedPrefix) + len(colorizedLine))
 		result.WriteString(colorizedPrefix)
 		result.WriteString(colorizedLine)
-		return result.String()
+		return result.String(), nil
 	}
 
 	// Write line directly to the existing builder to avoid a second allocation.
 	builder.WriteString(data.Line)
-	return builder.String()
+	return builder.String(), nil
 }
 
-func (f *DefaultFormatter) formatJSON(data TemplateData) string {
+// formatJSON renders data as one JSON object. The prefix template is never
+// executed: message is the line itself (after input cleanup), and the
+// values a template could show are separate keys.
+func (f *DefaultFormatter) formatJSON(data TemplateData) (string, error) {
 	jsonData := map[string]any{
 		"timestamp": data.Timestamp,
 		"level":     data.Level,
-		"message":   data.Line,
 	}
+
+	var passthrough map[string]any
+	var isObject bool
+	if f.config.Output.JSONPassthrough {
+		passthrough, isObject = parseJSONObject(data.Line)
+		if policy := f.config.Output.OnJSONParseFailure; !isObject && policy != "" && policy != "wrap" {
+			return "", apperrors.ErrNotJSONObject
+		}
+	}
+	if !isObject {
+		jsonData["message"] = data.Line
+	}
+
 	if f.config.Prefix.User.Enabled {
 		jsonData["user"] = data.User
 	}
 	if f.config.Prefix.PID.Enabled {
 		jsonData["pid"] = data.PID
 	}
+	if f.config.Prefix.PPID.Enabled {
+		jsonData["ppid"] = data.PPID
+	}
+	if f.config.Prefix.Command.Enabled {
+		jsonData["command"] = data.Command
+	}
+	if f.config.Output.IncludeLineNumber {
+		jsonData["line_no"] = data.LineNo
+	}
+	if f.config.Output.IncludeDelta {
+		jsonData["delta_ms"] = data.Delta.Milliseconds()
+	}
+	if f.config.Output.IncludeRaw {
+		jsonData["raw"] = data.Raw
+	}
+	if data.MatchedKeyword != "" {
+		jsonData["matched_keyword"] = data.MatchedKeyword
+	}
+	for _, c := range f.customFields {
+		jsonData[c.name] = data.Fields[c.name]
+	}
+	for _, e := range f.extractors {
+		if value := data.Fields[e.name]; value != "" {
+			jsonData[e.name] = e.jsonValue(value)
+		}
+	}
+	if isObject {
+		if f.config.Output.Flatten {
+			passthrough = flattenJSON(passthrough)
+		}
+		mergePassthrough(jsonData, passthrough)
+	}
 
 	jsonBytes, err := json.Marshal(jsonData)
 	if err != nil {
-		return data.Line
+		return "", fmt.Errorf("JSON encoding failed: %w", err)
 	}
 
-	return string(jsonBytes)
+	return string(jsonBytes), nil
 }
 
 func (f *DefaultFormatter) formatStructured(data TemplateData) string {
@@ -243,6 +630,44 @@ func (f *DefaultFormatter) formatStructured(data TemplateData) string {
 		sb.WriteString(" pid=")
 		sb.WriteString(quoteIfNeeded(data.PID))
 	}
+	if f.config.Prefix.PPID.Enabled {
+		sb.WriteString(" ppid=")
+		sb.WriteString(quoteIfNeeded(data.PPID))
+	}
+	if f.config.Prefix.Command.Enabled {
+		sb.WriteString(" command=")
+		sb.WriteString(quoteIfNeeded(data.Command))
+	}
+	if f.config.Output.IncludeLineNumber {
+		sb.WriteString(" line_no=")
+		sb.WriteString(strconv.Itoa(data.LineNo))
+	}
+	if f.config.Output.IncludeDelta {
+		sb.WriteString(" delta_ms=")
+		sb.WriteString(strconv.FormatInt(data.Delta.Milliseconds(), 10))
+	}
+	if f.config.Output.IncludeRaw {
+		sb.WriteString(" raw=")
+		sb.WriteString(strconv.Quote(data.Raw))
+	}
+	if data.MatchedKeyword != "" {
+		sb.WriteString(" matched_keyword=")
+		sb.WriteString(quoteIfNeeded(data.MatchedKeyword))
+	}
+	for _, c := range f.customFields {
+		sb.WriteString(" ")
+		sb.WriteString(c.name)
+		sb.WriteString("=")
+		sb.WriteString(quoteIfNeeded(data.Fields[c.name]))
+	}
+	for _, e := range f.extractors {
+		if value := data.Fields[e.name]; value != "" {
+			sb.WriteString(" ")
+			sb.WriteString(e.name)
+			sb.WriteString("=")
+			sb.WriteString(quoteIfNeeded(value))
+		}
+	}
 	sb.WriteString(" message=")
 	sb.WriteString(strconv.Quote(data.Line))
 	return sb.String()
@@ -276,53 +701,170 @@ func needsQuoting(s string) bool {
 	return false
 }
 
-func (f *DefaultFormatter) buildTemplateData(line string, streamType processor.StreamType) TemplateData {
-	return TemplateData{
+// Level returns the level line is formatted with: the detected level, the
+// stream's default level, or "DROP" for lines matching a drop keyword.
+func (f *DefaultFormatter) Level(line string, streamType processor.StreamType) string {
+	_, detected := f.splitInput(line)
+	return f.getLogLevel(detected, streamType)
+}
+
+// splitInput returns the message to output for line and the text used for
+// level detection and field extraction. The latter is the line before the
+// input prefix is stripped, unless strip_input_prefix_stage is
+// "before_detection".
+func (f *DefaultFormatter) splitInput(line string) (string, string) {
+	if f.stripPattern == nil {
+		return line, line
+	}
+	message := f.stripInputPrefix(line)
+	if f.config.Output.StripInputPrefixStage == "before_detection" {
+		return message, message
+	}
+	return message, line
+}
+
+// buildTemplateData builds the template data for line. A non-empty level is
+// used instead of the detected one.
+func (f *DefaultFormatter) buildTemplateData(line string, streamType processor.StreamType, level string) TemplateData {
+	message, detected := f.splitInput(line)
+	var keyword string
+	if level == "" {
+		match := f.matchLevel(detected, streamType)
+		level, keyword = match.level, match.keyword
+	}
+	data := TemplateData{
 		Timestamp: f.getTimestamp(),
-		Level:     f.getLogLevel(line, streamType),
+		Level:     level,
+		Severity:  f.severities[strings.ToUpper(level)],
 		User:      f.getUserString(),
 		PID:       f.getPIDString(),
-		Line:      line,
+		PPID:      f.getPPIDString(),
+		Command:   f.getCommandString(),
+		Host:      f.host,
+		Line:      message,
+		Raw:       line,
+		Fields:    f.extractFields(detected),
+		ExitCode:  f.getExitCodeString(),
+		Duration:  f.getDurationString(),
+
+		MatchedKeyword: keyword,
 	}
+	f.renderFieldTemplates(&data)
+	return data
+}
+
+// extractFields returns the configured fields extracted from line together
+// with the fixed custom fields, or nil when no fields are configured.
+// Templated custom fields are set by [DefaultFormatter.renderFieldTemplates].
+func (f *DefaultFormatter) extractFields(line string) map[string]string {
+	if len(f.extractors) == 0 && len(f.customFields) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]string, len(f.extractors)+len(f.customFields))
+	for _, c := range f.customFields {
+		if _, templated := f.fieldTemplates[c.name]; !templated {
+			fields[c.name] = c.value
+		}
+	}
+	for _, e := range f.extractors {
+		var value string
+		if m := e.pattern.FindStringSubmatch(line); len(m) > 1 {
+			value = m[1]
+		}
+		fields[e.name] = value
+	}
+	return fields
 }
 
 func (f *DefaultFormatter) getTimestamp() string {
 	now := time.Now()
-	if f.config.Prefix.Timestamp.UTC {
-		now = now.UTC()
+	if f.timestampCache != nil {
+		return f.timestampCache.get(now)
 	}
-	return timefmt.Format(now, f.config.Prefix.Timestamp.Format)
+	return f.timestamp.render(now)
 }
 
 func (f *DefaultFormatter) getLogLevel(line string, streamType processor.StreamType) string {
-	if !f.config.LogLevel.Detection.Enabled {
+	return f.matchLevel(line, streamType).level
+}
+
+// levelMatch is the result of level detection: the level and, when
+// detection.include_match is set, the keyword that triggered it.
+type levelMatch struct {
+	level   string
+	keyword string
+}
+
+// matchLevel returns the level of line, like getLogLevel, together with the
+// keyword that triggered it.
+func (f *DefaultFormatter) matchLevel(line string, streamType processor.StreamType) levelMatch {
+	// Lines over max_scan_bytes are neither scanned nor cached.
+	if !f.config.LogLevel.Detection.Enabled ||
+		(f.config.LogLevel.Detection.MaxScanBytes > 0 && len(line) > f.config.LogLevel.Detection.MaxScanBytes) {
 		if streamType == processor.StreamStdout {
-			return f.config.LogLevel.DefaultStdout
+			return levelMatch{level: f.config.LogLevel.DefaultStdout}
 		}
-		return f.config.LogLevel.DefaultStderr
+		return levelMatch{level: f.config.LogLevel.DefaultStderr}
+	}
+
+	if f.levelCache == nil {
+		return f.detectLevel(line, streamType)
 	}
 
-	lineUpper := strings.ToUpper(line)
+	key := levelCacheKey{line: line, stream: streamType}
+	if match, ok := f.levelCache.get(key); ok {
+		return match
+	}
+	match := f.detectLevel(line, streamType)
+	f.levelCache.put(key, match)
+	return match
+}
 
-	// Iterate in priority order to ensure deterministic detection
-	// when a line matches multiple levels (e.g., "INFO: An error occurred").
-	levelPriority := []string{"fatal", "error", "warn", "info", "debug", "trace"}
-	for _, level := range levelPriority {
-		keywords, ok := f.config.LogLevel.Detection.Keywords[level]
-		if !ok {
-			continue
+// detectLevel scans line for detection keywords and returns the matching
+// level, or the stream's default level if no keyword matches. When a line
+// matches several levels the one earliest in levelPriority wins, which
+// keeps detection deterministic (e.g., "INFO: An error occurred" is ERROR).
+// A drop keyword wins over every level and yields dropLevel. With
+// detection.level_key, the level of a key field in the line replaces the
+// one keywords would give, though drop keywords still apply. With
+// detection.override_only, a matched level less severe than the stream's
+// default yields the default instead. With detection.include_match, the
+// keyword that gave the level is returned as well; it is empty when the
+// level comes from the default or from the level key.
+func (f *DefaultFormatter) detectLevel(line string, streamType processor.StreamType) levelMatch {
+	defaultLevel := f.config.LogLevel.DefaultStderr
+	if streamType == processor.StreamStdout {
+		defaultLevel = f.config.LogLevel.DefaultStdout
+	}
+
+	var level, keyword string
+	var found bool
+	if key := f.config.LogLevel.Detection.LevelKey; key != "" {
+		level, found = fieldLevel(line, key)
+	}
+	if f.keywords != nil {
+		var priority int
+		var ok bool
+		if f.config.LogLevel.Detection.IncludeMatch {
+			priority, keyword, ok = f.keywords.matchKeyword(line)
+		} else {
+			priority, ok = f.keywords.match(line)
 		}
-		for _, keyword := range keywords {
-			if strings.Contains(lineUpper, strings.ToUpper(keyword)) {
-				return strings.ToUpper(level)
-			}
+		if ok && (!found || detectionLevels[priority] == config.DropLevel) {
+			level, found = strings.ToUpper(detectionLevels[priority]), true
+		} else {
+			keyword = ""
 		}
 	}
+	if !found {
+		return levelMatch{level: defaultLevel}
+	}
 
-	if streamType == processor.StreamStdout {
-		return f.config.LogLevel.DefaultStdout
+	if f.config.LogLevel.Detection.OverrideOnly && level != dropLevel && !moreSevere(level, defaultLevel) {
+		return levelMatch{level: defaultLevel}
 	}
-	return f.config.LogLevel.DefaultStderr
+	return levelMatch{level: level, keyword: keyword}
 }
 
 func (f *DefaultFormatter) getUserString() string {
@@ -337,23 +879,100 @@ func (f *DefaultFormatter) getUserString() string {
 		return f.userInfo.Uid
 	case "full":
 		return fmt.Sprintf("%s(%s)", f.userInfo.Username, f.userInfo.Uid)
+	case "user_host":
+		if f.host == "" {
+			return f.userInfo.Username
+		}
+		return f.userInfo.Username + "@" + f.host
+	case "user_group":
+		return f.userInfo.Username + ":" + f.group
 	default:
 		return f.userInfo.Username
 	}
 }
 
+// primaryGroup returns the name of u's primary group, or its ID when the
+// group cannot be looked up (e.g. it is missing from /etc/group).
+func primaryGroup(u *user.User) string {
+	g, err := user.LookupGroupId(u.Gid)
+	if err != nil {
+		return u.Gid
+	}
+	return g.Name
+}
+
+func (f *DefaultFormatter) getPPIDString() string {
+	if !f.config.Prefix.PPID.Enabled {
+		return ""
+	}
+	return strconv.Itoa(f.ppid)
+}
+
+func (f *DefaultFormatter) getCommandString() string {
+	if !f.config.Prefix.Command.Enabled {
+		return ""
+	}
+	return f.command
+}
+
+// SetChildPID records the PID of the wrapped command, shown instead of
+// logwrap's own PID when pid.source is "child". It is safe to call while
+// lines are being formatted.
+func (f *DefaultFormatter) SetChildPID(pid int) {
+	f.childPID.Store(int64(pid))
+}
+
+// SetExitCode records the exit code of the wrapped command, exposed as
+// {{.ExitCode}} on lines formatted from then on. It is safe to call while
+// lines are being formatted.
+func (f *DefaultFormatter) SetExitCode(code int) {
+	s := strconv.Itoa(code)
+	f.exitCode.Store(&s)
+}
+
+func (f *DefaultFormatter) getExitCodeString() string {
+	if code := f.exitCode.Load(); code != nil {
+		return *code
+	}
+	return ""
+}
+
+// SetDuration records how long the wrapped command ran, exposed as
+// {{.Duration}} on lines formatted from then on. It is safe to call while
+// lines are being formatted.
+func (f *DefaultFormatter) SetDuration(d time.Duration) {
+	s := d.Round(time.Millisecond).String()
+	f.duration.Store(&s)
+}
+
+func (f *DefaultFormatter) getDurationString() string {
+	if d := f.duration.Load(); d != nil {
+		return *d
+	}
+	return ""
+}
+
 func (f *DefaultFormatter) getPIDString() string {
 	if !f.config.Prefix.PID.Enabled {
 		return ""
 	}
 
+	pid := f.pid
+	if f.config.Prefix.PID.Source == "child" {
+		// Before the command has started there is no child PID yet.
+		pid = int(f.childPID.Load())
+		if pid == 0 {
+			return ""
+		}
+	}
+
 	switch f.config.Prefix.PID.Format {
 	case "decimal":
-		return strconv.Itoa(f.pid)
+		return strconv.Itoa(pid)
 	case "hex":
-		return fmt.Sprintf("0x%x", f.pid)
+		return fmt.Sprintf("0x%x", pid)
 	default:
-		return strconv.Itoa(f.pid)
+		return strconv.Itoa(pid)
 	}
 }
 
@@ -397,6 +1016,12 @@ func (f *DefaultFormatter) colorizeLine(line, level string) string {
 	return line
 }
 
+// ColorizeLevel wraps text in the color configured for the given level.
+// It returns text unchanged when colors are disabled or the level has no color.
+func (f *DefaultFormatter) ColorizeLevel(text, level string) string {
+	return f.colorizeLine(text, level)
+}
+
 func (f *DefaultFormatter) applyTimestampColor(text, color string) string {
 	reset := f.colors["reset"]
 	if color != "" && reset != "" {
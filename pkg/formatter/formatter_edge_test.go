@@ -13,10 +13,10 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// newTestFormatter creates a formatter with a simple config for edge case testing.
-func newTestFormatter(t *testing.T, outputFormat string) *DefaultFormatter {
-	t.Helper()
-	cfg := &config.Config{
+// newTestConfig returns a simple config for edge case testing. Tests may
+// adjust the returned config before passing it to New.
+func newTestConfig(outputFormat string) *config.Config {
+	return &config.Config{
 		Prefix: config.PrefixConfig{
 			Template: "[{{.Level}}] ",
 			Timestamp: config.TimestampConfig{
@@ -42,7 +42,12 @@ func newTestFormatter(t *testing.T, outputFormat string) *DefaultFormatter {
 			},
 		},
 	}
-	f, err := New(cfg)
+}
+
+// newTestFormatter creates a formatter with a simple config for edge case testing.
+func newTestFormatter(t *testing.T, outputFormat string) *DefaultFormatter {
+	t.Helper()
+	f, err := New(newTestConfig(outputFormat))
 	require.NoError(t, err)
 	return f
 }
@@ -5,8 +5,11 @@ import (
 	"os"
 	"os/user"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sgaunet/logwrap/pkg/apperrors"
 	"github.com/sgaunet/logwrap/pkg/config"
 	"github.com/sgaunet/logwrap/pkg/processor"
 	"github.com/stretchr/testify/assert"
@@ -554,11 +557,99 @@ func TestGetLogLevel_DetectionDisabled(t *testing.T) {
 	}
 }
 
+func TestGetLogLevel_CaseSensitive(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.Detection.CaseSensitive = true
+	formatter, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ERROR", formatter.getLogLevel("ERROR: something failed", processor.StreamStdout))
+	assert.Equal(t, "INFO", formatter.getLogLevel("error: something failed", processor.StreamStdout),
+		"lower-case error does not match the ERROR keyword")
+	assert.Equal(t, "INFO", formatter.getLogLevel("Error: something failed", processor.StreamStdout))
+
+	insensitive := newTestFormatter(t, "text")
+	assert.Equal(t, "ERROR", insensitive.getLogLevel("error: something failed", processor.StreamStdout),
+		"case is ignored by default")
+}
+
+func TestGetLogLevel_OverrideOnly(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.Detection.OverrideOnly = true
+	cfg.LogLevel.Detection.Keywords["fatal"] = []string{"OUT OF MEMORY"}
+	cfg.LogLevel.Detection.Keywords["drop"] = []string{"/healthz"}
+	formatter, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		line     string
+		stream   processor.StreamType
+		expected string
+	}{
+		{"info keyword keeps stderr at its default", "INFO: connected", processor.StreamStderr, "ERROR"},
+		{"debug keyword keeps stdout at its default", "DEBUG: cache hit", processor.StreamStdout, "INFO"},
+		{"warn keyword raises stdout", "WARN: disk almost full", processor.StreamStdout, "WARN"},
+		{"error keyword raises stdout", "ERROR: request failed", processor.StreamStdout, "ERROR"},
+		{"fatal keyword raises stderr", "worker: out of memory", processor.StreamStderr, "FATAL"},
+		{"no keyword uses the default", "listening on :8080", processor.StreamStdout, "INFO"},
+		{"drop keywords still drop", "GET /healthz", processor.StreamStderr, "DROP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, formatter.getLogLevel(tt.line, tt.stream))
+		})
+	}
+
+	plain := newTestFormatter(t, "text")
+	assert.Equal(t, "INFO", plain.getLogLevel("INFO: connected", processor.StreamStderr),
+		"without override_only keywords can lower the level")
+}
+
+func TestMoreSevere(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, moreSevere("ERROR", "WARN"))
+	assert.True(t, moreSevere("fatal", "ERROR"), "level names are compared case-insensitively")
+	assert.False(t, moreSevere("INFO", "INFO"))
+	assert.False(t, moreSevere("DEBUG", "info"))
+	assert.True(t, moreSevere("TRACE", "CUSTOM"), "unknown levels are the least severe")
+	assert.False(t, moreSevere("CUSTOM", "TRACE"))
+}
+
+func TestGetLogLevel_MaxScanBytes(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.Detection.MaxScanBytes = 32
+	formatter, err := New(cfg)
+	require.NoError(t, err)
+
+	long := "WARN: " + strings.Repeat("x", 64)
+
+	assert.Equal(t, "WARN", formatter.getLogLevel("WARN: disk almost full", processor.StreamStdout))
+	assert.Equal(t, "INFO", formatter.getLogLevel(long, processor.StreamStdout),
+		"lines over the limit should use the stdout default")
+	assert.Equal(t, "ERROR", formatter.getLogLevel(long, processor.StreamStderr),
+		"lines over the limit should use the stderr default")
+}
+
 func TestGetUserString(t *testing.T) {
 	t.Parallel()
 
 	currentUser, err := user.Current()
 	require.NoError(t, err)
+	currentHost, err := os.Hostname()
+	require.NoError(t, err)
+	currentGroup := currentUser.Gid
+	if g, err := user.LookupGroupId(currentUser.Gid); err == nil {
+		currentGroup = g.Name
+	}
 
 	tests := []struct {
 		name     string
@@ -590,6 +681,18 @@ func TestGetUserString(t *testing.T) {
 			format:   "full",
 			expected: currentUser.Username + "(" + currentUser.Uid + ")",
 		},
+		{
+			name:     "user_host format",
+			enabled:  true,
+			format:   "user_host",
+			expected: currentUser.Username + "@" + currentHost,
+		},
+		{
+			name:     "user_group format",
+			enabled:  true,
+			format:   "user_group",
+			expected: currentUser.Username + ":" + currentGroup,
+		},
 		{
 			name:     "invalid format defaults to username",
 			enabled:  true,
@@ -810,7 +913,7 @@ func TestBuildTemplateData(t *testing.T) {
 	require.NoError(t, err)
 
 	line := "test message"
-	data := formatter.buildTemplateData(line, processor.StreamStdout)
+	data := formatter.buildTemplateData(line, processor.StreamStdout, "")
 
 	assert.Equal(t, line, data.Line)
 	assert.Equal(t, "INFO", data.Level)
@@ -952,6 +1055,60 @@ func TestFormatLine_TemplateExecutionError(t *testing.T) {
 	assert.Equal(t, "INFOtest", result)
 }
 
+func TestFormatRecord_OnFormatError(t *testing.T) {
+	t.Parallel()
+
+	// The template parses and passes New's validation (Level is "t" there),
+	// but fails at execution time for ERROR lines.
+	const failingTemplate = `{{if eq .Level "ERROR"}}{{.Missing}}{{end}}[{{.Level}}] `
+
+	tests := []struct {
+		name        string
+		policy      string
+		expected    string
+		expectedErr error
+	}{
+		{"empty defaults to raw", "", "boom", nil},
+		{"raw", "raw", "boom", nil},
+		{"drop", "drop", "", apperrors.ErrLineDropped},
+		{"error", "error", "boom", apperrors.ErrFormatFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config.Config{
+				Prefix: config.PrefixConfig{Template: failingTemplate},
+				Output: config.OutputConfig{Format: "text", OnFormatError: tt.policy},
+				LogLevel: config.LogLevelConfig{
+					DefaultStdout: "INFO",
+					DefaultStderr: "ERROR",
+				},
+			}
+			f, err := New(cfg)
+			require.NoError(t, err)
+
+			// Lines that format successfully are unaffected by the policy.
+			result, err := f.FormatRecord(processor.Record{Line: "ok", Stream: processor.StreamStdout})
+			require.NoError(t, err)
+			assert.Equal(t, "[INFO] ok", result)
+
+			result, err = f.FormatRecord(processor.Record{Line: "boom", Stream: processor.StreamStderr})
+			assert.Equal(t, tt.expected, result)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				assert.Contains(t, err.Error(), "Missing")
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// FormatLine always falls back to the raw line.
+			assert.Equal(t, "boom", f.FormatLine("boom", processor.StreamStderr))
+		})
+	}
+}
+
 // TestFormatLine_JSONSanitization tests JSON output with special characters.
 func TestFormatLine_JSONSanitization(t *testing.T) {
 	t.Parallel()
@@ -1404,4 +1561,499 @@ func TestFormatLine_TemplateWithoutLine_AppendsLine(t *testing.T) {
 
 	result := formatter.FormatLine("hello world", processor.StreamStdout)
 	assert.Equal(t, "[INFO] hello world", result, "line should be appended when template does not include {{.Line}}")
-}
\ No newline at end of file
+}
+func TestFormatLine_ExtractFields(t *testing.T) {
+	t.Parallel()
+
+	extract := map[string]string{
+		"req":    `req=(\S+)`,
+		"thread": `\[thread-(\d+)\]`,
+	}
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("json")
+		cfg.LogLevel.Detection.ExtractFields = extract
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+
+		result := formatter.FormatLine("handled req=abc-123 in 5ms", processor.StreamStdout)
+
+		var jsonData map[string]any
+		require.NoError(t, json.Unmarshal([]byte(result), &jsonData))
+		assert.Equal(t, "abc-123", jsonData["req"])
+		assert.NotContains(t, jsonData, "thread", "unmatched fields should be omitted")
+		assert.Equal(t, "handled req=abc-123 in 5ms", jsonData["message"])
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("structured")
+		cfg.LogLevel.Detection.ExtractFields = extract
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+
+		result := formatter.FormatLine("[thread-7] req=xyz done", processor.StreamStdout)
+		assert.Contains(t, result, " req=xyz thread=7 message=")
+	})
+
+	t.Run("template", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("text")
+		cfg.Prefix.Template = "[{{.Fields.req}}] "
+		cfg.LogLevel.Detection.ExtractFields = extract
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+
+		assert.Equal(t, "[abc] req=abc", formatter.FormatLine("req=abc", processor.StreamStdout))
+		assert.Equal(t, "[] no request", formatter.FormatLine("no request", processor.StreamStdout))
+	})
+}
+
+func TestFormatLine_ExtractFieldTypes(t *testing.T) {
+	t.Parallel()
+
+	newFormatter := func(t *testing.T, format string) *DefaultFormatter {
+		t.Helper()
+		cfg := newTestConfig(format)
+		cfg.LogLevel.Detection.ExtractFields = map[string]string{
+			"count": `count=(\S+)`,
+			"ok":    `ok=(\S+)`,
+			"ratio": `ratio=(\S+)`,
+			"req":   `req=(\S+)`,
+		}
+		cfg.LogLevel.Detection.ExtractFieldTypes = map[string]string{
+			"count": "int",
+			"ok":    "bool",
+			"ratio": "float",
+		}
+		f, err := New(cfg)
+		require.NoError(t, err)
+		return f
+	}
+
+	t.Run("json types", func(t *testing.T) {
+		t.Parallel()
+
+		result := newFormatter(t, "json").FormatLine("count=5 ok=true ratio=0.5 req=42", processor.StreamStdout)
+		assert.Contains(t, result, `"count":5`)
+
+		var jsonData map[string]any
+		require.NoError(t, json.Unmarshal([]byte(result), &jsonData))
+		assert.InDelta(t, 5, jsonData["count"], 0)
+		assert.Equal(t, true, jsonData["ok"])
+		assert.InDelta(t, 0.5, jsonData["ratio"], 0)
+		assert.Equal(t, "42", jsonData["req"], "unhinted fields stay strings")
+	})
+
+	t.Run("unparsable value stays a string", func(t *testing.T) {
+		t.Parallel()
+
+		result := newFormatter(t, "json").FormatLine("count=many ratio=NaN", processor.StreamStdout)
+
+		var jsonData map[string]any
+		require.NoError(t, json.Unmarshal([]byte(result), &jsonData))
+		assert.Equal(t, "many", jsonData["count"])
+		assert.Equal(t, "NaN", jsonData["ratio"])
+	})
+
+	t.Run("structured unaffected", func(t *testing.T) {
+		t.Parallel()
+
+		result := newFormatter(t, "structured").FormatLine("count=5", processor.StreamStdout)
+		assert.Contains(t, result, " count=5 message=")
+	})
+}
+
+func TestFormatRecord_LineNumber(t *testing.T) {
+	t.Parallel()
+
+	rec := processor.Record{Line: "hello", Stream: processor.StreamStdout, LineNo: 7}
+
+	t.Run("text template", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("text")
+		cfg.Prefix.Template = "{{.LineNo}}: "
+		f, err := New(cfg)
+		require.NoError(t, err)
+
+		result, err := f.FormatRecord(rec)
+		require.NoError(t, err)
+		assert.Equal(t, "7: hello", result, ".LineNo must not be mistaken for .Line")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("json")
+		f, err := New(cfg)
+		require.NoError(t, err)
+		result, err := f.FormatRecord(rec)
+		require.NoError(t, err)
+		assert.NotContains(t, result, "line_no", "line_no is opt-in")
+
+		cfg = newTestConfig("json")
+		cfg.Output.IncludeLineNumber = true
+		f, err = New(cfg)
+		require.NoError(t, err)
+		result, err = f.FormatRecord(rec)
+		require.NoError(t, err)
+
+		var parsed map[string]any
+		require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+		assert.InDelta(t, 7, parsed["line_no"], 0)
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("structured")
+		cfg.Output.IncludeLineNumber = true
+		f, err := New(cfg)
+		require.NoError(t, err)
+		result, err := f.FormatRecord(rec)
+		require.NoError(t, err)
+		assert.Contains(t, result, " line_no=7 message=")
+	})
+}
+
+func TestTemplateReferencesLine(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, templateReferencesLine("[{{.Level}}] {{.Line}}"))
+	assert.True(t, templateReferencesLine("{{ .Line }}"))
+	assert.True(t, templateReferencesLine("{{- .Line}}"))
+	assert.True(t, templateReferencesLine("{{-.Line}}"))
+	assert.False(t, templateReferencesLine("{{.LineNo}}: "))
+	assert.False(t, templateReferencesLine("[{{.Level}}] "))
+}
+
+func TestFormatLine_IncludeRaw(t *testing.T) {
+	t.Parallel()
+
+	line := `{"event":"start","n":1}`
+
+	cfg := newTestConfig("json")
+	f, err := New(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, f.FormatLine(line, processor.StreamStdout), `"raw"`, "raw is opt-in")
+
+	// With passthrough the line is merged, so raw is the only place the
+	// original text survives.
+	cfg = newTestConfig("json")
+	cfg.Output.IncludeRaw = true
+	cfg.Output.JSONPassthrough = true
+	f, err = New(cfg)
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(f.FormatLine(line, processor.StreamStdout)), &parsed))
+	assert.Equal(t, line, parsed["raw"])
+	assert.Equal(t, "start", parsed["event"])
+	assert.NotContains(t, parsed, "message")
+
+	cfg = newTestConfig("structured")
+	cfg.Output.IncludeRaw = true
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, f.FormatLine("a b", processor.StreamStdout), ` raw="a b" `)
+}
+
+func TestFormatLine_PIDSourceChild(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.PID}}] "
+	cfg.Prefix.PID = config.PIDConfig{Enabled: true, Format: "decimal", Source: "child"}
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[] hello", f.FormatLine("hello", processor.StreamStdout),
+		"no child PID before the command has started")
+
+	f.SetChildPID(4321)
+	assert.Equal(t, "[4321] hello", f.FormatLine("hello", processor.StreamStdout))
+
+	cfg.Prefix.PID.Format = "hex"
+	f, err = New(cfg)
+	require.NoError(t, err)
+	f.SetChildPID(4321)
+	assert.Equal(t, "[0x10e1] hello", f.FormatLine("hello", processor.StreamStdout))
+
+	cfg.Prefix.PID = config.PIDConfig{Enabled: true, Format: "decimal", Source: "self"}
+	f, err = New(cfg)
+	require.NoError(t, err)
+	f.SetChildPID(4321)
+	assert.Equal(t, "["+strconv.Itoa(os.Getpid())+"] hello", f.FormatLine("hello", processor.StreamStdout))
+}
+
+func TestFormatLine_PPIDAndCommand(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.PPID}}] [{{.Command}}] "
+	f, err := New(cfg, WithCommand("/usr/bin/make"))
+	require.NoError(t, err)
+	assert.Equal(t, "[] [] hello", f.FormatLine("hello", processor.StreamStdout), "both are opt-in")
+
+	cfg.Prefix.PPID.Enabled = true
+	cfg.Prefix.Command.Enabled = true
+	f, err = New(cfg, WithCommand("/usr/bin/make"))
+	require.NoError(t, err)
+	ppid := strconv.Itoa(os.Getppid())
+	assert.Equal(t, "["+ppid+"] [make] hello", f.FormatLine("hello", processor.StreamStdout))
+
+	cfg = newTestConfig("json")
+	cfg.Prefix.PPID.Enabled = true
+	cfg.Prefix.Command.Enabled = true
+	f, err = New(cfg, WithCommand("make"))
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(f.FormatLine("hello", processor.StreamStdout)), &parsed))
+	assert.Equal(t, ppid, parsed["ppid"])
+	assert.Equal(t, "make", parsed["command"])
+
+	cfg = newTestConfig("structured")
+	cfg.Prefix.PPID.Enabled = true
+	cfg.Prefix.Command.Enabled = true
+	f, err = New(cfg, WithCommand("make"))
+	require.NoError(t, err)
+	assert.Contains(t, f.FormatLine("hello", processor.StreamStdout), " ppid="+ppid+" command=make ")
+}
+
+func TestFormatRecord_RawKeepsInput(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("json")
+	cfg.Output.IncludeRaw = true
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	result, err := f.FormatRecord(processor.Record{
+		Line: "hello", Raw: "\ufeffhello", Stream: processor.StreamStdout, LineNo: 1,
+	})
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(result), &parsed))
+	assert.Equal(t, "hello", parsed["message"])
+	assert.Equal(t, "\ufeffhello", parsed["raw"])
+}
+
+func TestFormatLine_ExitCode(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] code={{.ExitCode}} "
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[INFO] code= running", f.FormatLine("running", processor.StreamStdout),
+		"exit code is empty while the command runs")
+
+	f.SetExitCode(2)
+	assert.Equal(t, "[INFO] code=2 done", f.FormatLine("done", processor.StreamStdout))
+}
+
+func TestFormatLine_Duration(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] took={{.Duration}} "
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[INFO] took= running", f.FormatLine("running", processor.StreamStdout),
+		"duration is empty while the command runs")
+
+	f.SetDuration(1234567 * time.Microsecond)
+	assert.Equal(t, "[INFO] took=1.235s done", f.FormatLine("done", processor.StreamStdout))
+}
+
+func TestFormatRecord_DropKeyword(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.Detection.Keywords = map[string][]string{
+		"error":          {"ERROR"},
+		config.DropLevel: {"/healthz"},
+	}
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	// A drop keyword wins over level keywords on the same line.
+	result, err := f.FormatRecord(processor.Record{Line: "GET /HEALTHZ 500 ERROR", Stream: processor.StreamStdout})
+	require.ErrorIs(t, err, apperrors.ErrLineDropped)
+	assert.Empty(t, result)
+
+	result, err = f.FormatRecord(processor.Record{Line: "ERROR: real failure", Stream: processor.StreamStdout})
+	require.NoError(t, err)
+	assert.Contains(t, result, "ERROR: real failure")
+
+	// FormatLine cannot drop, so the line comes back unformatted.
+	assert.Equal(t, "GET /healthz", f.FormatLine("GET /healthz", processor.StreamStdout))
+}
+
+func TestFormatRecord_OnlyLevels(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Filter.Enabled = true
+	cfg.Filter.OnlyLevels = []string{"error"}
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		line    string
+		stream  processor.StreamType
+		dropped bool
+	}{
+		{"ERROR: disk full", processor.StreamStdout, false},
+		{"WARN: disk almost full", processor.StreamStdout, true},
+		{"plain output", processor.StreamStdout, true},
+		{"plain output", processor.StreamStderr, false}, // stderr defaults to ERROR
+	}
+
+	for _, tt := range tests {
+		result, err := f.FormatRecord(processor.Record{Line: tt.line, Stream: tt.stream})
+		if tt.dropped {
+			require.ErrorIs(t, err, apperrors.ErrLineDropped, "line %q", tt.line)
+			continue
+		}
+		require.NoError(t, err, "line %q", tt.line)
+		assert.Contains(t, result, tt.line)
+	}
+
+	// The set only applies while filtering is enabled.
+	cfg.Filter.Enabled = false
+	f, err = New(cfg)
+	require.NoError(t, err)
+	_, err = f.FormatRecord(processor.Record{Line: "plain output", Stream: processor.StreamStdout})
+	require.NoError(t, err)
+}
+
+func TestFormatRecord_Context(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] "
+	cfg.Filter.Enabled = true
+	cfg.Filter.OnlyLevels = []string{"error"}
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	result, err := f.FormatRecord(processor.Record{Line: "WARN: disk almost full", Stream: processor.StreamStdout, Context: true})
+	require.NoError(t, err, "context lines are kept whatever their level")
+	assert.Equal(t, "[WARN] context: WARN: disk almost full", result)
+
+	cfg.Output.Format = "json"
+	f, err = New(cfg)
+	require.NoError(t, err)
+	result, err = f.FormatRecord(processor.Record{Line: "loading", Stream: processor.StreamStdout, Context: true})
+	require.NoError(t, err)
+	assert.Contains(t, result, `"message":"context: loading"`)
+}
+
+func TestFormatRecord_LevelOverride(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}} {{.Severity}}] "
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	result, err := f.FormatRecord(processor.Record{Line: "--- END make code=2 ---", Stream: processor.StreamStdout, Level: "ERROR"})
+	require.NoError(t, err)
+	assert.Equal(t, "[ERROR 3] --- END make code=2 ---", result)
+
+	result, err = f.FormatRecord(processor.Record{Line: "ERROR: stopped", Stream: processor.StreamStdout, Level: "WARN"})
+	require.NoError(t, err)
+	assert.Equal(t, "[WARN 4] ERROR: stopped", result, "the level is used instead of the detected one")
+}
+
+func TestFormatLine_IncludeMatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("structured", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("structured")
+		cfg.LogLevel.Detection.IncludeMatch = true
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+
+		result := formatter.FormatLine("FATAL: out of memory", processor.StreamStdout)
+		assert.Contains(t, result, "level=ERROR matched_keyword=FATAL ")
+		assert.NotContains(t, formatter.FormatLine("all good", processor.StreamStdout), "matched_keyword",
+			"lines at the default level have no matched keyword")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("json")
+		cfg.LogLevel.Detection.IncludeMatch = true
+		cfg.LogLevel.CacheSize = 10
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+
+		for range 2 { // the second line is served from the level cache
+			var data map[string]any
+			require.NoError(t, json.Unmarshal([]byte(formatter.FormatLine("WARNING: slow", processor.StreamStdout)), &data))
+			assert.Equal(t, "WARN", data["level"])
+			assert.Equal(t, "WARN", data["matched_keyword"], "the first keyword found wins")
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("text")
+		cfg.Prefix.Template = "[{{.Level}}:{{.MatchedKeyword}}] "
+		cfg.LogLevel.Detection.IncludeMatch = true
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "[ERROR:PANIC] panic: nil map", formatter.FormatLine("panic: nil map", processor.StreamStdout))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		formatter, err := New(newTestConfig("structured"))
+		require.NoError(t, err)
+		assert.NotContains(t, formatter.FormatLine("FATAL: out of memory", processor.StreamStdout), "matched_keyword")
+	})
+}
+
+func TestFormatLine_MessageIgnoresTemplate(t *testing.T) {
+	t.Parallel()
+
+	const line = `  GET /health 200 "ok"	took 3ms`
+	template := `[{{.Timestamp}}] {{.Level}} {{.User}}@{{.PID}} {{if .Fields.req}}req={{.Fields.req}} {{end}}{{printf "%q" .Line}} {{.Line}}`
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("json")
+		cfg.Prefix.Template = template
+		cfg.LogLevel.Detection.ExtractFields = map[string]string{"req": `GET (\S+)`}
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+
+		var data map[string]any
+		require.NoError(t, json.Unmarshal([]byte(formatter.FormatLine(line, processor.StreamStdout)), &data))
+		assert.Equal(t, line, data["message"])
+		assert.Equal(t, "/health", data["req"])
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		t.Parallel()
+		cfg := newTestConfig("structured")
+		cfg.Prefix.Template = template
+		formatter, err := New(cfg)
+		require.NoError(t, err)
+
+		result := formatter.FormatLine(line, processor.StreamStdout)
+		assert.True(t, strings.HasSuffix(result, " message="+strconv.Quote(line)), result)
+	})
+}
@@ -0,0 +1,80 @@
+package formatter
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// JournalFormat is the output format of journald sinks. It is not a
+// user-selectable format: journald sinks use it regardless of
+// output.format.
+const JournalFormat = "journal"
+
+// maxJournalFieldName is the longest field name journald accepts.
+const maxJournalFieldName = 64
+
+// formatJournal renders data as a journald native protocol entry: one
+// KEY=value line per field, values containing a newline being written as
+// KEY, a newline, the value's length as a little-endian uint64 and the
+// value. The final newline is left to the line ending. MESSAGE is the line
+// without prefix, since the journal records the time, host and PID itself;
+// custom and extracted fields become journal fields with upper-cased names.
+func (f *DefaultFormatter) formatJournal(data TemplateData) string {
+	var b strings.Builder
+	identifier := f.command
+	if identifier == "" {
+		identifier = "logwrap"
+	}
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(data.Severity))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", identifier)
+	writeJournalField(&b, "LOGWRAP_LEVEL", data.Level)
+	for _, c := range f.customFields {
+		writeJournalField(&b, journalFieldName(c.name), data.Fields[c.name])
+	}
+	for _, e := range f.extractors {
+		if value := data.Fields[e.name]; value != "" {
+			writeJournalField(&b, journalFieldName(e.name), value)
+		}
+	}
+	writeJournalField(&b, "MESSAGE", data.Line)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeJournalField appends one field in the native protocol encoding.
+func writeJournalField(b *strings.Builder, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	b.Write(size[:])
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalFieldName turns name into a valid journal field name: upper-case
+// letters, digits and underscores, starting with a letter, at most 64
+// bytes. Names starting with an underscore are reserved for fields the
+// journal sets itself.
+func journalFieldName(name string) string {
+	mapped := []byte(strings.ToUpper(name))
+	for i, c := range mapped {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			mapped[i] = '_'
+		}
+	}
+	s := strings.TrimLeft(string(mapped), "_")
+	if s == "" || s[0] <= '9' {
+		s = "F_" + s
+	}
+	if len(s) > maxJournalFieldName {
+		s = s[:maxJournalFieldName]
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package formatter
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLine_Journal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fields", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig(JournalFormat)
+		cfg.Output.CustomFields = map[string]string{"env": "prod"}
+		cfg.LogLevel.Detection.ExtractFields = map[string]string{"req-id": `req=(\S+)`}
+		f, err := New(cfg, WithCommand("/usr/bin/make"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "PRIORITY=3\nSYSLOG_IDENTIFIER=make\nLOGWRAP_LEVEL=ERROR\nENV=prod\nREQ_ID=42\nMESSAGE=ERROR: build failed req=42",
+			f.FormatLine("ERROR: build failed req=42", processor.StreamStdout))
+	})
+
+	t.Run("priority follows the level", func(t *testing.T) {
+		t.Parallel()
+
+		f := newTestFormatter(t, JournalFormat)
+		for line, priority := range map[string]string{
+			"ERROR: failed":  "PRIORITY=3\n",
+			"WARN: slow":     "PRIORITY=4\n",
+			"INFO: started":  "PRIORITY=6\n",
+			"DEBUG: details": "PRIORITY=7\n",
+		} {
+			assert.True(t, strings.HasPrefix(f.FormatLine(line, processor.StreamStdout), priority), line)
+		}
+	})
+
+	t.Run("multi-line values are length-prefixed", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig(JournalFormat)
+		cfg.Output.CustomFields = map[string]string{"note": "a\nb"}
+		f, err := New(cfg)
+		require.NoError(t, err)
+
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], 3)
+		assert.Contains(t, f.FormatLine("hello", processor.StreamStdout), "\nNOTE\n"+string(size[:])+"a\nb\n")
+	})
+}
+
+func TestJournalFieldName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"env":                   "ENV",
+		"request.id":            "REQUEST_ID",
+		"_private":              "PRIVATE",
+		"9lives":                "F_9LIVES",
+		"___":                   "F_",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, journalFieldName(name), name)
+	}
+}
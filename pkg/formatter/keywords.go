@@ -0,0 +1,216 @@
+package formatter
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+)
+
+// levelPriority is the order in which levels win when a line contains
+// keywords of several levels (e.g. "INFO: An error occurred" is ERROR).
+var levelPriority = []string{"fatal", "error", "warn", "info", "debug", "trace"}
+
+// detectionLevels is levelPriority preceded by the drop pseudo-level, so
+// that a drop keyword wins over any level keyword on the same line.
+var detectionLevels = append([]string{config.DropLevel}, levelPriority...)
+
+// moreSevere reports whether level is more severe than other, comparing
+// level names case-insensitively by their position in levelPriority.
+// Unknown levels are less severe than every known one.
+func moreSevere(level, other string) bool {
+	rank := func(l string) int {
+		if i := slices.Index(levelPriority, strings.ToLower(l)); i >= 0 {
+			return i
+		}
+		return len(levelPriority)
+	}
+	return rank(level) < rank(other)
+}
+
+// noMatch marks an automaton state that completes no keyword.
+const noMatch = -1
+
+// keywordMatcher finds detection keywords in a line with a single pass,
+// using an Aho-Corasick automaton over all keywords of all levels. Matching
+// is case-insensitive like strings.Contains on strings.ToUpper of both
+// sides, or exact like strings.Contains when built without case folding,
+// and reports the highest-priority level found anywhere in the line.
+//
+// The automaton is a DFA: every state has a transition for every byte
+// class, so scanning costs one table lookup per byte. Bytes that occur in
+// no keyword share class 0 to keep the table small. It is immutable after
+// construction and safe for concurrent use.
+type keywordMatcher struct {
+	classes    [256]uint8 // byte -> class; ASCII letters fold to upper case if foldCase
+	numClasses int
+	foldCase   bool
+	next       []int32 // state*numClasses + class -> state
+	best       []int   // state -> best (lowest) priority completed, or noMatch
+	found      []int32 // state -> index in keywords of the keyword giving best
+	keywords   []string
+}
+
+// newKeywordMatcher builds a matcher for the keywords of levels, where a
+// level's index in levels is its priority (0 is the highest). foldCase
+// makes matching case-insensitive. It returns nil when there are no
+// keywords.
+func newKeywordMatcher(keywords map[string][]string, levels []string, foldCase bool) *keywordMatcher {
+	type pattern struct {
+		text     string
+		priority int
+	}
+	var patterns []pattern
+	var spellings []string
+	for priority, level := range levels {
+		for _, keyword := range keywords[level] {
+			spellings = append(spellings, keyword)
+			if foldCase {
+				keyword = strings.ToUpper(keyword)
+			}
+			patterns = append(patterns, pattern{keyword, priority})
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	m := &keywordMatcher{numClasses: 1, foldCase: foldCase, keywords: spellings}
+	for _, p := range patterns {
+		for i := 0; i < len(p.text); i++ {
+			if c := p.text[i]; m.classes[c] == 0 {
+				m.classes[c] = uint8(m.numClasses) //nolint:gosec // at most 256 distinct bytes
+				m.numClasses++
+			}
+		}
+	}
+	if foldCase {
+		for c := 'a'; c <= 'z'; c++ {
+			m.classes[c] = m.classes[c-'a'+'A']
+		}
+	}
+
+	// Build the trie; 0 in next means "no edge yet" until the BFS below.
+	m.addState()
+	for n, p := range patterns {
+		state := 0
+		for i := 0; i < len(p.text); i++ {
+			idx := state*m.numClasses + int(m.classes[p.text[i]])
+			if m.next[idx] == 0 {
+				m.next[idx] = int32(m.addState()) //nolint:gosec // bounded by total keyword length
+			}
+			state = int(m.next[idx])
+		}
+		m.setBest(state, p.priority, int32(n)) //nolint:gosec // bounded by the number of keywords
+	}
+
+	m.link()
+	return m
+}
+
+func (m *keywordMatcher) addState() int {
+	m.next = append(m.next, make([]int32, m.numClasses)...)
+	m.best = append(m.best, noMatch)
+	m.found = append(m.found, noMatch)
+	return len(m.best) - 1
+}
+
+// setBest records that state completes the keyword at index keyword with
+// the given priority, unless it already completes one of higher priority.
+func (m *keywordMatcher) setBest(state, priority int, keyword int32) {
+	if m.best[state] == noMatch || (priority != noMatch && priority < m.best[state]) {
+		m.best[state] = priority
+		m.found[state] = keyword
+	}
+}
+
+// link computes failure transitions breadth-first and folds them into
+// next, so that every state has a transition for every class, and
+// propagates matches along failure links.
+func (m *keywordMatcher) link() {
+	fail := make([]int32, len(m.best))
+	queue := make([]int32, 0, len(m.best))
+
+	for c := range m.numClasses {
+		if child := m.next[c]; child != 0 {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.setBest(int(state), m.best[fail[state]], m.found[fail[state]])
+
+		base := int(state) * m.numClasses
+		failBase := int(fail[state]) * m.numClasses
+		for c := range m.numClasses {
+			child := m.next[base+c]
+			if child == 0 {
+				m.next[base+c] = m.next[failBase+c]
+				continue
+			}
+			fail[child] = m.next[failBase+c]
+			queue = append(queue, child)
+		}
+	}
+}
+
+// match returns the priority of the highest-priority keyword in line.
+func (m *keywordMatcher) match(line string) (int, bool) {
+	if m.foldCase && !isASCII(line) {
+		// Unicode case mapping may change byte lengths; fall back to the
+		// same transformation the keywords went through.
+		line = strings.ToUpper(line)
+	}
+
+	best := m.best[0] // an empty keyword matches every line
+	state := 0
+	for i := 0; i < len(line) && best != 0; i++ {
+		state = int(m.next[state*m.numClasses+int(m.classes[line[i]])])
+		best = minPriority(best, m.best[state])
+	}
+	return best, best != noMatch
+}
+
+// matchKeyword is like match but also returns the keyword that gave the
+// priority, as spelled in the configuration. Among keywords of the same
+// priority, the one ending first in line wins.
+func (m *keywordMatcher) matchKeyword(line string) (int, string, bool) {
+	if m.foldCase && !isASCII(line) {
+		line = strings.ToUpper(line)
+	}
+
+	best, found := m.best[0], m.found[0]
+	state := 0
+	for i := 0; i < len(line) && best != 0; i++ {
+		state = int(m.next[state*m.numClasses+int(m.classes[line[i]])])
+		if b := m.best[state]; b != noMatch && (best == noMatch || b < best) {
+			best, found = b, m.found[state]
+		}
+	}
+	if best == noMatch {
+		return noMatch, "", false
+	}
+	return best, m.keywords[found], true
+}
+
+func minPriority(a, b int) int {
+	switch {
+	case a == noMatch:
+		return b
+	case b == noMatch:
+		return a
+	default:
+		return min(a, b)
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package formatter
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// naiveMatch is the reference implementation the matcher replaces: every
+// keyword of every level is searched for, in priority order.
+func naiveMatch(keywords map[string][]string, line string, foldCase bool) (int, bool) {
+	if foldCase {
+		line = strings.ToUpper(line)
+	}
+	for priority, level := range levelPriority {
+		for _, keyword := range keywords[level] {
+			if foldCase {
+				keyword = strings.ToUpper(keyword)
+			}
+			if strings.Contains(line, keyword) {
+				return priority, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestKeywordMatcher(t *testing.T) {
+	t.Parallel()
+
+	keywords := map[string][]string{
+		"error": {"ERROR", "FATAL", "PANIC"},
+		"warn":  {"WARN", "WARNING"},
+		"debug": {"DEBUG", "TRACE"},
+		"info":  {"INFO"},
+	}
+	m := newKeywordMatcher(keywords, levelPriority, true)
+	require.NotNil(t, m)
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"ERROR: failed", "error"},
+		{"info: An error occurred", "error"},
+		{"a warning", "warn"},
+		{"Debug trace", "debug"},
+		{"nothing here", ""},
+		{"prefix-PaNiC-suffix", "error"},
+		{"ÉTAT: info", "info"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		priority, ok := m.match(tt.line)
+		if tt.expected == "" {
+			assert.False(t, ok, "line %q", tt.line)
+			continue
+		}
+		require.True(t, ok, "line %q", tt.line)
+		assert.Equal(t, tt.expected, levelPriority[priority], "line %q", tt.line)
+	}
+
+	assert.Nil(t, newKeywordMatcher(nil, levelPriority, true))
+}
+
+func TestKeywordMatcher_MatchesNaive(t *testing.T) {
+	t.Parallel()
+
+	// Overlapping keywords exercise the failure links: "ERR" inside
+	// "TERROR", "AB" as a suffix of "AAB", a keyword inside another.
+	keywords := map[string][]string{
+		"fatal": {"aab", "terror"},
+		"error": {"err", "ab"},
+		"warn":  {"bba", "ba", "warnING"},
+		"info":  {"a", "é"},
+		"trace": {"ÉTÉ", "ſ"},
+	}
+	alphabet := []string{"a", "b", "A", "B", "e", "r", "R", "t", "o", " ", "é", "É", "s", "S", "ſ"}
+	for _, foldCase := range []bool{true, false} {
+		m := newKeywordMatcher(keywords, levelPriority, foldCase)
+		require.NotNil(t, m)
+
+		rng := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // deterministic test input
+		for range 5000 {
+			var sb strings.Builder
+			for range rng.IntN(12) {
+				sb.WriteString(alphabet[rng.IntN(len(alphabet))])
+			}
+			line := sb.String()
+
+			wantPriority, wantOK := naiveMatch(keywords, line, foldCase)
+			gotPriority, gotOK := m.match(line)
+			require.Equal(t, wantOK, gotOK, "line %q, foldCase %v", line, foldCase)
+			if wantOK {
+				require.Equal(t, wantPriority, gotPriority, "line %q, foldCase %v", line, foldCase)
+			}
+
+			kwPriority, keyword, kwOK := m.matchKeyword(line)
+			require.Equal(t, wantOK, kwOK, "line %q, foldCase %v", line, foldCase)
+			if wantOK {
+				require.Equal(t, wantPriority, kwPriority, "line %q, foldCase %v", line, foldCase)
+				require.Contains(t, keywords[levelPriority[kwPriority]], keyword, "line %q, foldCase %v", line, foldCase)
+				if foldCase {
+					line, keyword = strings.ToUpper(line), strings.ToUpper(keyword)
+				}
+				require.Contains(t, line, keyword, "line %q, foldCase %v", line, foldCase)
+			}
+		}
+	}
+}
+
+func TestKeywordMatcher_CaseSensitive(t *testing.T) {
+	t.Parallel()
+
+	keywords := map[string][]string{
+		"error": {"ERROR"},
+		"warn":  {"Warning"},
+	}
+	m := newKeywordMatcher(keywords, levelPriority, false)
+	require.NotNil(t, m)
+
+	priority, ok := m.match("ERROR: disk full")
+	require.True(t, ok)
+	assert.Equal(t, "error", levelPriority[priority])
+
+	priority, ok = m.match("Warning: low disk")
+	require.True(t, ok)
+	assert.Equal(t, "warn", levelPriority[priority])
+
+	for _, line := range []string{"error: disk full", "Error: disk full", "WARNING: low disk", "warning"} {
+		_, ok := m.match(line)
+		assert.False(t, ok, "line %q", line)
+	}
+}
+
+func TestKeywordMatcher_MatchKeyword(t *testing.T) {
+	t.Parallel()
+
+	keywords := map[string][]string{
+		"error": {"ERROR", "Fatal"},
+		"warn":  {"WARN"},
+	}
+	m := newKeywordMatcher(keywords, levelPriority, true)
+	require.NotNil(t, m)
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"FATAL: out of memory", "Fatal"},
+		{"warn: retrying after error", "ERROR"},
+		{"fatal error", "Fatal"},
+		{"WARN: slow", "WARN"},
+		{"nothing here", ""},
+	}
+	for _, tt := range tests {
+		_, keyword, ok := m.matchKeyword(tt.line)
+		assert.Equal(t, tt.expected != "", ok, "line %q", tt.line)
+		assert.Equal(t, tt.expected, keyword, "line %q", tt.line)
+	}
+}
@@ -0,0 +1,84 @@
+package formatter
+
+import "strings"
+
+// fieldLevels maps the values accepted for detection.level_key, lowercased,
+// to the level they stand for.
+var fieldLevels = map[string]string{
+	"trace":    "TRACE",
+	"debug":    "DEBUG",
+	"info":     "INFO",
+	"warn":     "WARN",
+	"warning":  "WARN",
+	"error":    "ERROR",
+	"err":      "ERROR",
+	"fatal":    "FATAL",
+	"critical": "FATAL",
+	"panic":    "FATAL",
+}
+
+// fieldLevel returns the level given by the first key field of line, in
+// logfmt (key=warn, key="warn") or JSON ("key": "warn") form. It reports
+// false when line has no such field or its value is not a known level.
+// This is a plain scan: the rest of the line does not need to be valid
+// logfmt or JSON.
+func fieldLevel(line, key string) (string, bool) {
+	for from := 0; from < len(line); {
+		i := strings.Index(line[from:], key)
+		if i < 0 {
+			return "", false
+		}
+		start := from + i
+		end := start + len(key)
+		if value, ok := fieldValue(line, start, end); ok {
+			level, known := fieldLevels[strings.ToLower(value)]
+			return level, known
+		}
+		from = start + 1
+	}
+	return "", false
+}
+
+// fieldValue returns the value of the field whose key spans line[start:end],
+// or false when that occurrence of the key is not a field key (e.g. it is
+// part of a longer key or of a message).
+func fieldValue(line string, start, end int) (string, bool) {
+	var before byte = ' '
+	if start > 0 {
+		before = line[start-1]
+	}
+	rest := line[end:]
+
+	switch {
+	case (before == ' ' || before == '\t') && strings.HasPrefix(rest, "="):
+		rest = rest[1:]
+		if strings.HasPrefix(rest, `"`) {
+			return quotedValue(rest)
+		}
+		if n := strings.IndexAny(rest, " \t"); n >= 0 {
+			rest = rest[:n]
+		}
+		return rest, rest != ""
+	case before == '"' && strings.HasPrefix(rest, `"`):
+		rest = strings.TrimLeft(rest[1:], " \t")
+		if !strings.HasPrefix(rest, ":") {
+			return "", false
+		}
+		return quotedValue(strings.TrimLeft(rest[1:], " \t"))
+	default:
+		return "", false
+	}
+}
+
+// quotedValue returns the text between the double quote s starts with and
+// the next one. Escaped quotes are not supported; level values have none.
+func quotedValue(s string) (string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", false
+	}
+	n := strings.IndexByte(s[1:], '"')
+	if n < 0 {
+		return "", false
+	}
+	return s[1 : n+1], true
+}
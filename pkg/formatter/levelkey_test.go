@@ -0,0 +1,71 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+		found    bool
+	}{
+		{"logfmt", "t=2024-05-01T10:00:00Z level=warn msg=\"disk almost full\"", "WARN", true},
+		{"logfmt at line start", "level=error msg=failed", "ERROR", true},
+		{"logfmt quoted", `t=1 level="info" msg=ok`, "INFO", true},
+		{"json", `{"ts":1,"level":"debug","msg":"cache hit"}`, "DEBUG", true},
+		{"json with spaces", `{"level" : "Fatal"}`, "FATAL", true},
+		{"alias", "level=warning msg=slow", "WARN", true},
+		{"value is case-insensitive", "level=ERR", "ERROR", true},
+		{"longer key is not the key", "t=1 loglevel=error msg=x", "", false},
+		{"key in the message", "msg=\"the level is fine\" level=info", "INFO", true},
+		{"unknown value", "level=verbose msg=x", "", false},
+		{"empty value", "level= msg=x", "", false},
+		{"no field", "plain text line", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			level, found := fieldLevel(tt.line, "level")
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, level)
+		})
+	}
+}
+
+func TestGetLogLevel_LevelKey(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.LogLevel.Detection.LevelKey = "level"
+	cfg.LogLevel.Detection.Keywords["drop"] = []string{"/healthz"}
+	formatter, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		line     string
+		stream   processor.StreamType
+		expected string
+	}{
+		{"field level", "t=2024-05-01T10:00:00Z level=warn msg=ready", processor.StreamStdout, "WARN"},
+		{"field wins over keywords", "t=1 level=info msg=\"retrying after error\"", processor.StreamStderr, "INFO"},
+		{"keywords without a field", "ERROR: request failed", processor.StreamStdout, "ERROR"},
+		{"keywords for unknown values", "level=verbose msg=\"warn: slow\"", processor.StreamStdout, "WARN"},
+		{"drop keywords still drop", "level=info path=/healthz", processor.StreamStdout, "DROP"},
+		{"default without field or keyword", "listening on :8080", processor.StreamStderr, "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, formatter.getLogLevel(tt.line, tt.stream))
+		})
+	}
+}
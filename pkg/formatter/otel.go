@@ -0,0 +1,118 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otelSeverities maps log levels to OpenTelemetry severity numbers, the
+// lowest number of each level's range (e.g. INFO is 9, INFO2-4 are 10-12).
+var otelSeverities = map[string]int{
+	"TRACE": 1,
+	"DEBUG": 5,
+	"INFO":  9,
+	"WARN":  13,
+	"ERROR": 17,
+	"FATAL": 21,
+}
+
+// otelRecord is a log record in the OTLP/JSON encoding. 64-bit integers
+// are strings, as in the protobuf JSON mapping.
+type otelRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber"`
+	SeverityText   string          `json:"severityText"`
+	Body           otelValue       `json:"body"`
+	Attributes     []otelAttribute `json:"attributes,omitempty"`
+}
+
+// otelAttribute is an OTLP key/value pair.
+type otelAttribute struct {
+	Key   string    `json:"key"`
+	Value otelValue `json:"value"`
+}
+
+// otelValue is an OTLP AnyValue; exactly one field is set.
+type otelValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+// otelAnyValue wraps v, as returned by fieldExtractor.jsonValue, in an
+// OTLP AnyValue.
+func otelAnyValue(v any) otelValue {
+	switch v := v.(type) {
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otelValue{IntValue: &s}
+	case float64:
+		return otelValue{DoubleValue: &v}
+	case bool:
+		return otelValue{BoolValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return otelValue{StringValue: &s}
+	}
+}
+
+// otelInt returns s as an int64, or s itself if it is not a number. Base 0
+// also parses the hex PID format.
+func otelInt(s string) any {
+	if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return n
+	}
+	return s
+}
+
+// formatOTel renders data as an OTLP/JSON log record. The line is the
+// body; user, PID, PPID and command use OpenTelemetry semantic convention
+// attribute names, and custom and extracted fields keep their own.
+func (f *DefaultFormatter) formatOTel(data TemplateData) (string, error) {
+	rec := otelRecord{
+		TimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber: otelSeverities[strings.ToUpper(data.Level)],
+		SeverityText:   data.Level,
+		Body:           otelAnyValue(data.Line),
+	}
+
+	attr := func(key string, value any) {
+		rec.Attributes = append(rec.Attributes, otelAttribute{Key: key, Value: otelAnyValue(value)})
+	}
+	if f.config.Prefix.User.Enabled {
+		attr("user.name", data.User)
+	}
+	if f.config.Prefix.PID.Enabled {
+		attr("process.pid", otelInt(data.PID))
+	}
+	if f.config.Prefix.PPID.Enabled {
+		attr("process.parent_pid", otelInt(data.PPID))
+	}
+	if f.config.Prefix.Command.Enabled {
+		attr("process.command", data.Command)
+	}
+	if f.config.Output.IncludeLineNumber {
+		attr("line_no", int64(data.LineNo))
+	}
+	if f.config.Output.IncludeRaw {
+		attr("raw", data.Raw)
+	}
+	for _, c := range f.customFields {
+		attr(c.name, data.Fields[c.name])
+	}
+	for _, e := range f.extractors {
+		if value := data.Fields[e.name]; value != "" {
+			attr(e.name, e.jsonValue(value))
+		}
+	}
+
+	jsonBytes, err := json.Marshal(rec)
+	if err != nil {
+		return "", fmt.Errorf("JSON encoding failed: %w", err)
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,99 @@
+package formatter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLine_OTel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("required fields", func(t *testing.T) {
+		t.Parallel()
+
+		f := newTestFormatter(t, "otel")
+		result := f.FormatLine("hello", processor.StreamStdout)
+
+		var rec map[string]any
+		require.NoError(t, json.Unmarshal([]byte(result), &rec))
+		assert.Regexp(t, `^[0-9]+$`, rec["timeUnixNano"], "64-bit integers are encoded as strings")
+		assert.InDelta(t, 9, rec["severityNumber"], 0)
+		assert.Equal(t, "INFO", rec["severityText"])
+		assert.Equal(t, map[string]any{"stringValue": "hello"}, rec["body"])
+		assert.NotContains(t, rec, "attributes")
+	})
+
+	t.Run("severity numbers", func(t *testing.T) {
+		t.Parallel()
+
+		f := newTestFormatter(t, "otel")
+		tests := []struct {
+			line     string
+			level    string
+			severity int
+		}{
+			{"TRACE step", "DEBUG", 5},
+			{"DEBUG step", "DEBUG", 5},
+			{"INFO ready", "INFO", 9},
+			{"WARN slow", "WARN", 13},
+			{"ERROR failed", "ERROR", 17},
+		}
+		for _, tt := range tests {
+			var rec map[string]any
+			require.NoError(t, json.Unmarshal([]byte(f.FormatLine(tt.line, processor.StreamStdout)), &rec))
+			assert.Equal(t, tt.level, rec["severityText"], tt.line)
+			assert.InDelta(t, tt.severity, rec["severityNumber"], 0, tt.line)
+		}
+	})
+
+	t.Run("severity table", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, map[string]int{
+			"TRACE": 1, "DEBUG": 5, "INFO": 9, "WARN": 13, "ERROR": 17, "FATAL": 21,
+		}, otelSeverities)
+	})
+
+	t.Run("attributes", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := newTestConfig("otel")
+		cfg.Prefix.User.Enabled = true
+		cfg.Prefix.PID.Enabled = true
+		cfg.Prefix.PID.Format = "decimal"
+		cfg.Output.CustomFields = map[string]string{"env": "prod"}
+		cfg.LogLevel.Detection.ExtractFields = map[string]string{
+			"count": `count=(\S+)`,
+			"ok":    `ok=(\S+)`,
+			"req":   `req=(\S+)`,
+		}
+		cfg.LogLevel.Detection.ExtractFieldTypes = map[string]string{"count": "int", "ok": "bool"}
+		f, err := New(cfg)
+		require.NoError(t, err)
+
+		result := f.FormatLine("count=5 ok=true req=42", processor.StreamStdout)
+
+		var rec struct {
+			Attributes []struct {
+				Key   string         `json:"key"`
+				Value map[string]any `json:"value"`
+			} `json:"attributes"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(result), &rec))
+		attrs := make(map[string]map[string]any, len(rec.Attributes))
+		for _, a := range rec.Attributes {
+			attrs[a.Key] = a.Value
+		}
+
+		assert.Contains(t, attrs["user.name"], "stringValue")
+		assert.Regexp(t, `^[0-9]+$`, attrs["process.pid"]["intValue"])
+		assert.Equal(t, map[string]any{"stringValue": "prod"}, attrs["env"])
+		assert.Equal(t, map[string]any{"intValue": "5"}, attrs["count"])
+		assert.Equal(t, map[string]any{"boolValue": true}, attrs["ok"])
+		assert.Equal(t, map[string]any{"stringValue": "42"}, attrs["req"])
+	})
+}
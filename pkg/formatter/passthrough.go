@@ -0,0 +1,113 @@
+package formatter
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// passthroughCollisionPrefix is prepended to input keys that collide with
+// keys logwrap writes itself (timestamp, level, ...).
+const passthroughCollisionPrefix = "input."
+
+// parseJSONObject parses line as a single JSON object. Numbers are kept as
+// [json.Number] so that large integers survive re-encoding unchanged.
+func parseJSONObject(line string) (map[string]any, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return nil, false
+	}
+	// Reject trailing data such as `{"a":1} extra`.
+	if dec.InputOffset() != int64(len(trimmed)) {
+		return nil, false
+	}
+	return obj, true
+}
+
+// mergePassthrough adds the keys of a passed-through JSON object to out.
+// Keys already present in out (logwrap's own fields) are kept, and the
+// colliding input value is stored under "input.<key>" instead.
+func mergePassthrough(out, input map[string]any) {
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		target := key
+		if _, exists := out[target]; exists {
+			target = uniqueKey(out, passthroughCollisionPrefix+key)
+		}
+		out[target] = input[key]
+	}
+}
+
+// flattenJSON flattens nested objects and arrays into a single-level map
+// with dot-separated keys: {"a":{"b":1},"c":[2]} becomes {"a.b":1,"c.0":2}.
+// Empty objects and arrays are kept as values so no key disappears.
+// When a flattened key collides with an existing one (e.g. a literal "a.b"
+// key next to {"a":{"b":...}}), the later key in sorted order gets a
+// numeric suffix ("a.b_1").
+func flattenJSON(obj map[string]any) map[string]any {
+	out := make(map[string]any, len(obj))
+	flattenInto(out, "", obj)
+	return out
+}
+
+func flattenInto(out map[string]any, prefix string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 && prefix != "" {
+			out[uniqueKey(out, prefix)] = v
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			flattenInto(out, joinKey(prefix, key), v[key])
+		}
+	case []any:
+		if len(v) == 0 {
+			out[uniqueKey(out, prefix)] = v
+			return
+		}
+		for i, item := range v {
+			flattenInto(out, joinKey(prefix, strconv.Itoa(i)), item)
+		}
+	default:
+		out[uniqueKey(out, prefix)] = v
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// uniqueKey returns key, or key with the smallest "_N" suffix not yet in m.
+func uniqueKey(m map[string]any, key string) string {
+	if _, exists := m[key]; !exists {
+		return key
+	}
+	for i := 1; ; i++ {
+		candidate := key + "_" + strconv.Itoa(i)
+		if _, exists := m[candidate]; !exists {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package formatter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/apperrors"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONObject(t *testing.T) {
+	t.Parallel()
+
+	obj, ok := parseJSONObject(`  {"a": 1, "b": {"c": "d"}}  `)
+	require.True(t, ok)
+	assert.Equal(t, json.Number("1"), obj["a"])
+
+	for _, line := range []string{
+		"plain text",
+		`["array"]`,
+		`{"a":1} trailing`,
+		`{"a":1}{"b":2}`,
+		`{broken`,
+		"",
+	} {
+		_, ok := parseJSONObject(line)
+		assert.False(t, ok, "line %q", line)
+	}
+}
+
+func TestFlattenJSON(t *testing.T) {
+	t.Parallel()
+
+	obj, ok := parseJSONObject(`{
+		"http": {"method": "GET", "status": 200, "headers": {"host": "x"}},
+		"tags": ["a", {"k": "v"}],
+		"empty": {},
+		"none": [],
+		"nil": null,
+		"a.b": "literal",
+		"a": {"b": "nested"}
+	}`)
+	require.True(t, ok)
+
+	assert.Equal(t, map[string]any{
+		"http.method":       "GET",
+		"http.status":       json.Number("200"),
+		"http.headers.host": "x",
+		"tags.0":            "a",
+		"tags.1.k":          "v",
+		"empty":             map[string]any{},
+		"none":              []any{},
+		"nil":               nil,
+		// "a" sorts before "a.b", so the nested value claims the key and
+		// the literal key gets a suffix.
+		"a.b":   "nested",
+		"a.b_1": "literal",
+	}, flattenJSON(obj))
+}
+
+func TestFormatLine_JSONPassthrough(t *testing.T) {
+	t.Parallel()
+
+	const line = `{"msg":"request done","level":"debug","http":{"status":200,"path":"/x"},"ids":[1,2]}`
+
+	tests := []struct {
+		name     string
+		flatten  bool
+		expected map[string]any
+	}{
+		{
+			name: "nested",
+			expected: map[string]any{
+				"msg":         "request done",
+				"input.level": "debug",
+				"http":        map[string]any{"status": float64(200), "path": "/x"},
+				"ids":         []any{float64(1), float64(2)},
+			},
+		},
+		{
+			name:    "flattened",
+			flatten: true,
+			expected: map[string]any{
+				"msg":         "request done",
+				"input.level": "debug",
+				"http.status": float64(200),
+				"http.path":   "/x",
+				"ids.0":       float64(1),
+				"ids.1":       float64(2),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := newTestConfig("json")
+			cfg.Output.JSONPassthrough = true
+			cfg.Output.Flatten = tt.flatten
+			f, err := New(cfg)
+			require.NoError(t, err)
+
+			var parsed map[string]any
+			require.NoError(t, json.Unmarshal([]byte(f.FormatLine(line, processor.StreamStdout)), &parsed))
+
+			assert.NotContains(t, parsed, "message", "the object itself replaces message")
+			assert.Equal(t, "DEBUG", parsed["level"], "logwrap's detected level wins over the input key")
+			assert.Contains(t, parsed, "timestamp")
+			for key, value := range tt.expected {
+				assert.Equal(t, value, parsed[key], "key %q", key)
+			}
+		})
+	}
+}
+
+func TestFormatLine_JSONPassthrough_NonObjectLine(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("json")
+	cfg.Output.JSONPassthrough = true
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, json.Unmarshal([]byte(f.FormatLine("not json", processor.StreamStdout)), &parsed))
+	assert.Equal(t, "not json", parsed["message"])
+}
+
+func TestFormatRecord_JSONParseFailure(t *testing.T) {
+	t.Parallel()
+
+	const broken = `{"level":"info","msg":"truncated`
+	tests := []struct {
+		policy  string
+		check   func(t *testing.T, out string)
+		dropped bool
+	}{
+		{policy: "wrap", check: func(t *testing.T, out string) {
+			t.Helper()
+			var parsed map[string]any
+			require.NoError(t, json.Unmarshal([]byte(out), &parsed))
+			assert.Equal(t, broken, parsed["message"])
+		}},
+		{policy: "passthrough", check: func(t *testing.T, out string) {
+			t.Helper()
+			assert.Equal(t, broken, out)
+		}},
+		{policy: "drop", dropped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := newTestConfig("json")
+			cfg.Output.JSONPassthrough = true
+			cfg.Output.OnJSONParseFailure = tt.policy
+			f, err := New(cfg)
+			require.NoError(t, err)
+
+			out, err := f.FormatRecord(processor.Record{Line: broken, Stream: processor.StreamStdout})
+			if tt.dropped {
+				require.ErrorIs(t, err, apperrors.ErrLineDropped)
+				assert.Empty(t, out)
+			} else {
+				require.NoError(t, err)
+				tt.check(t, out)
+			}
+
+			valid, err := f.FormatRecord(processor.Record{Line: `{"msg":"ok"}`, Stream: processor.StreamStdout})
+			require.NoError(t, err, "JSON objects are merged whatever the policy")
+			assert.Contains(t, valid, `"msg":"ok"`)
+		})
+	}
+}
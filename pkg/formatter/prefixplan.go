@@ -0,0 +1,88 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// prefixPlan is a prefix template precompiled for prefix.cache: the literal
+// text of the template and the TemplateData fields between it, rendered by
+// concatenation instead of executing the template for every line.
+type prefixPlan []prefixSegment
+
+// prefixSegment is literal text, or a TemplateData field when field is set.
+type prefixSegment struct {
+	text  string
+	field func(*TemplateData) string
+}
+
+// planFields are the TemplateData fields a prefixPlan can render, formatted
+// as text/template prints them.
+var planFields = map[string]func(*TemplateData) string{
+	"Timestamp": func(d *TemplateData) string { return d.Timestamp },
+	"Level":     func(d *TemplateData) string { return d.Level },
+	"Severity":  func(d *TemplateData) string { return strconv.Itoa(d.Severity) },
+	"User":      func(d *TemplateData) string { return d.User },
+	"PID":       func(d *TemplateData) string { return d.PID },
+	"PPID":      func(d *TemplateData) string { return d.PPID },
+	"Command":   func(d *TemplateData) string { return d.Command },
+	"Host":      func(d *TemplateData) string { return d.Host },
+	"Line":      func(d *TemplateData) string { return d.Line },
+	"Raw":       func(d *TemplateData) string { return d.Raw },
+	"LineNo":    func(d *TemplateData) string { return strconv.Itoa(d.LineNo) },
+	"ExitCode":  func(d *TemplateData) string { return d.ExitCode },
+	"Duration":  func(d *TemplateData) string { return d.Duration },
+}
+
+// compilePrefixPlan returns tmpl as a prefixPlan, or nil when it uses
+// anything besides literal text and plain fields such as {{.Timestamp}}:
+// functions, pipelines, conditionals, variables, nested templates or
+// {{.Fields}}. Such templates are executed for every line.
+func compilePrefixPlan(tmpl *template.Template) prefixPlan {
+	if tmpl.Tree == nil || tmpl.Root == nil || len(tmpl.Templates()) > 1 {
+		return nil
+	}
+
+	plan := make(prefixPlan, 0, len(tmpl.Root.Nodes))
+	for _, node := range tmpl.Root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			plan = append(plan, prefixSegment{text: string(n.Text)})
+		case *parse.ActionNode:
+			field := planField(n.Pipe)
+			if field == nil {
+				return nil
+			}
+			plan = append(plan, prefixSegment{field: field})
+		default:
+			return nil
+		}
+	}
+	return plan
+}
+
+// planField returns the planFields accessor for a pipeline that is a
+// single top-level field reference, or nil.
+func planField(pipe *parse.PipeNode) func(*TemplateData) string {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return nil
+	}
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return nil
+	}
+	return planFields[field.Ident[0]]
+}
+
+// render writes the prefix for data to b.
+func (p prefixPlan) render(b *strings.Builder, data *TemplateData) {
+	for _, seg := range p {
+		if seg.field != nil {
+			b.WriteString(seg.field(data))
+		} else {
+			b.WriteString(seg.text)
+		}
+	}
+}
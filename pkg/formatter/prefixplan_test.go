@@ -0,0 +1,135 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePrefixPlan_Fallback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		template string
+		compiled bool
+	}{
+		{"default template", "[{{.Timestamp}}] [{{.Level}}] [{{.User}}:{{.PID}}] ", true},
+		{"line and numbers", "{{.LineNo}}/{{.Severity}} {{.Line}}", true},
+		{"trim markers", "[{{- .Level -}}] ", true},
+		{"literal text only", "> ", true},
+		{"function", `{{printf "%-5s" .Level}} `, false},
+		{"pipeline", `{{.Level | printf "%s"}} `, false},
+		{"conditional", "{{if .User}}{{.User}} {{end}}", false},
+		{"variable", "{{$l := .Level}}{{$l}} ", false},
+		{"custom field", "[{{.Fields.env}}] ", false},
+		{"nested template", `{{define "p"}}[{{.Level}}]{{end}}{{template "p" .}} `, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpl := template.Must(template.New("prefix").Parse(tt.template))
+			assert.Equal(t, tt.compiled, compilePrefixPlan(tmpl) != nil)
+		})
+	}
+}
+
+func TestPrefixPlan_MatchesTemplate(t *testing.T) {
+	t.Parallel()
+
+	data := TemplateData{
+		Timestamp: "2026-10-16 12:00:00",
+		Level:     "WARN",
+		Severity:  4,
+		User:      "alice",
+		PID:       "1234",
+		PPID:      "1",
+		Command:   "make",
+		Host:      "build-01",
+		Line:      "disk {{almost}} full",
+		Raw:       "disk {{almost}} full\r",
+		LineNo:    42,
+		ExitCode:  "3",
+		Duration:  "1.5s",
+	}
+	templates := []string{
+		"[{{.Timestamp}}] [{{.Level}}] [{{.User}}:{{.PID}}] ",
+		"{{.Host}} {{.Command}}[{{.PID}}/{{.PPID}}] #{{.LineNo}} <{{.Severity}}> ",
+		"{{.Level}}: {{.Line}} (raw {{.Raw}})",
+		"[code={{.ExitCode}} took={{.Duration}}] ",
+		"{{.Level}}{{.Level}}",
+	}
+
+	for _, text := range templates {
+		tmpl := template.Must(template.New("prefix").Parse(text))
+		plan := compilePrefixPlan(tmpl)
+		require.NotNil(t, plan, text)
+
+		var want, got strings.Builder
+		require.NoError(t, tmpl.Execute(&want, data))
+		plan.render(&got, &data)
+		assert.Equal(t, want.String(), got.String(), text)
+	}
+}
+
+func TestFormatText_PrefixCacheSameOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		adjust func(cfg *config.Config)
+	}{
+		{"plain", func(*config.Config) {}},
+		{"colors", func(cfg *config.Config) { cfg.Prefix.Colors.Enabled = true }},
+		{"tidy and align", func(cfg *config.Config) {
+			cfg.Prefix.TidyEmptySegments = true
+			cfg.Output.AlignMessages = true
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			formatters := make([]*DefaultFormatter, 2)
+			for i, cache := range []bool{false, true} {
+				cfg := newTestConfig("text")
+				cfg.Prefix.Template = "[{{.Timestamp}}] [{{.Level}}] [{{.User}}:{{.PID}}] "
+				cfg.Prefix.Cache = cache
+				tt.adjust(cfg)
+				f, err := New(cfg)
+				require.NoError(t, err)
+				formatters[i] = f
+			}
+			require.Nil(t, formatters[0].prefixPlan)
+			require.NotNil(t, formatters[1].prefixPlan)
+
+			for _, line := range []string{"ERROR: failed", "plain message", ""} {
+				data := formatters[0].buildTemplateData(line, processor.StreamStdout, "")
+				want, err := formatters[0].formatText(data)
+				require.NoError(t, err)
+				got, err := formatters[1].formatText(data)
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "line %q", line)
+			}
+		})
+	}
+}
+
+func TestNew_PrefixCacheFallsBack(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = `[{{printf "%-5s" .Level}}] `
+	cfg.Prefix.Cache = true
+	f, err := New(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, f.prefixPlan, "templates with functions are executed")
+	assert.Equal(t, "[INFO ] hello", f.FormatLine("hello", processor.StreamStdout))
+}
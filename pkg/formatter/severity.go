@@ -0,0 +1,30 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/sgaunet/logwrap/pkg/config"
+)
+
+// defaultSeverities maps log levels to RFC 5424 syslog severities.
+var defaultSeverities = map[string]int{
+	"FATAL": 2, // critical
+	"ERROR": 3, // error
+	"WARN":  4, // warning
+	"INFO":  6, // informational
+	"DEBUG": 7, // debug
+	"TRACE": 7, // debug
+}
+
+// buildSeverities returns the default severities with output.severity_map
+// applied, keyed by upper-case level.
+func buildSeverities(cfg *config.Config) map[string]int {
+	severities := make(map[string]int, len(defaultSeverities))
+	for level, severity := range defaultSeverities {
+		severities[level] = severity
+	}
+	for level, severity := range cfg.Output.SeverityMap {
+		severities[strings.ToUpper(level)] = severity
+	}
+	return severities
+}
@@ -0,0 +1,33 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLine_Severity(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "<{{.Severity}}> "
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<4> WARN: slow", f.FormatLine("WARN: slow", processor.StreamStdout))
+	assert.Equal(t, "<3> ERROR: boom", f.FormatLine("ERROR: boom", processor.StreamStdout))
+	assert.Equal(t, "<6> hello", f.FormatLine("hello", processor.StreamStdout))
+
+	cfg = newTestConfig("text")
+	cfg.Prefix.Template = "<{{.Severity}}> "
+	cfg.Output.SeverityMap = map[string]int{"warn": 5}
+	f, err = New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<5> WARN: slow", f.FormatLine("WARN: slow", processor.StreamStdout),
+		"the custom mapping reports WARN as notice")
+	assert.Equal(t, "<3> ERROR: boom", f.FormatLine("ERROR: boom", processor.StreamStdout),
+		"unmapped levels keep their default severity")
+}
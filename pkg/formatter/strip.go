@@ -0,0 +1,51 @@
+package formatter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// compileStripPattern compiles output.strip_input_prefix_pattern, returning
+// nil when it is empty.
+func compileStripPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil //nolint:nilnil // no pattern configured
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid strip_input_prefix_pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// stripInputPrefix removes the first match of the strip pattern from line
+// when it starts at the beginning of the line, so that prefixes the command
+// already prints (timestamps, level tags) do not duplicate logwrap's own.
+// Matches elsewhere in the line are left alone, even for unanchored patterns.
+func (f *DefaultFormatter) stripInputPrefix(line string) string {
+	loc := f.stripPattern.FindStringIndex(line)
+	if loc == nil || loc[0] != 0 {
+		return line
+	}
+	return line[loc[1]:]
+}
+
+// compileSkipPattern compiles output.skip_prefix_if_matches, returning nil
+// when it is empty.
+func compileSkipPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil //nolint:nilnil // no pattern configured
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid skip_prefix_if_matches %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// alreadyFormatted reports whether line matches skip_prefix_if_matches and
+// is therefore passed through as is: its input prefix is not stripped and
+// no prefix is added. Drop keywords and level filters still apply.
+func (f *DefaultFormatter) alreadyFormatted(line string) bool {
+	return f.skipPattern != nil && f.skipPattern.MatchString(line)
+}
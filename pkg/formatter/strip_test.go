@@ -0,0 +1,137 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/apperrors"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLine_StripInputPrefix(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] "
+	cfg.Output.StripInputPrefixPattern = `^\d{2}:\d{2}:\d{2} `
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"10:30:45 compiling main.go", "[INFO] compiling main.go"},
+		{"compiling main.go", "[INFO] compiling main.go"},
+		{"done at 10:30:45 today", "[INFO] done at 10:30:45 today"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, f.FormatLine(tt.line, processor.StreamStdout), "line %q", tt.line)
+	}
+}
+
+func TestFormatLine_StripInputPrefix_Unanchored(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] "
+	cfg.Output.StripInputPrefixPattern = `\d{2}:\d{2}:\d{2} `
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[INFO] done at 10:30:45 today", f.FormatLine("done at 10:30:45 today", processor.StreamStdout),
+		"only matches at the start of the line are stripped")
+}
+
+func TestFormatLine_StripInputPrefix_Stage(t *testing.T) {
+	t.Parallel()
+
+	line := "10:30:45 WARN disk almost full"
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] "
+	cfg.Output.StripInputPrefixPattern = `^\d{2}:\d{2}:\d{2} (WARN|ERROR) `
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "[WARN] disk almost full", f.FormatLine(line, processor.StreamStdout),
+		"after detection, the stripped level tag still sets the level")
+
+	cfg = newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] "
+	cfg.Output.StripInputPrefixPattern = `^\d{2}:\d{2}:\d{2} (WARN|ERROR) `
+	cfg.Output.StripInputPrefixStage = "before_detection"
+
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] disk almost full", f.FormatLine(line, processor.StreamStdout))
+}
+
+func TestFormatRecord_StripInputPrefixKeepsRaw(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("json")
+	cfg.Output.IncludeRaw = true
+	cfg.Output.StripInputPrefixPattern = `^\d{2}:\d{2}:\d{2} `
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	result, err := f.FormatRecord(processor.Record{Line: "10:30:45 hello", Stream: processor.StreamStdout})
+	require.NoError(t, err)
+	assert.Contains(t, result, `"message":"hello"`)
+	assert.Contains(t, result, `"raw":"10:30:45 hello"`)
+}
+
+func TestFormatLine_SkipPrefixIfMatches(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] "
+	cfg.Output.SkipPrefixIfMatches = `^(\{|\[\d{4}-\d{2}-\d{2} )`
+	cfg.Output.StripInputPrefixPattern = `^\[\d{4}-\d{2}-\d{2} [^]]*\] `
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{`{"level":"info","msg":"ready"}`, `{"level":"info","msg":"ready"}`},
+		{"[2024-01-15 10:30:45] [INFO] nested", "[2024-01-15 10:30:45] [INFO] nested"},
+		{"plain line", "[INFO] plain line"},
+		{"  {indented}", "[INFO]   {indented}"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, f.FormatLine(tt.line, processor.StreamStdout), "line %q", tt.line)
+
+		result, err := f.FormatRecord(processor.Record{Line: tt.line, Stream: processor.StreamStdout})
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, result, "record %q", tt.line)
+	}
+}
+
+func TestFormatRecord_SkipPrefixIfMatchesStillFilters(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("json")
+	cfg.Output.SkipPrefixIfMatches = `^\{`
+	cfg.Filter.Enabled = true
+	cfg.Filter.OnlyLevels = []string{"ERROR"}
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+
+	result, err := f.FormatRecord(processor.Record{Line: `{"msg":"ERROR boom"}`, Stream: processor.StreamStdout})
+	require.NoError(t, err)
+	assert.Equal(t, `{"msg":"ERROR boom"}`, result)
+
+	_, err = f.FormatRecord(processor.Record{Line: `{"msg":"fine"}`, Stream: processor.StreamStdout})
+	assert.ErrorIs(t, err, apperrors.ErrLineDropped)
+}
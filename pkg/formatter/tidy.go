@@ -0,0 +1,33 @@
+package formatter
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// segmentLeadingSeparators and segmentTrailingSeparators match separators
+	// left at the edges of a bracketed segment by an empty value, as in
+	// "[:1234]" when the user is disabled.
+	segmentLeadingSeparators  = regexp.MustCompile(`([\[(])[\s:|/,;-]+`)
+	segmentTrailingSeparators = regexp.MustCompile(`[\s:|/,;-]+([\])])`)
+
+	// emptySegment matches an empty bracketed segment with the space on
+	// either side of it.
+	emptySegment = regexp.MustCompile(` ?(?:\[\]|\(\)) ?`)
+)
+
+// tidySegments removes the artifacts that disabled or empty fields leave in
+// a rendered prefix: separators at the edges of a bracketed segment are
+// trimmed ("[:1234]" becomes "[1234]"), and segments left empty ("[:]",
+// "[ ]", "()") are removed together with one surrounding space.
+func tidySegments(prefix string) string {
+	prefix = segmentLeadingSeparators.ReplaceAllString(prefix, "$1")
+	prefix = segmentTrailingSeparators.ReplaceAllString(prefix, "$1")
+	return emptySegment.ReplaceAllStringFunc(prefix, func(match string) string {
+		if strings.HasPrefix(match, " ") && strings.HasSuffix(match, " ") {
+			return " "
+		}
+		return ""
+	})
+}
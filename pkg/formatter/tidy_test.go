@@ -0,0 +1,75 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTidySegments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{"[10:30:45] [INFO] [:] ", "[10:30:45] [INFO] "},
+		{"[10:30:45] [INFO] [alice:] ", "[10:30:45] [INFO] [alice] "},
+		{"[10:30:45] [INFO] [:1234] ", "[10:30:45] [INFO] [1234] "},
+		{"[:] [INFO] ", "[INFO] "},
+		{"[INFO] [ | ] - ", "[INFO] - "},
+		{"[INFO] () ", "[INFO] "},
+		{"[INFO] [alice:1234] ", "[INFO] [alice:1234] "},
+		{"INFO: ", "INFO: "},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tidySegments(tt.prefix), "prefix %q", tt.prefix)
+	}
+}
+
+func TestFormatLine_TidyEmptySegments(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = "[{{.Level}}] [{{.User}}:{{.PID}}] "
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] [:] hello", f.FormatLine("hello", processor.StreamStdout),
+		"tidying is opt-in")
+
+	cfg.Prefix.TidyEmptySegments = true
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] hello", f.FormatLine("hello", processor.StreamStdout))
+
+	cfg.Prefix.PID.Enabled = true
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\[INFO\] \[\d+\] hello$`, f.FormatLine("hello", processor.StreamStdout))
+}
+
+func TestFormatLine_JoinTemplate(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Template = `[{{join "] [" .Level (join ":" .User .PID)}}] `
+
+	f, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "[INFO] hello", f.FormatLine("hello", processor.StreamStdout),
+		"empty user and PID are dropped with their separators")
+
+	cfg.Prefix.PID.Enabled = true
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\[INFO\] \[\d+\] hello$`, f.FormatLine("hello", processor.StreamStdout))
+
+	cfg.Prefix.User.Enabled = true
+	f, err = New(cfg)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\[INFO\] \[[^:\]]+:\d+\] hello$`, f.FormatLine("hello", processor.StreamStdout))
+}
@@ -0,0 +1,128 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/itchyny/timefmt-go"
+	"github.com/sgaunet/logwrap/pkg/config"
+)
+
+// timestampFormat formats timestamps with prefix.timestamp.format. Should
+// the format yield an empty timestamp, e.g. because it is empty after being
+// replaced at runtime, the fallback format is used instead and a warning is
+// written once, so that lines never carry an empty timestamp silently.
+type timestampFormat struct {
+	format   string
+	fallback string // strftime format; empty means RFC 3339
+	utc      bool
+
+	warn     io.Writer
+	warnOnce sync.Once
+}
+
+func newTimestampFormat(cfg config.TimestampConfig) *timestampFormat {
+	return &timestampFormat{format: cfg.Format, fallback: cfg.Fallback, utc: cfg.UTC, warn: os.Stderr}
+}
+
+// render returns the timestamp for now.
+func (t *timestampFormat) render(now time.Time) string {
+	if t.utc {
+		now = now.UTC()
+	}
+	if value := timefmt.Format(now, t.format); value != "" {
+		return value
+	}
+
+	var value string
+	if t.fallback != "" {
+		value = timefmt.Format(now, t.fallback)
+	}
+	used := t.fallback
+	if value == "" {
+		value, used = now.Format(time.RFC3339), "RFC 3339"
+	}
+	t.warnOnce.Do(func() {
+		_, _ = fmt.Fprintf(t.warn, "Warning: timestamp format %q produced an empty timestamp, using %s instead\n",
+			t.format, used)
+	})
+	return value
+}
+
+// cachedTimestamp is a formatted timestamp and the time until which it may
+// be reused.
+type cachedTimestamp struct {
+	value   string
+	expires time.Time
+}
+
+// timestampCache reuses a formatted timestamp for a fixed interval so that
+// high-volume streams do not pay the strftime formatting cost per line.
+// Reads are lock-free; concurrent refreshes may format the same value twice,
+// which is harmless.
+type timestampCache struct {
+	format   *timestampFormat
+	interval time.Duration
+	current  atomic.Pointer[cachedTimestamp]
+}
+
+// newTimestampCache returns a cache for format, or nil when interval is 0 or
+// the format or its fallback has sub-second resolution (caching would make
+// it wrong).
+func newTimestampCache(format *timestampFormat, interval time.Duration) *timestampCache {
+	if interval <= 0 || hasSubSecondDirective(format.format) || hasSubSecondDirective(format.fallback) {
+		return nil
+	}
+	return &timestampCache{format: format, interval: interval}
+}
+
+// get returns the timestamp for now, reusing the cached value while it is
+// fresh. A value expires after the cache interval or at the end of its
+// second, whichever comes first, so that it never shows a second that has
+// already passed.
+func (c *timestampCache) get(now time.Time) string {
+	if cached := c.current.Load(); cached != nil && now.Before(cached.expires) {
+		return cached.value
+	}
+
+	value := c.format.render(now)
+	expires := now.Add(c.interval)
+	if next := now.Truncate(time.Second).Add(time.Second); next.Before(expires) {
+		expires = next
+	}
+	c.current.Store(&cachedTimestamp{value: value, expires: expires})
+	return value
+}
+
+// hasSubSecondDirective reports whether a strftime format contains a
+// directive finer than one second (%f, microseconds). Flags and widths
+// such as %-f, %^f or %3f are accounted for, and %% escapes are skipped.
+func hasSubSecondDirective(format string) bool {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(format) && isDirectiveModifier(format[i]) {
+			i++
+		}
+		if i < len(format) && format[i] == 'f' {
+			return true
+		}
+	}
+	return false
+}
+
+// isDirectiveModifier reports whether c may appear between a % and its
+// directive: a flag (-, _, 0, ^, #) or a digit of a width.
+func isDirectiveModifier(c byte) bool {
+	switch c {
+	case '-', '_', '^', '#':
+		return true
+	}
+	return c >= '0' && c <= '9'
+}
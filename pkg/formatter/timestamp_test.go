@@ -0,0 +1,131 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasSubSecondDirective(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format   string
+		expected bool
+	}{
+		{"%Y-%m-%d %H:%M:%S", false},
+		{"%H:%M:%S.%f", true},
+		{"%H:%M:%S.%-f", true},
+		{"%H:%M:%S.%3f", true},
+		{"%H:%M:%S.%^f", true},
+		{"%H:%M:%S.%_6f", true},
+		{"%#F %T", false},
+		{"100%% done %S", false},
+		{"%%f", false},
+		{"%T", false},
+		{"trailing %", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, hasSubSecondDirective(tt.format), "format %q", tt.format)
+	}
+}
+
+func TestTimestampCache_Eligibility(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, newTimestampCache(&timestampFormat{format: "%H:%M:%S"}, 0), "interval 0 disables caching")
+	assert.Nil(t, newTimestampCache(&timestampFormat{format: "%H:%M:%S.%f"}, 100*time.Millisecond),
+		"sub-second formats are never cached")
+	assert.Nil(t, newTimestampCache(&timestampFormat{format: "%H:%M:%S", fallback: "%T.%3f"}, 100*time.Millisecond),
+		"nor are formats with a sub-second fallback")
+	assert.NotNil(t, newTimestampCache(&timestampFormat{format: "%H:%M:%S"}, 100*time.Millisecond))
+}
+
+func TestTimestampCache_WithinGranularity(t *testing.T) {
+	t.Parallel()
+
+	const format = "%Y-%m-%d %H:%M:%S"
+	interval := 100 * time.Millisecond
+	cache := newTimestampCache(&timestampFormat{format: format, utc: true}, interval)
+	require.NotNil(t, cache)
+
+	base := time.Date(2024, 1, 15, 10, 30, 44, 950_000_000, time.UTC)
+	assert.Equal(t, "2024-01-15 10:30:44", cache.get(base))
+
+	// Still within the interval and the second: the cached value is reused.
+	assert.Equal(t, "2024-01-15 10:30:44", cache.get(base.Add(40*time.Millisecond)))
+
+	// The second has rolled over within the interval: the value is refreshed.
+	assert.Equal(t, "2024-01-15 10:30:45", cache.get(base.Add(60*time.Millisecond)))
+	assert.Equal(t, "2024-01-15 10:30:45", cache.get(base.Add(interval)))
+}
+
+func TestGetTimestamp_Cached(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Timestamp.CacheInterval = time.Second
+	f, err := New(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, f.timestampCache)
+
+	before := time.Now().UTC()
+	got, err := time.Parse("15:04:05", f.getTimestamp())
+	require.NoError(t, err)
+
+	// The cached timestamp never lags the real clock by more than the interval.
+	clock := time.Date(0, 1, 1, before.Hour(), before.Minute(), before.Second(), 0, time.UTC)
+	assert.WithinDuration(t, clock, got, cfg.Prefix.Timestamp.CacheInterval+time.Second)
+}
+
+func TestTimestampFormat_Fallback(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 15, 10, 30, 44, 0, time.UTC)
+	tests := []struct {
+		name     string
+		format   string
+		fallback string
+		expected string
+		warning  string
+	}{
+		{"format works", "%Y-%m-%d", "%Y", "2024-01-15", ""},
+		{"empty format", "", "", "2024-01-15T10:30:44Z", `timestamp format "" produced an empty timestamp, using RFC 3339 instead`},
+		{"configured fallback", "", "%H:%M:%S", "10:30:44", `using %H:%M:%S instead`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var warn bytes.Buffer
+			ts := &timestampFormat{format: tt.format, fallback: tt.fallback, utc: true, warn: &warn}
+			assert.Equal(t, tt.expected, ts.render(now))
+			assert.Equal(t, tt.expected, ts.render(now))
+			if tt.warning == "" {
+				assert.Empty(t, warn.String())
+				return
+			}
+			assert.Contains(t, warn.String(), tt.warning)
+			assert.Equal(t, 1, strings.Count(warn.String(), "Warning:"), "the warning is written once")
+		})
+	}
+}
+
+func TestGetTimestamp_DegenerateFormat(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestConfig("text")
+	cfg.Prefix.Timestamp.Format = "" // bypasses validation, as a format replaced at runtime could
+	f, err := New(cfg)
+	require.NoError(t, err)
+	var warn bytes.Buffer
+	f.timestamp.warn = &warn
+
+	_, err = time.Parse(time.RFC3339, f.getTimestamp())
+	require.NoError(t, err, "the RFC 3339 fallback is used")
+	assert.Contains(t, warn.String(), "produced an empty timestamp")
+}
@@ -2,7 +2,10 @@ package processor_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 
@@ -99,3 +102,60 @@ func BenchmarkProcessStream_Concurrent(b *testing.B) {
 		_ = p.ProcessStreams(ctx, strings.NewReader(content), strings.NewReader(content))
 	}
 }
+
+// slowFormatter stands in for a formatting-heavy configuration (many
+// extracted fields, templated custom fields) by hashing each line.
+type slowFormatter struct{}
+
+func (f *slowFormatter) FormatLine(line string, _ processor.StreamType) string {
+	sum := sha256.Sum256([]byte(line))
+	for range 50 {
+		sum = sha256.Sum256(sum[:])
+	}
+	return hex.EncodeToString(sum[:4]) + " " + line
+}
+
+// BenchmarkProcessStream_Workers compares formatting on the reading
+// goroutine with formatting on a WithWorkers pool.
+func BenchmarkProcessStream_Workers(b *testing.B) {
+	content := strings.Repeat("INFO: benchmark log line for worker test\n", 10000)
+
+	for _, workers := range []int{0, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+
+			for b.Loop() {
+				p := processor.New(&slowFormatter{}, io.Discard, processor.WithWorkers(workers))
+				_ = p.ProcessStreams(context.Background(), strings.NewReader(content), strings.NewReader(""))
+			}
+		})
+	}
+}
+
+// BenchmarkProcessStream_ReadAhead compares reading each line after the
+// previous one is written with reading ahead through a WithReadAhead queue,
+// for a producer that writes in bursts.
+func BenchmarkProcessStream_ReadAhead(b *testing.B) {
+	burst := strings.Repeat("INFO: benchmark log line for read-ahead test\n", 1000)
+	const bursts = 10
+
+	for _, readAhead := range []int{0, 64, 1024} {
+		b.Run(fmt.Sprintf("read_ahead=%d", readAhead), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(burst) * bursts))
+
+			for b.Loop() {
+				stdout, producer := io.Pipe()
+				go func() {
+					for range bursts {
+						_, _ = io.WriteString(producer, burst)
+					}
+					_ = producer.Close()
+				}()
+				p := processor.New(&slowFormatter{}, io.Discard, processor.WithReadAhead(readAhead))
+				_ = p.ProcessStreams(context.Background(), stdout, strings.NewReader(""))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithBufferUntil holds every output line in memory until trigger returns
+// true for a line read from the command, e.g. the first ERROR line. The held
+// lines are then written ahead of that line and later lines are written
+// directly; if trigger never fires, the held lines are discarded. At most
+// maxLines lines are held: older ones are dropped and a note with the
+// number of dropped lines precedes the flushed output. Sinks are not
+// buffered.
+func WithBufferUntil(trigger func(Record) bool, maxLines int) Option {
+	return func(p *Processor) {
+		p.buffer = &lineBuffer{trigger: trigger, maxLines: maxLines}
+	}
+}
+
+// lineBuffer holds formatted lines for WithBufferUntil.
+type lineBuffer struct {
+	trigger  func(Record) bool
+	maxLines int
+
+	mu        sync.Mutex
+	lines     [][]byte
+	dropped   int
+	triggered bool
+}
+
+// observe fires the buffer when trigger accepts rec.
+func (b *lineBuffer) observe(rec Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.triggered && b.trigger(rec) {
+		b.triggered = true
+	}
+}
+
+// add holds data and reports true until the buffer has fired. The first
+// call after that returns the held lines followed by data, preceded by
+// notice when lines were dropped; later calls return data unchanged.
+func (b *lineBuffer) add(data []byte, notice func(dropped int) []byte) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.triggered {
+		b.lines = append(b.lines, data)
+		if excess := len(b.lines) - b.maxLines; excess > 0 {
+			// Reslicing is amortized: append reallocates to just the
+			// retained lines once capacity runs out.
+			clear(b.lines[:excess])
+			b.lines = b.lines[excess:]
+			b.dropped += excess
+		}
+		return nil, true
+	}
+	if len(b.lines) == 0 && b.dropped == 0 {
+		return data, false
+	}
+
+	var out []byte
+	if b.dropped > 0 {
+		out = append(out, notice(b.dropped)...)
+	}
+	for _, line := range b.lines {
+		out = append(out, line...)
+	}
+	b.lines, b.dropped = nil, 0
+	return append(out, data...), false
+}
+
+// bufferNotice formats the note written ahead of flushed lines when some
+// were dropped for exceeding the buffer size.
+func (p *Processor) bufferNotice(dropped int) []byte {
+	message := fmt.Sprintf("logwrap: %d earlier lines dropped from the buffer", dropped)
+	formatted, err := p.format(Record{Line: message, Stream: StreamStdout})
+	if err != nil && formatted == "" {
+		formatted = message
+	}
+	return []byte(formatted + p.lineEnding)
+}
@@ -0,0 +1,56 @@
+package processor_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failedLine fires a buffer on lines containing "FAILED".
+func failedLine(rec processor.Record) bool {
+	return strings.Contains(rec.Line, "FAILED")
+}
+
+func TestProcessor_BufferUntil_NeverTriggered(t *testing.T) {
+	t.Parallel()
+
+	output := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, output, processor.WithBufferUntil(failedLine, 100))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("one\ntwo\n"), strings.NewReader("three\n"))
+	require.NoError(t, err)
+	require.NoError(t, p.WriteMessage("done"))
+
+	assert.Empty(t, output.GetLines(), "held lines are discarded when nothing triggers")
+}
+
+func TestProcessor_BufferUntil_Triggered(t *testing.T) {
+	t.Parallel()
+
+	output := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, output, processor.WithBufferUntil(failedLine, 100))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("one\ntwo\nFAILED\nfour\n"), strings.NewReader(""))
+	require.NoError(t, err)
+
+	assert.Equal(t, "[stdout] one\n[stdout] two\n[stdout] FAILED\n[stdout] four\n",
+		strings.Join(output.GetLines(), ""))
+}
+
+func TestProcessor_BufferUntil_Bounded(t *testing.T) {
+	t.Parallel()
+
+	output := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, output, processor.WithBufferUntil(failedLine, 2))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("1\n2\n3\n4\n5\nFAILED\n"), strings.NewReader(""))
+	require.NoError(t, err)
+
+	assert.Equal(t, "[stdout] logwrap: 3 earlier lines dropped from the buffer\n"+
+		"[stdout] 4\n[stdout] 5\n[stdout] FAILED\n", strings.Join(output.GetLines(), ""))
+}
@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"sync/atomic"
+)
+
+// FormattedLine is a line sent to the channel given to [WithLineChannel].
+type FormattedLine struct {
+	Text   string // formatted line, without the line ending
+	Level  string // the line's level; empty without a level function
+	Stream StreamType
+}
+
+// ChannelPolicy selects what [WithLineChannel] does when the channel is full.
+type ChannelPolicy int
+
+const (
+	// ChannelBlock waits for the consumer, slowing processing (and, once
+	// the pipes fill up, the command) down to the consumer's pace.
+	ChannelBlock ChannelPolicy = iota
+	// ChannelDrop discards lines the channel has no room for. They are
+	// counted by [Processor.ChannelDropped].
+	ChannelDrop
+)
+
+// WithLineChannel sends every line written to the primary output to ch as
+// well, as it is formatted, including lines written with
+// [Processor.WriteMessage] and context lines. level, if not nil, gives the
+// level reported for a line. To receive lines only on the channel, pass
+// [io.Discard] as the output. policy decides what happens when ch is full;
+// with ChannelBlock the consumer must keep receiving until processing is
+// done. The channel is never closed by the processor, since messages may
+// still be written after ProcessStreams returns.
+func WithLineChannel(ch chan<- FormattedLine, level func(Record) string, policy ChannelPolicy) Option {
+	return func(p *Processor) {
+		p.lines = &lineChannel{ch: ch, level: level, policy: policy}
+	}
+}
+
+// lineChannel holds the settings of WithLineChannel.
+type lineChannel struct {
+	ch      chan<- FormattedLine
+	level   func(Record) string
+	policy  ChannelPolicy
+	dropped atomic.Int64
+}
+
+// ChannelDropped returns the number of lines WithLineChannel discarded
+// because the channel was full under ChannelDrop.
+func (p *Processor) ChannelDropped() int64 {
+	if p.lines == nil {
+		return 0
+	}
+	return p.lines.dropped.Load()
+}
+
+// publish sends the formatted text of rec to the WithLineChannel channel.
+func (p *Processor) publish(rec Record, text string) {
+	if p.lines == nil {
+		return
+	}
+	line := FormattedLine{Text: text, Level: rec.Level, Stream: rec.Stream}
+	if line.Level == "" && p.lines.level != nil {
+		line.Level = p.lines.level(rec)
+	}
+
+	if p.lines.policy == ChannelDrop {
+		select {
+		case p.lines.ch <- line:
+		default:
+			p.lines.dropped.Add(1)
+		}
+		return
+	}
+	p.lines.ch <- line
+}
@@ -0,0 +1,74 @@
+package processor_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func firstWord(rec processor.Record) string {
+	word, _, _ := strings.Cut(rec.Line, " ")
+	return word
+}
+
+func TestProcessor_LineChannel(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan processor.FormattedLine)
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithLineChannel(ch, firstWord, processor.ChannelBlock))
+
+	var received []processor.FormattedLine
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for line := range ch {
+			received = append(received, line)
+		}
+	}()
+
+	err := p.ProcessStreams(context.Background(),
+		strings.NewReader("INFO one\nWARN two\nINFO three\n"), strings.NewReader("ERROR four\n"))
+	require.NoError(t, err)
+	require.NoError(t, p.WriteMessage("DEBUG done"))
+	close(ch)
+	<-done
+
+	var stdout []processor.FormattedLine
+	for _, line := range received {
+		if line.Stream == processor.StreamStderr {
+			assert.Equal(t, processor.FormattedLine{Text: "[stderr] ERROR four", Level: "ERROR", Stream: processor.StreamStderr}, line)
+		} else {
+			stdout = append(stdout, line)
+		}
+	}
+	assert.Len(t, received, 5, "every line reaches the channel")
+	assert.Equal(t, []processor.FormattedLine{
+		{Text: "[stdout] INFO one", Level: "INFO", Stream: processor.StreamStdout},
+		{Text: "[stdout] WARN two", Level: "WARN", Stream: processor.StreamStdout},
+		{Text: "[stdout] INFO three", Level: "INFO", Stream: processor.StreamStdout},
+		{Text: "[stdout] DEBUG done", Level: "DEBUG", Stream: processor.StreamStdout},
+	}, stdout)
+	assert.Len(t, writer.GetLines(), 5, "lines are still written to the output")
+	assert.Zero(t, p.ChannelDropped())
+}
+
+func TestProcessor_LineChannel_Drop(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan processor.FormattedLine, 2)
+	p := processor.New(&mockFormatter{}, io.Discard, processor.WithLineChannel(ch, nil, processor.ChannelDrop))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("a\nb\nc\nd\n"), strings.NewReader(""))
+	require.NoError(t, err, "a full channel does not block processing")
+
+	assert.Equal(t, processor.FormattedLine{Text: "[stdout] a", Stream: processor.StreamStdout}, <-ch)
+	assert.Equal(t, processor.FormattedLine{Text: "[stdout] b", Stream: processor.StreamStdout}, <-ch)
+	assert.Equal(t, int64(2), p.ChannelDropped())
+}
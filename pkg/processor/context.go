@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"errors"
+	"sync"
+
+	pkgerrors "github.com/sgaunet/logwrap/pkg/apperrors"
+)
+
+// WithContextBefore keeps track of the last n lines read and, before
+// writing a line for which trigger returns true (e.g. an ERROR line), writes
+// those of them that were hidden by the filter or dropped by the formatter,
+// like grep -B. Context lines are formatted with [Record.Context] set. Lines
+// already written are not repeated and a hidden line is written as context
+// at most once, so at most n lines are held in memory. Only the primary
+// output receives context lines. An n of 0 disables context.
+func WithContextBefore(n int, trigger func(Record) bool) Option {
+	return func(p *Processor) {
+		if n > 0 {
+			p.context = &contextLines{trigger: trigger, lines: make([]*Record, n)}
+		}
+	}
+}
+
+// contextLines is a ring of the last lines read for WithContextBefore. A nil
+// entry stands for a line that was written.
+type contextLines struct {
+	trigger func(Record) bool
+
+	mu    sync.Mutex
+	lines []*Record
+	next  int
+	count int
+}
+
+// add records rec, or a written line when rec is nil, evicting the oldest
+// line once the ring is full.
+func (c *contextLines) add(rec *Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines[c.next] = rec
+	c.next = (c.next + 1) % len(c.lines)
+	c.count = min(c.count+1, len(c.lines))
+}
+
+// take returns the hidden lines held, oldest first, and empties the ring.
+func (c *contextLines) take() []Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var hidden []Record
+	for i := range c.count {
+		idx := (c.next - c.count + i + len(c.lines)) % len(c.lines)
+		if rec := c.lines[idx]; rec != nil {
+			hidden = append(hidden, *rec)
+		}
+		c.lines[idx] = nil
+	}
+	c.count = 0
+	return hidden
+}
+
+// holdContext records rec as a hidden line for WithContextBefore.
+func (p *Processor) holdContext(rec Record) {
+	if p.context != nil {
+		p.context.add(&rec)
+	}
+}
+
+// writeContext is called before rec is written. When rec fires the trigger,
+// the hidden lines held are formatted and written first. rec itself is
+// recorded as written.
+func (p *Processor) writeContext(rec Record) {
+	if p.context == nil {
+		return
+	}
+	if p.context.trigger(rec) {
+		for _, held := range p.context.take() {
+			held.Context = true
+			formatted, err := p.format(held)
+			if err != nil && (errors.Is(err, pkgerrors.ErrLineDropped) || formatted == "") {
+				continue
+			}
+			p.publish(held, formatted)
+			// Write errors also hit the trigger line, where they are
+			// reported with stream and line context.
+			_ = p.write([]byte(formatted + p.lineEnding))
+		}
+	}
+	p.context.add(nil)
+}
@@ -0,0 +1,78 @@
+package processor_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/apperrors"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// debugDropper drops "debug" lines unless they are written as context,
+// like filter.only_levels.
+type debugDropper struct{}
+
+func (debugDropper) FormatLine(line string, _ processor.StreamType) string { return line }
+
+func (debugDropper) FormatRecord(rec processor.Record) (string, error) {
+	if rec.Context {
+		return "ctx " + rec.Line, nil
+	}
+	if strings.HasPrefix(rec.Line, "debug") {
+		return "", apperrors.ErrLineDropped
+	}
+	return rec.Line, nil
+}
+
+// noDebug is a line filter rejecting "debug" lines.
+type noDebug struct{}
+
+func (noDebug) ShouldInclude(line string) bool { return !strings.HasPrefix(line, "debug") }
+
+func errorLine(rec processor.Record) bool {
+	return strings.HasPrefix(rec.Line, "ERROR")
+}
+
+func TestProcessor_ContextBefore(t *testing.T) {
+	t.Parallel()
+
+	input := "debug 1\ndebug 2\ndebug 3\nERROR a\ndebug 4\ninfo\ndebug 5\nERROR b\nERROR c\n"
+	expected := "ctx debug 2\nctx debug 3\nERROR a\ninfo\nctx debug 5\nERROR b\nERROR c\n"
+
+	tests := []struct {
+		name string
+		opts []processor.Option
+	}{
+		{"dropped by the formatter", nil},
+		{"rejected by the filter", []processor.Option{processor.WithFilter(noDebug{})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			output := &testutils.MockWriter{}
+			opts := append([]processor.Option{processor.WithContextBefore(2, errorLine)}, tt.opts...)
+			p := processor.New(debugDropper{}, output, opts...)
+			require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(input), strings.NewReader("")))
+
+			// Lines already written (info) count towards the two lines of
+			// context but are not repeated.
+			assert.Equal(t, expected, strings.Join(output.GetLines(), ""))
+		})
+	}
+}
+
+func TestProcessor_ContextBefore_Disabled(t *testing.T) {
+	t.Parallel()
+
+	output := &testutils.MockWriter{}
+	p := processor.New(debugDropper{}, output, processor.WithContextBefore(0, errorLine))
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader("debug 1\nERROR a\n"), strings.NewReader("")))
+
+	assert.Equal(t, "ERROR a\n", strings.Join(output.GetLines(), ""))
+}
@@ -0,0 +1,60 @@
+package processor
+
+import "strings"
+
+// ControlChars selects what WithControlChars does with the control
+// characters of a line: the C0 controls (NUL to 0x1F) except tab, and DEL.
+type ControlChars int
+
+const (
+	// ControlCharsKeep passes control characters through unchanged.
+	ControlCharsKeep ControlChars = iota
+	// ControlCharsEscape replaces each control character with a visible
+	// \xNN escape, e.g. NUL becomes `\x00`.
+	ControlCharsEscape
+	// ControlCharsStrip removes control characters.
+	ControlCharsStrip
+)
+
+// WithControlChars escapes or strips the control characters of every line
+// read, such as the NUL bytes of binary-ish output that would garble a
+// terminal, before it is filtered or formatted. This includes ESC, so ANSI
+// colors written by the command are escaped or stripped as well.
+// [Record.Raw] keeps the line as read.
+func WithControlChars(mode ControlChars) Option {
+	return func(p *Processor) {
+		p.controlChars = mode
+	}
+}
+
+func isControlChar(c byte) bool {
+	return (c < 0x20 && c != '\t') || c == 0x7f
+}
+
+// cleanControlChars applies the WithControlChars mode to line.
+func (p *Processor) cleanControlChars(line string) string {
+	if p.controlChars == ControlCharsKeep {
+		return line
+	}
+	first := strings.IndexFunc(line, func(r rune) bool { return r < 0x80 && isControlChar(byte(r)) })
+	if first < 0 {
+		return line
+	}
+
+	const hex = "0123456789abcdef"
+	var b strings.Builder
+	b.Grow(len(line) + 3)
+	b.WriteString(line[:first])
+	for i := first; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case !isControlChar(c):
+			b.WriteByte(c)
+		case p.controlChars == ControlCharsEscape:
+			b.WriteString(`\x`)
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0x0f])
+		}
+	}
+	return b.String()
+}
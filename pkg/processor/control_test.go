@@ -0,0 +1,55 @@
+package processor_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_ControlChars(t *testing.T) {
+	t.Parallel()
+
+	const input = "bin\x00ary\x01 data\tok\x1b[31m\x7f é\n"
+
+	tests := []struct {
+		name     string
+		mode     processor.ControlChars
+		expected string
+	}{
+		{"keep", processor.ControlCharsKeep, "[stdout] bin\x00ary\x01 data\tok\x1b[31m\x7f é\n"},
+		{"escape", processor.ControlCharsEscape, `[stdout] bin\x00ary\x01 data` + "\tok" + `\x1b[31m\x7f é` + "\n"},
+		{"strip", processor.ControlCharsStrip, "[stdout] binary data\tok[31m é\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := &testutils.MockWriter{}
+			p := processor.New(&mockFormatter{}, writer, processor.WithControlChars(tt.mode))
+			require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(input), strings.NewReader("")))
+			assert.Equal(t, []string{tt.expected}, writer.GetLines())
+
+			recorder := &recordCollector{}
+			p = processor.New(recorder, &testutils.MockWriter{}, processor.WithControlChars(tt.mode))
+			require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(input), strings.NewReader("")))
+			recs := recorder.records()
+			require.Len(t, recs, 1)
+			assert.Equal(t, strings.TrimSuffix(input, "\n"), recs[0].Raw, "Raw keeps the line as read")
+		})
+	}
+}
+
+func TestProcessor_ControlChars_CleanLinesUnchanged(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithControlChars(processor.ControlCharsEscape))
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader("plain\ttext ünïcode\n"), strings.NewReader("")))
+	assert.Equal(t, []string{"[stdout] plain\ttext ünïcode\n"}, writer.GetLines())
+}
@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// dedupeMaxLines bounds the distinct lines WithDedupeWindow remembers. When
+// it is reached the least recently seen line is forgotten, and is written
+// again on its next occurrence.
+const dedupeMaxLines = 10000
+
+// WithDedupeWindow drops lines identical to one written less than window
+// earlier, comparing lines as read, across both streams: a line repeated
+// every second with a 5s window is written once every five seconds. Every
+// window in which lines were dropped ends with summary(n), n being the
+// number dropped, written as a formatted stdout line like
+// [Processor.WriteMessage]; so does the end of processing. Dropped lines
+// reach no destination and are not kept as context. A window of 0
+// disables it.
+func WithDedupeWindow(window time.Duration, summary func(suppressed int) string) Option {
+	return func(p *Processor) {
+		if window <= 0 {
+			return
+		}
+		p.dedupe = newDedupeCache(window, dedupeMaxLines, summary)
+	}
+}
+
+// dedupeCache is an LRU of recently written lines, shared by both streams.
+type dedupeCache struct {
+	window   time.Duration
+	maxLines int
+	summary  func(suppressed int) string
+
+	mu         sync.Mutex
+	order      *list.List // of *dedupeEntry; front = most recently seen
+	entries    map[string]*list.Element
+	suppressed int // lines dropped since the last summary
+}
+
+func newDedupeCache(window time.Duration, maxLines int, summary func(int) string) *dedupeCache {
+	return &dedupeCache{
+		window:   window,
+		maxLines: maxLines,
+		summary:  summary,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+type dedupeEntry struct {
+	line    string
+	written time.Time // when the line was last written
+}
+
+// duplicate reports whether rec repeats a line written less than the window
+// before it was read, counting it if so, and otherwise records it as
+// written.
+func (c *dedupeCache) duplicate(rec Record) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[rec.Raw]; ok {
+		c.order.MoveToFront(elem)
+		entry, _ := elem.Value.(*dedupeEntry)
+		if rec.Time.Sub(entry.written) < c.window {
+			c.suppressed++
+			return true
+		}
+		entry.written = rec.Time
+		return false
+	}
+
+	if c.order.Len() >= c.maxLines {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		entry, _ := oldest.Value.(*dedupeEntry)
+		delete(c.entries, entry.line)
+	}
+	c.entries[rec.Raw] = c.order.PushFront(&dedupeEntry{line: rec.Raw, written: rec.Time})
+	return false
+}
+
+// takeSuppressed returns the number of lines dropped since the last call.
+func (c *dedupeCache) takeSuppressed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.suppressed
+	c.suppressed = 0
+	return n
+}
+
+// deduplicated reports whether rec is dropped by WithDedupeWindow.
+func (p *Processor) deduplicated(rec Record) bool {
+	return p.dedupe != nil && p.dedupe.duplicate(rec)
+}
+
+// runDedupeSummary writes a summary at the end of every window in which
+// lines were dropped, and a last one once done is closed.
+func (p *Processor) runDedupeSummary(done <-chan struct{}) {
+	ticker := time.NewTicker(p.dedupe.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			p.writeDedupeSummary()
+			return
+		case <-ticker.C:
+			p.writeDedupeSummary()
+		}
+	}
+}
+
+func (p *Processor) writeDedupeSummary() {
+	if n := p.dedupe.takeSuppressed(); n > 0 {
+		// Write errors will also hit the next real line, like heartbeats.
+		_ = p.WriteMessage(p.dedupe.summary(n))
+	}
+}
@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeCache_Window(t *testing.T) {
+	t.Parallel()
+
+	base := time.Now()
+	at := func(ms int) Record {
+		return Record{Raw: "retrying", Time: base.Add(time.Duration(ms) * time.Millisecond)}
+	}
+
+	c := newDedupeCache(time.Second, 10, nil)
+	assert.False(t, c.duplicate(at(0)))
+	assert.True(t, c.duplicate(at(500)))
+	assert.True(t, c.duplicate(at(999)))
+	assert.False(t, c.duplicate(at(1000)), "the window counts from when the line was written")
+	assert.True(t, c.duplicate(at(1500)))
+	assert.Equal(t, 3, c.takeSuppressed())
+	assert.Zero(t, c.takeSuppressed(), "the count restarts after each summary")
+}
+
+func TestDedupeCache_EvictsLeastRecentlySeen(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	rec := func(line string) Record { return Record{Raw: line, Time: now} }
+
+	c := newDedupeCache(time.Hour, 2, nil)
+	assert.False(t, c.duplicate(rec("a")))
+	assert.False(t, c.duplicate(rec("b")))
+	assert.True(t, c.duplicate(rec("a")), "seeing a again makes b the least recent")
+	assert.False(t, c.duplicate(rec("c")), "c evicts b")
+	assert.True(t, c.duplicate(rec("a")))
+	assert.False(t, c.duplicate(rec("b")), "b was forgotten")
+	assert.Len(t, c.entries, 2)
+	assert.Equal(t, 2, c.order.Len())
+}
@@ -0,0 +1,91 @@
+package processor_test
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dedupeSummary(suppressed int) string {
+	return "suppressed " + strconv.Itoa(suppressed)
+}
+
+func TestProcessor_DedupeWindow_SuppressesAndCounts(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithDedupeWindow(time.Hour, dedupeSummary))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("a\na\nb\na\nb\nc\n"), strings.NewReader(""))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"[stdout] a\n",
+		"[stdout] b\n",
+		"[stdout] c\n",
+		"[stdout] suppressed 3\n",
+	}, writer.GetLines(), "the count of duplicates is written once processing ends")
+}
+
+func TestProcessor_DedupeWindow_AcrossStreams(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithDedupeWindow(time.Hour, dedupeSummary))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("same\n"), strings.NewReader("same\n"))
+	require.NoError(t, err)
+
+	lines := writer.GetLines()
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[0], "] same\n"), "whichever stream is read first is written")
+	assert.Equal(t, "[stdout] suppressed 1\n", lines[1])
+}
+
+func TestProcessor_DedupeWindow_WritesAgainAfterWindow(t *testing.T) {
+	t.Parallel()
+
+	stdoutR, stdoutW := io.Pipe()
+	writer := &testutils.MockWriter{}
+	const window = 30 * time.Millisecond
+	p := processor.New(&mockFormatter{}, writer, processor.WithDedupeWindow(window, dedupeSummary))
+
+	done := make(chan error, 1)
+	go func() { done <- p.ProcessStreams(context.Background(), stdoutR, strings.NewReader("")) }()
+
+	_, err := stdoutW.Write([]byte("tick\ntick\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(writer.GetLines()) == 2 }, time.Second, 5*time.Millisecond,
+		"the count is written at the end of the window, while the stream is still open")
+
+	time.Sleep(2 * window)
+	_, err = stdoutW.Write([]byte("tick\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdoutW.Close())
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{
+		"[stdout] tick\n",
+		"[stdout] suppressed 1\n",
+		"[stdout] tick\n",
+	}, writer.GetLines(), "a line repeated after the window is written again")
+}
+
+func TestProcessor_DedupeWindow_Disabled(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithDedupeWindow(0, dedupeSummary))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("a\na\n"), strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[stdout] a\n", "[stdout] a\n"}, writer.GetLines())
+}
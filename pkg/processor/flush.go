@@ -0,0 +1,49 @@
+package processor
+
+import "strings"
+
+// Flusher is implemented by buffered writers such as [bufio.Writer]. The
+// primary output and sink outputs that implement it are flushed by
+// [WithFlushOnLevels].
+type Flusher interface {
+	Flush() error
+}
+
+// WithFlushOnLevels flushes the primary output and every sink output that
+// implements [Flusher] right after a line whose level, as returned by
+// level, is one of levels (case-insensitive), e.g. ERROR and FATAL, so
+// critical lines are not held in a buffer. Other lines are flushed when the
+// writer decides to. A flush failure is not reported here: the buffered
+// writer returns it from its next write.
+func WithFlushOnLevels(level func(Record) string, levels []string) Option {
+	return func(p *Processor) {
+		if len(levels) == 0 {
+			return
+		}
+		p.flushLevel = level
+		p.flushLevels = make(map[string]bool, len(levels))
+		for _, lvl := range levels {
+			p.flushLevels[strings.ToUpper(lvl)] = true
+		}
+	}
+}
+
+// flushOn flushes the outputs when rec is at one of the WithFlushOnLevels
+// levels.
+func (p *Processor) flushOn(rec Record) {
+	if p.flushLevels == nil || !p.flushLevels[strings.ToUpper(p.flushLevel(rec))] {
+		return
+	}
+
+	p.outputMu.RLock()
+	output := p.output
+	p.outputMu.RUnlock()
+	if f, ok := output.(Flusher); ok {
+		_ = f.Flush()
+	}
+	for _, sink := range p.sinks {
+		if f, ok := sink.Output.(Flusher); ok {
+			_ = f.Flush()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package processor_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bufferedWriter is a bufio.Writer safe for concurrent use, over a buffer
+// holding what has been flushed.
+type bufferedWriter struct {
+	mu      sync.Mutex
+	flushed bytes.Buffer
+	w       *bufio.Writer
+}
+
+func newBufferedWriter() *bufferedWriter {
+	b := &bufferedWriter{}
+	b.w = bufio.NewWriterSize(&b.flushed, 4096)
+	return b
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Write(p) //nolint:wrapcheck // test double
+}
+
+func (b *bufferedWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w.Flush() //nolint:wrapcheck // test double
+}
+
+func (b *bufferedWriter) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushed.String()
+}
+
+func TestProcessor_FlushOnLevels(t *testing.T) {
+	t.Parallel()
+
+	primary := newBufferedWriter()
+	sink := newBufferedWriter()
+	p := processor.New(&mockFormatter{}, primary,
+		processor.WithSinks(processor.Sink{Formatter: &mockFormatter{}, Output: sink}),
+		processor.WithFlushOnLevels(firstWord, []string{"error", "fatal"}),
+	)
+
+	stdout := strings.NewReader("INFO started\nERROR disk full\nINFO retrying\n")
+	require.NoError(t, p.ProcessStreams(context.Background(), stdout, strings.NewReader("")))
+
+	expected := "[stdout] INFO started\n[stdout] ERROR disk full\n"
+	assert.Equal(t, expected, primary.String(), "the ERROR line is flushed, the INFO line after it waits")
+	assert.Equal(t, expected, sink.String(), "sinks are flushed too")
+
+	require.NoError(t, primary.Flush())
+	assert.Equal(t, expected+"[stdout] INFO retrying\n", primary.String())
+}
+
+func TestProcessor_FlushOnLevels_Disabled(t *testing.T) {
+	t.Parallel()
+
+	primary := newBufferedWriter()
+	p := processor.New(&mockFormatter{}, primary, processor.WithFlushOnLevels(firstWord, nil))
+
+	stdout := strings.NewReader("ERROR disk full\n")
+	require.NoError(t, p.ProcessStreams(context.Background(), stdout, strings.NewReader("")))
+	assert.Empty(t, primary.String(), "without levels nothing is flushed")
+}
@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"time"
+)
+
+// WithHeartbeat makes the processor write message as a formatted stdout line
+// whenever no line has been written for interval, so that log tailers
+// (e.g. container orchestrators) can tell a silent command is still alive.
+// Heartbeats are suppressed while real output is flowing, bypass the line
+// filter, and carry line number 0. An interval of 0 disables heartbeats.
+func WithHeartbeat(interval time.Duration, message string) Option {
+	return func(p *Processor) {
+		p.heartbeatInterval = interval
+		p.heartbeatMessage = message
+	}
+}
+
+// runHeartbeat writes heartbeat lines until done is closed. The timer is
+// re-armed relative to the last write, so heartbeats only appear after a
+// full interval of silence.
+func (p *Processor) runHeartbeat(done <-chan struct{}) {
+	interval := p.heartbeatInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+			idle := time.Since(time.Unix(0, p.lastWrite.Load()))
+			if idle < interval {
+				timer.Reset(interval - idle)
+				continue
+			}
+			// Write errors (including EPIPE) will also hit the next real
+			// line, where they are reported with stream and line context.
+			_ = p.WriteMessage(p.heartbeatMessage)
+			timer.Reset(interval)
+		}
+	}
+}
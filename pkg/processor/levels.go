@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"maps"
+	"strings"
+	"sync"
+)
+
+// WithLevelCounts counts the lines read from both streams per level, as
+// returned by level, for [Processor.LevelCounts]. Every line read is
+// counted, including lines later kept from the output by the filter, rate
+// limits, the dedupe window or drop keywords; lines written with
+// [Processor.WriteMessage] are not.
+func WithLevelCounts(level func(Record) string) Option {
+	return func(p *Processor) {
+		p.levelCounts = &levelCounts{level: level, counts: make(map[string]int)}
+	}
+}
+
+// levelCounts holds the per-level line counts of WithLevelCounts, shared
+// by both streams.
+type levelCounts struct {
+	level func(Record) string
+
+	mu     sync.Mutex
+	counts map[string]int // upper-case level -> lines
+}
+
+func (c *levelCounts) count(rec Record) {
+	level := strings.ToUpper(c.level(rec))
+	c.mu.Lock()
+	c.counts[level]++
+	c.mu.Unlock()
+}
+
+// LevelCounts returns the number of lines read so far per upper-case level,
+// or nil unless WithLevelCounts is used.
+func (p *Processor) LevelCounts() map[string]int {
+	if p.levelCounts == nil {
+		return nil
+	}
+	p.levelCounts.mu.Lock()
+	defer p.levelCounts.mu.Unlock()
+	return maps.Clone(p.levelCounts.counts)
+}
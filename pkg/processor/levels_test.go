@@ -0,0 +1,38 @@
+package processor_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_LevelCounts(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer,
+		processor.WithLevelCounts(firstWord), processor.WithFilter(noDebug{}))
+
+	stdout := "info starting\ndebug cache warm\nINFO ready\nwarn slow request\nerror request failed\ninfo done\n"
+	stderr := "error disk full\nwarn retrying\nfatal giving up\n"
+	err := p.ProcessStreams(context.Background(), strings.NewReader(stdout), strings.NewReader(stderr))
+	require.NoError(t, err)
+	require.NoError(t, p.WriteMessage("info not counted"))
+
+	assert.Equal(t, map[string]int{"INFO": 3, "DEBUG": 1, "WARN": 2, "ERROR": 2, "FATAL": 1}, p.LevelCounts(),
+		"filtered lines are counted, messages are not")
+	assert.Len(t, writer.GetLines(), 9)
+}
+
+func TestProcessor_LevelCounts_Disabled(t *testing.T) {
+	t.Parallel()
+
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{})
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader("info a\n"), strings.NewReader("")))
+	assert.Nil(t, p.LevelCounts())
+}
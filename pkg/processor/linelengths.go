@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// WithLineLengths records the length of every line read from both streams
+// for [Processor.LineLengths], to spot anomalous output sizes. Like
+// [Processor.LinesRead], it covers lines later kept from the output.
+func WithLineLengths() Option {
+	return func(p *Processor) {
+		p.lineLengths = &lineLengths{}
+	}
+}
+
+// LineLengthStats summarizes the lengths in bytes of the lines read, line
+// terminators excluded. Percentiles are estimates, at most 1/16 above the
+// exact value; they never exceed Max.
+type LineLengthStats struct {
+	Count int64
+	Min   int
+	Max   int
+	Mean  float64
+	P50   int
+	P90   int
+	P99   int
+}
+
+const (
+	// lengthSubBuckets is the number of buckets per power of two. Lengths
+	// below it have a bucket each.
+	lengthSubBuckets = 16
+	lengthSubBits    = 4 // log2(lengthSubBuckets)
+	lengthBuckets    = lengthSubBuckets + (64-lengthSubBits)*lengthSubBuckets
+)
+
+// lineLengths is a streaming histogram of line lengths in log-linear
+// buckets: every power of two is split into lengthSubBuckets equal
+// buckets, which bounds the relative error of percentiles with constant
+// memory. It is shared by both streams.
+type lineLengths struct {
+	mu      sync.Mutex
+	count   int64
+	total   int64
+	min     int
+	max     int
+	buckets [lengthBuckets]int64
+}
+
+// lengthBucket returns the bucket index of a line of n bytes.
+func lengthBucket(n int) int {
+	if n < lengthSubBuckets {
+		return n
+	}
+	exp := bits.Len(uint(n)) - 1
+	sub := (n >> (exp - lengthSubBits)) & (lengthSubBuckets - 1)
+	return lengthSubBuckets + (exp-lengthSubBits)*lengthSubBuckets + sub
+}
+
+// bucketUpperBound returns the longest length that falls into bucket i.
+func bucketUpperBound(i int) int {
+	if i < lengthSubBuckets {
+		return i
+	}
+	exp := (i-lengthSubBuckets)/lengthSubBuckets + lengthSubBits
+	sub := (i - lengthSubBuckets) % lengthSubBuckets
+	width := 1 << (exp - lengthSubBits)
+	return (lengthSubBuckets+sub)*width + width - 1
+}
+
+func (l *lineLengths) add(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count == 0 || n < l.min {
+		l.min = n
+	}
+	l.max = max(l.max, n)
+	l.count++
+	l.total += int64(n)
+	l.buckets[lengthBucket(n)]++
+}
+
+func (l *lineLengths) stats() LineLengthStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count == 0 {
+		return LineLengthStats{}
+	}
+	return LineLengthStats{
+		Count: l.count,
+		Min:   l.min,
+		Max:   l.max,
+		Mean:  float64(l.total) / float64(l.count),
+		P50:   l.percentile(50),
+		P90:   l.percentile(90),
+		P99:   l.percentile(99),
+	}
+}
+
+// percentile returns the estimated length at or below which p percent of
+// the lines fall, using the nearest-rank method. l.mu must be held.
+func (l *lineLengths) percentile(p int) int {
+	rank := (l.count*int64(p) + 99) / 100 // ceil(count * p / 100)
+	var seen int64
+	for i, n := range l.buckets {
+		if seen += n; seen >= max(rank, 1) {
+			return min(max(bucketUpperBound(i), l.min), l.max)
+		}
+	}
+	return l.max
+}
+
+// LineLengths returns statistics on the lengths of the lines read so far,
+// or nil unless WithLineLengths is used.
+func (p *Processor) LineLengths() *LineLengthStats {
+	if p.lineLengths == nil {
+		return nil
+	}
+	stats := p.lineLengths.stats()
+	return &stats
+}
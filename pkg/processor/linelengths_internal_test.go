@@ -0,0 +1,20 @@
+package processor
+
+import "testing"
+
+func TestLengthBucket_Bounds(t *testing.T) {
+	t.Parallel()
+
+	for n := range 1 << 20 {
+		i := lengthBucket(n)
+		if i >= lengthBuckets {
+			t.Fatalf("length %d: bucket %d out of range", n, i)
+		}
+		if upper := bucketUpperBound(i); upper < n || upper > n+n/lengthSubBuckets {
+			t.Fatalf("length %d: bucket %d has upper bound %d", n, i, upper)
+		}
+		if n > 0 && lengthBucket(n-1) > i {
+			t.Fatalf("length %d: bucket %d is below the bucket of %d", n, i, n-1)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package processor_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_LineLengths(t *testing.T) {
+	t.Parallel()
+
+	// 90 short and 9 medium lines on stdout, one long line on stderr.
+	var stdout strings.Builder
+	for range 90 {
+		stdout.WriteString(strings.Repeat("s", 10) + "\n")
+	}
+	for range 9 {
+		stdout.WriteString(strings.Repeat("m", 100) + "\r\n")
+	}
+	stderr := strings.Repeat("l", 5000) + "\n"
+
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{},
+		processor.WithLineLengths(), processor.WithFilter(noDebug{}))
+	err := p.ProcessStreams(context.Background(), strings.NewReader(stdout.String()), strings.NewReader(stderr))
+	require.NoError(t, err)
+
+	stats := p.LineLengths()
+	require.NotNil(t, stats)
+	assert.Equal(t, int64(100), stats.Count)
+	assert.Equal(t, 10, stats.Min)
+	assert.Equal(t, 5000, stats.Max)
+	assert.InDelta(t, 68.0, stats.Mean, 0.001)
+	assert.Equal(t, 10, stats.P50)
+	assert.Equal(t, 10, stats.P90)
+	assert.GreaterOrEqual(t, stats.P99, 100)
+	assert.LessOrEqual(t, stats.P99, 100+100/16, "percentiles are at most 1/16 too high")
+}
+
+func TestProcessor_LineLengths_Empty(t *testing.T) {
+	t.Parallel()
+
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{}, processor.WithLineLengths())
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(""), strings.NewReader("")))
+	assert.Equal(t, &processor.LineLengthStats{}, p.LineLengths())
+}
+
+func TestProcessor_LineLengths_Disabled(t *testing.T) {
+	t.Parallel()
+
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{})
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader("a\n"), strings.NewReader("")))
+	assert.Nil(t, p.LineLengths())
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+)
+
+// passthroughBufferSize is the largest chunk read from a stream at once in
+// passthrough mode.
+const passthroughBufferSize = 32 * 1024
+
+// WithPassthrough makes ProcessStreams copy the bytes of both streams
+// unmodified, as soon as they are read, instead of splitting them into
+// formatted lines: stdout goes to the output and stderr to stderr, or to
+// the output as well when stderr is nil. Partial lines, such as the prompt
+// of an interactive program, appear immediately. Line-based options
+// (filter, sinks, routes, rates, context, reordering, ...) do not apply to
+// the streams; lines written with [Processor.WriteMessage] are still
+// formatted.
+func WithPassthrough(stderr io.Writer) Option {
+	return func(p *Processor) {
+		p.passthrough = &passthrough{stderr: stderr}
+	}
+}
+
+// passthrough holds the settings of WithPassthrough.
+type passthrough struct {
+	stderr io.Writer // nil writes stderr to the output
+}
+
+// copyStream copies stream to its destination chunk by chunk until EOF.
+// Like processStream, a closed stream ends the copy without error and a
+// broken pipe closes the output.
+func (p *Processor) copyStream(ctx context.Context, stream io.Reader, streamType StreamType) *ProcessingError {
+	buf := make([]byte, passthroughBufferSize)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 && !p.isOutputClosed() {
+			if werr := p.writeChunk(buf[:n], streamType); werr != nil {
+				if !errors.Is(werr, syscall.EPIPE) {
+					return &ProcessingError{Stream: streamType, Err: werr}
+				}
+				p.outputOnce.Do(func() { close(p.outputDone) })
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || isExpectedStreamError(err) {
+				return nil
+			}
+			return &ProcessingError{Stream: streamType, Err: fmt.Errorf("read error: %w", err)}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// writeChunk writes raw bytes read from streamType to their destination.
+func (p *Processor) writeChunk(data []byte, streamType StreamType) error {
+	if streamType == StreamStderr && p.passthrough.stderr != nil {
+		if _, err := p.passthrough.stderr.Write(data); err != nil {
+			return fmt.Errorf("failed to write to stderr: %w", err)
+		}
+		return nil
+	}
+	return p.write(data)
+}
@@ -0,0 +1,70 @@
+package processor_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_Passthrough(t *testing.T) {
+	t.Parallel()
+
+	stdout := &testutils.MockWriter{}
+	stderr := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, stdout, processor.WithPassthrough(stderr))
+
+	err := p.ProcessStreams(context.Background(),
+		strings.NewReader("one\ntwo"), strings.NewReader("oops\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "one\ntwo", strings.Join(stdout.GetLines(), ""), "stdout is copied unmodified")
+	assert.Equal(t, "oops\n", strings.Join(stderr.GetLines(), ""), "stderr goes to its own writer")
+	assert.Zero(t, p.LinesRead(), "no lines are split")
+
+	require.NoError(t, p.WriteMessage("note"))
+	assert.Equal(t, "[stdout] note\n", stdout.GetLines()[len(stdout.GetLines())-1], "messages are still formatted")
+}
+
+func TestProcessor_Passthrough_StderrToOutput(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithPassthrough(nil))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader(""), strings.NewReader("oops"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"oops"}, writer.GetLines())
+}
+
+func TestProcessor_Passthrough_PartialLineWrittenImmediately(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithPassthrough(nil))
+
+	stdoutR, stdoutW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- p.ProcessStreams(context.Background(), stdoutR, strings.NewReader(""))
+	}()
+
+	// A prompt has no line ending; it must not wait for one.
+	_, err := stdoutW.Write([]byte("> "))
+	require.NoError(t, err)
+	testutils.AssertEventuallyTrue(t, func() bool {
+		return strings.Join(writer.GetLines(), "") == "> "
+	}, time.Second, "prompt should be written before the line is complete")
+
+	_, err = stdoutW.Write([]byte("answer\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdoutW.Close())
+	require.NoError(t, <-done)
+	assert.Equal(t, "> answer\n", strings.Join(writer.GetLines(), ""))
+}
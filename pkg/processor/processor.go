@@ -11,14 +11,37 @@
 //  2. Launch one goroutine per stream for concurrent processing
 //  3. Use [bufio.Scanner] for efficient line-by-line reading
 //  4. Pass each line to the formatter with its stream type
-//  5. Write formatted output immediately (no buffering)
+//  5. Write formatted output as soon as it is formatted, unless one of the
+//     modes below holds lines back
 //
 // # Concurrency Model
 //
 // Two goroutines run concurrently, one per stream (stdout and stderr).
 // A [sync.WaitGroup] coordinates completion. Errors from each goroutine
 // are collected in a mutex-protected slice. Context cancellation is
-// checked between lines for responsive shutdown.
+// checked between lines for responsive shutdown. With [WithWorkers], lines
+// are formatted on a pool of goroutines instead, and still written one at
+// a time in the order they were read from their stream.
+//
+// The output writer can be replaced at any time with [Processor.SetOutput]
+// (e.g. for config reloads or tee-ing); writes and swaps are serialized by
+// a read/write mutex. Additional destinations with their own formatter
+// (e.g. JSON to a file next to text on the terminal) are added with
+// [WithSinks]; each line is formatted once per destination. [WithRoutes]
+// restricts the destinations of a line by its level. [WithLineChannel]
+// also delivers formatted lines to a Go channel, e.g. for a TUI.
+//
+// With [WithHeartbeat] a third goroutine writes a heartbeat line after each
+// interval without output; it stops when both streams complete.
+//
+// # Holding Lines Back
+//
+// [WithBufferUntil] keeps output lines in memory, bounded by a line count,
+// until a trigger line is read, and only then writes them; if the trigger
+// never fires they are discarded.
+//
+// [WithReorderWindow] holds every line for a short window and writes lines
+// sorted by the time they were read, adding up to that window of latency.
 //
 // # Buffer Management
 //
@@ -28,14 +51,31 @@
 //
 // Lines exceeding 1MB will cause a scanner error for that stream.
 //
+// [WithReadAhead] adds a bounded queue of read lines between each scanner
+// and the processing of its lines, so that a slow write does not block the
+// command on a full pipe.
+//
+// # Input Cleanup
+//
+// A UTF-8 byte order mark at the start of a stream is stripped from its
+// first line, and [WithControlChars] can escape or strip control characters
+// such as NUL. [Record.Raw] keeps the line as read.
+//
 // # Error Handling
 //
 // EOF and closed-pipe errors are expected during normal shutdown and
-// handled gracefully. Scanner errors are collected and returned as a
-// combined error after both streams complete. Lines exceeding the
+// handled gracefully. Scanner, write and formatting errors are collected as
+// [ProcessingError] values carrying the stream and line number, and are
+// returned joined into one error after both streams complete; use
+// [errors.As] or [Processor.GetErrors] to inspect them. Lines exceeding the
 // maximum buffer size (1MB) cause [bufio.ErrTooLong], which is
 // returned with a descriptive message including the byte limit.
 //
+// A broken output pipe (EPIPE, e.g. when piped into head) is not an error:
+// the processor closes the channel returned by [Processor.OutputClosed] and
+// keeps draining both streams without writing, so the command is never
+// blocked on a full pipe while the caller shuts it down.
+//
 // # Performance Characteristics
 //
 // Approximate throughput (Apple M2 Max, benchFormatter):
@@ -62,7 +102,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	pkgerrors "github.com/sgaunet/logwrap/pkg/apperrors"
@@ -94,6 +137,51 @@ type Formatter interface {
 	FormatLine(line string, streamType StreamType) string
 }
 
+// Record is a single line read from a stream, passed to a [RecordFormatter].
+type Record struct {
+	Line   string
+	Stream StreamType
+	LineNo int // 1-based, counted independently per stream
+	// Raw is the line exactly as read, before input cleanup such as BOM
+	// stripping. Empty means the same as Line.
+	Raw string
+	// Context marks a hidden line written ahead of a trigger line by
+	// [WithContextBefore]. Formatters should not drop it for its level.
+	Context bool
+	// Time is when the line was read, used to order lines across streams
+	// by [WithReorderWindow].
+	Time time.Time
+	// Level, when set, is used instead of the level detected from Line,
+	// e.g. for lines logwrap writes itself. See [Processor.WriteMessageWithLevel].
+	Level string
+}
+
+// RecordFormatter is an optional interface a [Formatter] may implement to
+// report formatting failures. When the formatter implements it, FormatRecord
+// is used instead of FormatLine. An error wrapping apperrors.ErrLineDropped
+// discards the line silently; any other error is recorded as a processing
+// error and the returned string, if non-empty, is still written.
+type RecordFormatter interface {
+	FormatRecord(rec Record) (string, error)
+}
+
+// ProcessingError describes a failure while processing a stream. It records
+// which stream and which line (1-based, counted per stream) the failure
+// relates to, and wraps the underlying error.
+type ProcessingError struct {
+	Stream StreamType
+	Line   int
+	Err    error
+}
+
+func (e *ProcessingError) Error() string {
+	return fmt.Sprintf("%s processing error at line %d: %v", e.Stream, e.Line, e.Err)
+}
+
+func (e *ProcessingError) Unwrap() error {
+	return e.Err
+}
+
 // LineFilter is an optional filter that decides whether a raw line should be
 // processed. If ShouldInclude returns false, the line is silently dropped
 // before formatting.
@@ -101,18 +189,80 @@ type LineFilter interface {
 	ShouldInclude(line string) bool
 }
 
+// Sink is an additional destination for processed lines with its own
+// formatter, e.g. JSON to a file next to colored text on the terminal.
+// Output must be safe for concurrent use.
+type Sink struct {
+	// Name identifies the sink in [WithRoutes]; unnamed sinks can only
+	// receive lines whose level has no route.
+	Name      string
+	Formatter Formatter
+	Output    io.Writer
+	// Fallback receives the sink's lines once a write to Output has
+	// failed (e.g. a closed file or a full disk). Nil drops them.
+	Fallback io.Writer
+}
+
+// PrimaryRoute is the destination name [WithRoutes] uses for the primary
+// output writer.
+const PrimaryRoute = "primary"
+
+// utf8BOM is the UTF-8 encoded byte order mark, stripped from the start of
+// each stream.
+const utf8BOM = "\ufeff"
+
 // Processor handles real-time processing of command output streams.
 type Processor struct {
 	formatter  Formatter
+	sinks      []Sink
+	sinkFailed []atomic.Bool // per sink, set once a write to its Output fails
 	filter     LineFilter
 	output     io.Writer
+	outputMu   sync.RWMutex // guards output against SetOutput
 	wg         sync.WaitGroup
-	errors     []error
+	errors     []*ProcessingError
 	mutex      sync.Mutex
 	parentDone <-chan struct{} // closed when parent context is cancelled; nil if no WithContext
 	stopCh     chan struct{}
 	readers    []io.Reader // stored so Stop() can close them to unblock scanners
 	stopOnce   sync.Once
+	outputDone chan struct{} // closed when a write fails with EPIPE
+	outputOnce sync.Once
+
+	lineEnding string      // terminator appended to every line, "\n" by default
+	maxBlank   int         // consecutive blank lines kept per stream; -1 keeps all
+	buffer     *lineBuffer // nil unless WithBufferUntil is used
+
+	routeLevel func(Record) string
+	routes     map[string]map[string]bool // upper-case level -> destination names; nil routes nothing
+
+	flushLevel  func(Record) string
+	flushLevels map[string]bool // upper-case levels of WithFlushOnLevels; nil flushes nothing
+
+	recent  *recentLines   // nil unless WithRecentLines is used
+	context *contextLines  // nil unless WithContextBefore is used
+	rates   *levelRates    // nil unless WithLevelRates is used
+	lines   *lineChannel   // nil unless WithLineChannel is used
+	reorder *reorderBuffer // nil unless WithReorderWindow is used
+
+	passthrough *passthrough // nil unless WithPassthrough is used
+	dedupe      *dedupeCache // nil unless WithDedupeWindow is used
+	levelCounts *levelCounts // nil unless WithLevelCounts is used
+	lineLengths *lineLengths // nil unless WithLineLengths is used
+
+	controlChars  ControlChars
+	progressFinal bool // WithProgressFinal
+
+	workers   int             // formatting goroutines of WithWorkers; 0 formats on the reading goroutine
+	readAhead int             // lines each stream may read ahead with WithReadAhead; 0 disables it
+	jobs      chan *formatJob // WithWorkers queue, open while streams are processed
+
+	heartbeatInterval time.Duration // 0 disables heartbeats
+	heartbeatMessage  string
+	lastWrite         atomic.Int64 // UnixNano of the last successful write
+
+	linesRead atomic.Int64 // lines read from both streams, before filtering
+	bytesRead atomic.Int64 // bytes read from both streams
 }
 
 // Option defines a function that configures a Processor.
@@ -138,17 +288,82 @@ func WithFilter(f LineFilter) Option {
 	}
 }
 
+// WithSinks adds destinations that receive every line the primary output
+// receives, each formatted with the sink's own formatter. Formatting and
+// write errors on a sink are recorded as processing errors without
+// interrupting the stream; a broken pipe only closes the primary output.
+func WithSinks(sinks ...Sink) Option {
+	return func(p *Processor) {
+		p.sinks = append(p.sinks, sinks...)
+	}
+}
+
+// WithRoutes sends each line only to the destinations routed for its
+// level: level returns the line's level and routes maps a level
+// (case-insensitive) to sink names, [PrimaryRoute] naming the primary
+// output. Lines whose level has no route go to the primary output only.
+// Lines written with [Processor.WriteMessage] are not routed.
+func WithRoutes(level func(Record) string, routes map[string][]string) Option {
+	return func(p *Processor) {
+		if len(routes) == 0 {
+			return
+		}
+		p.routeLevel = level
+		p.routes = make(map[string]map[string]bool, len(routes))
+		for lvl, names := range routes {
+			set := make(map[string]bool, len(names))
+			for _, name := range names {
+				set[name] = true
+			}
+			p.routes[strings.ToUpper(lvl)] = set
+		}
+	}
+}
+
+// destinations returns the names of the destinations rec is routed to, or
+// nil when routing is disabled and every destination receives it.
+func (p *Processor) destinations(rec Record) map[string]bool {
+	if p.routes == nil {
+		return nil
+	}
+	if set, ok := p.routes[strings.ToUpper(p.routeLevel(rec))]; ok {
+		return set
+	}
+	return map[string]bool{PrimaryRoute: true}
+}
+
+// WithSquashBlankLines keeps at most keep consecutive blank (empty or
+// whitespace-only) lines of each stream and drops the rest of the run;
+// keep 0 drops blank lines altogether.
+func WithSquashBlankLines(keep int) Option {
+	return func(p *Processor) {
+		p.maxBlank = keep
+	}
+}
+
+// WithLineEnding sets the terminator appended to every line written to the
+// output and the sinks, e.g. "\r\n" for Windows consumers. The default is "\n".
+func WithLineEnding(ending string) Option {
+	return func(p *Processor) {
+		p.lineEnding = ending
+	}
+}
+
 // New creates a new Processor with the given formatter and output writer.
 func New(formatter Formatter, output io.Writer, opts ...Option) *Processor {
 	p := &Processor{
-		formatter: formatter,
-		output:    output,
-		errors:    make([]error, 0),
+		formatter:  formatter,
+		output:     output,
+		errors:     make([]*ProcessingError, 0),
+		outputDone: make(chan struct{}),
+		lineEnding: "\n",
+		maxBlank:   -1,
 	}
 
 	for _, opt := range opts {
 		opt(p)
 	}
+	p.sinkFailed = make([]atomic.Bool, len(p.sinks))
 
 	return p
 }
@@ -172,21 +387,70 @@ func (p *Processor) ProcessStreams(ctx context.Context, stdout, stderr io.Reader
 	const streamCount = 2
 	p.wg.Add(streamCount)
 
+	if p.heartbeatInterval > 0 {
+		p.lastWrite.Store(time.Now().UnixNano())
+		heartbeatDone := make(chan struct{})
+		heartbeatExited := make(chan struct{})
+		go func() {
+			defer close(heartbeatExited)
+			p.runHeartbeat(heartbeatDone)
+		}()
+		defer func() {
+			close(heartbeatDone)
+			<-heartbeatExited
+		}()
+	}
+
+	if p.dedupe != nil {
+		// The last summary is written once ProcessStreams returns, after
+		// every line.
+		dedupeDone := make(chan struct{})
+		dedupeExited := make(chan struct{})
+		go func() {
+			defer close(dedupeExited)
+			p.runDedupeSummary(dedupeDone)
+		}()
+		defer func() {
+			close(dedupeDone)
+			<-dedupeExited
+		}()
+	}
+
+	var streamsDone, reorderExited chan struct{}
+	if p.reorder != nil {
+		streamsDone = make(chan struct{})
+		reorderExited = make(chan struct{})
+		go func() {
+			defer close(reorderExited)
+			p.runReorder(streamsDone)
+		}()
+	}
+
+	if p.workers > 0 && p.reorder == nil && p.passthrough == nil {
+		stopWorkers := p.startWorkers()
+		defer stopWorkers()
+	}
+
 	go func() {
 		defer p.wg.Done()
-		if err := p.processStream(ctx, stdout, StreamStdout); err != nil {
-			p.addError(fmt.Errorf("stdout processing error: %w", err))
+		if err := p.readStream(ctx, stdout, StreamStdout); err != nil {
+			p.addError(err)
 		}
 	}()
 
 	go func() {
 		defer p.wg.Done()
-		if err := p.processStream(ctx, stderr, StreamStderr); err != nil {
-			p.addError(fmt.Errorf("stderr processing error: %w", err))
+		if err := p.readStream(ctx, stderr, StreamStderr); err != nil {
+			p.addError(err)
 		}
 	}()
 
 	p.wg.Wait()
+	if p.reorder != nil {
+		// Both streams ended: flush the lines still held, in order.
+		close(streamsDone)
+		<-reorderExited
+	}
 
 	// Clear reader references so Stop() won't close them — the executor
 	// owns these pipes and will close them via Cleanup().
@@ -197,12 +461,131 @@ func (p *Processor) ProcessStreams(ctx context.Context, stdout, stderr io.Reader
 	p.Stop() // Clean up cancellation goroutines
 
 	if errs := p.GetErrors(); len(errs) > 0 {
-		return fmt.Errorf("%w: %v", pkgerrors.ErrProcessingErrors, errs)
+		joined := make([]error, len(errs))
+		for i, err := range errs {
+			joined[i] = err
+		}
+		return fmt.Errorf("%w: %w", pkgerrors.ErrProcessingErrors, errors.Join(joined...))
 	}
 
 	return nil
 }
 
+// SetOutput replaces the writer that formatted lines are written to. It is
+// safe to call while streams are being processed: a line being written when
+// SetOutput is called completes on the old writer, and every later line goes
+// to w. The previous writer is not closed. w itself must be safe for
+// concurrent use, since stdout and stderr lines are written from separate
+// goroutines.
+func (p *Processor) SetOutput(w io.Writer) {
+	p.outputMu.Lock()
+	defer p.outputMu.Unlock()
+	p.output = w
+}
+
+// write writes a formatted line to the current output writer, or holds it
+// while a WithBufferUntil buffer has not fired. The line is recorded for
+// RecentLines either way.
+func (p *Processor) write(data []byte) error {
+	p.recordRecent(data)
+	if p.buffer != nil {
+		var held bool
+		if data, held = p.buffer.add(data, p.bufferNotice); held {
+			return nil
+		}
+	}
+
+	p.outputMu.RLock()
+	defer p.outputMu.RUnlock()
+	if _, err := p.output.Write(data); err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+	p.lastWrite.Store(time.Now().UnixNano())
+	return nil
+}
+
+// WriteMessage formats message as a stdout line and writes it to the output
+// and all sinks, bypassing the line filter. It is used for lines logwrap
+// produces itself, such as heartbeats and run markers, and carries line
+// number 0. Nothing is written once the output has been closed.
+func (p *Processor) WriteMessage(message string) error {
+	return p.writeMessage(Record{Line: message, Stream: StreamStdout})
+}
+
+// WriteMessageWithLevel is like [Processor.WriteMessage] but formats message
+// at level instead of the level detected from it, e.g. to report a summary
+// line at a level derived from the command's exit code.
+func (p *Processor) WriteMessageWithLevel(message, level string) error {
+	return p.writeMessage(Record{Line: message, Stream: StreamStdout, Level: level})
+}
+
+// writeMessage formats and writes a message record to the output and all
+// sinks.
+func (p *Processor) writeMessage(rec Record) error {
+	if p.isOutputClosed() {
+		return nil
+	}
+
+	p.writeSinks(rec, nil)
+
+	formatted, err := p.format(rec)
+	if err != nil && formatted == "" {
+		return nil
+	}
+	p.publish(rec, formatted)
+	return p.write([]byte(formatted + p.lineEnding))
+}
+
+// WriteRaw writes line to the output as is, followed by the line ending,
+// without formatting it or passing it to sinks. It is used for records
+// logwrap adds to an output stream itself, such as the JSON summary record.
+// Nothing is written once the output has been closed.
+func (p *Processor) WriteRaw(line string) error {
+	if p.isOutputClosed() {
+		return nil
+	}
+	return p.write([]byte(line + p.lineEnding))
+}
+
+// LinesRead returns the number of lines read so far from both streams,
+// including lines later dropped by the filter or the formatter.
+func (p *Processor) LinesRead() int64 {
+	return p.linesRead.Load()
+}
+
+// BytesRead returns the number of bytes read so far from both streams, line
+// terminators included.
+func (p *Processor) BytesRead() int64 {
+	return p.bytesRead.Load()
+}
+
+// countingReader adds the number of bytes read from r to n.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n.Add(int64(n))
+	return n, err //nolint:wrapcheck // passed through unchanged for the scanner
+}
+
+// OutputClosed returns a channel that is closed when the output writer
+// reports a broken pipe (EPIPE). Lines read after that are discarded.
+func (p *Processor) OutputClosed() <-chan struct{} {
+	return p.outputDone
+}
+
+func (p *Processor) isOutputClosed() bool {
+	select {
+	case <-p.outputDone:
+		return true
+	default:
+		return false
+	}
+}
+
 // Stop signals the processor to stop stream processing.
 // Safe to call multiple times - subsequent calls are no-ops.
 // If the readers implement io.Closer, they are closed to unblock
@@ -244,12 +627,13 @@ func (p *Processor) Wait(timeout time.Duration) error {
 	}
 }
 
-// GetErrors returns a copy of all processing errors that occurred.
-func (p *Processor) GetErrors() []error {
+// GetErrors returns a copy of all processing errors that occurred, in the
+// order they were recorded.
+func (p *Processor) GetErrors() []*ProcessingError {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	errors := make([]error, len(p.errors))
+	errors := make([]*ProcessingError, len(p.errors))
 	copy(errors, p.errors)
 	return errors
 }
@@ -280,6 +664,53 @@ func (p *Processor) setupCancellation(ctx context.Context) (context.Context, con
 	return ctx, mergedCancel
 }
 
+// readStream processes a single stream, copying it unmodified under
+// WithPassthrough and line by line otherwise, through the WithReadAhead
+// queue and formatting lines on the WithWorkers pool when there are ones.
+func (p *Processor) readStream(ctx context.Context, stream io.Reader, streamType StreamType) *ProcessingError {
+	stream = countingReader{r: stream, n: &p.bytesRead}
+	if p.passthrough != nil {
+		return p.copyStream(ctx, stream, streamType)
+	}
+
+	handle := p.handleRecord
+	var queue *orderedQueue
+	if p.jobs != nil {
+		queue = p.newOrderedQueue()
+		handle = queue.submit
+	}
+	var ahead *readAheadQueue
+	if p.readAhead > 0 {
+		ahead = p.newReadAheadQueue(handle)
+		handle = ahead.submit
+	}
+
+	err := p.processStream(ctx, stream, streamType, handle)
+	var aheadErr *ProcessingError
+	if ahead != nil {
+		aheadErr = ahead.close()
+	}
+	if queue != nil {
+		if qerr := queue.close(); qerr != nil {
+			return qerr
+		}
+	}
+	if aheadErr != nil {
+		return aheadErr
+	}
+	return err
+}
+
+// handleRecord processes a line read from a stream, or hands it to the
+// WithReorderWindow buffer.
+func (p *Processor) handleRecord(rec Record) error {
+	if p.reorder != nil {
+		p.reorder.push(rec)
+		return nil
+	}
+	return p.processRecord(rec)
+}
+
 // processStream reads lines from a single stream using [bufio.Scanner].
 //
 // Scanner buffer configuration:
@@ -290,7 +721,9 @@ func (p *Processor) setupCancellation(ctx context.Context) (context.Context, con
 // wrapped with the byte limit for diagnostics. EOF and closed-pipe errors
 // are expected during normal process shutdown and return nil.
 // Context cancellation is checked between lines for responsive shutdown.
-func (p *Processor) processStream(ctx context.Context, stream io.Reader, streamType StreamType) error {
+func (p *Processor) processStream(
+	ctx context.Context, stream io.Reader, streamType StreamType, handle func(Record) error,
+) *ProcessingError {
 	scanner := bufio.NewScanner(stream)
 
 	const (
@@ -321,18 +754,46 @@ func (p *Processor) processStream(ctx context.Context, stream io.Reader, streamT
 
 	buf := make([]byte, 0, bufferSize)
 	scanner.Buffer(buf, maxScannerSize)
+	if p.progressFinal {
+		scanner.Split((&progressSplitter{}).split)
+	}
 
+	lineNo := 0
+	blankRun := 0 // consecutive blank lines seen, for WithSquashBlankLines
 	for scanner.Scan() {
-		line := scanner.Text()
-
-		if p.filter != nil && !p.filter.ShouldInclude(line) {
+		lineNo++
+		p.linesRead.Add(1)
+		if p.lineLengths != nil {
+			p.lineLengths.add(len(scanner.Bytes()))
+		}
+		if p.isOutputClosed() {
 			continue
 		}
 
-		formattedLine := p.formatter.FormatLine(line, streamType)
+		if p.maxBlank >= 0 {
+			if strings.TrimSpace(scanner.Text()) != "" {
+				blankRun = 0
+			} else if blankRun++; blankRun > p.maxBlank {
+				continue
+			}
+		}
 
-		if _, err := p.output.Write([]byte(formattedLine + "\n")); err != nil {
-			return fmt.Errorf("failed to write to output: %w", err)
+		raw := scanner.Text()
+		line := raw
+		if lineNo == 1 {
+			// Some (mostly Windows) tools start their output with a UTF-8
+			// byte order mark, which would prefix the first message.
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		line = p.cleanControlChars(line)
+
+		rec := Record{Line: line, Stream: streamType, LineNo: lineNo, Raw: raw, Time: time.Now()}
+		if err := handle(rec); err != nil {
+			return &ProcessingError{
+				Stream: streamType,
+				Line:   lineNo,
+				Err:    err,
+			}
 		}
 
 		// Check for context cancellation after writing the line, not before,
@@ -349,16 +810,153 @@ func (p *Processor) processStream(ctx context.Context, stream io.Reader, streamT
 			return nil
 		}
 		// Handle oversized lines explicitly with actionable diagnostics
+		// The failed line is the one after the last successfully scanned line.
 		if errors.Is(err, bufio.ErrTooLong) {
-			return fmt.Errorf("line exceeds maximum buffer size (%d bytes) for %s: %w",
-				maxScannerSize, streamType.String(), err)
+			return &ProcessingError{
+				Stream: streamType,
+				Line:   lineNo + 1,
+				Err:    fmt.Errorf("line exceeds maximum buffer size (%d bytes): %w", maxScannerSize, err),
+			}
 		}
-		return fmt.Errorf("scanner error for %s: %w", streamType.String(), err)
+		return &ProcessingError{Stream: streamType, Line: lineNo + 1, Err: fmt.Errorf("scanner error: %w", err)}
 	}
 
 	return nil
 }
 
+// processRecord filters, formats and writes a line read from a stream.
+// Formatting errors are recorded and a broken pipe closes the output; any
+// other write error is returned.
+func (p *Processor) processRecord(rec Record) error {
+	return p.commitRecord(rec, nil)
+}
+
+// commitRecord is processRecord for a line that was already formatted by
+// a WithWorkers worker, or formats it itself when job is nil.
+func (p *Processor) commitRecord(rec Record, job *formatJob) error {
+	if p.levelCounts != nil {
+		p.levelCounts.count(rec)
+	}
+	if p.filter != nil && !p.filter.ShouldInclude(rec.Line) {
+		p.holdContext(rec)
+		return nil
+	}
+	if p.throttled(rec) || p.deduplicated(rec) {
+		return nil
+	}
+
+	dest := p.destinations(rec)
+	p.writeSinks(rec, dest)
+	defer p.flushOn(rec)
+	if dest != nil && !dest[PrimaryRoute] {
+		return nil
+	}
+
+	var formattedLine string
+	var err error
+	if job != nil {
+		formattedLine, err = job.formatted, job.err
+	} else {
+		formattedLine, err = p.format(rec)
+	}
+	if err != nil {
+		if errors.Is(err, pkgerrors.ErrLineDropped) {
+			p.holdContext(rec)
+			return nil
+		}
+		p.addError(&ProcessingError{Stream: rec.Stream, Line: rec.LineNo, Err: err})
+		if formattedLine == "" {
+			return nil
+		}
+	}
+
+	p.writeContext(rec)
+	if p.buffer != nil {
+		p.buffer.observe(rec)
+	}
+	p.publish(rec, formattedLine)
+	if err := p.write([]byte(formattedLine + p.lineEnding)); err != nil {
+		if errors.Is(err, syscall.EPIPE) {
+			p.outputOnce.Do(func() { close(p.outputDone) })
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// format formats rec with the primary formatter.
+func (p *Processor) format(rec Record) (string, error) {
+	return formatWith(p.formatter, rec)
+}
+
+// formatWith formats rec with FormatRecord when f implements
+// [RecordFormatter], falling back to FormatLine otherwise.
+func formatWith(f Formatter, rec Record) (string, error) {
+	if rf, ok := f.(RecordFormatter); ok {
+		return rf.FormatRecord(rec)
+	}
+	return f.FormatLine(rec.Line, rec.Stream), nil
+}
+
+// writeSinks formats rec for every sink named in dest, or every sink when
+// dest is nil, and writes it. Failures are recorded and do not stop the
+// stream.
+func (p *Processor) writeSinks(rec Record, dest map[string]bool) {
+	for i, sink := range p.sinks {
+		if dest != nil && !dest[sink.Name] {
+			continue
+		}
+		formatted, err := formatWith(sink.Formatter, rec)
+		if err != nil {
+			if errors.Is(err, pkgerrors.ErrLineDropped) {
+				continue
+			}
+			p.addError(&ProcessingError{Stream: rec.Stream, Line: rec.LineNo, Err: err})
+			if formatted == "" {
+				continue
+			}
+		}
+		p.writeSink(i, rec, []byte(formatted+p.lineEnding))
+	}
+}
+
+// writeSink writes data to the i-th sink. The first failed write records a
+// single error wrapping [pkgerrors.ErrSinkFailed] and takes the sink out of
+// service: that line and all later ones go to its fallback, or are dropped
+// when it has none, so a full disk does not produce one error per line.
+func (p *Processor) writeSink(i int, rec Record, data []byte) {
+	sink := p.sinks[i]
+	if !p.sinkFailed[i].Load() {
+		_, err := sink.Output.Write(data)
+		if err == nil {
+			return
+		}
+		if p.sinkFailed[i].CompareAndSwap(false, true) {
+			next := "dropping its lines from here on"
+			if sink.Fallback != nil {
+				next = "writing its lines to the fallback from here on"
+			}
+			p.addError(&ProcessingError{
+				Stream: rec.Stream,
+				Line:   rec.LineNo,
+				Err:    fmt.Errorf("%w: %s, %s: %w", pkgerrors.ErrSinkFailed, sinkLabel(i, sink), next, err),
+			})
+		}
+	}
+	if sink.Fallback != nil {
+		_, _ = sink.Fallback.Write(data)
+	}
+}
+
+// sinkLabel names the i-th sink in diagnostics.
+func sinkLabel(i int, sink Sink) string {
+	if sink.Name != "" {
+		return fmt.Sprintf("sink %q", sink.Name)
+	}
+	return fmt.Sprintf("sink %d", i+1)
+}
+
 // isExpectedStreamError returns true for errors that occur during normal
 // process shutdown: closed file descriptors and closed pipes.
 // Note: bufio.Scanner.Err() never returns io.EOF (it returns nil at EOF),
@@ -368,7 +966,7 @@ func isExpectedStreamError(err error) bool {
 	return errors.Is(err, os.ErrClosed)
 }
 
-func (p *Processor) addError(err error) {
+func (p *Processor) addError(err *ProcessingError) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 	p.errors = append(p.errors, err)
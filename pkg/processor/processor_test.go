@@ -5,6 +5,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -295,6 +296,474 @@ func TestProcessor_ProcessStreams_FormatterError(t *testing.T) {
 	assert.Contains(t, err.Error(), "processing errors occurred")
 }
 
+// recordFormatter implements processor.RecordFormatter, failing on lines
+// that start with "bad" and dropping lines that start with "skip".
+type recordFormatter struct{}
+
+func (f *recordFormatter) FormatLine(line string, _ processor.StreamType) string {
+	return "unused " + line
+}
+
+func (f *recordFormatter) FormatRecord(rec processor.Record) (string, error) {
+	switch {
+	case strings.HasPrefix(rec.Line, "skip"):
+		return "", apperrors.ErrLineDropped
+	case strings.HasPrefix(rec.Line, "bad"):
+		return rec.Line, apperrors.ErrFormatFailed
+	default:
+		return "[" + rec.Stream.String() + "] " + rec.Line, nil
+	}
+}
+
+func TestProcessor_RecordFormatter(t *testing.T) {
+	t.Parallel()
+
+	output := &testutils.MockWriter{}
+	p := processor.New(&recordFormatter{}, output)
+
+	stdout := strings.NewReader("good\nskip me\nbad line\n")
+	stderr := strings.NewReader("")
+
+	err := p.ProcessStreams(context.Background(), stdout, stderr)
+	require.ErrorIs(t, err, apperrors.ErrProcessingErrors)
+
+	assert.Equal(t, []string{"[stdout] good\n", "bad line\n"}, output.GetLines())
+
+	errs := p.GetErrors()
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], apperrors.ErrFormatFailed)
+}
+
+// lineNoRecorder records the line number of every record it formats.
+type lineNoRecorder struct {
+	mu      sync.Mutex
+	lineNos map[processor.StreamType][]int
+}
+
+func (f *lineNoRecorder) FormatLine(line string, _ processor.StreamType) string {
+	return line
+}
+
+func (f *lineNoRecorder) FormatRecord(rec processor.Record) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lineNos[rec.Stream] = append(f.lineNos[rec.Stream], rec.LineNo)
+	return rec.Line, nil
+}
+
+func TestProcessor_RecordLineNumbers(t *testing.T) {
+	t.Parallel()
+
+	f := &lineNoRecorder{lineNos: map[processor.StreamType][]int{}}
+	p := processor.New(f, &testutils.MockWriter{})
+
+	stdout := strings.NewReader("a\nb\nc\n")
+	stderr := strings.NewReader("x\ny\n")
+
+	require.NoError(t, p.ProcessStreams(context.Background(), stdout, stderr))
+	assert.Equal(t, []int{1, 2, 3}, f.lineNos[processor.StreamStdout])
+	assert.Equal(t, []int{1, 2}, f.lineNos[processor.StreamStderr])
+}
+
+func TestProcessor_LinesRead(t *testing.T) {
+	t.Parallel()
+
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{})
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(""), strings.NewReader("")))
+	assert.Zero(t, p.LinesRead())
+
+	p = processor.New(&mockFormatter{}, &testutils.MockWriter{})
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader("a\nb\n"), strings.NewReader("x\n")))
+	assert.Equal(t, int64(3), p.LinesRead())
+}
+
+func TestProcessor_SquashBlankLines(t *testing.T) {
+	t.Parallel()
+
+	input := "a\n\n\n  \n\nb\n\nc\n\n\n"
+	tests := []struct {
+		name     string
+		opts     []processor.Option
+		expected []string
+	}{
+		{
+			name:     "disabled keeps every line",
+			expected: []string{"a", "", "", "  ", "", "b", "", "c", "", ""},
+		},
+		{
+			name:     "keep one",
+			opts:     []processor.Option{processor.WithSquashBlankLines(1)},
+			expected: []string{"a", "", "b", "", "c", ""},
+		},
+		{
+			name:     "keep two",
+			opts:     []processor.Option{processor.WithSquashBlankLines(2)},
+			expected: []string{"a", "", "", "b", "", "c", "", ""},
+		},
+		{
+			name:     "keep zero",
+			opts:     []processor.Option{processor.WithSquashBlankLines(0)},
+			expected: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			output := &testutils.MockWriter{}
+			identity := &mockFormatter{formatFunc: func(line string, _ processor.StreamType) string { return line }}
+			p := processor.New(identity, output, tt.opts...)
+			require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(input), strings.NewReader("")))
+
+			lines := make([]string, 0, len(output.GetLines()))
+			for _, line := range output.GetLines() {
+				lines = append(lines, strings.TrimSuffix(line, "\n"))
+			}
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestProcessor_SetOutput(t *testing.T) {
+	t.Parallel()
+
+	first := &testutils.MockWriter{}
+	second := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, first)
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	processingDone := make(chan error, 1)
+	go func() {
+		processingDone <- p.ProcessStreams(context.Background(), stdoutReader, strings.NewReader(""))
+	}()
+
+	_, err := stdoutWriter.Write([]byte("before\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(first.GetLines()) == 1 },
+		time.Second, 5*time.Millisecond)
+
+	p.SetOutput(second)
+
+	_, err = stdoutWriter.Write([]byte("after 1\nafter 2\n"))
+	require.NoError(t, err)
+	require.NoError(t, stdoutWriter.Close())
+	require.NoError(t, <-processingDone)
+
+	assert.Equal(t, []string{"[stdout] before\n"}, first.GetLines())
+	assert.Equal(t, []string{"[stdout] after 1\n", "[stdout] after 2\n"}, second.GetLines())
+}
+
+func TestProcessor_SetOutput_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	writers := []*testutils.MockWriter{{}, {}}
+	p := processor.New(&mockFormatter{}, writers[0])
+
+	input := strings.Repeat("line\n", 1000)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			p.SetOutput(writers[i%2])
+		}
+	}()
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader(input), strings.NewReader(input))
+	require.NoError(t, err)
+	wg.Wait()
+
+	assert.Len(t, append(writers[0].GetLines(), writers[1].GetLines()...), 2000, "no line is lost while swapping")
+}
+
+func TestProcessor_Heartbeat(t *testing.T) {
+	t.Parallel()
+
+	output := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, output, processor.WithHeartbeat(20*time.Millisecond, "alive"))
+
+	// A silent stream that stays open: only heartbeats are written.
+	stdoutReader, stdoutWriter := io.Pipe()
+	processingDone := make(chan error, 1)
+	go func() {
+		processingDone <- p.ProcessStreams(context.Background(), stdoutReader, strings.NewReader(""))
+	}()
+
+	require.Eventually(t, func() bool { return len(output.GetLines()) >= 3 },
+		time.Second, 5*time.Millisecond)
+	require.NoError(t, stdoutWriter.Close())
+	require.NoError(t, <-processingDone)
+
+	for _, line := range output.GetLines() {
+		assert.Equal(t, "[stdout] alive\n", line)
+	}
+}
+
+func TestProcessor_Heartbeat_SuppressedByOutput(t *testing.T) {
+	t.Parallel()
+
+	output := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, output, processor.WithHeartbeat(200*time.Millisecond, "alive"))
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	processingDone := make(chan error, 1)
+	go func() {
+		processingDone <- p.ProcessStreams(context.Background(), stdoutReader, strings.NewReader(""))
+	}()
+
+	// Lines arrive well within the interval for longer than the interval.
+	for range 10 {
+		_, err := stdoutWriter.Write([]byte("work\n"))
+		require.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+	}
+	require.NoError(t, stdoutWriter.Close())
+	require.NoError(t, <-processingDone)
+
+	assert.NotContains(t, output.GetLines(), "[stdout] alive\n")
+	assert.Len(t, output.GetLines(), 10)
+}
+
+func TestProcessor_Sinks(t *testing.T) {
+	t.Parallel()
+
+	primary := &testutils.MockWriter{}
+	textSink := &testutils.MockWriter{}
+	jsonSink := &testutils.MockWriter{}
+	jsonFormatter := &mockFormatter{formatFunc: func(line string, streamType processor.StreamType) string {
+		return `{"stream":"` + streamType.String() + `","message":"` + line + `"}`
+	}}
+
+	p := processor.New(&mockFormatter{}, primary, processor.WithSinks(
+		processor.Sink{Formatter: &mockFormatter{}, Output: textSink},
+		processor.Sink{Formatter: jsonFormatter, Output: jsonSink},
+	))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("hello\n"), strings.NewReader(""))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"[stdout] hello\n"}, primary.GetLines())
+	assert.Equal(t, []string{"[stdout] hello\n"}, textSink.GetLines())
+	assert.Equal(t, []string{`{"stream":"stdout","message":"hello"}` + "\n"}, jsonSink.GetLines())
+}
+
+func TestProcessor_Routes(t *testing.T) {
+	t.Parallel()
+
+	primary := &testutils.MockWriter{}
+	webhook := &testutils.MockWriter{}
+	archive := &testutils.MockWriter{}
+	level := func(rec processor.Record) string {
+		level, _, _ := strings.Cut(rec.Line, ":")
+		return level
+	}
+
+	p := processor.New(&mockFormatter{}, primary,
+		processor.WithSinks(
+			processor.Sink{Name: "webhook", Formatter: &mockFormatter{}, Output: webhook},
+			processor.Sink{Name: "archive", Formatter: &mockFormatter{}, Output: archive},
+		),
+		processor.WithRoutes(level, map[string][]string{
+			"error": {"webhook", "archive"},
+			"info":  {processor.PrimaryRoute, "archive"},
+		}),
+	)
+
+	stdout := strings.NewReader("INFO: started\nERROR: disk full\nWARN: slow\n")
+	require.NoError(t, p.ProcessStreams(context.Background(), stdout, strings.NewReader("")))
+
+	assert.Equal(t, []string{"[stdout] INFO: started\n", "[stdout] WARN: slow\n"}, primary.GetLines(),
+		"unrouted levels go to the primary output only")
+	assert.Equal(t, []string{"[stdout] ERROR: disk full\n"}, webhook.GetLines())
+	assert.Equal(t, []string{"[stdout] INFO: started\n", "[stdout] ERROR: disk full\n"}, archive.GetLines())
+
+	require.NoError(t, p.WriteMessage("--- END ---"))
+	assert.Contains(t, webhook.GetLines(), "[stdout] --- END ---\n", "messages are not routed")
+}
+
+func TestProcessor_WriteMessage(t *testing.T) {
+	t.Parallel()
+
+	primary := &testutils.MockWriter{}
+	sink := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, primary, processor.WithSinks(
+		processor.Sink{Formatter: &mockFormatter{}, Output: sink},
+	))
+
+	require.NoError(t, p.WriteMessage("--- START ---"))
+	assert.Equal(t, []string{"[stdout] --- START ---\n"}, primary.GetLines())
+	assert.Equal(t, primary.GetLines(), sink.GetLines())
+}
+
+func TestProcessor_WriteRaw(t *testing.T) {
+	t.Parallel()
+
+	primary := &testutils.MockWriter{}
+	sink := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, primary, processor.WithLineEnding("\r\n"), processor.WithSinks(
+		processor.Sink{Formatter: &mockFormatter{}, Output: sink},
+	))
+
+	require.NoError(t, p.WriteRaw(`{"event":"summary"}`))
+	assert.Equal(t, []string{"{\"event\":\"summary\"}\r\n"}, primary.GetLines(), "raw lines are not formatted")
+	assert.Empty(t, sink.GetLines(), "raw lines are not written to sinks")
+}
+
+func TestProcessor_LineEnding(t *testing.T) {
+	t.Parallel()
+
+	primary := &testutils.MockWriter{}
+	sink := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, primary, processor.WithLineEnding("\r\n"), processor.WithSinks(
+		processor.Sink{Formatter: &mockFormatter{}, Output: sink},
+	))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("a\r\nb\n"), strings.NewReader(""))
+	require.NoError(t, err)
+	require.NoError(t, p.WriteMessage("c"))
+
+	expected := []string{"[stdout] a\r\n", "[stdout] b\r\n", "[stdout] c\r\n"}
+	assert.Equal(t, expected, primary.GetLines())
+	assert.Equal(t, expected, sink.GetLines())
+}
+
+func TestProcessor_SinkWriteError(t *testing.T) {
+	t.Parallel()
+
+	primary := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, primary, processor.WithSinks(
+		processor.Sink{Formatter: &mockFormatter{}, Output: &testutils.FailingWriter{FailAfter: 0}},
+	))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("a\nb\n"), strings.NewReader(""))
+	require.ErrorIs(t, err, testutils.ErrMockWriteFailure)
+	require.ErrorIs(t, err, apperrors.ErrSinkFailed)
+	assert.Len(t, primary.GetLines(), 2, "a failing sink does not interrupt the primary output")
+	assert.Len(t, p.GetErrors(), 1, "a failing sink is reported once, not once per line")
+}
+
+func TestProcessor_SinkWriteError_Fallback(t *testing.T) {
+	t.Parallel()
+
+	primary := &testutils.MockWriter{}
+	fallback := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, primary, processor.WithSinks(processor.Sink{
+		Name:      "audit",
+		Formatter: &mockFormatter{},
+		Output:    &testutils.FailingWriter{FailAfter: 2},
+		Fallback:  fallback,
+	}))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("a\nb\nc\nd\ne\n"), strings.NewReader(""))
+	require.ErrorIs(t, err, apperrors.ErrSinkFailed)
+
+	errs := p.GetErrors()
+	require.Len(t, errs, 1)
+	assert.Equal(t, 3, errs[0].Line, "the error points at the first line that could not be written")
+	assert.Contains(t, errs[0].Error(), `sink "audit"`)
+	assert.Contains(t, errs[0].Error(), "to the fallback from here on")
+
+	assert.Len(t, primary.GetLines(), 5)
+	assert.Equal(t, []string{"[stdout] c\n", "[stdout] d\n", "[stdout] e\n"}, fallback.GetLines(),
+		"the failed line and every later one reach the fallback")
+}
+
+// recordCollector keeps every record it is asked to format.
+type recordCollector struct {
+	mu   sync.Mutex
+	recs []processor.Record
+}
+
+func (c *recordCollector) FormatLine(line string, _ processor.StreamType) string {
+	return line
+}
+
+func (c *recordCollector) FormatRecord(rec processor.Record) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recs = append(c.recs, rec)
+	return rec.Line, nil
+}
+
+func (c *recordCollector) records() []processor.Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]processor.Record(nil), c.recs...)
+}
+
+func TestProcessor_StripsLeadingBOM(t *testing.T) {
+	t.Parallel()
+
+	recorder := &recordCollector{}
+	p := processor.New(recorder, &testutils.MockWriter{})
+
+	stdout := strings.NewReader("\ufeff{\"msg\":\"first\"}\n\ufeffsecond\n")
+	stderr := strings.NewReader("\ufefferr\n")
+	require.NoError(t, p.ProcessStreams(context.Background(), stdout, stderr))
+
+	recs := recorder.records()
+	require.Len(t, recs, 3)
+	for _, rec := range recs {
+		switch {
+		case rec.Stream == processor.StreamStdout && rec.LineNo == 1:
+			assert.Equal(t, `{"msg":"first"}`, rec.Line)
+			assert.Equal(t, "\ufeff{\"msg\":\"first\"}", rec.Raw, "Raw keeps the line as read")
+		case rec.Stream == processor.StreamStdout:
+			assert.Equal(t, "\ufeffsecond", rec.Line, "only the first line of a stream is cleaned")
+		default:
+			assert.Equal(t, "err", rec.Line)
+		}
+	}
+}
+
+// brokenPipeWriter accepts a fixed number of writes, then fails with EPIPE
+// like a pipe whose reader has gone away.
+type brokenPipeWriter struct {
+	mu      sync.Mutex
+	allowed int
+	writes  int
+}
+
+func (w *brokenPipeWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes++
+	if w.writes > w.allowed {
+		return 0, syscall.EPIPE
+	}
+	return len(p), nil
+}
+
+func TestProcessor_ProcessStreams_BrokenPipe(t *testing.T) {
+	t.Parallel()
+
+	output := &brokenPipeWriter{allowed: 1}
+	p := processor.New(&mockFormatter{}, output)
+
+	select {
+	case <-p.OutputClosed():
+		t.Fatal("output should not be reported closed before any write fails")
+	default:
+	}
+
+	stdout := strings.NewReader(strings.Repeat("line\n", 100))
+	stderr := strings.NewReader("")
+
+	err := p.ProcessStreams(context.Background(), stdout, stderr)
+	require.NoError(t, err, "EPIPE must not be reported as a processing error")
+	assert.Empty(t, p.GetErrors())
+
+	select {
+	case <-p.OutputClosed():
+	default:
+		t.Fatal("expected OutputClosed to be closed after EPIPE")
+	}
+
+	// Lines after the broken pipe are drained without further writes.
+	assert.Equal(t, 2, output.writes)
+}
+
 func TestProcessor_Stop(t *testing.T) {
 	t.Parallel()
 
@@ -484,6 +953,53 @@ func TestProcessor_GetErrors(t *testing.T) {
 	assert.Equal(t, len(processingErrors), len(processingErrors2))
 }
 
+func TestProcessor_ProcessingErrorContext(t *testing.T) {
+	t.Parallel()
+
+	// Two writes succeed, then every write fails.
+	output := &testutils.FailingWriter{FailAfter: 2}
+	p := processor.New(&mockFormatter{}, output)
+
+	stdout := strings.NewReader("one\ntwo\nthree\nfour\n")
+	stderr := strings.NewReader("")
+
+	err := p.ProcessStreams(context.Background(), stdout, stderr)
+	require.ErrorIs(t, err, apperrors.ErrProcessingErrors)
+	require.ErrorIs(t, err, testutils.ErrMockWriteFailure, "the underlying error stays reachable")
+
+	var procErr *processor.ProcessingError
+	require.ErrorAs(t, err, &procErr)
+	assert.Equal(t, processor.StreamStdout, procErr.Stream)
+	assert.Equal(t, 3, procErr.Line)
+	assert.Contains(t, procErr.Error(), "stdout processing error at line 3")
+
+	errs := p.GetErrors()
+	require.Len(t, errs, 1)
+	assert.Same(t, procErr, errs[0])
+}
+
+func TestProcessor_ProcessingErrorContext_PerStream(t *testing.T) {
+	t.Parallel()
+
+	p := processor.New(&recordFormatter{}, &testutils.MockWriter{})
+
+	stdout := strings.NewReader("good\nbad stdout\n")
+	stderr := strings.NewReader("good\ngood\nbad stderr\n")
+
+	err := p.ProcessStreams(context.Background(), stdout, stderr)
+	require.Error(t, err)
+
+	lines := map[processor.StreamType]int{}
+	for _, e := range p.GetErrors() {
+		assert.ErrorIs(t, e, apperrors.ErrFormatFailed)
+		lines[e.Stream] = e.Line
+	}
+	assert.Equal(t, map[processor.StreamType]int{
+		processor.StreamStdout: 2,
+		processor.StreamStderr: 3,
+	}, lines)
+}
+
 func TestProcessor_ConcurrentAccess(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,68 @@
+package processor
+
+import "bytes"
+
+// WithProgressFinal treats carriage returns that are not followed by a line
+// feed as progress updates overwriting the current line, as written by
+// curl, wget or pip: the updates are dropped and only the final state of
+// the line is processed, once its line feed (or the end of the stream)
+// arrives. A line feed right after a progress update, as in "100%\r\n",
+// writes that update. Updates are consumed as they arrive, so a long
+// progress display does not grow into one huge line. Dropped updates are
+// not counted by [Processor.LinesRead]. It has no effect with
+// [WithPassthrough].
+func WithProgressFinal() Option {
+	return func(p *Processor) {
+		p.progressFinal = true
+	}
+}
+
+// progressSplitter is a [bufio.SplitFunc] for WithProgressFinal. Like
+// [bufio.ScanLines] it splits lines at "\n" or "\r\n", but it consumes
+// lone-"\r"-terminated updates, keeping only the last one.
+type progressSplitter struct {
+	last []byte // the last update of the current line, nil if none
+}
+
+func (s *progressSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	for advance := 0; ; {
+		rest := data[advance:]
+		i := bytes.IndexAny(rest, "\r\n")
+		switch {
+		case i < 0:
+			if atEOF && len(rest) > 0 {
+				s.last = nil
+				return len(data), rest, nil
+			}
+			if atEOF && s.last != nil {
+				last := s.last
+				s.last = nil
+				return len(data), last, nil
+			}
+			return advance, nil, nil
+		case rest[i] == '\n':
+			return advance + i + 1, s.line(rest[:i]), nil
+		case i+1 < len(rest) && rest[i+1] == '\n':
+			return advance + i + 2, s.line(rest[:i]), nil
+		case i+1 == len(rest) && !atEOF:
+			// Whether a line feed follows is not known yet.
+			return advance, nil, nil
+		default:
+			if i > 0 {
+				s.last = append(s.last[:0], rest[:i]...)
+			}
+			advance += i + 1
+		}
+	}
+}
+
+// line returns the token for a line that ends with text: text itself, or
+// the last update when text is empty.
+func (s *progressSplitter) line(text []byte) []byte {
+	last := s.last
+	s.last = nil
+	if len(text) == 0 && last != nil {
+		return last
+	}
+	return text
+}
@@ -0,0 +1,99 @@
+package processor_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_ProgressFinal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "updates then line feed",
+			input:    "Downloading\n 10%\r 50%\r100% done\nnext\n",
+			expected: []string{"Downloading", "100% done", "next"},
+		},
+		{
+			name:     "line feed right after an update",
+			input:    " 10%\r 50%\r100%\r\nnext\r\n",
+			expected: []string{"100%", "next"},
+		},
+		{
+			name:     "stream ends on an update",
+			input:    "start\n 10%\r 90%\r",
+			expected: []string{"start", " 90%"},
+		},
+		{
+			name:     "stream ends mid line",
+			input:    " 10%\r 90%\rfinished",
+			expected: []string{"finished"},
+		},
+		{
+			name:     "blank lines are kept",
+			input:    "a\n\nb\n",
+			expected: []string{"a", "", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Reading a byte at a time makes every "\r" arrive before what
+			// follows it.
+			inputs := []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))}
+			for _, input := range inputs {
+				writer := &testutils.MockWriter{}
+				p := processor.New(&mockFormatter{formatFunc: func(line string, _ processor.StreamType) string {
+					return line
+				}}, writer, processor.WithProgressFinal())
+
+				require.NoError(t, p.ProcessStreams(context.Background(), input, strings.NewReader("")))
+				assert.Equal(t, tt.expected, trimLines(writer.GetLines()))
+				assert.Equal(t, int64(len(tt.expected)), p.LinesRead(), "updates are not counted as lines")
+			}
+		})
+	}
+}
+
+func TestProcessor_ProgressKeptByDefault(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer)
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(" 10%\r100%\n"), strings.NewReader("")))
+	assert.Equal(t, []string{"[stdout]  10%\r100%\n"}, writer.GetLines())
+}
+
+func TestProcessor_ProgressFinal_LongDisplay(t *testing.T) {
+	t.Parallel()
+
+	// More updates than the longest line the scanner accepts.
+	input := strings.Repeat("downloading... 42%\r", 100000) + "downloaded\n"
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithProgressFinal())
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(input), strings.NewReader("")))
+	assert.Equal(t, []string{"[stdout] downloaded\n"}, writer.GetLines())
+	assert.Empty(t, p.GetErrors())
+}
+
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSuffix(line, "\n")
+	}
+	return trimmed
+}
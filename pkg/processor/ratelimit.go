@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithLevelRates caps the lines per second written for each level: level
+// returns the line's level and rates maps a level (case-insensitive) to its
+// rate. Each level has its own token bucket holding up to one second's
+// worth of lines (at least one), so a level may burst before being
+// throttled. Lines over the cap are dropped from every destination and are
+// not kept as context. Levels without a rate are never throttled, nor are
+// lines written with [Processor.WriteMessage].
+func WithLevelRates(level func(Record) string, rates map[string]float64) Option {
+	return func(p *Processor) {
+		if len(rates) == 0 {
+			return
+		}
+		now := time.Now()
+		p.rates = &levelRates{level: level, buckets: make(map[string]*tokenBucket, len(rates))}
+		for lvl, rate := range rates {
+			burst := max(rate, 1)
+			p.rates.buckets[strings.ToUpper(lvl)] = &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+		}
+	}
+}
+
+// levelRates holds the token buckets of WithLevelRates, shared by both
+// streams.
+type levelRates struct {
+	level func(Record) string
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket // upper-case level -> bucket
+}
+
+// tokenBucket refills at rate tokens per second up to burst; each line
+// written takes one token.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// allow reports whether rec is within its level's rate, taking a token if so.
+func (r *levelRates) allow(rec Record) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.buckets[strings.ToUpper(r.level(rec))]
+	if !ok {
+		return true
+	}
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// throttled reports whether rec exceeds the rate of its level.
+func (p *Processor) throttled(rec Record) bool {
+	return p.rates != nil && !p.rates.allow(rec)
+}
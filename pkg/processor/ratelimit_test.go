@@ -0,0 +1,59 @@
+package processor_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_LevelRates(t *testing.T) {
+	t.Parallel()
+
+	level := func(rec processor.Record) string {
+		lvl, _, _ := strings.Cut(rec.Line, " ")
+		return lvl
+	}
+
+	var input strings.Builder
+	for range 50 {
+		input.WriteString("INFO chatty\nERROR broken\n")
+	}
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer,
+		processor.WithLevelRates(level, map[string]float64{"info": 2}))
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader(input.String()), strings.NewReader("")))
+
+	var info, errs int
+	for _, line := range writer.GetLines() {
+		switch {
+		case strings.Contains(line, "INFO"):
+			info++
+		case strings.Contains(line, "ERROR"):
+			errs++
+		}
+	}
+	assert.Equal(t, 50, errs, "levels without a rate are never throttled")
+	assert.GreaterOrEqual(t, info, 2, "a burst of up to one second's worth of lines passes")
+	assert.Less(t, info, 10, "INFO is throttled during the burst")
+	assert.Equal(t, int64(100), p.LinesRead(), "throttled lines are still read")
+}
+
+func TestProcessor_LevelRates_WriteMessage(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithLevelRates(func(processor.Record) string {
+		return "INFO"
+	}, map[string]float64{"INFO": 0.001}))
+
+	for range 3 {
+		require.NoError(t, p.WriteMessage("status"))
+	}
+	assert.Len(t, writer.GetLines(), 3)
+}
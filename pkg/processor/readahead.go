@@ -0,0 +1,68 @@
+package processor
+
+// WithReadAhead lets each stream read up to n lines ahead of the lines being
+// processed, handing them over through a bounded queue: while a write is
+// slow, reading goes on and the command is not blocked on a full pipe.
+// Once n lines are waiting, reading waits for room, so memory stays
+// bounded. Lines are still processed in the order they were read, and the
+// lines waiting when a stream ends are processed before ProcessStreams
+// returns. n <= 0 disables it. It has no effect with [WithPassthrough].
+func WithReadAhead(n int) Option {
+	return func(p *Processor) {
+		p.readAhead = max(n, 0)
+	}
+}
+
+// readAheadQueue is the WithReadAhead queue of one stream: lines enter it
+// as they are read, and a single goroutine passes them on in order.
+type readAheadQueue struct {
+	handle  func(Record) error
+	pending chan Record
+	exited  chan struct{}
+	err     *ProcessingError // first failure, read once exited is closed
+	failed  chan struct{}    // closed when err is set
+}
+
+func (p *Processor) newReadAheadQueue(handle func(Record) error) *readAheadQueue {
+	q := &readAheadQueue{
+		handle:  handle,
+		pending: make(chan Record, p.readAhead),
+		exited:  make(chan struct{}),
+		failed:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// submit queues rec, waiting while the queue is full.
+func (q *readAheadQueue) submit(rec Record) error {
+	select {
+	case <-q.failed:
+		return errEarlierLineFailed
+	case q.pending <- rec:
+		return nil
+	}
+}
+
+// run passes the queued lines on in order. After a failure the rest are
+// discarded, as reading stops at the next submit.
+func (q *readAheadQueue) run() {
+	defer close(q.exited)
+	for rec := range q.pending {
+		if q.err != nil {
+			continue
+		}
+		if err := q.handle(rec); err != nil {
+			q.err = &ProcessingError{Stream: rec.Stream, Line: rec.LineNo, Err: err}
+			close(q.failed)
+		}
+	}
+}
+
+// close waits for the queued lines to be processed and returns the first
+// failure, if any.
+func (q *readAheadQueue) close() *ProcessingError {
+	close(q.pending)
+	<-q.exited
+	return q.err
+}
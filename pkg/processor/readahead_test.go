@@ -0,0 +1,151 @@
+package processor_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gateWriter blocks every write until release is closed.
+type gateWriter struct {
+	testutils.MockWriter
+	release chan struct{}
+}
+
+func (g *gateWriter) Write(p []byte) (int, error) {
+	<-g.release
+	return g.MockWriter.Write(p)
+}
+
+// produce writes lines to w one write at a time, then closes it, and
+// closes the returned channel once every line has been read from w.
+func produce(w *io.PipeWriter, lines int) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range lines {
+			if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+				return
+			}
+		}
+		_ = w.Close()
+	}()
+	return done
+}
+
+func TestProcessor_ReadAhead_ReadsWhileWriteBlocks(t *testing.T) {
+	t.Parallel()
+
+	writer := &gateWriter{release: make(chan struct{})}
+	p := processor.New(&mockFormatter{}, writer, processor.WithReadAhead(10))
+
+	stdout, producer := io.Pipe()
+	// One line is being written and ten wait in the queue.
+	produced := produce(producer, 11)
+	processed := make(chan error, 1)
+	go func() {
+		processed <- p.ProcessStreams(context.Background(), stdout, strings.NewReader(""))
+	}()
+
+	select {
+	case <-produced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading stalled behind the blocked write")
+	}
+
+	close(writer.release)
+	require.NoError(t, <-processed)
+	lines := writer.GetLines()
+	require.Len(t, lines, 11)
+	for i, line := range lines {
+		assert.Equal(t, fmt.Sprintf("[stdout] line %d\n", i), line)
+	}
+}
+
+func TestProcessor_ReadAhead_Backpressure(t *testing.T) {
+	t.Parallel()
+
+	writer := &gateWriter{release: make(chan struct{})}
+	p := processor.New(&mockFormatter{}, writer, processor.WithReadAhead(2))
+
+	stdout, producer := io.Pipe()
+	produced := produce(producer, 10)
+	processed := make(chan error, 1)
+	go func() {
+		processed <- p.ProcessStreams(context.Background(), stdout, strings.NewReader(""))
+	}()
+
+	select {
+	case <-produced:
+		t.Fatal("reading went on past the read-ahead limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(writer.release)
+	<-produced
+	require.NoError(t, <-processed)
+	assert.Len(t, writer.GetLines(), 10, "no line is lost once writing resumes")
+}
+
+func TestProcessor_ReadAhead_BurstyProducer(t *testing.T) {
+	t.Parallel()
+
+	const bursts, burstLines = 20, 500
+	slow := 0
+	formatter := &mockFormatter{formatFunc: func(line string, streamType processor.StreamType) string {
+		// Formatting stalls now and then, as a slow write would.
+		if slow++; slow%1000 == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		return "[" + streamType.String() + "] " + line
+	}}
+	writer := &testutils.MockWriter{}
+	p := processor.New(formatter, writer, processor.WithReadAhead(64))
+
+	stdout, producer := io.Pipe()
+	go func() {
+		for burst := range bursts {
+			var b strings.Builder
+			for i := range burstLines {
+				fmt.Fprintf(&b, "line %d\n", burst*burstLines+i)
+			}
+			if _, err := io.WriteString(producer, b.String()); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_ = producer.Close()
+	}()
+
+	require.NoError(t, p.ProcessStreams(context.Background(), stdout, strings.NewReader("")))
+	lines := writer.GetLines()
+	require.Len(t, lines, bursts*burstLines)
+	for i, line := range lines {
+		require.Equal(t, fmt.Sprintf("[stdout] line %d\n", i), line)
+	}
+}
+
+func TestProcessor_ReadAhead_WriteError(t *testing.T) {
+	t.Parallel()
+
+	for _, workers := range []int{0, 4} {
+		writer := &testutils.FailingWriter{FailAfter: 2}
+		p := processor.New(&mockFormatter{}, writer, processor.WithReadAhead(8), processor.WithWorkers(workers))
+
+		err := p.ProcessStreams(context.Background(),
+			strings.NewReader(strings.Repeat("line\n", 100)), strings.NewReader(""))
+		require.Error(t, err)
+
+		errs := p.GetErrors()
+		require.Len(t, errs, 1, "the stream stops at the first failed write (workers=%d)", workers)
+		assert.Equal(t, 3, errs[0].Line)
+	}
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"strings"
+	"sync"
+)
+
+// WithRecentLines keeps the last size formatted lines written to the
+// primary output in a ring buffer, for embedding programs that display
+// recent output (e.g. a TUI); see [Processor.RecentLines]. Lines are
+// recorded as formatted, whether or not a WithBufferUntil buffer holds
+// them back. Without this option no lines are kept.
+func WithRecentLines(size int) Option {
+	return func(p *Processor) {
+		if size > 0 {
+			p.recent = &recentLines{lines: make([]string, size)}
+		}
+	}
+}
+
+// recentLines is a fixed-size ring buffer of formatted lines.
+type recentLines struct {
+	mu    sync.Mutex
+	lines []string
+	next  int // index the next line is written to
+	count int // number of lines held, at most len(lines)
+}
+
+func (r *recentLines) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.count < len(r.lines) {
+		r.count++
+	}
+}
+
+// last returns up to n of the most recent lines, oldest first.
+func (r *recentLines) last(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n = min(n, r.count)
+	out := make([]string, n)
+	start := r.next - n + len(r.lines)
+	for i := range out {
+		out[i] = r.lines[(start+i)%len(r.lines)]
+	}
+	return out
+}
+
+// RecentLines returns up to n of the most recently written formatted lines,
+// oldest first and without line terminators. It returns nil unless the
+// processor was created with [WithRecentLines] or when n is not positive.
+// It is safe to call while streams are being processed.
+func (p *Processor) RecentLines(n int) []string {
+	if p.recent == nil || n <= 0 {
+		return nil
+	}
+	return p.recent.last(n)
+}
+
+// recordRecent adds a formatted line, terminator included, to the recent
+// lines when they are kept.
+func (p *Processor) recordRecent(data []byte) {
+	if p.recent != nil {
+		p.recent.add(strings.TrimSuffix(string(data), p.lineEnding))
+	}
+}
@@ -0,0 +1,75 @@
+package processor_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_RecentLines(t *testing.T) {
+	t.Parallel()
+
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{}, processor.WithRecentLines(3))
+	assert.Empty(t, p.RecentLines(3), "nothing written yet")
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader("1\n2\n3\n4\n5\n"), strings.NewReader(""))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"[stdout] 3", "[stdout] 4", "[stdout] 5"}, p.RecentLines(3))
+	assert.Equal(t, []string{"[stdout] 4", "[stdout] 5"}, p.RecentLines(2))
+	assert.Equal(t, []string{"[stdout] 3", "[stdout] 4", "[stdout] 5"}, p.RecentLines(10),
+		"at most the buffer size is returned")
+	assert.Nil(t, p.RecentLines(0))
+
+	require.NoError(t, p.WriteMessage("done"))
+	assert.Equal(t, []string{"[stdout] 5", "[stdout] done"}, p.RecentLines(2))
+}
+
+func TestProcessor_RecentLines_Disabled(t *testing.T) {
+	t.Parallel()
+
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{})
+	require.NoError(t, p.ProcessStreams(context.Background(), strings.NewReader("a\n"), strings.NewReader("")))
+	assert.Nil(t, p.RecentLines(5))
+}
+
+func TestProcessor_RecentLines_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	const lines = 1000
+	p := processor.New(&mockFormatter{}, &testutils.MockWriter{}, processor.WithRecentLines(10))
+
+	var input strings.Builder
+	for i := range lines {
+		fmt.Fprintf(&input, "%d\n", i)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Go(func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				assert.LessOrEqual(t, len(p.RecentLines(10)), 10)
+			}
+		}
+	})
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader(input.String()), strings.NewReader(""))
+	close(done)
+	wg.Wait()
+	require.NoError(t, err)
+
+	recent := p.RecentLines(10)
+	require.Len(t, recent, 10)
+	assert.Equal(t, fmt.Sprintf("[stdout] %d", lines-1), recent[9])
+}
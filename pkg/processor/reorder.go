@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// reorderMaxLines bounds the lines held by WithReorderWindow. When it is
+// reached, the oldest line is written before its window has passed and
+// reading waits for room.
+const reorderMaxLines = 10000
+
+// WithReorderWindow holds every line for window after it was read and
+// writes lines sorted by [Record.Time], so that stdout and stderr lines are
+// interleaved in the order they were read rather than the order the two
+// stream goroutines happen to write them. This adds up to window of latency
+// to every line. Lines still held when both streams end are flushed. A
+// window of 0 disables reordering.
+func WithReorderWindow(window time.Duration) Option {
+	return func(p *Processor) {
+		if window > 0 {
+			p.reorder = newReorderBuffer(window, reorderMaxLines)
+		}
+	}
+}
+
+// reorderBuffer is a bounded min-heap of the lines held for
+// WithReorderWindow, shared by both streams.
+type reorderBuffer struct {
+	window time.Duration
+	max    int
+	wake   chan struct{} // signalled when a line is added
+
+	mu    sync.Mutex
+	room  *sync.Cond // broadcast when lines are taken
+	lines heldRecords
+	seq   uint64
+}
+
+func newReorderBuffer(window time.Duration, maxLines int) *reorderBuffer {
+	r := &reorderBuffer{window: window, max: maxLines, wake: make(chan struct{}, 1)}
+	r.room = sync.NewCond(&r.mu)
+	return r
+}
+
+// heldRecord is a line held by reorderBuffer. seq breaks ties between lines
+// read at the same time, keeping them in the order they were added.
+type heldRecord struct {
+	rec Record
+	seq uint64
+}
+
+// heldRecords implements heap.Interface, oldest line first.
+type heldRecords []heldRecord
+
+func (h heldRecords) Len() int { return len(h) }
+
+func (h heldRecords) Less(i, j int) bool {
+	if !h[i].rec.Time.Equal(h[j].rec.Time) {
+		return h[i].rec.Time.Before(h[j].rec.Time)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h heldRecords) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *heldRecords) Push(x any) {
+	if held, ok := x.(heldRecord); ok {
+		*h = append(*h, held)
+	}
+}
+
+func (h *heldRecords) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// push holds rec, waiting while the buffer is full.
+func (r *reorderBuffer) push(rec Record) {
+	r.mu.Lock()
+	for len(r.lines) >= r.max {
+		r.signal()
+		r.room.Wait()
+	}
+	heap.Push(&r.lines, heldRecord{rec: rec, seq: r.seq})
+	r.seq++
+	r.mu.Unlock()
+	r.signal()
+}
+
+// signal wakes the reorder goroutine without blocking.
+func (r *reorderBuffer) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ready takes the lines due at now, oldest first: those held for the whole
+// window and, while the buffer is full, the oldest. It also returns how
+// long until the next line is due, 0 when no line is held.
+func (r *reorderBuffer) ready(now time.Time) ([]Record, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []Record
+	var next time.Duration
+	for len(r.lines) > 0 {
+		wait := r.lines[0].rec.Time.Add(r.window).Sub(now)
+		if wait > 0 && len(r.lines) < r.max {
+			next = wait
+			break
+		}
+		due = append(due, r.popOldest())
+	}
+	if len(due) > 0 {
+		r.room.Broadcast()
+	}
+	return due, next
+}
+
+// popOldest removes and returns the oldest line held. r.mu must be held.
+func (r *reorderBuffer) popOldest() Record {
+	held, _ := heap.Pop(&r.lines).(heldRecord)
+	return held.rec
+}
+
+// flush takes every line held, oldest first.
+func (r *reorderBuffer) flush() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make([]Record, 0, len(r.lines))
+	for len(r.lines) > 0 {
+		all = append(all, r.popOldest())
+	}
+	r.room.Broadcast()
+	return all
+}
+
+// runReorder writes held lines as they become due until streamsDone is
+// closed, then flushes the rest. After a write error on a stream's line,
+// the stream's remaining lines are discarded, as they would not have been
+// read without reordering.
+func (p *Processor) runReorder(streamsDone <-chan struct{}) {
+	r := p.reorder
+	timer := time.NewTimer(r.window)
+	defer timer.Stop()
+
+	failed := make(map[StreamType]bool)
+	emit := func(recs []Record) {
+		for _, rec := range recs {
+			if failed[rec.Stream] {
+				continue
+			}
+			if err := p.processRecord(rec); err != nil {
+				failed[rec.Stream] = true
+				p.addError(&ProcessingError{Stream: rec.Stream, Line: rec.LineNo, Err: err})
+			}
+		}
+	}
+
+	for {
+		due, next := r.ready(time.Now())
+		emit(due)
+		if next > 0 {
+			timer.Reset(next)
+		}
+
+		select {
+		case <-streamsDone:
+			emit(r.flush())
+			return
+		case <-r.wake:
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordLines(recs []Record) []string {
+	out := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, rec.Line)
+	}
+	return out
+}
+
+func TestReorderBuffer_SortsByCaptureTime(t *testing.T) {
+	t.Parallel()
+
+	base := time.Now()
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	r := newReorderBuffer(time.Second, 10)
+	r.push(Record{Line: "stderr 3", Stream: StreamStderr, Time: at(3)})
+	r.push(Record{Line: "stdout 1", Stream: StreamStdout, Time: at(1)})
+	r.push(Record{Line: "stdout 2", Stream: StreamStdout, Time: at(2)})
+	r.push(Record{Line: "stderr 0", Stream: StreamStderr, Time: at(0)})
+
+	due, next := r.ready(at(500))
+	assert.Empty(t, due, "no line has been held for the whole window")
+	assert.Equal(t, 500*time.Millisecond, next)
+
+	due, next = r.ready(at(1001))
+	assert.Equal(t, []string{"stderr 0", "stdout 1"}, recordLines(due))
+	assert.Equal(t, time.Millisecond, next)
+
+	assert.Equal(t, []string{"stdout 2", "stderr 3"}, recordLines(r.flush()))
+	due, next = r.ready(at(5000))
+	assert.Empty(t, due)
+	assert.Zero(t, next)
+}
+
+func TestReorderBuffer_SameTimeKeepsArrivalOrder(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	r := newReorderBuffer(time.Second, 10)
+	for _, line := range []string{"a", "b", "c"} {
+		r.push(Record{Line: line, Time: now})
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, recordLines(r.flush()))
+}
+
+func TestReorderBuffer_Bounded(t *testing.T) {
+	t.Parallel()
+
+	base := time.Now()
+	r := newReorderBuffer(time.Hour, 2)
+	r.push(Record{Line: "late", Time: base.Add(time.Millisecond)})
+	r.push(Record{Line: "early", Time: base})
+
+	pushed := make(chan struct{})
+	go func() {
+		r.push(Record{Line: "next", Time: base.Add(2 * time.Millisecond)})
+		close(pushed)
+	}()
+
+	due, _ := r.ready(base)
+	assert.Equal(t, []string{"early"}, recordLines(due), "a full buffer gives up its oldest line early")
+	<-pushed
+	assert.Equal(t, []string{"late", "next"}, recordLines(r.flush()))
+}
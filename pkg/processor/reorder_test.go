@@ -0,0 +1,59 @@
+package processor_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_ReorderWindow_FlushesOnEnd(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithReorderWindow(time.Hour))
+
+	start := time.Now()
+	err := p.ProcessStreams(context.Background(), strings.NewReader("a\nb\nc\n"), strings.NewReader("x\ny\n"))
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Minute, "held lines are flushed when the streams end")
+
+	var stdout, stderr []string
+	for _, line := range writer.GetLines() {
+		if strings.HasPrefix(line, "[stdout]") {
+			stdout = append(stdout, line)
+		} else {
+			stderr = append(stderr, line)
+		}
+	}
+	assert.Equal(t, []string{"[stdout] a\n", "[stdout] b\n", "[stdout] c\n"}, stdout)
+	assert.Equal(t, []string{"[stderr] x\n", "[stderr] y\n"}, stderr)
+}
+
+func TestProcessor_ReorderWindow_WritesAfterWindow(t *testing.T) {
+	t.Parallel()
+
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer, processor.WithReorderWindow(20*time.Millisecond))
+
+	done := make(chan error, 1)
+	go func() { done <- p.ProcessStreams(context.Background(), stdoutR, stderrR) }()
+
+	_, err := stdoutW.Write([]byte("first\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(writer.GetLines()) == 1 }, time.Second, 5*time.Millisecond,
+		"lines are written once the window has passed, while the streams are still open")
+
+	require.NoError(t, stdoutW.Close())
+	require.NoError(t, stderrW.Close())
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"[stdout] first\n"}, writer.GetLines())
+}
@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"errors"
+	"sync"
+)
+
+// WithWorkers formats lines on n goroutines instead of on the goroutine
+// reading each stream, for configurations where formatting rather than
+// reading limits throughput (many extracted fields, templated custom
+// fields, JSON output). Lines are still filtered and written one at a time,
+// in the order they were read from their stream. n <= 1 disables the pool.
+// It has no effect with [WithReorderWindow], which orders lines itself, or
+// with [WithPassthrough], which formats nothing.
+func WithWorkers(n int) Option {
+	return func(p *Processor) {
+		if n > 1 {
+			p.workers = n
+		}
+	}
+}
+
+// formatJob is a line handed to a worker for formatting.
+type formatJob struct {
+	rec       Record
+	formatted string
+	err       error
+	done      chan struct{} // closed once formatted and err are set
+}
+
+// errEarlierLineFailed stops reading a stream once writing one of its
+// earlier lines failed; that failure is the error reported.
+var errEarlierLineFailed = errors.New("an earlier line could not be written")
+
+// startWorkers starts the WithWorkers pool for ProcessStreams and returns
+// a function that stops it once both streams are done.
+func (p *Processor) startWorkers() func() {
+	p.jobs = make(chan *formatJob, p.workers)
+	var wg sync.WaitGroup
+	for range p.workers {
+		wg.Go(func() {
+			for job := range p.jobs {
+				job.formatted, job.err = p.format(job.rec)
+				close(job.done)
+			}
+		})
+	}
+	return func() {
+		close(p.jobs)
+		wg.Wait()
+		p.jobs = nil
+	}
+}
+
+// orderedQueue writes the lines of one stream in read order while workers
+// format them concurrently: lines enter the queue as they are read, and a
+// single goroutine waits for each one's formatting in turn before writing
+// it.
+type orderedQueue struct {
+	p       *Processor
+	pending chan *formatJob
+	exited  chan struct{}
+	err     *ProcessingError // first write failure, read once exited is closed
+	failed  chan struct{}    // closed when err is set
+}
+
+func (p *Processor) newOrderedQueue() *orderedQueue {
+	q := &orderedQueue{
+		p: p,
+		// Room for every line being formatted, and as many again waiting,
+		// so workers never idle while the queue drains.
+		pending: make(chan *formatJob, 2*p.workers),
+		exited:  make(chan struct{}),
+		failed:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// submit hands rec to the workers and queues it for writing.
+func (q *orderedQueue) submit(rec Record) error {
+	select {
+	case <-q.failed:
+		return errEarlierLineFailed
+	default:
+	}
+	job := &formatJob{rec: rec, done: make(chan struct{})}
+	q.pending <- job
+	q.p.jobs <- job
+	return nil
+}
+
+// run writes the queued lines in order. After a write failure the rest
+// are only waited for, so that no worker is left blocked.
+func (q *orderedQueue) run() {
+	defer close(q.exited)
+	for job := range q.pending {
+		<-job.done
+		if q.err != nil {
+			continue
+		}
+		if err := q.p.commitRecord(job.rec, job); err != nil {
+			q.err = &ProcessingError{Stream: job.rec.Stream, Line: job.rec.LineNo, Err: err}
+			close(q.failed)
+		}
+	}
+}
+
+// close waits for the queued lines to be written and returns the first
+// write failure, if any.
+func (q *orderedQueue) close() *ProcessingError {
+	close(q.pending)
+	<-q.exited
+	return q.err
+}
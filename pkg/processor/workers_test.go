@@ -0,0 +1,78 @@
+package processor_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/logwrap/internal/testutils"
+	"github.com/sgaunet/logwrap/pkg/processor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_Workers_PreserveOrder(t *testing.T) {
+	t.Parallel()
+
+	const lineCount = 10000
+	var stdout, stderr strings.Builder
+	for i := range lineCount {
+		fmt.Fprintf(&stdout, "out %d\n", i)
+		fmt.Fprintf(&stderr, "err %d\n", i)
+	}
+
+	// Every 7th line formats slowly, so workers finish out of order.
+	formatter := &mockFormatter{formatFunc: func(line string, streamType processor.StreamType) string {
+		if len(line)%7 == 0 {
+			time.Sleep(10 * time.Microsecond)
+		}
+		return "[" + streamType.String() + "] " + line
+	}}
+	writer := &testutils.MockWriter{}
+	p := processor.New(formatter, writer, processor.WithWorkers(8))
+
+	err := p.ProcessStreams(context.Background(), strings.NewReader(stdout.String()), strings.NewReader(stderr.String()))
+	require.NoError(t, err)
+
+	lines := writer.GetLines()
+	require.Len(t, lines, 2*lineCount)
+	next := map[string]int{}
+	for _, line := range lines {
+		var stream, prefix string
+		var n int
+		_, err := fmt.Sscanf(line, "[%s %s %d\n", &stream, &prefix, &n)
+		require.NoError(t, err, line)
+		require.Equal(t, next[prefix], n, "%s lines out of order", prefix)
+		next[prefix]++
+	}
+}
+
+func TestProcessor_Workers_Filter(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.MockWriter{}
+	p := processor.New(&mockFormatter{}, writer,
+		processor.WithWorkers(4), processor.WithFilter(noDebug{}))
+
+	err := p.ProcessStreams(context.Background(),
+		strings.NewReader("one\ndebug me\ntwo\nthree\n"), strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[stdout] one\n", "[stdout] two\n", "[stdout] three\n"}, writer.GetLines())
+}
+
+func TestProcessor_Workers_WriteError(t *testing.T) {
+	t.Parallel()
+
+	writer := &testutils.FailingWriter{FailAfter: 2}
+	p := processor.New(&mockFormatter{}, writer, processor.WithWorkers(4))
+
+	err := p.ProcessStreams(context.Background(),
+		strings.NewReader(strings.Repeat("line\n", 100)), strings.NewReader(""))
+	require.Error(t, err)
+
+	errs := p.GetErrors()
+	require.Len(t, errs, 1, "the stream stops at the first failed write")
+	assert.Equal(t, 3, errs[0].Line)
+}